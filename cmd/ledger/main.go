@@ -0,0 +1,68 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package main
+
+import (
+	"fmt"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"os"
+)
+
+const usage = `用法: ledger <command>
+
+command:
+  reconcile    根据记账分录重新计算所有钱包的余额, 报告不一致的钱包和借贷不平衡的凭证
+  verify       校验流水的哈希链, 报告每个钱包第一条校验失败的流水`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "reconcile":
+		reconcile()
+	case "verify":
+		verify()
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
+
+func reconcile() {
+	report, err := ledger.Reconcile()
+
+	if err != nil {
+		fmt.Printf("对账失败: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("对账完成, 报告ID: %s\n", report.Id)
+	fmt.Printf("检查钱包: %d, 不一致的钱包: %d, 借贷不平衡的凭证: %d\n", report.Wallets, report.Drifts, report.UnbalancedPostings)
+
+	if report.Drifts > 0 || report.UnbalancedPostings > 0 {
+		fmt.Println(report.Detail)
+		os.Exit(1)
+	}
+}
+
+func verify() {
+	report, err := finance.VerifyChain(finance.ChainQuery{})
+
+	if err != nil {
+		fmt.Printf("校验失败: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("校验钱包: %d, 校验流水: %d, 哈希链断开的钱包: %d\n", report.Wallets, report.Logs, len(report.Broken))
+
+	for _, b := range report.Broken {
+		fmt.Printf("用户 %s 币种 %s 第 %d 条流水 %s: %s\n", b.Uid, b.Currency, b.Sequence, b.LogId, b.Reason)
+	}
+
+	if len(report.Broken) > 0 {
+		os.Exit(1)
+	}
+}
@@ -2,9 +2,13 @@
 package main
 
 import (
+	"github.com/axetroy/go-server/src/controller/message"
+	"github.com/axetroy/go-server/src/controller/statement"
 	"github.com/axetroy/go-server/src/message_queue"
 )
 
 func main() {
+	statement.RunStatementConsumer()
+	message.RunDispatchConsumer()
 	message_queue.RunMessageQueueConsumer()
 }
@@ -3,11 +3,13 @@ package main
 
 import (
 	"github.com/axetroy/go-server/src"
+	"github.com/axetroy/go-server/src/controller/statement"
 	"github.com/axetroy/go-server/src/message_queue"
 )
 
 func main() {
 	go message_queue.RunMessageQueueConsumer()
+	go statement.RunStatementConsumer()
 	go src.ServerUserClient()
 	src.ServerAdminClient()
 }
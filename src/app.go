@@ -4,6 +4,8 @@ package src
 import (
 	"fmt"
 	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller/notification"
+	"github.com/axetroy/go-server/src/controller/transfer"
 	"net/http"
 	"time"
 )
@@ -19,6 +21,13 @@ func ServerUserClient() {
 		WriteTimeout:   60 * time.Second,
 		MaxHeaderBytes: 1024 * 1024 * 20, // 20M
 	}
+	// 定时退回收款方超时未确认的转账
+	go transfer.RunExpireWorker()
+	// 定时执行到期的定时转账
+	go transfer.RunScheduleWorker()
+	// 定时推送到达发布时间的系统通知
+	go notification.RunDeliverWorker()
+
 	fmt.Printf("用户端 HTTP 监听:  %s\n", s.Addr)
 	if err := s.ListenAndServe(); err != nil {
 		panic(err)
@@ -3,12 +3,15 @@ package config
 
 import (
 	"github.com/axetroy/go-server/src/service/dotenv"
+	"github.com/shopspring/decimal"
 )
 
 type admin struct {
 	Domain string `json:"domain"` // 管理员端 API 绑定的域名
 	Port   string `json:"port"`   // 管理员端 API 监听的端口
 	Secret string `json:"secret"` // 管理员端密钥，用于加密/解密 token
+
+	WalletAdjustApproveThreshold *decimal.Decimal `json:"wallet_adjust_approve_threshold"` // 钱包调整超过这个数量时, 需要另一个管理员审核. 为空则不需要审核
 }
 
 var Admin admin
@@ -23,4 +26,7 @@ func init() {
 	if Admin.Secret = dotenv.Get("ADMIN_TOKEN_SECRET_KEY"); Admin.Secret == "" {
 		Admin.Secret = "admin"
 	}
+	if d, err := decimal.NewFromString(dotenv.Get("ADMIN_WALLET_ADJUST_APPROVE_THRESHOLD")); err == nil && d.IsPositive() {
+		Admin.WalletAdjustApproveThreshold = &d
+	}
 }
@@ -7,7 +7,7 @@ import (
 
 var (
 	ModeProduction  = "production"
-	ModeDevelopment = "production"
+	ModeDevelopment = "development"
 )
 
 type common struct {
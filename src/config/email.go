@@ -0,0 +1,64 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"path"
+	"strconv"
+	"time"
+)
+
+const (
+	EmailTransportSMTP   = "smtp"   // 通过 SMTP 服务器发送
+	EmailTransportFile   = "file"   // 写入到本地的 maildir 目录, 用于开发环境
+	EmailTransportMemory = "memory" // 保存在内存中, 用于测试
+)
+
+type email struct {
+	Transport            string `json:"transport"`               // 邮件的发送方式, smtp/file/memory
+	Maildir              string `json:"maildir"`                 // file 方式时邮件写入的 maildir 目录
+	TemplateDir          string `json:"template_dir"`            // 邮件模版所在的目录, 目录下为 layout.html 和各个语言的模版目录
+	ActivationPath       string `json:"activation_path"`         // 邮件中激活链接的路径, 拼接在 USER_HTTP_DOMAIN 之后
+	ResetPasswordPath    string `json:"reset_password_path"`     // 邮件中重置登陆密码页面的路径, 拼接在 USER_WEB_DOMAIN 之后
+	ResetPayPasswordPath string `json:"reset_pay_password_path"` // 邮件中重置交易密码页面的路径, 拼接在 USER_WEB_DOMAIN 之后
+
+	MaxAttempts int           `json:"max_attempts"` // 消息队列中的邮件最多尝试发送的次数, 超过之后放入死信队列
+	RetryDelay  time.Duration `json:"retry_delay"`  // 第一次重试的延迟, 之后每次翻倍
+}
+
+var Email email
+
+func init() {
+	if Email.Transport = dotenv.Get("EMAIL_TRANSPORT"); Email.Transport == "" {
+		if dotenv.Test {
+			Email.Transport = EmailTransportMemory
+		} else {
+			Email.Transport = EmailTransportSMTP
+		}
+	}
+	if Email.Maildir = dotenv.Get("EMAIL_MAILDIR"); Email.Maildir == "" {
+		Email.Maildir = path.Join(dotenv.RootDir, "mail")
+	}
+	if Email.TemplateDir = dotenv.Get("EMAIL_TEMPLATE_DIR"); Email.TemplateDir == "" {
+		Email.TemplateDir = path.Join(dotenv.RootDir, "templates", "email")
+	}
+	if Email.ActivationPath = dotenv.Get("EMAIL_ACTIVATION_PATH"); Email.ActivationPath == "" {
+		Email.ActivationPath = "/v1/auth/activation"
+	}
+	if Email.ResetPasswordPath = dotenv.Get("EMAIL_RESET_PASSWORD_PATH"); Email.ResetPasswordPath == "" {
+		Email.ResetPasswordPath = "/password/reset"
+	}
+	if Email.ResetPayPasswordPath = dotenv.Get("EMAIL_RESET_PAY_PASSWORD_PATH"); Email.ResetPayPasswordPath == "" {
+		Email.ResetPayPasswordPath = "/password2/reset"
+	}
+	if n, err := strconv.Atoi(dotenv.Get("EMAIL_MAX_ATTEMPTS")); err != nil || n <= 0 {
+		Email.MaxAttempts = 5
+	} else {
+		Email.MaxAttempts = n
+	}
+	if d, err := time.ParseDuration(dotenv.Get("EMAIL_RETRY_DELAY")); err != nil || d <= 0 {
+		Email.RetryDelay = time.Second * 30
+	} else {
+		Email.RetryDelay = d
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	ExchangeSourceStatic = "static" // 管理员维护的汇率表
+	ExchangeSourceHttp   = "http"   // 从 HTTP 接口获取汇率
+)
+
+type exchange struct {
+	Source      string          `json:"source"`       // 汇率来源, static/http
+	FeedURL     string          `json:"feed_url"`     // HTTP 汇率接口的地址
+	FeedTimeout time.Duration   `json:"feed_timeout"` // 请求 HTTP 汇率接口的超时时间
+	Spread      decimal.Decimal `json:"spread"`       // 点差, 例如 0.005 表示在中间价的基础上少给 0.5%
+	FeeRate     decimal.Decimal `json:"fee_rate"`     // 手续费率, 从兑换得到的数量中扣除
+	QuoteTTL    time.Duration   `json:"quote_ttl"`    // 报价的有效期, 过期之后不能再按这个报价兑换
+}
+
+var Exchange exchange
+
+func init() {
+	if Exchange.Source = dotenv.Get("EXCHANGE_SOURCE"); Exchange.Source == "" {
+		Exchange.Source = ExchangeSourceStatic
+	}
+	Exchange.FeedURL = dotenv.Get("EXCHANGE_FEED_URL")
+	if d, err := time.ParseDuration(dotenv.Get("EXCHANGE_FEED_TIMEOUT")); err != nil || d <= 0 {
+		Exchange.FeedTimeout = time.Second * 5
+	} else {
+		Exchange.FeedTimeout = d
+	}
+	if d, err := decimal.NewFromString(dotenv.Get("EXCHANGE_SPREAD")); err == nil && !d.IsNegative() && d.LessThan(decimal.New(1, 0)) {
+		Exchange.Spread = d
+	}
+	if d, err := decimal.NewFromString(dotenv.Get("EXCHANGE_FEE_RATE")); err == nil && !d.IsNegative() && d.LessThan(decimal.New(1, 0)) {
+		Exchange.FeeRate = d
+	}
+	if d, err := time.ParseDuration(dotenv.Get("EXCHANGE_QUOTE_TTL")); err != nil || d <= 0 {
+		Exchange.QuoteTTL = time.Second * 30
+	} else {
+		Exchange.QuoteTTL = d
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+)
+
+type finance struct {
+	HashSecret string `json:"hash_secret"` // 流水哈希链的 HMAC 密钥, 保存在数据库之外, 只能修改数据库时无法伪造流水的哈希
+}
+
+var Finance finance
+
+func init() {
+	if Finance.HashSecret = dotenv.Get("FINANCE_HASH_SECRET"); Finance.HashSecret == "" {
+		Finance.HashSecret = "finance"
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"strconv"
+)
+
+type invite struct {
+	TreeDepth   int `json:"tree_depth"`   // 邀请关系树统计的最大层级, 1 表示只统计直接邀请的用户
+	ClusterSize int `json:"cluster_size"` // 同一个邀请人有多少个被邀请人使用同一个 IP 登陆时视为可疑
+}
+
+var Invite invite
+
+func init() {
+	if n, err := strconv.Atoi(dotenv.Get("INVITE_TREE_DEPTH")); err != nil || n <= 0 {
+		Invite.TreeDepth = 3
+	} else {
+		Invite.TreeDepth = n
+	}
+	if n, err := strconv.Atoi(dotenv.Get("INVITE_CLUSTER_SIZE")); err != nil || n < 2 {
+		Invite.ClusterSize = 3
+	} else {
+		Invite.ClusterSize = n
+	}
+}
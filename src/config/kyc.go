@@ -0,0 +1,28 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"strconv"
+	"time"
+)
+
+type kyc struct {
+	TransferLevel  int32         `json:"transfer_level"`  // 时间窗口内累计转账超过币种的实名认证数量时, 需要达到的认证等级
+	TransferWindow time.Duration `json:"transfer_window"` // 累计转账数量的时间窗口
+}
+
+var Kyc kyc
+
+func init() {
+	if n, err := strconv.Atoi(dotenv.Get("KYC_TRANSFER_LEVEL")); err != nil || n <= 0 {
+		Kyc.TransferLevel = 1
+	} else {
+		Kyc.TransferLevel = int32(n)
+	}
+	if d, err := time.ParseDuration(dotenv.Get("KYC_TRANSFER_WINDOW")); err != nil || d <= 0 {
+		Kyc.TransferWindow = time.Hour * 24
+	} else {
+		Kyc.TransferWindow = d
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+)
+
+type message struct {
+	DefaultLocale   string `json:"default_locale"`   // 用户没有设置语言, 或者没有对应语言的模版时使用的语言
+	DefaultTimezone string `json:"default_timezone"` // 用户没有设置时区时, 免打扰时间使用的时区
+}
+
+var Message message
+
+func init() {
+	if Message.DefaultLocale = dotenv.Get("MESSAGE_DEFAULT_LOCALE"); Message.DefaultLocale == "" {
+		Message.DefaultLocale = "zh-CN"
+	}
+	if Message.DefaultTimezone = dotenv.Get("MESSAGE_DEFAULT_TIMEZONE"); Message.DefaultTimezone == "" {
+		Message.DefaultTimezone = "Local"
+	}
+}
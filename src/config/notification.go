@@ -0,0 +1,21 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"time"
+)
+
+type notification struct {
+	PushInterval time.Duration `json:"push_interval"` // 检查到达发布时间的通知并推送给在线用户的时间间隔
+}
+
+var Notification notification
+
+func init() {
+	if d, err := time.ParseDuration(dotenv.Get("NOTIFICATION_PUSH_INTERVAL")); err != nil || d <= 0 {
+		Notification.PushInterval = time.Minute
+	} else {
+		Notification.PushInterval = d
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type push struct {
+	Channel   string        `json:"channel"`   // 多个实例之间通过 Redis 的这个频道广播推送的事件
+	Heartbeat time.Duration `json:"heartbeat"` // 推送连接的心跳间隔, 防止连接被代理服务器断开
+	Buffer    int           `json:"buffer"`    // 每个连接最多缓存的事件数量, 客户端接收太慢时丢弃新的事件
+	Origins   []string      `json:"origins"`   // 允许建立推送连接的网页来源, 为空时只允许用户端 API 绑定的域名
+}
+
+var Push push
+
+func init() {
+	if Push.Channel = dotenv.Get("PUSH_CHANNEL"); Push.Channel == "" {
+		Push.Channel = "push"
+	}
+	if d, err := time.ParseDuration(dotenv.Get("PUSH_HEARTBEAT")); err != nil || d <= 0 {
+		Push.Heartbeat = time.Second * 30
+	} else {
+		Push.Heartbeat = d
+	}
+	if n, err := strconv.Atoi(dotenv.Get("PUSH_BUFFER")); err != nil || n <= 0 {
+		Push.Buffer = 32
+	} else {
+		Push.Buffer = n
+	}
+	for _, origin := range strings.Split(dotenv.Get("PUSH_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			Push.Origins = append(Push.Origins, origin)
+		}
+	}
+}
@@ -10,12 +10,19 @@ type sender struct {
 	Email string `json:"email"`
 }
 
+const (
+	SMTPSecurityTLS      = "tls"      // 连接时直接使用 TLS, 一般为 465 端口
+	SMTPSecurityStartTLS = "starttls" // 先明文连接, 再通过 STARTTLS 升级, 一般为 587 端口
+)
+
 type smtp struct {
-	Host     string `json:"host"`
-	Port     string `json:"port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Sender   sender `json:"sender"`
+	Host               string `json:"host"`
+	Port               string `json:"port"`
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	Sender             sender `json:"sender"`
+	Security           string `json:"security"`             // 加密方式, tls 或者 starttls
+	InsecureSkipVerify string `json:"insecure_skip_verify"` // 是否跳过证书校验, on/off, 只应该在测试自签名证书时打开
 }
 
 var SMTP smtp
@@ -27,4 +34,14 @@ func init() {
 	SMTP.Password = dotenv.Get("SMTP_PASSWORD")
 	SMTP.Sender.Name = dotenv.Get("SMTP_FROM_NAME")
 	SMTP.Sender.Email = dotenv.Get("SMTP_FROM_EMAIL")
+	if SMTP.Security = dotenv.Get("SMTP_SECURITY"); SMTP.Security == "" {
+		if SMTP.Port == "465" {
+			SMTP.Security = SMTPSecurityTLS
+		} else {
+			SMTP.Security = SMTPSecurityStartTLS
+		}
+	}
+	if SMTP.InsecureSkipVerify = dotenv.Get("SMTP_INSECURE_SKIP_VERIFY"); SMTP.InsecureSkipVerify == "" {
+		SMTP.InsecureSkipVerify = "off"
+	}
 }
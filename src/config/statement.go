@@ -0,0 +1,27 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"strconv"
+)
+
+type statement struct {
+	SyncDays int `json:"sync_days"` // 日期范围不超过这个天数的对账单直接生成, 超过的通过消息队列异步生成
+	MaxDays  int `json:"max_days"`  // 一份对账单最多包含的天数
+}
+
+var Statement statement
+
+func init() {
+	if n, err := strconv.Atoi(dotenv.Get("STATEMENT_SYNC_DAYS")); err != nil || n < 0 {
+		Statement.SyncDays = 31
+	} else {
+		Statement.SyncDays = n
+	}
+	if n, err := strconv.Atoi(dotenv.Get("STATEMENT_MAX_DAYS")); err != nil || n <= 0 {
+		Statement.MaxDays = 366
+	} else {
+		Statement.MaxDays = n
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/src/service/dotenv"
+	"strconv"
+	"time"
+)
+
+const (
+	ReversalPolicyPartial  = "partial"  // 收款方余额不足时, 只撤回剩余的余额
+	ReversalPolicyNegative = "negative" // 收款方余额不足时, 允许余额变成负数
+)
+
+type transfer struct {
+	ConfirmTTL     time.Duration `json:"confirm_ttl"`     // 需要收款方确认的转账, 超过这个时间没有确认则自动退回
+	ExpireInterval time.Duration `json:"expire_interval"` // 检查过期转账的时间间隔
+
+	ScheduleInterval   time.Duration `json:"schedule_interval"`    // 检查到期的定时转账的时间间隔
+	ScheduleRetryDelay time.Duration `json:"schedule_retry_delay"` // 定时转账余额不足时, 多久之后重试
+	ScheduleMaxRetries int           `json:"schedule_max_retries"` // 定时转账余额不足时最多重试的次数, 超过之后跳过本次
+
+	PreviewLimit  int           `json:"preview_limit"`  // 每个用户在时间窗口内最多预览收款方的次数, 防止遍历账号
+	PreviewWindow time.Duration `json:"preview_window"` // 预览收款方的时间窗口
+
+	ReversalPolicy string `json:"reversal_policy"` // 管理员撤回转账时, 收款方余额不足的处理方式, partial/negative
+}
+
+var Transfer transfer
+
+func init() {
+	if d, err := time.ParseDuration(dotenv.Get("TRANSFER_CONFIRM_TTL")); err != nil || d <= 0 {
+		Transfer.ConfirmTTL = time.Hour * 24
+	} else {
+		Transfer.ConfirmTTL = d
+	}
+	if d, err := time.ParseDuration(dotenv.Get("TRANSFER_EXPIRE_INTERVAL")); err != nil || d <= 0 {
+		Transfer.ExpireInterval = time.Minute
+	} else {
+		Transfer.ExpireInterval = d
+	}
+	if d, err := time.ParseDuration(dotenv.Get("TRANSFER_SCHEDULE_INTERVAL")); err != nil || d <= 0 {
+		Transfer.ScheduleInterval = time.Minute
+	} else {
+		Transfer.ScheduleInterval = d
+	}
+	if d, err := time.ParseDuration(dotenv.Get("TRANSFER_SCHEDULE_RETRY_DELAY")); err != nil || d <= 0 {
+		Transfer.ScheduleRetryDelay = time.Minute * 30
+	} else {
+		Transfer.ScheduleRetryDelay = d
+	}
+	if n, err := strconv.Atoi(dotenv.Get("TRANSFER_SCHEDULE_MAX_RETRIES")); err != nil || n < 0 {
+		Transfer.ScheduleMaxRetries = 5
+	} else {
+		Transfer.ScheduleMaxRetries = n
+	}
+	if n, err := strconv.Atoi(dotenv.Get("TRANSFER_PREVIEW_LIMIT")); err != nil || n <= 0 {
+		Transfer.PreviewLimit = 10
+	} else {
+		Transfer.PreviewLimit = n
+	}
+	if d, err := time.ParseDuration(dotenv.Get("TRANSFER_PREVIEW_WINDOW")); err != nil || d <= 0 {
+		Transfer.PreviewWindow = time.Minute
+	} else {
+		Transfer.PreviewWindow = d
+	}
+	if Transfer.ReversalPolicy = dotenv.Get("TRANSFER_REVERSAL_POLICY"); Transfer.ReversalPolicy != ReversalPolicyNegative {
+		Transfer.ReversalPolicy = ReversalPolicyPartial
+	}
+}
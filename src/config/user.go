@@ -3,12 +3,16 @@ package config
 
 import (
 	"github.com/axetroy/go-server/src/service/dotenv"
+	"time"
 )
 
 type user struct {
-	Domain string `json:"domain"` // 用户端 API 绑定的域名, 例如 https://example.com
-	Port   string `json:"port"`   // 用户端 API 监听的端口
-	Secret string `json:"secret"` // 用户端密钥，用于加密/解密 token
+	Domain    string `json:"domain"`     // 用户端 API 绑定的域名, 例如 https://example.com
+	WebDomain string `json:"web_domain"` // 用户端前端页面的域名, 邮件中需要用户填写表单的链接指向这里, 例如 https://www.example.com
+	Port      string `json:"port"`       // 用户端 API 监听的端口
+	Secret    string `json:"secret"`     // 用户端密钥，用于加密/解密 token
+
+	IdempotencyKeyTTL time.Duration `json:"idempotency_key_ttl"` // 幂等键的有效期, 在有效期内重复的请求会返回第一次的响应
 }
 
 var User user
@@ -20,7 +24,15 @@ func init() {
 	if User.Domain = dotenv.Get("USER_HTTP_DOMAIN"); User.Domain == "" {
 		User.Domain = "http://127.0.0.1:" + User.Port
 	}
+	if User.WebDomain = dotenv.Get("USER_WEB_DOMAIN"); User.WebDomain == "" {
+		User.WebDomain = User.Domain
+	}
 	if User.Secret = dotenv.Get("USER_TOKEN_SECRET_KEY"); User.Secret == "" {
 		User.Secret = "user"
 	}
+	if d, err := time.ParseDuration(dotenv.Get("USER_IDEMPOTENCY_KEY_TTL")); err != nil || d <= 0 {
+		User.IdempotencyKeyTTL = time.Hour * 24
+	} else {
+		User.IdempotencyKeyTTL = d
+	}
 }
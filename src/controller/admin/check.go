@@ -0,0 +1,31 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package admin
+
+import (
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/jinzhu/gorm"
+)
+
+// 获取管理员并检查是否有某个权限, 超级管理员拥有所有权限
+func Check(tx *gorm.DB, uid string, a accession.Accession) (adminInfo model.Admin, err error) {
+	adminInfo = model.Admin{
+		Id: uid,
+	}
+
+	if err = tx.First(&adminInfo).Error; err != nil {
+		// 没有找到管理员
+		if err == gorm.ErrRecordNotFound {
+			err = exception.AdminNotExist
+		}
+		return
+	}
+
+	if !adminInfo.HasAccession(a) {
+		err = exception.NoPermission
+		return
+	}
+
+	return
+}
@@ -4,6 +4,8 @@ package auth
 import (
 	"errors"
 	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/controller/wallet"
 	"github.com/axetroy/go-server/src/exception"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
@@ -83,11 +85,27 @@ func Activation(input ActivationParams) (res schema.Response) {
 		return
 	}
 
+	// 注册的邀请奖励需要的钱包在更新用户状态之前一起加锁
+	keys, err := invite.RewardWallets(tx, userInfo.Id, model.StatusInviteRegistered)
+
+	if err != nil {
+		return
+	}
+
+	if _, err = wallet.LockAll(tx, keys...); err != nil {
+		return
+	}
+
 	// 更新激活状态
 	if err = tx.Model(&userInfo).Update("status", model.UserStatusInit).Error; err != nil {
 		return
 	}
 
+	// 激活之后才结算注册的邀请奖励, 防止批量注册不激活的账号刷奖励
+	if err = invite.Settle(tx, userInfo.Id, model.StatusInviteRegistered); err != nil {
+		return
+	}
+
 	// delete code from redis
 	if err = redis.ActivationCodeClient.Del(input.Code).Err(); err != nil {
 		return
@@ -117,3 +135,23 @@ func ActivationRouter(context *gin.Context) {
 
 	res = Activation(input)
 }
+
+// 通过邮件中的激活链接激活账号, 激活码在 query 参数 code 中
+func ActivationByLinkRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Activation(ActivationParams{
+		Code: context.Query("code"),
+	})
+}
@@ -4,9 +4,9 @@ package auth_test
 import (
 	"encoding/json"
 	"github.com/axetroy/go-server/src/controller/auth"
-	"github.com/axetroy/go-server/src/controller/email"
 	"github.com/axetroy/go-server/src/exception"
 	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/email"
 	"github.com/axetroy/go-server/src/service/redis"
 	"github.com/axetroy/go-server/tester"
 	"github.com/stretchr/testify/assert"
@@ -78,7 +78,12 @@ func TestActivationSuccess(t *testing.T) {
 	}
 
 	// generate activation code
-	activationCode := email.GenerateActivationCode(testerUid)
+	activationCode, err := email.NewActivationCode()
+
+	if err != nil {
+		t.Error(err)
+		return
+	}
 
 	// set activationCode to redis
 	if err := redis.ActivationCodeClient.Set(activationCode, testerUid, time.Minute*30).Err(); err != nil {
@@ -2,7 +2,6 @@
 package auth
 
 import (
-	"encoding/json"
 	"errors"
 	"github.com/axetroy/go-server/src/controller/wallet"
 	"github.com/axetroy/go-server/src/exception"
@@ -10,7 +9,6 @@ import (
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
-	"github.com/axetroy/go-server/src/service/redis"
 	"github.com/axetroy/go-server/src/util"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
@@ -31,10 +29,11 @@ type SignUpParams struct {
 
 func SignUp(input SignUpParams) (res schema.Response) {
 	var (
-		err     error
-		data    schema.Profile
-		tx      *gorm.DB
-		inviter *model.User // 邀请人信息
+		err             error
+		data            schema.Profile
+		tx              *gorm.DB
+		inviter         *model.User     // 邀请人信息
+		activationEmail *model.EmailLog // 需要发送的激活邮件
 	)
 
 	defer func() {
@@ -63,6 +62,11 @@ func SignUp(input SignUpParams) (res schema.Response) {
 		} else {
 			res.Data = data
 			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再把激活邮件加入消息队列, 否则消费者可能找不到邮件记录
+			if activationEmail != nil {
+				_ = message_queue.PublishEmail(*activationEmail)
+			}
 		}
 
 	}()
@@ -77,6 +81,11 @@ func SignUp(input SignUpParams) (res schema.Response) {
 		return
 	}
 
+	if input.Username != nil && !util.IsValidUsername(*input.Username) {
+		err = exception.InvalidUsername
+		return
+	}
+
 	if input.Phone != nil {
 		if input.MCode == nil {
 			err = errors.New("请输入短信验证码")
@@ -205,40 +214,20 @@ func SignUp(input SignUpParams) (res schema.Response) {
 	data.UpdatedAt = userInfo.UpdatedAt.Format(time.RFC3339Nano)
 
 	// 创建用户对应的钱包账号
-	for _, walletName := range model.Wallets {
-		if err = tx.Table(wallet.GetTableName(walletName)).Create(&model.Wallet{
-			Id:       userInfo.Id,
-			Currency: walletName,
-			Balance:  0,
-			Frozen:   0,
-		}).Error; err != nil {
-			return
-		}
+	if err = wallet.CreateWallets(tx, userInfo.Id); err != nil {
+		return
 	}
 
 	// 如果是以邮箱注册的，那么发送激活链接
 	if userInfo.Email != nil && len(*userInfo.Email) != 0 {
-		// 生成激活码
-		activationCode := "activation-" + userInfo.Id
+		// 记录要发送的激活邮件, 激活码在发送时生成
+		var log model.EmailLog
 
-		// 把激活码存到 redis
-		if err = redis.ActivationCodeClient.Set(activationCode, userInfo.Id, time.Minute*30).Err(); err != nil {
+		if log, err = message_queue.CreateActivationEmail(tx, userInfo.Id, *input.Email); err != nil {
 			return
 		}
 
-		// 把 "发送激活码" 加入消息队列
-		var body []byte
-
-		if body, err = json.Marshal(message_queue.SendActivationEmailBody{
-			Email: *input.Email,
-			Code:  activationCode,
-		}); err != nil {
-			return
-		}
-
-		if err = message_queue.Publish(message_queue.TopicSendEmail, body); err != nil {
-			return
-		}
+		activationEmail = &log
 
 		return
 	}
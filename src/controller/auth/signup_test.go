@@ -52,6 +52,20 @@ func TestSignUpWithNotFullBody(t *testing.T) {
 	assert.Nil(t, res.Data)
 }
 
+func TestSignUpInvalidUsername(t *testing.T) {
+	// 用户名不能是邮箱或者手机号, 否则可以抢注别人的账号收取转账
+	for _, username := range []string{"test@example.com", "13800138000"} {
+		name := username
+
+		res := auth.SignUp(auth.SignUpParams{
+			Username: &name,
+			Password: "123123",
+		})
+
+		assert.Equal(t, exception.InvalidUsername.Error(), res.Message)
+	}
+}
+
 func TestSignUpSuccess(t *testing.T) {
 	rand.Seed(99) // 重置随机码，否则随机数会一样
 
@@ -0,0 +1,162 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+)
+
+type CreateParams struct {
+	Code         string  `json:"code" valid:"required~请输入币种代码"` // 币种代码
+	Name         string  `json:"name" valid:"required~请输入币种名称"` // 币种名称
+	Scale        int32   `json:"scale"`                         // 币种精度
+	Transferable bool    `json:"transferable"`                  // 是否允许转账
+	MinAmount    *string `json:"min_amount"`                    // 单笔转账的最小数量
+	MaxAmount    *string `json:"max_amount"`                    // 单笔转账的最大数量
+	KycAmount    *string `json:"kyc_amount"`                    // 时间窗口内累计转账超过这个数量需要实名认证
+}
+
+// 添加一个币种, 添加之后立即启用
+func Create(context controller.Context, input CreateParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.Currency
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	// 参数校验
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	code := strings.ToUpper(input.Code)
+
+	if !codeReg.MatchString(code) {
+		err = exception.InvalidCurrencyCode
+		return
+	}
+
+	if input.Scale < 0 || input.Scale > MaxScale {
+		err = exception.InvalidCurrencyScale
+		return
+	}
+
+	currencyInfo := model.Currency{
+		Code:         code,
+		Name:         input.Name,
+		Scale:        input.Scale,
+		Enabled:      true,
+		Transferable: input.Transferable,
+	}
+
+	if currencyInfo.MinAmount, err = parseLimit(input.MinAmount, input.Scale); err != nil {
+		return
+	}
+
+	if currencyInfo.MaxAmount, err = parseLimit(input.MaxAmount, input.Scale); err != nil {
+		return
+	}
+
+	if err = checkLimit(currencyInfo.MinAmount, currencyInfo.MaxAmount); err != nil {
+		return
+	}
+
+	if currencyInfo.KycAmount, err = parseLimit(input.KycAmount, input.Scale); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminCurrencyUpdate); err != nil {
+		return
+	}
+
+	var count int
+
+	if err = tx.Model(model.Currency{}).Where("code = ?", code).Count(&count).Error; err != nil {
+		return
+	}
+
+	if count > 0 {
+		err = exception.CurrencyExist
+		return
+	}
+
+	if err = tx.Create(&currencyInfo).Error; err != nil {
+		return
+	}
+
+	mapToSchema(currencyInfo, &data)
+
+	return
+}
+
+func CreateRouter(context *gin.Context) {
+	var (
+		input CreateParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Create(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
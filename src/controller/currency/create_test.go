@@ -0,0 +1,135 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package currency_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/currency"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+
+	code := "T" + strings.ToUpper(util.RandomString(6))
+
+	defer database.DeleteRowByTable("currency", "code", code)
+
+	minAmount := "1"
+	maxAmount := "1000"
+
+	r := currency.Create(controller.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code:         strings.ToLower(code),
+		Name:         "测试币",
+		Scale:        4,
+		Transferable: true,
+		MinAmount:    &minAmount,
+		MaxAmount:    &maxAmount,
+	})
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+
+	data := schema.Currency{}
+
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, code, data.Code)
+	assert.Equal(t, int32(4), data.Scale)
+	assert.True(t, data.Enabled)
+	assert.Equal(t, "1.00000000", *data.MinAmount)
+	assert.Equal(t, "1000.00000000", *data.MaxAmount)
+
+	// 重复添加
+	r = currency.Create(controller.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code: code,
+		Name: "测试币",
+	})
+
+	assert.Equal(t, exception.CurrencyExist.Error(), r.Message)
+
+	// 限额不正确
+	r = currency.Create(controller.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code:      code + "X",
+		Name:      "测试币",
+		MinAmount: &maxAmount,
+		MaxAmount: &minAmount,
+	})
+
+	assert.Equal(t, exception.InvalidCurrencyLimit.Error(), r.Message)
+
+	// 无效的币种代码
+	r = currency.Create(controller.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code: "A-B",
+		Name: "测试币",
+	})
+
+	assert.Equal(t, exception.InvalidCurrencyCode.Error(), r.Message)
+}
+
+func TestUpdate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+
+	code := "T" + strings.ToUpper(util.RandomString(6))
+
+	defer database.DeleteRowByTable("currency", "code", code)
+
+	r := currency.Create(controller.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code:  code,
+		Name:  "测试币",
+		Scale: 2,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	enabled := false
+	maxAmount := "100"
+
+	r = currency.Update(controller.Context{Uid: adminInfo.Id}, code, currency.UpdateParams{
+		Enabled:   &enabled,
+		MaxAmount: &maxAmount,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	data := schema.Currency{}
+
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.False(t, data.Enabled)
+	assert.Nil(t, data.MinAmount)
+	assert.Equal(t, "100.00000000", *data.MaxAmount)
+
+	// 停用的币种不会出现在用户的币种列表中
+	r = currency.GetList(controller.Context{})
+
+	list := make([]schema.Currency, 0)
+
+	assert.Nil(t, tester.Decode(r.Data, &list))
+
+	for _, c := range list {
+		assert.NotEqual(t, code, c.Code)
+	}
+
+	_, err := currency.Get(database.Db, code)
+
+	assert.Equal(t, exception.CurrencyDisabled, err)
+
+	// 精度超出
+	maxAmount = "0.001"
+
+	r = currency.Update(controller.Context{Uid: adminInfo.Id}, code, currency.UpdateParams{
+		MaxAmount: &maxAmount,
+	})
+
+	assert.Equal(t, exception.InvalidAmountPrecision.Error(), r.Message)
+
+	// 不存在的币种
+	r = currency.Update(controller.Context{Uid: adminInfo.Id}, "NOT_EXIST", currency.UpdateParams{})
+
+	assert.Equal(t, exception.CurrencyNotExist.Error(), r.Message)
+}
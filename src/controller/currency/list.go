@@ -0,0 +1,116 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 获取币种列表, 用户只能看到已启用的币种, 管理员可以看到所有的币种
+func getList(context controller.Context, isAdmin bool) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.Currency, 0)
+		list = make([]model.Currency, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if isAdmin {
+		if _, err = admin.Check(database.Db, context.Uid, *accession.AdminCurrencyUpdate); err != nil {
+			return
+		}
+
+		if err = database.Db.Order("code ASC").Find(&list).Error; err != nil {
+			return
+		}
+	} else {
+		if list, err = GetEnabled(database.Db); err != nil {
+			return
+		}
+	}
+
+	for _, v := range list {
+		d := schema.Currency{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	return
+}
+
+// 获取已启用的币种
+func GetList(context controller.Context) (res schema.Response) {
+	return getList(context, false)
+}
+
+// 管理员获取所有的币种
+func GetListByAdmin(context controller.Context) (res schema.Response) {
+	return getList(context, true)
+}
+
+func GetListRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetList(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
+
+func GetListByAdminRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetListByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+)
+
+// 币种的精度不允许修改, 否则已有的余额可能会超出精度
+type UpdateParams struct {
+	Name         *string `json:"name"`         // 币种名称
+	Enabled      *bool   `json:"enabled"`      // 是否启用
+	Transferable *bool   `json:"transferable"` // 是否允许转账
+	MinAmount    *string `json:"min_amount"`   // 单笔转账的最小数量, 空字符串表示不限制
+	MaxAmount    *string `json:"max_amount"`   // 单笔转账的最大数量, 空字符串表示不限制
+	KycAmount    *string `json:"kyc_amount"`   // 时间窗口内累计转账超过这个数量需要实名认证, 空字符串表示不需要
+}
+
+func Update(context controller.Context, code string, input UpdateParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.Currency
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminCurrencyUpdate); err != nil {
+		return
+	}
+
+	currencyInfo := model.Currency{}
+
+	if err = tx.Where("code = ?", strings.ToUpper(code)).First(&currencyInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.CurrencyNotExist
+		}
+		return
+	}
+
+	// 布尔值和清空限额需要用 map 更新, 否则零值会被忽略
+	updated := map[string]interface{}{}
+
+	if input.Name != nil {
+		currencyInfo.Name = *input.Name
+		updated["name"] = currencyInfo.Name
+	}
+
+	if input.Enabled != nil {
+		currencyInfo.Enabled = *input.Enabled
+		updated["enabled"] = currencyInfo.Enabled
+	}
+
+	if input.Transferable != nil {
+		currencyInfo.Transferable = *input.Transferable
+		updated["transferable"] = currencyInfo.Transferable
+	}
+
+	if input.MinAmount != nil {
+		if currencyInfo.MinAmount, err = parseLimit(input.MinAmount, currencyInfo.Scale); err != nil {
+			return
+		}
+		updated["min_amount"] = currencyInfo.MinAmount
+	}
+
+	if input.MaxAmount != nil {
+		if currencyInfo.MaxAmount, err = parseLimit(input.MaxAmount, currencyInfo.Scale); err != nil {
+			return
+		}
+		updated["max_amount"] = currencyInfo.MaxAmount
+	}
+
+	if err = checkLimit(currencyInfo.MinAmount, currencyInfo.MaxAmount); err != nil {
+		return
+	}
+
+	if input.KycAmount != nil {
+		if currencyInfo.KycAmount, err = parseLimit(input.KycAmount, currencyInfo.Scale); err != nil {
+			return
+		}
+		updated["kyc_amount"] = currencyInfo.KycAmount
+	}
+
+	if len(updated) > 0 {
+		if err = tx.Model(&currencyInfo).Updates(updated).Error; err != nil {
+			return
+		}
+	}
+
+	mapToSchema(currencyInfo, &data)
+
+	return
+}
+
+func UpdateRouter(context *gin.Context) {
+	var (
+		input UpdateParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Update(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("code"), input)
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var codeReg = regexp.MustCompile(`^[A-Z0-9]{2,16}$`)
+
+// 币种的最大精度
+const MaxScale int32 = 18
+
+func mapToSchema(model model.Currency, d *schema.Currency) {
+	d.Code = model.Code
+	d.Name = model.Name
+	d.Scale = model.Scale
+	d.Enabled = model.Enabled
+	d.Transferable = model.Transferable
+	if model.MinAmount != nil {
+		minAmount := util.AmountToStr(*model.MinAmount)
+		d.MinAmount = &minAmount
+	}
+	if model.MaxAmount != nil {
+		maxAmount := util.AmountToStr(*model.MaxAmount)
+		d.MaxAmount = &maxAmount
+	}
+	if model.KycAmount != nil {
+		kycAmount := util.AmountToStr(*model.KycAmount)
+		d.KycAmount = &kycAmount
+	}
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 获取一个已启用的币种, 币种代码忽略大小写
+func Get(tx *gorm.DB, code string) (c model.Currency, err error) {
+	if err = tx.Where("code = ?", strings.ToUpper(code)).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.CurrencyNotExist
+		}
+		return
+	}
+
+	if !c.Enabled {
+		err = exception.CurrencyDisabled
+		return
+	}
+
+	return
+}
+
+// 获取所有已启用的币种
+func GetEnabled(tx *gorm.DB) (list []model.Currency, err error) {
+	list = make([]model.Currency, 0)
+
+	err = tx.Where("enabled = ?", true).Order("code ASC").Find(&list).Error
+
+	return
+}
+
+// 检查转账的数量是否在币种的限额之内
+func CheckTransferAmount(c model.Currency, amount decimal.Decimal) error {
+	if !c.Transferable {
+		return exception.CurrencyNotTransferable
+	}
+
+	if c.MinAmount != nil && amount.LessThan(*c.MinAmount) {
+		return exception.AmountTooSmall
+	}
+
+	if c.MaxAmount != nil && amount.GreaterThan(*c.MaxAmount) {
+		return exception.AmountTooLarge
+	}
+
+	return nil
+}
+
+// 检查用户的实名认证等级是否满足该数量的转账
+// spent 为时间窗口内已经转出的数量, 与本次转账累计计算, 防止拆分成多笔小额转账绕过实名认证
+func CheckKyc(c model.Currency, kycLevel int32, spent decimal.Decimal, amount decimal.Decimal) error {
+	if c.KycAmount != nil && spent.Add(amount).GreaterThan(*c.KycAmount) && kycLevel < config.Kyc.TransferLevel {
+		return exception.KycRequired
+	}
+
+	return nil
+}
+
+// 解析币种的限额, 空字符串表示不限制
+func parseLimit(s *string, scale int32) (*decimal.Decimal, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+
+	d, err := util.ParseAmount(*s, scale)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func checkLimit(min *decimal.Decimal, max *decimal.Decimal) error {
+	if min != nil && max != nil && min.GreaterThan(*max) {
+		return exception.InvalidCurrencyLimit
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package downloader
+
+import (
+	"fmt"
+	"github.com/axetroy/go-server/src/controller/kyc"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 管理员下载用户的实名认证证件图片
+func KycDocumentByAdmin(context *gin.Context) {
+	filePath, name, err := kyc.OpenDocumentByAdmin(context.GetString(middleware.ContextUidField), context.Param("filename"))
+
+	if err != nil {
+		context.JSON(http.StatusOK, schema.Response{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	context.Header("Content-Disposition", fmt.Sprintf("inline; filename=%v", name))
+
+	http.ServeFile(context.Writer, context.Request, filePath)
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package downloader
+
+import (
+	"fmt"
+	"github.com/axetroy/go-fs"
+	"github.com/axetroy/go-server/src/controller/statement"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func serveStatement(context *gin.Context, open func(uid string, id string) (string, string, error)) {
+	filePath, name, err := open(context.GetString(middleware.ContextUidField), context.Param("statement_id"))
+
+	if err != nil {
+		context.JSON(http.StatusOK, schema.Response{
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if isExistFile := fs.PathExists(filePath); isExistFile == false {
+		http.NotFound(context.Writer, context.Request)
+		return
+	}
+
+	context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%v", name))
+
+	http.ServeFile(context.Writer, context.Request, filePath)
+}
+
+// 用户下载自己的对账单
+func Statement(context *gin.Context) {
+	serveStatement(context, statement.Open)
+}
+
+// 管理员下载用户的对账单
+func StatementByAdmin(context *gin.Context) {
+	serveStatement(context, statement.OpenByAdmin)
+}
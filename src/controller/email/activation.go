@@ -4,31 +4,24 @@ package email
 import (
 	"errors"
 	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/message_queue"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
-	"github.com/axetroy/go-server/src/service/email"
-	"github.com/axetroy/go-server/src/service/redis"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"net/http"
-	"time"
 )
 
 type SendActivationEmailParams struct {
 	To string `json:"to"` // 发送给谁
 }
 
-func GenerateActivationCode(uid string) string {
-	// 生成重置码
-	activationCode := "activation-" + uid
-	return activationCode
-}
-
 func SendActivationEmail(input SendActivationEmailParams) (res schema.Response) {
 	var (
-		err error
-		tx  *gorm.DB
+		err             error
+		tx              *gorm.DB
+		activationEmail *model.EmailLog
 	)
 
 	defer func() {
@@ -56,6 +49,11 @@ func SendActivationEmail(input SendActivationEmailParams) (res schema.Response)
 			res.Message = err.Error()
 		} else {
 			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再加入消息队列, 由消息队列生成激活码并发送
+			if activationEmail != nil {
+				_ = message_queue.PublishEmail(*activationEmail)
+			}
 		}
 	}()
 
@@ -77,22 +75,13 @@ func SendActivationEmail(input SendActivationEmailParams) (res schema.Response)
 		return
 	}
 
-	// generate activation code
-	activationCode := GenerateActivationCode(userInfo.Id)
+	log, err := message_queue.CreateActivationEmail(tx, userInfo.Id, input.To)
 
-	// set activationCode to redis
-	if err = redis.ActivationCodeClient.Set(activationCode, userInfo.Id, time.Minute*30).Err(); err != nil {
+	if err != nil {
 		return
 	}
 
-	e := email.NewMailer()
-
-	// send email
-	if err = e.SendActivationEmail(input.To, activationCode); err != nil {
-		// 邮件没发出去的话，删除redis的key
-		_ = redis.ActivationCodeClient.Del(activationCode).Err()
-		return
-	}
+	activationEmail = &log
 
 	return
 }
@@ -0,0 +1,144 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+type CapturedQuery struct {
+	To    string `json:"to" form:"to"`       // 只查看发送给这个邮箱的邮件
+	Limit int    `json:"limit" form:"limit"` // 最多返回多少封, 默认 20, 最大 100
+}
+
+// 收件人中是否包含这个邮箱
+func sentTo(c email.Captured, to string) bool {
+	for _, v := range c.To {
+		for _, s := range strings.Split(v, ",") {
+			if addr, err := mail.ParseAddress(strings.TrimSpace(s)); err == nil && strings.EqualFold(addr.Address, to) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 查看开发环境中捕获的邮件, 只有使用 file/memory 发送方式时可用
+func GetCaptured(context controller.Context, input CapturedQuery) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.CapturedEmail, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminEmail); err != nil {
+		return
+	}
+
+	// 只有明确处于开发模式时才能查看, 其他任何模式中即使误配置了 file/memory 发送方式, 也不能查看用户的邮件
+	catcher, ok := email.CurrentTransport().(email.Catcher)
+
+	if !ok || config.Common.Mode != config.ModeDevelopment {
+		err = exception.EmailCatcherDisabled
+		return
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = 20
+	} else if input.Limit > 100 {
+		input.Limit = 100
+	}
+
+	// 按收件人过滤时需要先取出所有邮件
+	limit := input.Limit
+
+	if input.To != "" {
+		limit = 0
+	}
+
+	list, err := catcher.Captured(limit)
+
+	if err != nil {
+		return
+	}
+
+	for _, c := range list {
+		if len(data) >= input.Limit {
+			break
+		}
+
+		if input.To != "" && !sentTo(c, input.To) {
+			continue
+		}
+
+		data = append(data, schema.CapturedEmail{
+			Id:        c.Id,
+			From:      c.From,
+			To:        c.To,
+			Subject:   c.Subject,
+			Text:      c.Text,
+			HTML:      c.HTML,
+			CreatedAt: c.CreatedAt.Format(time.RFC3339Nano),
+		})
+	}
+
+	return
+}
+
+func GetCapturedRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input CapturedQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetCaptured(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
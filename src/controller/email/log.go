@@ -0,0 +1,319 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/message_queue"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+const ParamsLogIdName = "log_id"
+
+type LogQuery struct {
+	schema.Query
+	Uid    *string               `json:"uid" form:"uid"`       // 指定用户
+	To     *string               `json:"to" form:"to"`         // 指定收件人邮箱
+	Status *model.EmailLogStatus `json:"status" form:"status"` // 指定状态, 例如 -1 查看发送失败的邮件
+}
+
+func mapLogToSchema(log model.EmailLog, d *schema.EmailLog) {
+	d.Id = log.Id
+	d.Uid = log.Uid
+	d.To = log.To
+	d.Template = log.Template
+	d.Status = log.Status
+	d.Attempts = log.Attempts
+	d.Error = log.Error
+	if log.SentAt != nil {
+		sentAt := log.SentAt.Format(time.RFC3339Nano)
+		d.SentAt = &sentAt
+	}
+	d.CreatedAt = log.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = log.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 获取邮件发送记录
+func GetLog(context controller.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.EmailLog
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminEmail); err != nil {
+		return
+	}
+
+	log := model.EmailLog{}
+
+	if err = database.Db.Where("id = ?", id).First(&log).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.EmailLogNotExist
+		}
+		return
+	}
+
+	mapLogToSchema(log, &data)
+
+	return
+}
+
+// 获取邮件发送记录列表
+func GetLogs(context controller.Context, input LogQuery) (res schema.List) {
+	var (
+		err   error
+		data  = make([]schema.EmailLog, 0)
+		meta  = &schema.Meta{}
+		total int64
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminEmail); err != nil {
+		return
+	}
+
+	query := input.Query
+
+	query.Normalize()
+
+	filter := map[string]interface{}{}
+
+	if input.Uid != nil {
+		filter["uid"] = *input.Uid
+	}
+
+	if input.To != nil {
+		filter["to"] = *input.To
+	}
+
+	if input.Status != nil {
+		filter["status"] = *input.Status
+	}
+
+	list := make([]model.EmailLog, 0)
+
+	if err = database.Db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.EmailLog{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.EmailLog{}
+		mapLogToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+// 重新发送失败的邮件, 重新计算重试次数
+func Resend(context controller.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.EmailLog
+		tx   *gorm.DB
+		log  model.EmailLog
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再加入消息队列, 发送时会生成新的激活码
+			_ = message_queue.PublishEmail(log)
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminEmail); err != nil {
+		return
+	}
+
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&log).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.EmailLogNotExist
+		}
+		return
+	}
+
+	if log.Status != model.EmailLogStatusFailed {
+		err = exception.EmailLogNotFailed
+		return
+	}
+
+	userInfo := model.User{}
+
+	if err = tx.Where("id = ?", log.Uid).First(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	// 已经激活的用户不需要再发送激活邮件
+	if log.Template == email.TemplateActivation && userInfo.Status != model.UserStatusInactivated {
+		err = exception.UserHaveActive
+		return
+	}
+
+	if err = tx.Model(&log).Updates(map[string]interface{}{
+		"status":   model.EmailLogStatusPending,
+		"attempts": 0,
+		"error":    nil,
+	}).Error; err != nil {
+		return
+	}
+
+	mapLogToSchema(log, &data)
+
+	return
+}
+
+func GetLogRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetLog(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param(ParamsLogIdName))
+}
+
+func GetLogsRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input LogQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetLogs(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func ResendRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Resend(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param(ParamsLogIdName))
+}
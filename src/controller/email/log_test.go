@@ -0,0 +1,84 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/email"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEmailLog(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer database.DeleteRowByTable("email_log", "uid", userInfo.Id)
+
+	reason := "dial tcp: i/o timeout"
+
+	failed := model.EmailLog{
+		Uid:      userInfo.Id,
+		To:       "test@example.com",
+		Template: "activation",
+		Payload:  `{"uid":"` + userInfo.Id + `"}`,
+		Status:   model.EmailLogStatusFailed,
+		Attempts: 5,
+		Error:    &reason,
+	}
+
+	sent := model.EmailLog{
+		Uid:      userInfo.Id,
+		To:       "test@example.com",
+		Template: "activation",
+		Payload:  "{}",
+		Status:   model.EmailLogStatusSent,
+		Attempts: 1,
+	}
+
+	assert.Nil(t, database.Db.Create(&failed).Error)
+	assert.Nil(t, database.Db.Create(&sent).Error)
+
+	// 查看发送失败的邮件
+	status := model.EmailLogStatusFailed
+
+	list := email.GetLogs(controller.Context{Uid: adminInfo.Id}, email.LogQuery{Uid: &userInfo.Id, Status: &status})
+	logs := make([]schema.EmailLog, 0)
+
+	assert.Equal(t, "", list.Message)
+	assert.Nil(t, tester.Decode(list.Data, &logs))
+	assert.Len(t, logs, 1)
+	assert.Equal(t, failed.Id, logs[0].Id)
+	assert.Equal(t, reason, *logs[0].Error)
+
+	r := email.GetLog(controller.Context{Uid: adminInfo.Id}, sent.Id)
+
+	assert.Equal(t, "", r.Message)
+
+	// 普通用户不能查看
+	r = email.GetLog(controller.Context{Uid: userInfo.Id}, sent.Id)
+
+	assert.Equal(t, exception.AdminNotExist.Error(), r.Message)
+
+	// 已经发送成功的邮件不能重新发送
+	r = email.Resend(controller.Context{Uid: adminInfo.Id}, sent.Id)
+
+	assert.Equal(t, exception.EmailLogNotFailed.Error(), r.Message)
+
+	// 已经激活的用户不需要重新发送激活邮件
+	assert.Nil(t, database.Db.Model(&model.User{}).Where("id = ?", userInfo.Id).Update("status", model.UserStatusInit).Error)
+
+	r = email.Resend(controller.Context{Uid: adminInfo.Id}, failed.Id)
+
+	assert.Equal(t, exception.UserHaveActive.Error(), r.Message)
+
+	r = email.Resend(controller.Context{Uid: adminInfo.Id}, "not-exist")
+
+	assert.Equal(t, exception.EmailLogNotExist.Error(), r.Message)
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const ParamsTemplateName = "name"
+
+type PreviewQuery struct {
+	Locale string `json:"locale" form:"locale"` // 预览的语言, 为空时使用默认语言
+}
+
+// 预览时使用的示例数据
+var samples = map[string]map[string]interface{}{
+	email.TemplateActivation: {
+		"Code": "activation-sample",
+		"Link": email.Link(config.Email.ActivationPath, "activation-sample"),
+	},
+	email.TemplateResetPassword: {
+		"Code": "reset-sample",
+		"Link": email.PageLink(config.Email.ResetPasswordPath, "reset-sample"),
+	},
+	email.TemplateResetPayPassword: {
+		"Code": "reset-pay-sample",
+		"Link": email.PageLink(config.Email.ResetPayPasswordPath, "reset-pay-sample"),
+	},
+	email.TemplateNotice: {
+		"Title":          "转账到账通知",
+		"Content":        "您收到了一笔 100 CNY 的转账",
+		"UnsubscribeURL": config.User.Domain + "/v1/unsubscribe/sample?event=" + model.MessageEventTransferIn,
+	},
+}
+
+// 用示例数据渲染邮件模版, 用于管理员检查模版效果
+func Preview(context controller.Context, name string, input PreviewQuery) (res schema.Response) {
+	var (
+		err  error
+		data schema.EmailPreview
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminEmail); err != nil {
+		return
+	}
+
+	vars, ok := samples[name]
+
+	if !ok {
+		err = exception.EmailTemplateNotExist
+		return
+	}
+
+	c, err := email.Render(input.Locale, name, vars)
+
+	if err != nil {
+		return
+	}
+
+	data.Name = name
+	data.Locale = c.Locale
+	data.Subject = c.Subject
+	data.HTML = c.HTML
+	data.Text = c.Text
+
+	return
+}
+
+func PreviewRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input PreviewQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Preview(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param(ParamsTemplateName), input)
+}
@@ -4,16 +4,15 @@ package email
 import (
 	"errors"
 	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/message_queue"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/axetroy/go-server/src/service/email"
-	"github.com/axetroy/go-server/src/service/redis"
 	"github.com/axetroy/go-server/src/util"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"net/http"
-	"time"
 )
 
 type SendResetPasswordEmailParams struct {
@@ -28,8 +27,9 @@ func GenerateResetCode(uid string) string {
 
 func SendResetPasswordEmail(input SendResetPasswordEmailParams) (res schema.Response) {
 	var (
-		err error
-		tx  *gorm.DB
+		err        error
+		tx         *gorm.DB
+		resetEmail *model.EmailLog
 	)
 
 	defer func() {
@@ -59,6 +59,11 @@ func SendResetPasswordEmail(input SendResetPasswordEmailParams) (res schema.Resp
 		} else {
 			res.Data = true
 			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再加入消息队列, 由消息队列生成重置码并发送
+			if resetEmail != nil {
+				_ = message_queue.PublishEmail(*resetEmail)
+			}
 		}
 	}()
 
@@ -75,25 +80,15 @@ func SendResetPasswordEmail(input SendResetPasswordEmailParams) (res schema.Resp
 		return
 	}
 
-	// 生成重置码
-	var code = GenerateResetCode(userInfo.Id)
+	log, err := message_queue.CreateEmail(tx, userInfo.Id, input.To, "", email.TemplateResetPassword, nil)
 
-	// set activationCode to redis
-	if err = redis.ResetCodeClient.Set(code, userInfo.Id, time.Minute*30).Err(); err != nil {
+	if err != nil {
 		return
 	}
 
-	e := email.NewMailer()
-
-	// send email
-	if err = e.SendForgotPasswordEmail(input.To, code); err != nil {
-		// 邮件没发出去的话，删除redis的key
-		_ = redis.ResetCodeClient.Del(code).Err()
-		return
-	}
+	resetEmail = &log
 
 	return
-
 }
 
 func SendResetPasswordEmailRouter(context *gin.Context) {
@@ -0,0 +1,178 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/currency"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 按照报价兑换
+// 扣除源币种和增加目标币种在同一个事务中完成, 两条流水都以报价ID作为订单ID
+func Execute(context controller.Context, quoteId string) (res schema.Response) {
+	var (
+		err  error
+		data schema.ExchangeQuote
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	quote := model.ExchangeQuote{}
+
+	// 锁定报价, 防止同一个报价被重复兑换
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ? AND uid = ?", quoteId, context.Uid).First(&quote).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.ExchangeQuoteNotExist
+		}
+		return
+	}
+
+	if quote.Status == model.ExchangeQuoteStatusExecuted {
+		err = exception.ExchangeQuoteExecuted
+		return
+	}
+
+	if quote.ExpiredAt.Before(time.Now()) {
+		err = exception.ExchangeQuoteExpired
+		return
+	}
+
+	// 报价之后币种可能被停用
+	if _, err = currency.Get(tx, quote.From); err != nil {
+		return
+	}
+
+	if _, err = currency.Get(tx, quote.To); err != nil {
+		return
+	}
+
+	// 同一个用户的两个钱包也需要按固定的顺序加锁
+	locked, err := wallet.LockAll(tx, wallet.LockKey{Currency: quote.From, Uid: quote.Uid}, wallet.LockKey{Currency: quote.To, Uid: quote.Uid})
+
+	if err != nil {
+		return
+	}
+
+	fromWallet := locked[strings.ToUpper(quote.From)][quote.Uid]
+	toWallet := locked[strings.ToUpper(quote.To)][quote.Uid]
+
+	if fromWallet.Balance.LessThan(quote.Amount) {
+		err = exception.NotEnoughBalance
+		return
+	}
+
+	fromBefore := *fromWallet
+	toBefore := *toWallet
+
+	fromWallet.Balance = fromWallet.Balance.Sub(quote.Amount)
+	toWallet.Balance = toWallet.Balance.Add(quote.Receive)
+
+	if err = wallet.Update(tx, quote.From, fromWallet); err != nil {
+		return
+	}
+
+	if err = wallet.Update(tx, quote.To, toWallet); err != nil {
+		return
+	}
+
+	if err = finance.CreateLog(tx, quote.From, fromBefore, *fromWallet, quote.Id, model.FinanceTypeExchangeOut, nil); err != nil {
+		return
+	}
+
+	if err = finance.CreateLog(tx, quote.To, toBefore, *toWallet, quote.Id, model.FinanceTypeExchangeIn, nil); err != nil {
+		return
+	}
+
+	// 不同币种不能在同一张凭证中平衡, 所以通过兑换系统账户分别记账
+	if _, err = ledger.Post(tx, quote.From, quote.Id, model.FinanceTypeExchangeOut, nil,
+		ledger.Debit(quote.Uid, model.LedgerBucketBalance, quote.Amount),
+		ledger.Credit(model.LedgerAccountExchange, model.LedgerBucketBalance, quote.Amount),
+	); err != nil {
+		return
+	}
+
+	legs := []ledger.Leg{
+		ledger.Debit(model.LedgerAccountExchange, model.LedgerBucketBalance, quote.Gross),
+		ledger.Credit(quote.Uid, model.LedgerBucketBalance, quote.Receive),
+	}
+
+	if quote.Fee.IsPositive() {
+		legs = append(legs, ledger.Credit(model.LedgerAccountFee, model.LedgerBucketBalance, quote.Fee))
+	}
+
+	if _, err = ledger.Post(tx, quote.To, quote.Id, model.FinanceTypeExchangeIn, nil, legs...); err != nil {
+		return
+	}
+
+	quote.Status = model.ExchangeQuoteStatusExecuted
+
+	if err = tx.Model(&quote).UpdateColumn("status", quote.Status).Error; err != nil {
+		return
+	}
+
+	mapQuoteToSchema(quote, &data)
+
+	return
+}
+
+func ExecuteRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Execute(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("quote_id"))
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exchange_test
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/exchange"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type fixedSource struct {
+	rate decimal.Decimal
+}
+
+func (s fixedSource) Rate(from string, to string) (decimal.Decimal, error) {
+	return s.rate, nil
+}
+
+func useFixedSource(rate string) func() {
+	source := exchange.Source
+	spread := config.Exchange.Spread
+	feeRate := config.Exchange.FeeRate
+
+	exchange.Source = fixedSource{rate: decimal.RequireFromString(rate)}
+	config.Exchange.Spread = decimal.Zero
+	config.Exchange.FeeRate = decimal.RequireFromString("0.001")
+
+	return func() {
+		exchange.Source = source
+		config.Exchange.Spread = spread
+		config.Exchange.FeeRate = feeRate
+	}
+}
+
+func TestQuote(t *testing.T) {
+	defer useFixedSource("7")()
+
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	r := exchange.Quote(controller.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+		From:   model.WalletUSD,
+		To:     model.WalletCNY,
+		Amount: "10",
+	})
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+
+	data := schema.ExchangeQuote{}
+
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, "70", data.Gross)
+	assert.Equal(t, "0.07", data.Fee)
+	assert.Equal(t, "69.93", data.Receive)
+	assert.Equal(t, model.ExchangeQuoteStatusPending, data.Status)
+
+	// 相同币种
+	r = exchange.Quote(controller.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+		From:   model.WalletCNY,
+		To:     model.WalletCNY,
+		Amount: "10",
+	})
+
+	assert.Equal(t, exception.ExchangeSameCurrency.Error(), r.Message)
+
+	// 兑换数量太少, 到账数量为 0
+	r = exchange.Quote(controller.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+		From:   model.WalletUSD,
+		To:     model.WalletCNY,
+		Amount: "0.001",
+	})
+
+	assert.Equal(t, exception.ExchangeAmountTooSmall.Error(), r.Message)
+}
+
+func TestExecute(t *testing.T) {
+	defer useFixedSource("7")()
+
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	r := exchange.Quote(controller.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+		From:   model.WalletUSD,
+		To:     model.WalletCNY,
+		Amount: "10",
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	quote := schema.ExchangeQuote{}
+
+	assert.Nil(t, tester.Decode(r.Data, &quote))
+
+	defer database.DeleteRowByTable("exchange_quote", "uid", userInfo.Id)
+
+	// 余额不足
+	r = exchange.Execute(controller.Context{Uid: userInfo.Id}, quote.Id)
+
+	assert.Equal(t, exception.NotEnoughBalance.Error(), r.Message)
+
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userInfo.Id, model.WalletUSD).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletUSD,
+	}).Error)
+
+	// 别人的报价
+	r = exchange.Execute(controller.Context{Uid: "123"}, quote.Id)
+
+	assert.Equal(t, exception.ExchangeQuoteNotExist.Error(), r.Message)
+
+	r = exchange.Execute(controller.Context{Uid: userInfo.Id}, quote.Id)
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+
+	// 检查两个钱包的余额
+	usd := schema.Wallet{}
+	cny := schema.Wallet{}
+
+	assert.Nil(t, tester.Decode(wallet.GetWallet(controller.Context{Uid: userInfo.Id}, model.WalletUSD).Data, &usd))
+	assert.Nil(t, tester.Decode(wallet.GetWallet(controller.Context{Uid: userInfo.Id}, model.WalletCNY).Data, &cny))
+	assert.True(t, decimal.RequireFromString(usd.Balance).Equal(decimal.New(90, 0)))
+	assert.True(t, decimal.RequireFromString(cny.Balance).Equal(decimal.RequireFromString("69.93")))
+
+	// 不能重复兑换
+	r = exchange.Execute(controller.Context{Uid: userInfo.Id}, quote.Id)
+
+	assert.Equal(t, exception.ExchangeQuoteExecuted.Error(), r.Message)
+
+	// 过期的报价
+	r = exchange.Quote(controller.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+		From:   model.WalletUSD,
+		To:     model.WalletCNY,
+		Amount: "10",
+	})
+
+	assert.Nil(t, tester.Decode(r.Data, &quote))
+	assert.Nil(t, database.Db.Model(&model.ExchangeQuote{}).Where("id = ?", quote.Id).UpdateColumn("expired_at", time.Now().Add(-time.Minute)).Error)
+
+	r = exchange.Execute(controller.Context{Uid: userInfo.Id}, quote.Id)
+
+	assert.Equal(t, exception.ExchangeQuoteExpired.Error(), r.Message)
+}
+
+func TestSetRate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+
+	defer database.DeleteRowByTable("exchange_rate", "updater", adminInfo.Id)
+
+	r := exchange.SetRate(controller.Context{Uid: adminInfo.Id}, exchange.SetRateParams{
+		From: model.WalletUSD,
+		To:   model.WalletCNY,
+		Rate: "7.1",
+	})
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+
+	// 反方向使用倒数
+	rate, err := (&exchange.StaticSource{}).Rate(model.WalletCNY, model.WalletUSD)
+
+	assert.Nil(t, err)
+	assert.True(t, rate.Mul(decimal.RequireFromString("7.1")).Round(8).Equal(decimal.New(1, 0)))
+
+	r = exchange.SetRate(controller.Context{Uid: adminInfo.Id}, exchange.SetRateParams{
+		From: model.WalletUSD,
+		To:   model.WalletCNY,
+		Rate: "-1",
+	})
+
+	assert.Equal(t, exception.InvalidExchangeRate.Error(), r.Message)
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/currency"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type QuoteParams struct {
+	From   string `json:"from" valid:"required~请选择源币种"`    // 源币种
+	To     string `json:"to" valid:"required~请选择目标币种"`     // 目标币种
+	Amount string `json:"amount" valid:"required~请输入兑换数量"` // 兑换的源币种数量
+}
+
+// 获取兑换报价, 报价在有效期内锁定汇率
+func Quote(context controller.Context, input QuoteParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.ExchangeQuote
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	// 参数校验
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	from, err := currency.Get(tx, input.From)
+
+	if err != nil {
+		return
+	}
+
+	to, err := currency.Get(tx, input.To)
+
+	if err != nil {
+		return
+	}
+
+	if from.Code == to.Code {
+		err = exception.ExchangeSameCurrency
+		return
+	}
+
+	amount, err := util.ParseAmount(input.Amount, from.Scale)
+
+	if err != nil {
+		return
+	}
+
+	mid, err := Source.Rate(from.Code, to.Code)
+
+	if err != nil {
+		return
+	}
+
+	rate, gross, fee, receive := calculate(amount, mid, config.Exchange.Spread, config.Exchange.FeeRate, to.Scale)
+
+	if !receive.IsPositive() {
+		err = exception.ExchangeAmountTooSmall
+		return
+	}
+
+	quote := model.ExchangeQuote{
+		Uid:       context.Uid,
+		From:      from.Code,
+		To:        to.Code,
+		Amount:    amount,
+		Rate:      rate,
+		Gross:     gross,
+		Fee:       fee,
+		Receive:   receive,
+		Status:    model.ExchangeQuoteStatusPending,
+		ExpiredAt: time.Now().Add(config.Exchange.QuoteTTL),
+	}
+
+	if err = tx.Create(&quote).Error; err != nil {
+		return
+	}
+
+	mapQuoteToSchema(quote, &data)
+
+	return
+}
+
+func QuoteRouter(context *gin.Context) {
+	var (
+		input QuoteParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Quote(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
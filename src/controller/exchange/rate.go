@@ -0,0 +1,229 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"net/http"
+)
+
+type SetRateParams struct {
+	From string `json:"from" valid:"required~请选择源币种"` // 源币种
+	To   string `json:"to" valid:"required~请选择目标币种"`  // 目标币种
+	Rate string `json:"rate" valid:"required~请输入汇率"`  // 中间价, 1 个源币种可以兑换多少目标币种
+}
+
+// 管理员获取汇率表
+func GetRates(context controller.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.ExchangeRate, 0)
+		list = make([]model.ExchangeRate, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminExchangeRateUpdate); err != nil {
+		return
+	}
+
+	if err = database.Db.Order(`"from" ASC, "to" ASC`).Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.ExchangeRate{}
+		mapRateToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	return
+}
+
+// 管理员设置汇率, 不存在则创建
+// 只设置了单向汇率时, 反向兑换会使用它的倒数
+func SetRate(context controller.Context, input SetRateParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.ExchangeRate
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	// 参数校验
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	rate, er := decimal.NewFromString(input.Rate)
+
+	if er != nil || !rate.IsPositive() {
+		err = exception.InvalidExchangeRate
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminExchangeRateUpdate); err != nil {
+		return
+	}
+
+	// 停用的币种也允许预先设置汇率
+	from := model.Currency{}
+	to := model.Currency{}
+
+	if err = tx.Where("code = ?", input.From).First(&from).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.CurrencyNotExist
+		}
+		return
+	}
+
+	if err = tx.Where("code = ?", input.To).First(&to).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.CurrencyNotExist
+		}
+		return
+	}
+
+	if from.Code == to.Code {
+		err = exception.ExchangeSameCurrency
+		return
+	}
+
+	info := model.ExchangeRate{}
+
+	if err = tx.Where(`"from" = ? AND "to" = ?`, from.Code, to.Code).First(&info).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+
+		info = model.ExchangeRate{
+			From:    from.Code,
+			To:      to.Code,
+			Rate:    rate,
+			Updater: context.Uid,
+		}
+
+		if err = tx.Create(&info).Error; err != nil {
+			return
+		}
+	} else {
+		if err = tx.Model(&info).Where(`"from" = ? AND "to" = ?`, from.Code, to.Code).Updates(map[string]interface{}{
+			"rate":    rate,
+			"updater": context.Uid,
+		}).Error; err != nil {
+			return
+		}
+	}
+
+	mapRateToSchema(info, &data)
+
+	return
+}
+
+func GetRatesRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetRates(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
+
+func SetRateRouter(context *gin.Context) {
+	var (
+		input SetRateParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = SetRate(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"net/url"
+)
+
+// 汇率的来源
+type RateSource interface {
+	// 获取中间价, 即 1 个 from 可以兑换多少个 to
+	Rate(from string, to string) (decimal.Decimal, error)
+}
+
+// 当前使用的汇率来源, 根据配置 EXCHANGE_SOURCE 选择, 测试时可以替换
+var Source = NewSource()
+
+func NewSource() RateSource {
+	switch config.Exchange.Source {
+	case config.ExchangeSourceHttp:
+		return &HttpSource{
+			URL:    config.Exchange.FeedURL,
+			Client: &http.Client{Timeout: config.Exchange.FeedTimeout},
+		}
+	default:
+		return &StaticSource{}
+	}
+}
+
+// 管理员维护的汇率表, 只设置了反方向汇率的时候取倒数
+type StaticSource struct {
+}
+
+func (s *StaticSource) Rate(from string, to string) (rate decimal.Decimal, err error) {
+	r := model.ExchangeRate{}
+
+	if err = database.Db.Where(`"from" = ? AND "to" = ?`, from, to).First(&r).Error; err == nil {
+		rate = r.Rate
+		return
+	} else if err != gorm.ErrRecordNotFound {
+		return
+	}
+
+	if err = database.Db.Where(`"from" = ? AND "to" = ?`, to, from).First(&r).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.ExchangeRateNotExist
+		}
+		return
+	}
+
+	rate = decimal.New(1, 0).DivRound(r.Rate, 16)
+
+	return
+}
+
+// 从 HTTP 接口获取汇率
+// 请求 GET {URL}?from=CNY&to=USD, 接口返回 {"rate": "0.14"}
+type HttpSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *HttpSource) Rate(from string, to string) (rate decimal.Decimal, err error) {
+	query := url.Values{}
+	query.Set("from", from)
+	query.Set("to", to)
+
+	response, err := s.Client.Get(s.URL + "?" + query.Encode())
+
+	if err != nil {
+		err = exception.ExchangeRateUnavailable
+		return
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		err = exception.ExchangeRateNotExist
+		return
+	}
+
+	if response.StatusCode != http.StatusOK {
+		err = exception.ExchangeRateUnavailable
+		return
+	}
+
+	body := struct {
+		Rate decimal.Decimal `json:"rate"`
+	}{}
+
+	if err = json.NewDecoder(response.Body).Decode(&body); err != nil {
+		err = exception.ExchangeRateUnavailable
+		return
+	}
+
+	if !body.Rate.IsPositive() {
+		err = exception.ExchangeRateUnavailable
+		return
+	}
+
+	rate = body.Rate
+
+	return
+}
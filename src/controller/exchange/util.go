@@ -0,0 +1,56 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+func mapQuoteToSchema(model model.ExchangeQuote, d *schema.ExchangeQuote) {
+	d.Id = model.Id
+	d.Uid = model.Uid
+	d.From = model.From
+	d.To = model.To
+	d.Amount = util.AmountToStr(model.Amount)
+	d.Rate = model.Rate.String()
+	d.Gross = util.AmountToStr(model.Gross)
+	d.Fee = util.AmountToStr(model.Fee)
+	d.Receive = util.AmountToStr(model.Receive)
+	d.Status = model.Status
+	d.ExpiredAt = model.ExpiredAt.Format(time.RFC3339Nano)
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+func mapRateToSchema(model model.ExchangeRate, d *schema.ExchangeRate) {
+	d.From = model.From
+	d.To = model.To
+	d.Rate = model.Rate.String()
+	d.Updater = model.Updater
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 向上取整到指定的精度
+func ceil(d decimal.Decimal, scale int32) decimal.Decimal {
+	t := d.Truncate(scale)
+
+	if t.LessThan(d) {
+		t = t.Add(decimal.New(1, -scale))
+	}
+
+	return t
+}
+
+// 根据中间价计算兑换结果
+// 汇率 = 中间价 * (1 - 点差), 兑换得到的数量向下取整, 手续费向上取整, 都按照目标币种的精度
+func calculate(amount decimal.Decimal, mid decimal.Decimal, spread decimal.Decimal, feeRate decimal.Decimal, scale int32) (rate decimal.Decimal, gross decimal.Decimal, fee decimal.Decimal, receive decimal.Decimal) {
+	rate = mid.Mul(decimal.New(1, 0).Sub(spread))
+	gross = amount.Mul(rate).Truncate(scale)
+	fee = ceil(gross.Mul(feeRate), scale)
+	receive = gross.Sub(fee)
+	return
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCalculate(t *testing.T) {
+	d := decimal.RequireFromString
+
+	rate, gross, fee, receive := calculate(d("100"), d("0.145"), d("0.01"), d("0.001"), 2)
+
+	assert.Equal(t, "0.14355", rate.String())
+	assert.Equal(t, "14.35", gross.String())
+	assert.Equal(t, "0.02", fee.String()) // 0.01435 向上取整
+	assert.Equal(t, "14.33", receive.String())
+
+	// 没有点差和手续费
+	rate, gross, fee, receive = calculate(d("1"), d("6.9"), decimal.Zero, decimal.Zero, 8)
+
+	assert.Equal(t, "6.9", rate.String())
+	assert.Equal(t, "6.9", gross.String())
+	assert.True(t, fee.IsZero())
+	assert.Equal(t, "6.9", receive.String())
+}
+
+func TestCeil(t *testing.T) {
+	d := decimal.RequireFromString
+
+	assert.Equal(t, "0.02", ceil(d("0.011"), 2).String())
+	assert.Equal(t, "0.01", ceil(d("0.01"), 2).String())
+	assert.Equal(t, "1", ceil(d("0.1"), 0).String())
+}
@@ -0,0 +1,228 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package finance
+
+import (
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+)
+
+var (
+	ChainBreakSequence   = "流水序号不连续, 可能有流水被删除或者插入"
+	ChainBreakPrevHash   = "与上一条流水的哈希不匹配"
+	ChainBreakHash       = "流水的内容与哈希不匹配, 可能被修改"
+	ChainBreakCheckpoint = "与检查点不一致, 可能有末尾的流水被删除"
+)
+
+type ChainQuery struct {
+	Uid      *string `json:"uid" form:"uid"`           // 只校验某个用户
+	Currency *string `json:"currency" form:"currency"` // 只校验某个币种
+}
+
+// 校验一个钱包的哈希链, 返回第一个断开的位置, 没有断开则返回 nil
+func verifyWallet(uid string, currency string) (broken *schema.FinanceChainBreak, count int, err error) {
+	rows, err := database.Db.Unscoped().Model(&model.FinanceLog{}).Where("uid = ? AND currency = ?", uid, currency).Order("sequence ASC, id ASC").Rows()
+
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	var (
+		sequence int64 = 1
+		prevHash       = ""
+		lastId         = ""
+	)
+
+	for rows.Next() {
+		log := model.FinanceLog{}
+
+		if err = database.Db.ScanRows(rows, &log); err != nil {
+			return
+		}
+
+		count = count + 1
+
+		reason := ""
+
+		if log.Sequence != sequence {
+			reason = ChainBreakSequence
+		} else if log.PrevHash != prevHash {
+			reason = ChainBreakPrevHash
+		} else if log.ComputeHash(config.Finance.HashSecret) != log.Hash {
+			reason = ChainBreakHash
+		}
+
+		if reason != "" {
+			broken = &schema.FinanceChainBreak{
+				Uid:      uid,
+				Currency: currency,
+				LogId:    log.Id,
+				Sequence: log.Sequence,
+				Reason:   reason,
+			}
+			return
+		}
+
+		sequence = sequence + 1
+		prevHash = log.Hash
+		lastId = log.Id
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	// 逐条校验只能发现中间的流水被修改, 末尾的流水被删除时需要与检查点对比
+	// 检查点的签名不正确时, 检查点可能被改成了之前的某条流水
+	checkpoint := model.FinanceCheckpoint{}
+	signed := true
+
+	if err = database.Db.Where("uid = ? AND currency = ?", uid, currency).First(&checkpoint).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+		err = nil
+	} else {
+		signed = checkpoint.Signature == checkpoint.Sign(config.Finance.HashSecret)
+	}
+
+	if checkpoint.Sequence != int64(count) || checkpoint.Hash != prevHash || !signed {
+		broken = &schema.FinanceChainBreak{
+			Uid:      uid,
+			Currency: currency,
+			LogId:    lastId,
+			Sequence: checkpoint.Sequence,
+			Reason:   ChainBreakCheckpoint,
+		}
+	}
+
+	return
+}
+
+// 校验流水的哈希链, 按钱包逐条重新计算哈希
+func VerifyChain(query ChainQuery) (report schema.FinanceChainReport, err error) {
+	type wallet struct {
+		Uid      string
+		Currency string
+	}
+
+	report.Broken = make([]schema.FinanceChainBreak, 0)
+
+	wallets := make([]wallet, 0)
+
+	conditions := []string{"1 = 1"}
+	args := make([]interface{}, 0)
+
+	if query.Uid != nil {
+		conditions = append(conditions, "uid = ?")
+		args = append(args, *query.Uid)
+	}
+
+	if query.Currency != nil {
+		conditions = append(conditions, "currency = ?")
+		args = append(args, strings.ToUpper(*query.Currency))
+	}
+
+	where := strings.Join(conditions, " AND ")
+
+	// 流水全部被删除的钱包只剩下检查点, 也需要校验
+	raw := fmt.Sprintf(`SELECT uid, currency FROM "finance_log" WHERE %s UNION SELECT uid, currency FROM "finance_checkpoint" WHERE %s ORDER BY uid, currency`, where, where)
+
+	if err = database.Db.Raw(raw, append(args, args...)...).Scan(&wallets).Error; err != nil {
+		return
+	}
+
+	for _, w := range wallets {
+		broken, count, er := verifyWallet(w.Uid, w.Currency)
+
+		if er != nil {
+			err = er
+			return
+		}
+
+		report.Wallets = report.Wallets + 1
+		report.Logs = report.Logs + count
+
+		if broken != nil {
+			report.Broken = append(report.Broken, *broken)
+		}
+	}
+
+	return
+}
+
+// 管理员校验流水的哈希链
+func VerifyChainByAdmin(context controller.Context, input ChainQuery) (res schema.Response) {
+	var (
+		err  error
+		data schema.FinanceChainReport
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminLedgerGet); err != nil {
+		return
+	}
+
+	data, err = VerifyChain(input)
+
+	return
+}
+
+func VerifyChainByAdminRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input ChainQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = VerifyChainByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package finance_test
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyChain(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+	config.Admin.WalletAdjustApproveThreshold = nil
+	defer func() {
+		config.Admin.WalletAdjustApproveThreshold = threshold
+	}()
+
+	for _, t1 := range []model.FinanceType{model.FinanceTypeAdminCredit, model.FinanceTypeFreeze, model.FinanceTypeUnfreeze} {
+		r := wallet.Adjust(controller.Context{Uid: adminInfo.Id}, wallet.AdjustParams{
+			Uid:      userInfo.Id,
+			Currency: model.WalletCNY,
+			Type:     t1,
+			Amount:   "10",
+			Reason:   "test",
+		})
+		assert.Equal(t, "", r.Message)
+	}
+
+	query := finance.ChainQuery{Uid: &userInfo.Id}
+
+	report, err := finance.VerifyChain(query)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Wallets)
+	assert.Equal(t, 3, report.Logs)
+	assert.Len(t, report.Broken, 0)
+
+	list := make([]model.FinanceLog, 0)
+
+	assert.Nil(t, database.Db.Where("uid = ?", userInfo.Id).Order("sequence ASC").Find(&list).Error)
+	assert.Len(t, list, 3)
+
+	// 修改第二条流水的内容
+	assert.Nil(t, database.Db.Model(&model.FinanceLog{}).Where("id = ?", list[1].Id).UpdateColumn("after_balance", decimal.New(100, 0)).Error)
+
+	report, err = finance.VerifyChain(query)
+
+	assert.Nil(t, err)
+
+	if assert.Len(t, report.Broken, 1) {
+		assert.Equal(t, list[1].Id, report.Broken[0].LogId)
+		assert.Equal(t, finance.ChainBreakHash, report.Broken[0].Reason)
+	}
+
+	// 删除第一条流水
+	assert.Nil(t, database.Db.Unscoped().Delete(&list[0]).Error)
+
+	report, err = finance.VerifyChain(query)
+
+	assert.Nil(t, err)
+
+	if assert.Len(t, report.Broken, 1) {
+		assert.Equal(t, list[1].Id, report.Broken[0].LogId)
+		assert.Equal(t, finance.ChainBreakSequence, report.Broken[0].Reason)
+	}
+}
+
+func TestVerifyChainTruncated(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+	config.Admin.WalletAdjustApproveThreshold = nil
+	defer func() {
+		config.Admin.WalletAdjustApproveThreshold = threshold
+	}()
+
+	for i := 0; i < 3; i++ {
+		r := wallet.Adjust(controller.Context{Uid: adminInfo.Id}, wallet.AdjustParams{
+			Uid:      userInfo.Id,
+			Currency: model.WalletCNY,
+			Type:     model.FinanceTypeAdminCredit,
+			Amount:   "10",
+			Reason:   "test",
+		})
+		assert.Equal(t, "", r.Message)
+	}
+
+	query := finance.ChainQuery{Uid: &userInfo.Id}
+
+	list := make([]model.FinanceLog, 0)
+
+	assert.Nil(t, database.Db.Where("uid = ?", userInfo.Id).Order("sequence ASC").Find(&list).Error)
+	assert.Len(t, list, 3)
+
+	checkpoint := model.FinanceCheckpoint{}
+
+	assert.Nil(t, database.Db.Where("uid = ? AND currency = ?", userInfo.Id, model.WalletCNY).First(&checkpoint).Error)
+	assert.Equal(t, int64(3), checkpoint.Sequence)
+	assert.Equal(t, list[2].Hash, checkpoint.Hash)
+
+	// 软删除最后一条流水
+	assert.Nil(t, database.Db.Delete(&list[2]).Error)
+
+	report, err := finance.VerifyChain(query)
+
+	assert.Nil(t, err)
+
+	if assert.Len(t, report.Broken, 1) {
+		assert.Equal(t, list[2].Id, report.Broken[0].LogId)
+		assert.Equal(t, finance.ChainBreakHash, report.Broken[0].Reason)
+	}
+
+	// 彻底删除最后一条流水, 剩下的哈希链是完整的, 只能通过检查点发现
+	assert.Nil(t, database.Db.Unscoped().Delete(&list[2]).Error)
+
+	report, err = finance.VerifyChain(query)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Logs)
+
+	if assert.Len(t, report.Broken, 1) {
+		assert.Equal(t, list[1].Id, report.Broken[0].LogId)
+		assert.Equal(t, int64(3), report.Broken[0].Sequence)
+		assert.Equal(t, finance.ChainBreakCheckpoint, report.Broken[0].Reason)
+	}
+}
+
+// 只能修改数据库时, 即使重新计算了哈希和检查点也能被发现
+func TestVerifyChainForged(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+	config.Admin.WalletAdjustApproveThreshold = nil
+	defer func() {
+		config.Admin.WalletAdjustApproveThreshold = threshold
+	}()
+
+	for i := 0; i < 3; i++ {
+		r := wallet.Adjust(controller.Context{Uid: adminInfo.Id}, wallet.AdjustParams{
+			Uid:      userInfo.Id,
+			Currency: model.WalletCNY,
+			Type:     model.FinanceTypeAdminCredit,
+			Amount:   "10",
+			Reason:   "test",
+		})
+		assert.Equal(t, "", r.Message)
+	}
+
+	query := finance.ChainQuery{Uid: &userInfo.Id}
+
+	list := make([]model.FinanceLog, 0)
+
+	assert.Nil(t, database.Db.Where("uid = ?", userInfo.Id).Order("sequence ASC").Find(&list).Error)
+	assert.Len(t, list, 3)
+
+	// 截断最后一条流水, 并把检查点改成剩下的最后一条流水
+	assert.Nil(t, database.Db.Unscoped().Delete(&list[2]).Error)
+	assert.Nil(t, database.Db.Model(&model.FinanceCheckpoint{}).Where("uid = ? AND currency = ?", userInfo.Id, model.WalletCNY).UpdateColumns(map[string]interface{}{
+		"sequence": list[1].Sequence,
+		"hash":     list[1].Hash,
+	}).Error)
+
+	report, err := finance.VerifyChain(query)
+
+	assert.Nil(t, err)
+
+	if assert.Len(t, report.Broken, 1) {
+		assert.Equal(t, finance.ChainBreakCheckpoint, report.Broken[0].Reason)
+	}
+
+	// 修改第二条流水的内容, 并用不带密钥的哈希重新计算
+	forged := list[1]
+	forged.AfterBalance = decimal.New(100, 0)
+
+	assert.Nil(t, database.Db.Model(&model.FinanceLog{}).Where("id = ?", forged.Id).UpdateColumns(map[string]interface{}{
+		"after_balance": forged.AfterBalance,
+		"hash":          forged.LegacyHash(),
+	}).Error)
+
+	report, err = finance.VerifyChain(query)
+
+	assert.Nil(t, err)
+
+	if assert.Len(t, report.Broken, 1) {
+		assert.Equal(t, forged.Id, report.Broken[0].LogId)
+		assert.Equal(t, finance.ChainBreakHash, report.Broken[0].Reason)
+	}
+}
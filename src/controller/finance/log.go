@@ -0,0 +1,65 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package finance
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// 根据钱包变动前后的状态, 生成一条财务日志
+func CreateLog(tx *gorm.DB, currency string, before model.Wallet, after model.Wallet, orderId string, financeType model.FinanceType, note *string) (err error) {
+	log := model.FinanceLog{
+		Currency:        strings.ToUpper(currency),
+		OrderId:         orderId,
+		Uid:             before.Id,
+		BeforeBalance:   before.Balance,
+		BalanceMutation: after.Balance.Sub(before.Balance),
+		AfterBalance:    after.Balance,
+		BeforeFrozen:    before.Frozen,
+		FrozenMutation:  after.Frozen.Sub(before.Frozen),
+		AfterFrozen:     after.Frozen,
+		Type:            financeType,
+		Note:            note,
+	}
+
+	// 同一个钱包的流水组成一条哈希链, 调用方已经锁定了钱包, 所以同一个钱包的流水不会并发写入
+	// 已删除的流水也在链上, 所以需要 Unscoped
+	prev := model.FinanceLog{}
+
+	if err = tx.Unscoped().Where("uid = ? AND currency = ?", log.Uid, log.Currency).Order("sequence DESC").First(&prev).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+		err = nil
+	}
+
+	log.Id = util.GenerateId()
+	log.Sequence = prev.Sequence + 1
+	log.PrevHash = prev.Hash
+	log.CreatedAt = time.Now().Truncate(time.Microsecond)
+	log.Hash = log.ComputeHash(config.Finance.HashSecret)
+
+	if err = tx.Create(&log).Error; err != nil {
+		return
+	}
+
+	// 更新钱包的检查点
+	checkpoint := model.FinanceCheckpoint{
+		Uid:      log.Uid,
+		Currency: log.Currency,
+		Sequence: log.Sequence,
+		Hash:     log.Hash,
+	}
+
+	checkpoint.Signature = checkpoint.Sign(config.Finance.HashSecret)
+
+	if err = tx.Exec(`INSERT INTO "finance_checkpoint" ("uid", "currency", "sequence", "hash", "signature", "updated_at") VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT ("uid", "currency") DO UPDATE SET "sequence" = EXCLUDED."sequence", "hash" = EXCLUDED."hash", "signature" = EXCLUDED."signature", "updated_at" = EXCLUDED."updated_at"`, checkpoint.Uid, checkpoint.Currency, checkpoint.Sequence, checkpoint.Hash, checkpoint.Signature, log.CreatedAt).Error; err != nil {
+		return
+	}
+
+	return
+}
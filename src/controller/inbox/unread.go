@@ -0,0 +1,89 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package inbox
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/message"
+	"github.com/axetroy/go-server/src/controller/notification"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+)
+
+// 获取用户每个分类未读的数量
+func GetUnread(context controller.Context) (res schema.Response) {
+	var (
+		err  error
+		data schema.InboxUnread
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	userInfo := model.User{
+		Id: context.Uid,
+	}
+
+	if err = database.Db.Where(&userInfo).First(&userInfo).Error; err != nil {
+		// 没有找到用户
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	if data.Message, err = message.CountUnread(database.Db, userInfo.Id); err != nil {
+		return
+	}
+
+	if data.Notification, err = notification.CountUnread(database.Db, userInfo); err != nil {
+		return
+	}
+
+	data.Total = data.Message + data.Notification
+
+	return
+}
+
+func GetUnreadRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetUnread(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
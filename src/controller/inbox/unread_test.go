@@ -0,0 +1,118 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package inbox_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/inbox"
+	"github.com/axetroy/go-server/src/controller/message"
+	"github.com/axetroy/go-server/src/controller/notification"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnread(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer database.DeleteRowByTable("message", "uid", userInfo.Id)
+	defer database.DeleteRowByTable("notification_mark", "uid", userInfo.Id)
+
+	adminContext := controller.Context{Uid: adminInfo.Id}
+	userContext := controller.Context{Uid: userInfo.Id}
+
+	ids := make([]string, 0)
+
+	for i := 0; i < 3; i++ {
+		r := message.Create(adminContext, message.CreateMessageParams{
+			Uid:     userInfo.Id,
+			Title:   "TestUnread",
+			Content: "TestUnread",
+		})
+
+		assert.Equal(t, "", r.Message)
+
+		n := model.Message{}
+
+		assert.Nil(t, tester.Decode(r.Data, &n))
+
+		ids = append(ids, n.Id)
+	}
+
+	// 只通知这个用户, 避免受到其他通知的影响
+	r := notification.Create(adminContext, notification.CreateParams{
+		Title:   "TestUnread",
+		Content: "TestUnread",
+		Target:  &notification.TargetParams{Users: []string{userInfo.Id}},
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	n := schema.Notification{}
+
+	assert.Nil(t, tester.Decode(r.Data, &n))
+
+	defer notification.DeleteNotificationById(n.Id)
+
+	unread := func() schema.InboxUnread {
+		u := schema.InboxUnread{}
+		r := inbox.GetUnread(userContext)
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &u))
+		return u
+	}
+
+	u := unread()
+
+	assert.Equal(t, int64(3), u.Message)
+	assert.Equal(t, u.Message+u.Notification, u.Total)
+
+	// 批量删除, 别人的消息不会被删除
+	r = message.DeleteBatchByUser(controller.Context{Uid: adminInfo.Id}, message.BatchDeleteParams{Ids: ids[:1]})
+	affected := schema.InboxAffected{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &affected))
+	assert.Equal(t, int64(0), affected.Count)
+
+	r = message.DeleteBatchByUser(userContext, message.BatchDeleteParams{Ids: ids[:1]})
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &affected))
+	assert.Equal(t, int64(1), affected.Count)
+
+	// 标记所有消息为已读
+	r = message.ReadAll(userContext)
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &affected))
+	assert.Equal(t, int64(2), affected.Count)
+
+	// 标记所有通知为已读
+	r = notification.ReadAll(userContext)
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &affected))
+	assert.True(t, affected.Count >= 1)
+
+	u = unread()
+
+	assert.Equal(t, int64(0), u.Total)
+
+	// 通知列表带出已读状态
+	list := notification.GetNotificationListByUser(userContext, notification.Query{})
+	data := make([]schema.Notification, 0)
+
+	assert.Equal(t, "", list.Message)
+	assert.Nil(t, tester.Decode(list.Data, &data))
+
+	for _, v := range data {
+		assert.True(t, v.Read)
+		assert.NotEqual(t, "", v.ReadAt)
+	}
+}
@@ -0,0 +1,446 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package invite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 按邀请人统计每个阶段的人数, 状态是递进的, 达到后面的状态也算作完成了前面的阶段
+const funnelSQL = `SELECT h.inviter, COALESCE(u.username, ''), COUNT(*) AS registered,
+	SUM(CASE WHEN h.status >= %d THEN 1 ELSE 0 END) AS auth,
+	SUM(CASE WHEN h.status >= %d THEN 1 ELSE 0 END) AS pay
+FROM "invite_history" h LEFT JOIN "user" u ON u.id = h.inviter
+WHERE %s
+GROUP BY h.inviter, u.username
+ORDER BY %s DESC, h.inviter ASC LIMIT ? OFFSET ?`
+
+// 同一个邀请人的被邀请人, 按照登陆成功时的 IP 分组
+const clusterSQL = `SELECT h.inviter, COALESCE(u.username, ''), l.last_ip, array_agg(DISTINCT h.invitee),
+	EXISTS (SELECT 1 FROM "login_log" il WHERE il.uid = h.inviter AND il.last_ip = l.last_ip AND il.command = %d AND il.deleted_at IS NULL)
+FROM "invite_history" h
+INNER JOIN "login_log" l ON l.uid = h.invitee AND l.command = %d AND l.deleted_at IS NULL
+LEFT JOIN "user" u ON u.id = h.inviter
+WHERE %s
+GROUP BY h.inviter, u.username, l.last_ip
+HAVING COUNT(DISTINCT h.invitee) >= ?`
+
+var (
+	// 排行榜可以按照这些字段排序
+	leaderboardFields = map[string]bool{
+		"registered": true,
+		"auth":       true,
+		"pay":        true,
+	}
+)
+
+type RangeQuery struct {
+	Start *string `json:"start" form:"start"` // 邀请时间的开始日期, 格式 2006-01-02
+	End   *string `json:"end" form:"end"`     // 邀请时间的结束日期, 格式 2006-01-02, 包括这一天
+}
+
+type FunnelQuery struct {
+	schema.Query
+	RangeQuery
+	Inviter *string `json:"inviter" form:"inviter"` // 指定邀请人
+}
+
+type LeaderboardQuery struct {
+	RangeQuery
+	By    string `json:"by" form:"by"`       // 排序的字段, registered/auth/pay, 默认 registered
+	Limit int    `json:"limit" form:"limit"` // 获取前几名, 默认 10
+}
+
+type ClusterQuery struct {
+	schema.Query
+	Inviter *string `json:"inviter" form:"inviter"` // 指定邀请人
+	Size    int     `json:"size" form:"size"`       // 多少个被邀请人使用同一个 IP 时视为可疑, 默认 INVITE_CLUSTER_SIZE
+}
+
+// 把日期范围转换成 SQL 条件
+func (q RangeQuery) where() (conditions []string, args []interface{}, err error) {
+	if q.Start != nil && *q.Start != "" {
+		start, er := time.ParseInLocation("2006-01-02", *q.Start, time.Local)
+
+		if er != nil {
+			err = exception.InvalidParams
+			return
+		}
+
+		conditions = append(conditions, "h.created_at >= ?")
+		args = append(args, start)
+	}
+
+	if q.End != nil && *q.End != "" {
+		end, er := time.ParseInLocation("2006-01-02", *q.End, time.Local)
+
+		if er != nil {
+			err = exception.InvalidParams
+			return
+		}
+
+		conditions = append(conditions, "h.created_at < ?")
+		args = append(args, end.AddDate(0, 0, 1))
+	}
+
+	return
+}
+
+func rate(a int64, b int64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return math.Round(float64(a)/float64(b)*10000) / 10000
+}
+
+// 按邀请人统计转化漏斗, orderBy 必须是 registered/auth/pay 中的一个
+func funnel(conditions []string, args []interface{}, orderBy string, limit int, offset int) (data []schema.InviteFunnel, err error) {
+	var rows *sql.Rows
+
+	data = make([]schema.InviteFunnel, 0)
+
+	conditions = append([]string{"h.deleted_at IS NULL"}, conditions...)
+
+	query := fmt.Sprintf(funnelSQL, model.StatusInviteAuth, model.StatusInvitePay, strings.Join(conditions, " AND "), orderBy)
+
+	if rows, err = database.Db.Raw(query, append(args, limit, offset)...).Rows(); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		d := schema.InviteFunnel{}
+
+		if err = rows.Scan(&d.Inviter, &d.Username, &d.Registered, &d.Auth, &d.Pay); err != nil {
+			return
+		}
+
+		d.AuthRate = rate(d.Auth, d.Registered)
+		d.PayRate = rate(d.Pay, d.Auth)
+
+		data = append(data, d)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// 管理员获取每个邀请人的转化漏斗
+func GetFunnel(context controller.Context, input FunnelQuery) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.InviteFunnel, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminInviteAnalytics); err != nil {
+		return
+	}
+
+	query := input.Query
+
+	query.Normalize()
+
+	conditions, args, err := input.RangeQuery.where()
+
+	if err != nil {
+		return
+	}
+
+	if input.Inviter != nil {
+		conditions = append(conditions, "h.inviter = ?")
+		args = append(args, *input.Inviter)
+	}
+
+	if data, err = funnel(conditions, args, "registered", query.Limit, query.Limit*query.Page); err != nil {
+		return
+	}
+
+	conditions = append([]string{"h.deleted_at IS NULL"}, conditions...)
+
+	if err = database.Db.Raw(`SELECT COUNT(DISTINCT h.inviter) FROM "invite_history" h WHERE `+strings.Join(conditions, " AND "), args...).Row().Scan(&meta.Total); err != nil {
+		return
+	}
+
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+// 管理员获取邀请排行榜
+func GetLeaderboard(context controller.Context, input LeaderboardQuery) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.InviteFunnel, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminInviteAnalytics); err != nil {
+		return
+	}
+
+	if input.By == "" {
+		input.By = "registered"
+	}
+
+	if !leaderboardFields[input.By] {
+		err = exception.InvalidParams
+		return
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = schema.DefaultLimit
+	} else if input.Limit > schema.MaxLimit {
+		input.Limit = schema.MaxLimit
+	}
+
+	conditions, args, err := input.RangeQuery.where()
+
+	if err != nil {
+		return
+	}
+
+	if data, err = funnel(conditions, args, input.By, input.Limit, 0); err != nil {
+		return
+	}
+
+	for i := range data {
+		data[i].Rank = i + 1
+	}
+
+	return
+}
+
+// 管理员获取可疑的邀请, 即同一个邀请人的多个被邀请人使用同一个 IP 登陆
+func GetClusters(context controller.Context, input ClusterQuery) (res schema.List) {
+	var (
+		err  error
+		rows *sql.Rows
+		data = make([]schema.InviteCluster, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if rows != nil {
+			_ = rows.Close()
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminInviteAnalytics); err != nil {
+		return
+	}
+
+	query := input.Query
+
+	query.Normalize()
+
+	size := input.Size
+
+	if size < 2 {
+		size = config.Invite.ClusterSize
+	}
+
+	conditions := []string{"h.deleted_at IS NULL"}
+	args := make([]interface{}, 0)
+
+	if input.Inviter != nil {
+		conditions = append(conditions, "h.inviter = ?")
+		args = append(args, *input.Inviter)
+	}
+
+	args = append(args, size)
+
+	clusters := fmt.Sprintf(clusterSQL, model.LoginLogCommandLoginSuccess, model.LoginLogCommandLoginSuccess, strings.Join(conditions, " AND "))
+
+	if err = database.Db.Raw(`SELECT COUNT(*) FROM (`+clusters+`) c`, args...).Row().Scan(&meta.Total); err != nil {
+		return
+	}
+
+	if rows, err = database.Db.Raw(clusters+` ORDER BY COUNT(DISTINCT h.invitee) DESC, h.inviter ASC LIMIT ? OFFSET ?`, append(args, query.Limit, query.Limit*query.Page)...).Rows(); err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var (
+			d        schema.InviteCluster
+			invitees pq.StringArray
+		)
+
+		if err = rows.Scan(&d.Inviter, &d.Username, &d.Ip, &invitees, &d.WithInviter); err != nil {
+			return
+		}
+
+		d.Invitees = invitees
+
+		data = append(data, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+func GetFunnelRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input FunnelQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetFunnel(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func GetLeaderboardRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input LeaderboardQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetLeaderboard(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func GetClustersRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input ClusterQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetClusters(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
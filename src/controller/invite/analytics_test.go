@@ -0,0 +1,265 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package invite_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// 直接写入邀请记录, 被邀请人不需要是真实的用户
+func seedInvites(t *testing.T, inviter string, createdAt time.Time, status ...model.InviteStatus) []string {
+	invitees := make([]string, 0, len(status))
+
+	for _, s := range status {
+		h := model.InviteHistory{
+			Inviter:   inviter,
+			Invitee:   util.GenerateId(),
+			Status:    s,
+			CreatedAt: createdAt,
+		}
+
+		assert.Nil(t, database.Db.Create(&h).Error)
+
+		invitees = append(invitees, h.Invitee)
+	}
+
+	return invitees
+}
+
+func seedLogin(t *testing.T, uid string, ip string, deleted bool) {
+	log := model.LoginLog{
+		Uid:     uid,
+		Command: model.LoginLogCommandLoginSuccess,
+		LastIp:  ip,
+	}
+
+	if deleted {
+		now := time.Now()
+		log.DeletedAt = &now
+	}
+
+	assert.Nil(t, database.Db.Create(&log).Error)
+}
+
+func TestGetFunnel(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	inviter, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(inviter.Username)
+	defer database.DeleteRowByTable("invite_history", "inviter", inviter.Id)
+
+	now := time.Now()
+
+	// 状态是递进的, 首次支付也算作完成了认证
+	seedInvites(t, inviter.Id, now, model.StatusInviteRegistered, model.StatusInviteAuth, model.StatusInvitePay, model.StatusInvitePay)
+
+	// 时间范围之外的邀请
+	seedInvites(t, inviter.Id, now.AddDate(0, 0, -10), model.StatusInvitePay)
+
+	get := func(query invite.FunnelQuery) (schema.List, []schema.InviteFunnel) {
+		query.Inviter = &inviter.Id
+
+		list := invite.GetFunnel(controller.Context{Uid: adminInfo.Id}, query)
+		data := make([]schema.InviteFunnel, 0)
+
+		assert.Equal(t, "", list.Message)
+		assert.Nil(t, tester.Decode(list.Data, &data))
+
+		return list, data
+	}
+
+	list, data := get(invite.FunnelQuery{})
+
+	assert.Equal(t, int64(1), list.Meta.Total)
+
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, inviter.Id, data[0].Inviter)
+		assert.Equal(t, inviter.Username, data[0].Username)
+		assert.Equal(t, int64(5), data[0].Registered)
+		assert.Equal(t, int64(4), data[0].Auth)
+		assert.Equal(t, int64(3), data[0].Pay)
+		assert.Equal(t, 0.8, data[0].AuthRate)
+		assert.Equal(t, 0.75, data[0].PayRate)
+		assert.Equal(t, 0, data[0].Rank)
+	}
+
+	// 只统计最近三天的邀请
+	start := now.AddDate(0, 0, -3).Format("2006-01-02")
+
+	_, data = get(invite.FunnelQuery{RangeQuery: invite.RangeQuery{Start: &start}})
+
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, int64(4), data[0].Registered)
+		assert.Equal(t, int64(3), data[0].Auth)
+		assert.Equal(t, int64(2), data[0].Pay)
+		assert.Equal(t, 0.75, data[0].AuthRate)
+		assert.Equal(t, 0.6667, data[0].PayRate)
+	}
+
+	// 结束日期包括这一天
+	end := now.AddDate(0, 0, -10).Format("2006-01-02")
+
+	_, data = get(invite.FunnelQuery{RangeQuery: invite.RangeQuery{End: &end}})
+
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, int64(1), data[0].Registered)
+		assert.Equal(t, int64(1), data[0].Pay)
+	}
+
+	// 日期格式错误
+	invalid := "2006/01/02"
+
+	list = invite.GetFunnel(controller.Context{Uid: adminInfo.Id}, invite.FunnelQuery{RangeQuery: invite.RangeQuery{Start: &invalid}})
+
+	assert.NotEqual(t, "", list.Message)
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	a, _ := tester.CreateUser()
+	b, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(a.Username)
+	defer auth.DeleteUserByUserName(b.Username)
+	defer database.DeleteRowByTable("invite_history", "inviter", a.Id)
+	defer database.DeleteRowByTable("invite_history", "inviter", b.Id)
+
+	// 使用一个很早的日期, 排行榜中只有这两个邀请人
+	day := time.Date(2001, 1, 1, 12, 0, 0, 0, time.Local)
+	date := day.Format("2006-01-02")
+
+	seedInvites(t, a.Id, day, model.StatusInviteRegistered, model.StatusInviteRegistered, model.StatusInvitePay)
+	seedInvites(t, b.Id, day, model.StatusInvitePay, model.StatusInvitePay)
+
+	get := func(by string) []schema.InviteFunnel {
+		r := invite.GetLeaderboard(controller.Context{Uid: adminInfo.Id}, invite.LeaderboardQuery{
+			RangeQuery: invite.RangeQuery{Start: &date, End: &date},
+			By:         by,
+		})
+		data := make([]schema.InviteFunnel, 0)
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &data))
+
+		return data
+	}
+
+	// 默认按注册人数排序
+	data := get("")
+
+	if assert.Len(t, data, 2) {
+		assert.Equal(t, a.Id, data[0].Inviter)
+		assert.Equal(t, 1, data[0].Rank)
+		assert.Equal(t, int64(3), data[0].Registered)
+		assert.Equal(t, b.Id, data[1].Inviter)
+		assert.Equal(t, 2, data[1].Rank)
+		assert.Equal(t, int64(2), data[1].Registered)
+	}
+
+	data = get("pay")
+
+	if assert.Len(t, data, 2) {
+		assert.Equal(t, b.Id, data[0].Inviter)
+		assert.Equal(t, 1, data[0].Rank)
+		assert.Equal(t, int64(2), data[0].Pay)
+		assert.Equal(t, a.Id, data[1].Inviter)
+		assert.Equal(t, 2, data[1].Rank)
+		assert.Equal(t, int64(1), data[1].Pay)
+	}
+
+	// 只取第一名
+	r := invite.GetLeaderboard(controller.Context{Uid: adminInfo.Id}, invite.LeaderboardQuery{
+		RangeQuery: invite.RangeQuery{Start: &date, End: &date},
+		By:         "auth",
+		Limit:      1,
+	})
+
+	data = make([]schema.InviteFunnel, 0)
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &data))
+
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, b.Id, data[0].Inviter)
+	}
+}
+
+func TestGetClusters(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	inviter, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(inviter.Username)
+	defer database.DeleteRowByTable("invite_history", "inviter", inviter.Id)
+	defer database.DeleteRowByTable("login_log", "uid", inviter.Id)
+
+	invitees := seedInvites(t, inviter.Id, time.Now(), model.StatusInviteRegistered, model.StatusInviteRegistered, model.StatusInviteRegistered, model.StatusInviteRegistered)
+
+	for _, uid := range invitees {
+		defer database.DeleteRowByTable("login_log", "uid", uid)
+	}
+
+	// 前三个被邀请人使用同一个 IP, 邀请人自己也用过这个 IP. 同一个用户多次登陆只算一次
+	seedLogin(t, inviter.Id, "10.1.0.1", false)
+	seedLogin(t, invitees[0], "10.1.0.1", false)
+	seedLogin(t, invitees[0], "10.1.0.1", false)
+	seedLogin(t, invitees[1], "10.1.0.1", false)
+	seedLogin(t, invitees[2], "10.1.0.1", false)
+
+	// 两个被邀请人使用另一个 IP, 其中一条登陆记录已删除
+	seedLogin(t, invitees[2], "10.1.0.2", false)
+	seedLogin(t, invitees[3], "10.1.0.2", true)
+
+	get := func(size int) (schema.List, []schema.InviteCluster) {
+		list := invite.GetClusters(controller.Context{Uid: adminInfo.Id}, invite.ClusterQuery{Inviter: &inviter.Id, Size: size})
+		data := make([]schema.InviteCluster, 0)
+
+		assert.Equal(t, "", list.Message)
+		assert.Nil(t, tester.Decode(list.Data, &data))
+
+		return list, data
+	}
+
+	list, data := get(2)
+
+	assert.Equal(t, int64(1), list.Meta.Total)
+
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, inviter.Id, data[0].Inviter)
+		assert.Equal(t, inviter.Username, data[0].Username)
+		assert.Equal(t, "10.1.0.1", data[0].Ip)
+		assert.ElementsMatch(t, invitees[:3], data[0].Invitees)
+		assert.True(t, data[0].WithInviter)
+	}
+
+	// 第四个被邀请人恢复登陆记录之后, 另一个 IP 也达到了阈值, 人数多的排在前面
+	assert.Nil(t, database.Db.Unscoped().Model(&model.LoginLog{}).Where("uid = ?", invitees[3]).UpdateColumn("deleted_at", nil).Error)
+
+	list, data = get(2)
+
+	assert.Equal(t, int64(2), list.Meta.Total)
+
+	if assert.Len(t, data, 2) {
+		assert.Equal(t, "10.1.0.1", data[0].Ip)
+		assert.Equal(t, "10.1.0.2", data[1].Ip)
+		assert.ElementsMatch(t, []string{invitees[2], invitees[3]}, data[1].Invitees)
+		assert.False(t, data[1].WithInviter)
+	}
+
+	// 提高阈值之后只剩下三个人的分组
+	list, data = get(3)
+
+	assert.Equal(t, int64(1), list.Meta.Total)
+
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, "10.1.0.1", data[0].Ip)
+	}
+}
@@ -0,0 +1,557 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package invite
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type SetRewardRuleParams struct {
+	Milestone     model.InviteStatus `json:"milestone"`                       // 里程碑, 0 注册, 10 认证, 50 首次支付
+	Currency      string             `json:"currency" valid:"required~请选择币种"` // 奖励的币种
+	InviterAmount *string            `json:"inviter_amount"`                  // 邀请人的奖励, 为空表示没有奖励
+	InviteeAmount *string            `json:"invitee_amount"`                  // 被邀请人的奖励, 为空表示没有奖励
+	Enabled       *bool              `json:"enabled"`                         // 是否启用, 默认启用
+}
+
+type RewardRuleQuery struct {
+	Milestone *model.InviteStatus `json:"milestone" form:"milestone"` // 指定里程碑
+	Currency  *string             `json:"currency" form:"currency"`   // 指定币种
+}
+
+type RewardQuery struct {
+	schema.Query
+	Milestone *model.InviteStatus `json:"milestone" form:"milestone"` // 指定里程碑
+	Currency  *string             `json:"currency" form:"currency"`   // 指定币种
+}
+
+type RewardQueryAdmin struct {
+	RewardQuery
+	Inviter *string `json:"inviter" form:"inviter"` // 指定邀请人
+	Invitee *string `json:"invitee" form:"invitee"` // 指定被邀请人
+}
+
+func mapRuleToSchema(model model.InviteRewardRule, d *schema.InviteRewardRule) {
+	d.Id = model.Id
+	d.Milestone = model.Milestone
+	d.Currency = model.Currency
+	d.InviterAmount = util.AmountToStr(model.InviterAmount)
+	d.InviteeAmount = util.AmountToStr(model.InviteeAmount)
+	d.Enabled = model.Enabled
+	d.Updater = model.Updater
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+func mapRewardToSchema(model model.InviteReward, d *schema.InviteReward) {
+	d.Id = model.Id
+	d.InviteId = model.InviteId
+	d.Milestone = model.Milestone
+	d.Currency = model.Currency
+	d.Inviter = model.Inviter
+	d.Invitee = model.Invitee
+	d.InviterAmount = util.AmountToStr(model.InviterAmount)
+	d.InviteeAmount = util.AmountToStr(model.InviteeAmount)
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 查询奖励记录, 公共部分
+func listRewards(db *gorm.DB, input RewardQuery) (data []schema.InviteReward, meta schema.Meta, err error) {
+	var (
+		total int64
+		list  = make([]model.InviteReward, 0)
+	)
+
+	data = make([]schema.InviteReward, 0)
+
+	query := input.Query
+
+	query.Normalize()
+
+	if input.Milestone != nil {
+		db = db.Where("milestone = ?", *input.Milestone)
+	}
+
+	if input.Currency != nil {
+		db = db.Where("currency = ?", strings.ToUpper(*input.Currency))
+	}
+
+	if err = db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = db.Model(model.InviteReward{}).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.InviteReward{}
+		mapRewardToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+// 管理员获取邀请奖励规则
+func GetRewardRules(context controller.Context, input RewardRuleQuery) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.InviteRewardRule, 0)
+		list = make([]model.InviteRewardRule, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminInviteReward); err != nil {
+		return
+	}
+
+	filter := map[string]interface{}{}
+
+	if input.Milestone != nil {
+		filter["milestone"] = *input.Milestone
+	}
+
+	if input.Currency != nil {
+		filter["currency"] = strings.ToUpper(*input.Currency)
+	}
+
+	if err = database.Db.Where(filter).Order("milestone ASC, currency ASC").Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.InviteRewardRule{}
+		mapRuleToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	return
+}
+
+// 管理员设置邀请奖励规则, 同一个里程碑和币种只有一条规则, 已存在则覆盖
+// 修改规则只影响之后的结算, 已经发放的奖励不变
+func SetRewardRule(context controller.Context, input SetRewardRuleParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.InviteRewardRule
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	// 参数校验
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	if !isMilestone(input.Milestone) {
+		err = exception.InvalidInviteMilestone
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminInviteReward); err != nil {
+		return
+	}
+
+	c := model.Currency{}
+
+	if err = tx.Where("code = ?", strings.ToUpper(input.Currency)).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.CurrencyNotExist
+		}
+		return
+	}
+
+	rule := model.InviteRewardRule{
+		Milestone: input.Milestone,
+		Currency:  c.Code,
+		Enabled:   input.Enabled == nil || *input.Enabled,
+		Updater:   context.Uid,
+	}
+
+	for _, v := range []struct {
+		input *string
+		field *decimal.Decimal
+	}{
+		{input.InviterAmount, &rule.InviterAmount},
+		{input.InviteeAmount, &rule.InviteeAmount},
+	} {
+		if v.input == nil || *v.input == "" {
+			continue
+		}
+
+		if *v.field, err = util.ParseAmount(*v.input, c.Scale); err != nil {
+			return
+		}
+	}
+
+	// 两边都没有奖励的规则没有意义
+	if rule.InviterAmount.IsZero() && rule.InviteeAmount.IsZero() {
+		err = exception.InvalidInviteRewardRule
+		return
+	}
+
+	exist := model.InviteRewardRule{}
+
+	if err = tx.Where("milestone = ? AND currency = ?", rule.Milestone, rule.Currency).First(&exist).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+
+		if err = tx.Create(&rule).Error; err != nil {
+			return
+		}
+	} else {
+		rule.Id = exist.Id
+		rule.CreatedAt = exist.CreatedAt
+
+		if err = tx.Save(&rule).Error; err != nil {
+			return
+		}
+	}
+
+	mapRuleToSchema(rule, &data)
+
+	return
+}
+
+// 管理员删除邀请奖励规则
+func DeleteRewardRule(context controller.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.InviteRewardRule
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminInviteReward); err != nil {
+		return
+	}
+
+	rule := model.InviteRewardRule{}
+
+	if err = tx.Where("id = ?", id).First(&rule).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.InviteRewardRuleNotExist
+		}
+		return
+	}
+
+	if err = tx.Delete(&rule).Error; err != nil {
+		return
+	}
+
+	mapRuleToSchema(rule, &data)
+
+	return
+}
+
+// 用户获取自己得到的邀请奖励, 包括作为邀请人和被邀请人
+func GetRewards(context controller.Context, input RewardQuery) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.InviteReward, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	data, *meta, err = listRewards(database.Db.Where("inviter = ? OR invitee = ?", context.Uid, context.Uid), input)
+
+	return
+}
+
+// 管理员获取邀请奖励的发放记录
+func GetRewardsByAdmin(context controller.Context, input RewardQueryAdmin) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.InviteReward, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminInviteReward); err != nil {
+		return
+	}
+
+	db := database.Db
+
+	if input.Inviter != nil {
+		db = db.Where("inviter = ?", *input.Inviter)
+	}
+
+	if input.Invitee != nil {
+		db = db.Where("invitee = ?", *input.Invitee)
+	}
+
+	data, *meta, err = listRewards(db, input.RewardQuery)
+
+	return
+}
+
+func GetRewardRulesRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input RewardRuleQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetRewardRules(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func SetRewardRuleRouter(context *gin.Context) {
+	var (
+		input SetRewardRuleParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = SetRewardRule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func DeleteRewardRuleRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = DeleteRewardRule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("rule_id"))
+}
+
+func GetRewardsRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input RewardQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetRewards(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func GetRewardsByAdminRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input RewardQueryAdmin
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetRewardsByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package invite
+
+import (
+	"fmt"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+var (
+	// 里程碑在财务日志备注中的名字
+	milestoneNames = map[model.InviteStatus]string{
+		model.StatusInviteRegistered: "注册",
+		model.StatusInviteAuth:       "认证",
+		model.StatusInvitePay:        "首次支付",
+	}
+)
+
+func isMilestone(m model.InviteStatus) bool {
+	_, ok := milestoneNames[m]
+	return ok
+}
+
+// 每个里程碑单独记录是否已经结算, 与达到的顺序无关
+// 例如被邀请人先支付再实名认证, 认证的奖励仍然会结算
+func milestoneField(invite *model.InviteHistory, milestone model.InviteStatus) (column string, settledAt **time.Time) {
+	switch milestone {
+	case model.StatusInviteRegistered:
+		return "registered_at", &invite.RegisteredAt
+	case model.StatusInviteAuth:
+		return "auth_at", &invite.AuthAt
+	default:
+		return "paid_at", &invite.PaidAt
+	}
+}
+
+// 结算某个里程碑时需要锁定的钱包, 没有需要结算的奖励时返回空
+// 触发结算的事务如果还需要锁定其他的钱包, 需要先通过 wallet.LockAll 一起按顺序加锁, 否则可能会死锁
+// 钱包总是在邀请记录之前加锁, 和转账等先锁钱包的事务保持同样的顺序
+func RewardWallets(tx *gorm.DB, invitee string, milestone model.InviteStatus) (keys []wallet.LockKey, err error) {
+	invite := model.InviteHistory{}
+
+	if err = tx.Where("invitee = ?", invitee).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		return
+	}
+
+	if _, settledAt := milestoneField(&invite, milestone); *settledAt != nil {
+		return
+	}
+
+	currencies := make([]string, 0)
+
+	if err = tx.Model(&model.InviteRewardRule{}).Where("milestone = ? AND enabled = ?", milestone, true).Pluck("currency", &currencies).Error; err != nil {
+		return
+	}
+
+	for _, currency := range currencies {
+		keys = append(keys, wallet.LockKey{Currency: currency, Uid: invite.Inviter}, wallet.LockKey{Currency: currency, Uid: invite.Invitee})
+	}
+
+	return
+}
+
+// 被邀请人达到某个里程碑时结算邀请奖励, 在触发事件的事务中执行, 结算失败会导致整个事务回滚
+// 每个里程碑只在第一次达到时结算, 同一个邀请的同一个里程碑和币种只会发放一次奖励
+// 没有邀请人的用户直接跳过
+func Settle(tx *gorm.DB, invitee string, milestone model.InviteStatus) (err error) {
+	invite := model.InviteHistory{}
+
+	// 先锁定奖励的钱包再锁定邀请记录, 调用方已经锁定过的钱包在同一个事务中重复加锁不会阻塞
+	keys, err := RewardWallets(tx, invitee, milestone)
+
+	if err != nil {
+		return
+	}
+
+	if _, err = wallet.LockAll(tx, keys...); err != nil {
+		return
+	}
+
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("invitee = ?", invitee).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		return
+	}
+
+	column, settledAt := milestoneField(&invite, milestone)
+
+	if *settledAt != nil {
+		return
+	}
+
+	rules := make([]model.InviteRewardRule, 0)
+
+	if err = tx.Where("milestone = ? AND enabled = ?", milestone, true).Order("currency ASC").Find(&rules).Error; err != nil {
+		return
+	}
+
+	for _, rule := range rules {
+		var count int
+
+		if err = tx.Model(&model.InviteReward{}).Where("invite_id = ? AND milestone = ? AND currency = ?", invite.Id, milestone, rule.Currency).Count(&count).Error; err != nil {
+			return
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		reward := model.InviteReward{
+			InviteId:      invite.Id,
+			Milestone:     milestone,
+			Currency:      rule.Currency,
+			Inviter:       invite.Inviter,
+			Invitee:       invite.Invitee,
+			InviterAmount: rule.InviterAmount,
+			InviteeAmount: rule.InviteeAmount,
+		}
+
+		if err = tx.Create(&reward).Error; err != nil {
+			return
+		}
+
+		if err = pay(tx, reward); err != nil {
+			return
+		}
+	}
+
+	now := time.Now()
+	*settledAt = &now
+
+	updates := map[string]interface{}{
+		column: now,
+	}
+
+	if invite.Status < milestone {
+		updates["status"] = milestone
+	}
+
+	// 所有的里程碑都结算之后, 这个邀请的奖励就全部发放完了
+	if invite.RegisteredAt != nil && invite.AuthAt != nil && invite.PaidAt != nil {
+		updates["reward_settled"] = true
+	}
+
+	if err = tx.Model(&model.InviteHistory{}).Where("id = ?", invite.Id).Updates(updates).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+// 把一条奖励记到邀请人和被邀请人的钱包上, 资金来自系统的奖励账户
+func pay(tx *gorm.DB, reward model.InviteReward) (err error) {
+	wallets, err := wallet.Lock(tx, reward.Currency, reward.Inviter, reward.Invitee)
+
+	if err != nil {
+		return
+	}
+
+	note := fmt.Sprintf("邀请奖励: %s", milestoneNames[reward.Milestone])
+
+	total := decimal.Zero
+	legs := make([]ledger.Leg, 0)
+
+	for _, v := range []struct {
+		uid    string
+		amount decimal.Decimal
+	}{
+		{reward.Inviter, reward.InviterAmount},
+		{reward.Invitee, reward.InviteeAmount},
+	} {
+		if !v.amount.IsPositive() {
+			continue
+		}
+
+		w := wallets[v.uid]
+		before := *w
+
+		w.Balance = w.Balance.Add(v.amount)
+
+		if err = wallet.Update(tx, reward.Currency, w); err != nil {
+			return
+		}
+
+		if err = finance.CreateLog(tx, reward.Currency, before, *w, reward.Id, model.FinanceTypeInviteReward, &note); err != nil {
+			return
+		}
+
+		total = total.Add(v.amount)
+		legs = append(legs, ledger.Credit(v.uid, model.LedgerBucketBalance, v.amount))
+	}
+
+	if total.IsZero() {
+		return
+	}
+
+	legs = append(legs, ledger.Debit(model.LedgerAccountReward, model.LedgerBucketBalance, total))
+
+	if _, err = ledger.Post(tx, reward.Currency, reward.Id, model.FinanceTypeInviteReward, &note, legs...); err != nil {
+		return
+	}
+
+	return
+}
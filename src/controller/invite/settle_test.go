@@ -0,0 +1,149 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package invite_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/axetroy/go-server/src/service/redis"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSettle(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	inviter, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(inviter.Username)
+
+	setRule := func(milestone model.InviteStatus, inviterAmount string, inviteeAmount string) schema.InviteRewardRule {
+		data := schema.InviteRewardRule{}
+		r := invite.SetRewardRule(controller.Context{Uid: adminInfo.Id}, invite.SetRewardRuleParams{
+			Milestone:     milestone,
+			Currency:      model.WalletCNY,
+			InviterAmount: &inviterAmount,
+			InviteeAmount: &inviteeAmount,
+		})
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &data))
+		return data
+	}
+
+	registered := setRule(model.StatusInviteRegistered, "5", "1")
+	authed := setRule(model.StatusInviteAuth, "2", "")
+	pay := setRule(model.StatusInvitePay, "10", "")
+
+	defer invite.DeleteRewardRule(controller.Context{Uid: adminInfo.Id}, registered.Id)
+	defer invite.DeleteRewardRule(controller.Context{Uid: adminInfo.Id}, authed.Id)
+	defer invite.DeleteRewardRule(controller.Context{Uid: adminInfo.Id}, pay.Id)
+
+	// 两边都没有奖励的规则
+	zero := ""
+	r := invite.SetRewardRule(controller.Context{Uid: adminInfo.Id}, invite.SetRewardRuleParams{
+		Milestone:     model.StatusInviteAuth,
+		Currency:      model.WalletCNY,
+		InviterAmount: &zero,
+	})
+
+	assert.Equal(t, exception.InvalidInviteRewardRule.Error(), r.Message)
+
+	r = invite.SetRewardRule(controller.Context{Uid: adminInfo.Id}, invite.SetRewardRuleParams{
+		Milestone: 3,
+		Currency:  model.WalletCNY,
+	})
+
+	assert.Equal(t, exception.InvalidInviteMilestone.Error(), r.Message)
+
+	username := "test-TestSettle"
+
+	r = auth.SignUp(auth.SignUpParams{
+		Username:   &username,
+		Password:   "123123",
+		InviteCode: &inviter.InviteCode,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	defer auth.DeleteUserByUserName(username)
+
+	invitee := schema.Profile{}
+
+	assert.Nil(t, tester.Decode(r.Data, &invitee))
+
+	defer database.DeleteRowByTable("invite_reward", "invitee", invitee.Id)
+
+	getBalance := func(uid string) string {
+		w := model.Wallet{}
+		assert.Nil(t, database.Db.Where("id = ? AND currency = ?", uid, model.WalletCNY).First(&w).Error)
+		return w.Balance.String()
+	}
+
+	// 注册时还没有激活, 不发放奖励
+	assert.Equal(t, "0", getBalance(inviter.Id))
+	assert.Equal(t, "0", getBalance(invitee.Id))
+
+	activationCode, err := email.NewActivationCode()
+
+	assert.Nil(t, err)
+
+	assert.Nil(t, redis.ActivationCodeClient.Set(activationCode, invitee.Id, time.Minute*30).Err())
+
+	// 激活时发放注册的奖励
+	assert.Equal(t, "", auth.Activation(auth.ActivationParams{Code: activationCode}).Message)
+	assert.Equal(t, "5", getBalance(inviter.Id))
+	assert.Equal(t, "1", getBalance(invitee.Id))
+
+	// 重复结算不会重复发放
+	assert.Nil(t, invite.Settle(database.Db, invitee.Id, model.StatusInviteRegistered))
+	assert.Equal(t, "5", getBalance(inviter.Id))
+
+	// 首次转账发放奖励, 之后的转账不会再发放
+	for i := 0; i < 2; i++ {
+		r = transfer.To(controller.Context{Uid: invitee.Id}, transfer.ToParams{
+			Currency: model.WalletCNY,
+			To:       inviter.Id,
+			Amount:   "0.5",
+		})
+		assert.Equal(t, "", r.Message)
+	}
+
+	assert.Equal(t, "16", getBalance(inviter.Id))
+	assert.Equal(t, "0", getBalance(invitee.Id))
+
+	history := model.InviteHistory{}
+
+	assert.Nil(t, database.Db.Where("invitee = ?", invitee.Id).First(&history).Error)
+	assert.Equal(t, model.StatusInvitePay, history.Status)
+	assert.False(t, history.RewardSettled)
+
+	// 先支付再实名认证, 认证的奖励仍然发放
+	assert.Nil(t, invite.Settle(database.Db, invitee.Id, model.StatusInviteAuth))
+	assert.Equal(t, "18", getBalance(inviter.Id))
+
+	assert.Nil(t, invite.Settle(database.Db, invitee.Id, model.StatusInviteAuth))
+	assert.Equal(t, "18", getBalance(inviter.Id))
+
+	history = model.InviteHistory{}
+
+	assert.Nil(t, database.Db.Where("invitee = ?", invitee.Id).First(&history).Error)
+	assert.Equal(t, model.StatusInvitePay, history.Status)
+	assert.True(t, history.RewardSettled)
+
+	// 双方都能看到奖励记录
+	list := invite.GetRewards(controller.Context{Uid: inviter.Id}, invite.RewardQuery{})
+
+	assert.Equal(t, "", list.Message)
+	assert.Equal(t, int64(3), list.Meta.Total)
+
+	list = invite.GetRewards(controller.Context{Uid: invitee.Id}, invite.RewardQuery{})
+
+	assert.Equal(t, int64(3), list.Meta.Total)
+}
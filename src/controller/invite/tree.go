@@ -0,0 +1,243 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package invite
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// 从某个用户开始向下递归查询邀请关系, 最多查询指定的层数
+// 每个用户只会被邀请一次, 并且只能被比自己先注册的用户邀请, 所以不会有环
+const treeSQL = `WITH RECURSIVE tree AS (
+	SELECT invitee, inviter, status, created_at, 1 AS level FROM "invite_history" WHERE inviter = ? AND deleted_at IS NULL
+	UNION ALL
+	SELECT h.invitee, h.inviter, h.status, h.created_at, t.level + 1 FROM "invite_history" h INNER JOIN tree t ON h.inviter = t.invitee
+	WHERE t.level < ? AND h.deleted_at IS NULL
+) `
+
+type TreeQuery struct {
+	schema.Query
+	Uid   string `json:"uid" form:"uid"`     // 从哪个用户开始
+	Depth int    `json:"depth" form:"depth"` // 查询的层数, 默认并且最多为 INVITE_TREE_DEPTH
+}
+
+// 统计每一层下线的人数, 没有下线的层级人数为 0
+func downline(uid string, depth int) (data []schema.InviteDownline, err error) {
+	var rows *sql.Rows
+
+	data = make([]schema.InviteDownline, depth)
+
+	for i := range data {
+		data[i].Level = i + 1
+	}
+
+	if rows, err = database.Db.Raw(treeSQL+`SELECT level, COUNT(*) FROM tree GROUP BY level`, uid, depth).Rows(); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			level int
+			count int64
+		)
+
+		if err = rows.Scan(&level, &count); err != nil {
+			return
+		}
+
+		if level >= 1 && level <= depth {
+			data[level-1].Count = count
+		}
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// 获取邀请关系树, 按层级展开成列表
+func tree(uid string, depth int, query schema.Query) (data []schema.InviteTreeNode, total int64, err error) {
+	var rows *sql.Rows
+
+	data = make([]schema.InviteTreeNode, 0)
+
+	if err = database.Db.Raw(treeSQL+`SELECT COUNT(*) FROM tree`, uid, depth).Row().Scan(&total); err != nil {
+		return
+	}
+
+	if rows, err = database.Db.Raw(treeSQL+`SELECT tree.invitee, COALESCE(u.username, ''), tree.inviter, tree.level, tree.status, tree.created_at
+FROM tree LEFT JOIN "user" u ON u.id = tree.invitee
+ORDER BY tree.level ASC, tree.created_at ASC LIMIT ? OFFSET ?`, uid, depth, query.Limit, query.Limit*query.Page).Rows(); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			node      schema.InviteTreeNode
+			createdAt time.Time
+		)
+
+		if err = rows.Scan(&node.Uid, &node.Username, &node.Inviter, &node.Level, &node.Status, &createdAt); err != nil {
+			return
+		}
+
+		node.CreatedAt = createdAt.Format(time.RFC3339Nano)
+
+		data = append(data, node)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// 用户获取自己每一层下线的人数
+func GetDownline(context controller.Context) (res schema.Response) {
+	var (
+		err  error
+		data []schema.InviteDownline
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	data, err = downline(context.Uid, config.Invite.TreeDepth)
+
+	return
+}
+
+// 管理员查看某个用户的邀请关系树
+func GetTree(context controller.Context, input TreeQuery) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.InviteTreeNode, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminInviteAnalytics); err != nil {
+		return
+	}
+
+	if input.Uid == "" {
+		err = exception.InvalidParams
+		return
+	}
+
+	depth := input.Depth
+
+	if depth <= 0 || depth > config.Invite.TreeDepth {
+		depth = config.Invite.TreeDepth
+	}
+
+	query := input.Query
+
+	query.Normalize()
+
+	data, meta.Total, err = tree(input.Uid, depth, query)
+
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+func GetDownlineRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetDownline(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
+
+func GetTreeRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input TreeQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetTree(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package invite_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTreeAndAnalytics(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	root, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(root.Username)
+	defer database.DeleteRowByTable("invite_history", "inviter", root.Id)
+
+	signUp := func(username string, inviteCode string) schema.Profile {
+		profile := schema.Profile{}
+		r := auth.SignUp(auth.SignUpParams{
+			Username:   &username,
+			Password:   "123123",
+			InviteCode: &inviteCode,
+		})
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &profile))
+		return profile
+	}
+
+	// root 邀请了 a 和 b, a 邀请了 c
+	a := signUp("test-TestTree-a", root.InviteCode)
+	b := signUp("test-TestTree-b", root.InviteCode)
+	c := signUp("test-TestTree-c", a.InviteCode)
+
+	for _, u := range []schema.Profile{a, b, c} {
+		defer auth.DeleteUserByUserName(u.Username)
+		defer database.DeleteRowByTable("invite_history", "invitee", u.Id)
+		defer database.DeleteRowByTable("login_log", "uid", u.Id)
+	}
+
+	// 每一层下线的人数
+	r := invite.GetDownline(controller.Context{Uid: root.Id})
+	downline := make([]schema.InviteDownline, 0)
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &downline))
+	assert.Equal(t, int64(2), downline[0].Count)
+	assert.Equal(t, int64(1), downline[1].Count)
+	assert.Equal(t, int64(0), downline[2].Count)
+
+	// 管理员查看关系树, 只看一层
+	list := invite.GetTree(controller.Context{Uid: adminInfo.Id}, invite.TreeQuery{Uid: root.Id, Depth: 1})
+
+	assert.Equal(t, "", list.Message)
+	assert.Equal(t, int64(2), list.Meta.Total)
+
+	list = invite.GetTree(controller.Context{Uid: adminInfo.Id}, invite.TreeQuery{Uid: root.Id})
+	nodes := make([]schema.InviteTreeNode, 0)
+
+	assert.Equal(t, "", list.Message)
+	assert.Nil(t, tester.Decode(list.Data, &nodes))
+	assert.Len(t, nodes, 3)
+	assert.Equal(t, c.Id, nodes[2].Uid)
+	assert.Equal(t, a.Id, nodes[2].Inviter)
+	assert.Equal(t, 2, nodes[2].Level)
+
+	// 转化漏斗
+	list = invite.GetFunnel(controller.Context{Uid: adminInfo.Id}, invite.FunnelQuery{Inviter: &root.Id})
+	funnel := make([]schema.InviteFunnel, 0)
+
+	assert.Equal(t, "", list.Message)
+	assert.Nil(t, tester.Decode(list.Data, &funnel))
+	assert.Len(t, funnel, 1)
+	assert.Equal(t, int64(2), funnel[0].Registered)
+	assert.Equal(t, int64(0), funnel[0].Pay)
+
+	// 排行榜
+	r = invite.GetLeaderboard(controller.Context{Uid: adminInfo.Id}, invite.LeaderboardQuery{By: "pay"})
+
+	assert.Equal(t, "", r.Message)
+
+	r = invite.GetLeaderboard(controller.Context{Uid: adminInfo.Id}, invite.LeaderboardQuery{By: "unknown"})
+
+	assert.NotEqual(t, "", r.Message)
+
+	// a 和 b 使用同一个 IP 登陆
+	for _, u := range []schema.Profile{a, b} {
+		assert.Nil(t, database.Db.Create(&model.LoginLog{
+			Uid:     u.Id,
+			Command: model.LoginLogCommandLoginSuccess,
+			LastIp:  "10.0.0.1",
+		}).Error)
+	}
+
+	list = invite.GetClusters(controller.Context{Uid: adminInfo.Id}, invite.ClusterQuery{Inviter: &root.Id, Size: 2})
+	clusters := make([]schema.InviteCluster, 0)
+
+	assert.Equal(t, "", list.Message)
+	assert.Nil(t, tester.Decode(list.Data, &clusters))
+	assert.Len(t, clusters, 1)
+	assert.Equal(t, "10.0.0.1", clusters[0].Ip)
+	assert.ElementsMatch(t, []string{a.Id, b.Id}, clusters[0].Invitees)
+	assert.False(t, clusters[0].WithInviter)
+}
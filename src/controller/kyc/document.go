@@ -0,0 +1,200 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package kyc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-fs"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/controller/uploader"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// 证件图片支持的后缀名, 不支持 svg 等可以包含脚本的格式
+var documentExtNames = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
+func documentPath(filename string) string {
+	return path.Join(uploader.Config.Path, uploader.Config.Kyc.Path, filename)
+}
+
+// 证件图片必须是当前用户自己上传的文件
+func isOwnedDocument(tx *gorm.DB, uid string, filename string) (bool, error) {
+	var count int
+
+	if filename == "" {
+		return false, nil
+	}
+
+	if err := tx.Model(model.KycDocument{}).Where("uid = ? AND filename = ?", uid, filename).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// 保存上传的文件, 写入失败时删除不完整的文件
+func saveDocument(file *multipart.FileHeader, distPath string) (err error) {
+	var (
+		src  multipart.File
+		dist *os.File
+	)
+
+	if src, err = file.Open(); err != nil {
+		return
+	}
+
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if dist, err = os.Create(distPath); err != nil {
+		return
+	}
+
+	if _, err = io.Copy(dist, src); err != nil {
+		_ = dist.Close()
+		_ = os.Remove(distPath)
+		return
+	}
+
+	return dist.Close()
+}
+
+// 用户上传实名认证的证件图片, 存放在私有目录并记录上传的用户
+func UploadDocument(context controller.Context, file *multipart.FileHeader) (res schema.Response) {
+	var (
+		err  error
+		data schema.KycDocument
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	extname := strings.ToLower(path.Ext(file.Filename))
+
+	if !documentExtNames[extname] {
+		err = exception.NotSupportType
+		return
+	}
+
+	if maxSize := uploader.Config.Image.MaxSize; maxSize > 0 && file.Size > int64(maxSize) {
+		err = exception.OutOfSize
+		return
+	}
+
+	document := model.KycDocument{
+		Id:     util.GenerateId(),
+		Uid:    context.Uid,
+		Origin: file.Filename,
+		Size:   file.Size,
+	}
+
+	document.Filename = document.Id + extname
+
+	distPath := documentPath(document.Filename)
+
+	if err = saveDocument(file, distPath); err != nil {
+		return
+	}
+
+	if err = database.Db.Create(&document).Error; err != nil {
+		_ = os.Remove(distPath)
+		return
+	}
+
+	data.Filename = document.Filename
+	data.Origin = document.Origin
+	data.Size = document.Size
+	data.CreatedAt = document.CreatedAt.Format(time.RFC3339Nano)
+
+	return
+}
+
+// 管理员下载用户的证件图片, 返回文件的路径和下载时的文件名
+func OpenDocumentByAdmin(uid string, filename string) (filepath string, name string, err error) {
+	if _, err = admin.Check(database.Db, uid, *accession.AdminKycReview); err != nil {
+		return
+	}
+
+	document := model.KycDocument{}
+
+	if err = database.Db.Where("filename = ?", filename).First(&document).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.KycDocumentNotExist
+		}
+		return
+	}
+
+	filepath = documentPath(document.Filename)
+	name = fmt.Sprintf("%s-%s", document.Uid, document.Filename)
+
+	if !fs.PathExists(filepath) {
+		err = exception.KycDocumentNotExist
+	}
+
+	return
+}
+
+func UploadDocumentRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	file, err := context.FormFile("file")
+
+	if err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = UploadDocument(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, file)
+}
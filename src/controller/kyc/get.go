@@ -0,0 +1,269 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package kyc
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+)
+
+type Query struct {
+	schema.Query
+	Status *model.KycStatus `json:"status" form:"status"` // 指定状态
+}
+
+type QueryAdmin struct {
+	Query
+	Uid   *string `json:"uid" form:"uid"`     // 指定用户
+	Level *int32  `json:"level" form:"level"` // 指定认证等级
+}
+
+func list(input Query, filter map[string]interface{}) (data []schema.Kyc, meta schema.Meta, err error) {
+	var (
+		total int64
+		items = make([]model.Kyc, 0)
+	)
+
+	data = make([]schema.Kyc, 0)
+
+	query := input.Query
+
+	query.Normalize()
+
+	if input.Status != nil {
+		filter["status"] = *input.Status
+	}
+
+	if err = database.Db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(filter).Find(&items).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.Kyc{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range items {
+		d := schema.Kyc{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+// 用户获取自己的实名认证申请
+func GetKycList(context controller.Context, input Query) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.Kyc, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	data, *meta, err = list(input, map[string]interface{}{
+		"uid": context.Uid,
+	})
+
+	return
+}
+
+// 管理员获取实名认证申请, 只获取等待审核的申请即为审核队列
+func GetKycListByAdmin(context controller.Context, input QueryAdmin) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.Kyc, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminKycReview); err != nil {
+		return
+	}
+
+	filter := map[string]interface{}{}
+
+	if input.Uid != nil {
+		filter["uid"] = *input.Uid
+	}
+
+	if input.Level != nil {
+		filter["level"] = *input.Level
+	}
+
+	data, *meta, err = list(input.Query, filter)
+
+	return
+}
+
+// 管理员获取一个实名认证申请
+func GetKycByAdmin(context controller.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data = schema.Kyc{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminKycReview); err != nil {
+		return
+	}
+
+	kycInfo := model.Kyc{}
+
+	if err = database.Db.Where("id = ?", id).First(&kycInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.KycNotExist
+		}
+		return
+	}
+
+	mapToSchema(kycInfo, &data)
+
+	return
+}
+
+func GetKycListRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input Query
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetKycList(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func GetKycListByAdminRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input QueryAdmin
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetKycListByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func GetKycByAdminRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetKycByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("kyc_id"))
+}
@@ -0,0 +1,308 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package kyc_test
+
+import (
+	"bytes"
+	"github.com/axetroy/go-fs"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/currency"
+	"github.com/axetroy/go-server/src/controller/kyc"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/controller/uploader"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// 通过证件上传接口上传一张图片, 返回服务端的文件名
+func upload(t *testing.T, uid string, filename string) string {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", filename)
+
+	assert.Nil(t, err)
+
+	_, err = part.Write([]byte("image"))
+
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(1024)
+
+	assert.Nil(t, err)
+
+	r := kyc.UploadDocument(controller.Context{Uid: uid}, form.File["file"][0])
+	data := schema.KycDocument{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, filename, data.Origin)
+
+	p := path.Join(uploader.Config.Path, uploader.Config.Kyc.Path, data.Filename)
+
+	// 不在公开的图片目录中
+	assert.True(t, fs.PathExists(p))
+	assert.False(t, fs.PathExists(path.Join(uploader.Config.Path, uploader.Config.Image.Path, data.Filename)))
+
+	return data.Filename
+}
+
+func TestKyc(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	inviter, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(inviter.Username)
+
+	username := "test-TestKyc"
+
+	r := auth.SignUp(auth.SignUpParams{
+		Username:   &username,
+		Password:   "123123",
+		InviteCode: &inviter.InviteCode,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	userInfo := schema.Profile{}
+
+	assert.Nil(t, tester.Decode(r.Data, &userInfo))
+
+	defer auth.DeleteUserByUserName(username)
+	defer database.DeleteRowByTable("invite_history", "invitee", userInfo.Id)
+	defer database.DeleteRowByTable("kyc", "uid", userInfo.Id)
+
+	defer database.DeleteRowByTable("kyc_document", "uid", userInfo.Id)
+
+	front := upload(t, userInfo.Id, "front.png")
+	back := upload(t, userInfo.Id, "back.jpg")
+
+	// 其他用户上传的证件图片
+	other, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(other.Username)
+	defer database.DeleteRowByTable("kyc_document", "uid", other.Id)
+
+	stolen := upload(t, other.Id, "front.png")
+
+	for _, filename := range []string{front, back, stolen} {
+		defer os.Remove(path.Join(uploader.Config.Path, uploader.Config.Kyc.Path, filename))
+	}
+
+	// 不支持可以包含脚本的格式
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_, _ = writer.CreateFormFile("file", "front.svg")
+	assert.Nil(t, writer.Close())
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(1024)
+
+	assert.Nil(t, err)
+	assert.Equal(t, exception.NotSupportType.Error(), kyc.UploadDocument(controller.Context{Uid: userInfo.Id}, form.File["file"][0]).Message)
+
+	// 大额转账需要实名认证
+	code := "T" + strings.ToUpper(util.RandomString(6))
+	kycAmount := "100"
+
+	defer database.DeleteRowByTable("currency", "code", code)
+	defer database.DeleteRowByTable("wallet", "currency", code)
+
+	r = currency.Create(controller.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code:         code,
+		Name:         "测试币",
+		Scale:        2,
+		Transferable: true,
+		KycAmount:    &kycAmount,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	r = transfer.To(controller.Context{Uid: userInfo.Id}, transfer.ToParams{
+		Currency: code,
+		To:       inviter.Id,
+		Amount:   "200",
+	})
+
+	assert.Equal(t, exception.KycRequired.Error(), r.Message)
+
+	params := kyc.SubmitParams{
+		Name:     "张三",
+		IdType:   string(model.KycIdTypeIdCard),
+		IdNumber: "11010119900307001x",
+		Front:    front,
+		Back:     "not-exist.png",
+	}
+
+	// 证件图片没有上传
+	r = kyc.Submit(controller.Context{Uid: userInfo.Id}, params)
+
+	assert.Equal(t, exception.KycImageMissing.Error(), r.Message)
+
+	// 不能使用其他用户上传的证件图片
+	params.Back = stolen
+
+	r = kyc.Submit(controller.Context{Uid: userInfo.Id}, params)
+
+	assert.Equal(t, exception.KycImageMissing.Error(), r.Message)
+
+	// 高级认证需要手持证件的照片
+	params.Back = back
+	params.Level = model.KycLevelAdvanced
+
+	r = kyc.Submit(controller.Context{Uid: userInfo.Id}, params)
+
+	assert.Equal(t, exception.KycImageMissing.Error(), r.Message)
+
+	params.Level = model.KycLevelBasic
+
+	r = kyc.Submit(controller.Context{Uid: userInfo.Id}, params)
+
+	assert.Equal(t, "", r.Message)
+
+	first := schema.Kyc{}
+
+	assert.Nil(t, tester.Decode(r.Data, &first))
+	assert.Equal(t, model.KycStatusPending, first.Status)
+	assert.Equal(t, "11010119900307001X", first.IdNumber)
+	assert.Equal(t, "/v1/kyc/document/"+front, first.Images[0])
+
+	// 证件图片只能由管理员下载
+	filepath, _, er := kyc.OpenDocumentByAdmin(adminInfo.Id, front)
+
+	assert.Nil(t, er)
+	assert.Equal(t, path.Join(uploader.Config.Path, uploader.Config.Kyc.Path, front), filepath)
+
+	_, _, err = kyc.OpenDocumentByAdmin(userInfo.Id, front)
+
+	assert.Equal(t, exception.AdminNotExist, err)
+
+	// 同一时间只能有一个等待审核的申请
+	r = kyc.Submit(controller.Context{Uid: userInfo.Id}, params)
+
+	assert.Equal(t, exception.KycPending.Error(), r.Message)
+
+	// 拒绝需要填写原因
+	r = kyc.Reject(controller.Context{Uid: adminInfo.Id}, first.Id, kyc.RejectParams{})
+
+	assert.NotEqual(t, "", r.Message)
+
+	r = kyc.Reject(controller.Context{Uid: adminInfo.Id}, first.Id, kyc.RejectParams{Reason: "图片不清晰"})
+
+	assert.Equal(t, "", r.Message)
+
+	// 不能重复审核
+	r = kyc.Approve(controller.Context{Uid: adminInfo.Id}, first.Id)
+
+	assert.Equal(t, exception.KycNotPending.Error(), r.Message)
+
+	// 被拒绝之后重新提交
+	r = kyc.Submit(controller.Context{Uid: userInfo.Id}, params)
+
+	assert.Equal(t, "", r.Message)
+
+	second := schema.Kyc{}
+
+	assert.Nil(t, tester.Decode(r.Data, &second))
+
+	// 审核队列
+	pending := model.KycStatusPending
+	list := kyc.GetKycListByAdmin(controller.Context{Uid: adminInfo.Id}, kyc.QueryAdmin{
+		Query: kyc.Query{Status: &pending},
+		Uid:   &userInfo.Id,
+	})
+
+	assert.Equal(t, "", list.Message)
+	assert.Equal(t, int64(1), list.Meta.Total)
+
+	r = kyc.Approve(controller.Context{Uid: adminInfo.Id}, second.Id)
+
+	assert.Equal(t, "", r.Message)
+
+	u := model.User{}
+
+	assert.Nil(t, database.Db.Where("id = ?", userInfo.Id).First(&u).Error)
+	assert.Equal(t, model.KycLevelBasic, u.KycLevel)
+
+	// 邀请记录进入认证状态
+	history := model.InviteHistory{}
+
+	assert.Nil(t, database.Db.Where("invitee = ?", userInfo.Id).First(&history).Error)
+	assert.Equal(t, model.StatusInviteAuth, history.Status)
+
+	// 已经完成的等级不能重复申请
+	r = kyc.Submit(controller.Context{Uid: userInfo.Id}, params)
+
+	assert.Equal(t, exception.KycLevelReached.Error(), r.Message)
+
+	// 完成认证之后不再限制, 只是余额不足
+	r = transfer.To(controller.Context{Uid: userInfo.Id}, transfer.ToParams{
+		Currency: code,
+		To:       inviter.Id,
+		Amount:   "200",
+	})
+
+	assert.Equal(t, exception.NotEnoughBalance.Error(), r.Message)
+
+	// 用户可以看到自己的申请和拒绝原因
+	list = kyc.GetKycList(controller.Context{Uid: userInfo.Id}, kyc.Query{})
+
+	assert.Equal(t, "", list.Message)
+	assert.Equal(t, int64(2), list.Meta.Total)
+}
+
+func TestKycRollingLimit(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	code := "T" + strings.ToUpper(util.RandomString(6))
+	kycAmount := "100"
+
+	defer database.DeleteRowByTable("currency", "code", code)
+	defer database.DeleteRowByTable("wallet", "currency", code)
+	defer database.DeleteRowByTable("transfer_log", "currency", code)
+	defer database.DeleteRowByTable("finance_log", "currency", code)
+
+	r := currency.Create(controller.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code:         code,
+		Name:         "测试币",
+		Scale:        2,
+		Transferable: true,
+		KycAmount:    &kycAmount,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	assert.Nil(t, database.Db.Create(&model.Wallet{
+		Id:       userFrom.Id,
+		Currency: code,
+		Balance:  decimal.New(200, 0),
+	}).Error)
+
+	to := func(amount string) schema.Response {
+		return transfer.To(controller.Context{Uid: userFrom.Id}, transfer.ToParams{
+			Currency: code,
+			To:       userTo.Id,
+			Amount:   amount,
+		})
+	}
+
+	// 单笔都没有超过限额, 但是累计超过之后需要实名认证
+	assert.Equal(t, "", to("60").Message)
+	assert.Equal(t, "", to("40").Message)
+	assert.Equal(t, exception.KycRequired.Error(), to("1").Message)
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package kyc
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type RejectParams struct {
+	Reason string `json:"reason" valid:"required~请输入拒绝的原因"` // 拒绝的原因, 用户可以看到
+}
+
+// 审核一个实名认证申请, 拒绝时需要填写原因
+func review(context controller.Context, kycId string, approve bool, reason *string) (res schema.Response) {
+	var (
+		err  error
+		tx   *gorm.DB
+		data = schema.Kyc{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Status = schema.StatusSuccess
+			res.Data = data
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminKycReview); err != nil {
+		return
+	}
+
+	kycInfo := model.Kyc{}
+
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", kycId).First(&kycInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.KycNotExist
+		}
+		return
+	}
+
+	if kycInfo.Status != model.KycStatusPending {
+		err = exception.KycNotPending
+		return
+	}
+
+	now := time.Now()
+
+	kycInfo.Reviewer = &context.Uid
+	kycInfo.ReviewedAt = &now
+
+	if approve {
+		// 认证的邀请奖励需要的钱包在更新其他数据之前一起加锁
+		keys, er := invite.RewardWallets(tx, kycInfo.Uid, model.StatusInviteAuth)
+
+		if er != nil {
+			err = er
+			return
+		}
+
+		if _, err = wallet.LockAll(tx, keys...); err != nil {
+			return
+		}
+
+		// 提交之后可能有其他用户使用同一个证件通过了认证
+		if used, er := isIdNumberUsed(tx, kycInfo.Uid, kycInfo.IdType, kycInfo.IdNumber); er != nil {
+			err = er
+			return
+		} else if used {
+			err = exception.IdNumberUsed
+			return
+		}
+
+		kycInfo.Status = model.KycStatusApproved
+	} else {
+		kycInfo.Status = model.KycStatusRejected
+		kycInfo.Reason = reason
+	}
+
+	if err = tx.Model(&model.Kyc{}).Where("id = ?", kycInfo.Id).Updates(map[string]interface{}{
+		"status":      kycInfo.Status,
+		"reason":      kycInfo.Reason,
+		"reviewer":    kycInfo.Reviewer,
+		"reviewed_at": kycInfo.ReviewedAt,
+	}).Error; err != nil {
+		return
+	}
+
+	if approve {
+		// 认证等级只升不降
+		if err = tx.Model(&model.User{}).Where("id = ? AND kyc_level < ?", kycInfo.Uid, kycInfo.Level).Update("kyc_level", kycInfo.Level).Error; err != nil {
+			return
+		}
+
+		// 完成实名认证, 结算认证的邀请奖励
+		if err = invite.Settle(tx, kycInfo.Uid, model.StatusInviteAuth); err != nil {
+			return
+		}
+	}
+
+	mapToSchema(kycInfo, &data)
+
+	return
+}
+
+// 审核通过, 更新用户的认证等级
+func Approve(context controller.Context, kycId string) (res schema.Response) {
+	return review(context, kycId, true, nil)
+}
+
+// 审核拒绝, 用户可以重新提交
+func Reject(context controller.Context, kycId string, input RejectParams) (res schema.Response) {
+	if isValidInput, err := govalidator.ValidateStruct(input); err != nil {
+		res.Message = err.Error()
+		return
+	} else if isValidInput == false {
+		res.Message = exception.InvalidParams.Error()
+		return
+	}
+
+	return review(context, kycId, false, &input.Reason)
+}
+
+func ApproveRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Approve(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("kyc_id"))
+}
+
+func RejectRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input RejectParams
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Reject(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("kyc_id"), input)
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package kyc
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+)
+
+type SubmitParams struct {
+	Level    int32   `json:"level"`                                                         // 申请的认证等级, 默认初级认证
+	Name     string  `json:"name" valid:"required~请输入真实姓名"`                                 // 真实姓名
+	IdType   string  `json:"id_type" valid:"required~请选择证件类型,in(id_card|passport)~无效的证件类型"` // 证件类型
+	IdNumber string  `json:"id_number" valid:"required~请输入证件号码"`                            // 证件号码
+	Front    string  `json:"front"`                                                         // 证件正面图片的文件名, 通过证件上传接口上传
+	Back     string  `json:"back"`                                                          // 证件反面图片的文件名, 通过证件上传接口上传
+	Selfie   *string `json:"selfie"`                                                        // 手持证件图片的文件名, 高级认证需要
+}
+
+// 用户提交实名认证申请, 同一时间只能有一个等待审核的申请
+func Submit(context controller.Context, input SubmitParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.Kyc
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	if input.Level == model.KycLevelNone {
+		input.Level = model.KycLevelBasic
+	}
+
+	images := []string{input.Front, input.Back}
+
+	switch input.Level {
+	case model.KycLevelBasic:
+	case model.KycLevelAdvanced:
+		if input.Selfie == nil {
+			err = exception.KycImageMissing
+			return
+		}
+		images = append(images, *input.Selfie)
+	default:
+		err = exception.InvalidKycLevel
+		return
+	}
+
+	idType := model.KycIdType(input.IdType)
+	idNumber := strings.ToUpper(strings.TrimSpace(input.IdNumber))
+
+	tx = database.Db.Begin()
+
+	// 锁定用户, 防止同时提交多个申请
+	userInfo := model.User{}
+
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", context.Uid).First(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	// 只能使用自己上传的证件图片
+	for _, filename := range images {
+		if owned, er := isOwnedDocument(tx, context.Uid, filename); er != nil {
+			err = er
+			return
+		} else if !owned {
+			err = exception.KycImageMissing
+			return
+		}
+	}
+
+	if userInfo.KycLevel >= input.Level {
+		err = exception.KycLevelReached
+		return
+	}
+
+	var count int
+
+	if err = tx.Model(model.Kyc{}).Where("uid = ? AND status = ?", context.Uid, model.KycStatusPending).Count(&count).Error; err != nil {
+		return
+	}
+
+	if count > 0 {
+		err = exception.KycPending
+		return
+	}
+
+	if used, er := isIdNumberUsed(tx, context.Uid, idType, idNumber); er != nil {
+		err = er
+		return
+	} else if used {
+		err = exception.IdNumberUsed
+		return
+	}
+
+	kycInfo := model.Kyc{
+		Uid:      context.Uid,
+		Level:    input.Level,
+		Name:     strings.TrimSpace(input.Name),
+		IdType:   idType,
+		IdNumber: idNumber,
+		Images:   images,
+		Status:   model.KycStatusPending,
+	}
+
+	if err = tx.Create(&kycInfo).Error; err != nil {
+		return
+	}
+
+	mapToSchema(kycInfo, &data)
+
+	return
+}
+
+func SubmitRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input SubmitParams
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Submit(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
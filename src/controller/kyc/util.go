@@ -0,0 +1,43 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package kyc
+
+import (
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+func mapToSchema(model model.Kyc, d *schema.Kyc) {
+	d.Id = model.Id
+	d.Uid = model.Uid
+	d.Level = model.Level
+	d.Name = model.Name
+	d.IdType = model.IdType
+	d.IdNumber = model.IdNumber
+	d.Images = make([]string, 0, len(model.Images))
+	for _, filename := range model.Images {
+		// 证件图片不公开, 只能通过管理员的接口下载
+		d.Images = append(d.Images, "/v1/kyc/document/"+filename)
+	}
+	d.Status = model.Status
+	d.Reason = model.Reason
+	d.Reviewer = model.Reviewer
+	if model.ReviewedAt != nil {
+		reviewedAt := model.ReviewedAt.Format(time.RFC3339Nano)
+		d.ReviewedAt = &reviewedAt
+	}
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 证件号码是否已经被其他用户认证过
+func isIdNumberUsed(tx *gorm.DB, uid string, idType model.KycIdType, idNumber string) (bool, error) {
+	var count int
+
+	if err := tx.Model(model.Kyc{}).Where("uid <> ? AND id_type = ? AND id_number = ? AND status = ?", uid, idType, idNumber, model.KycStatusApproved).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package ledger
+
+import (
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+// 一条记账分录
+type Leg struct {
+	Account   string                // 用户ID或者系统账户
+	Bucket    model.LedgerBucket    // 可用余额还是冻结余额
+	Direction model.LedgerDirection // 借贷方向
+	Amount    decimal.Decimal       // 数量
+}
+
+// 借方, 账户减少
+func Debit(account string, bucket model.LedgerBucket, amount decimal.Decimal) Leg {
+	return Leg{Account: account, Bucket: bucket, Direction: model.LedgerDirectionDebit, Amount: amount}
+}
+
+// 贷方, 账户增加
+func Credit(account string, bucket model.LedgerBucket, amount decimal.Decimal) Leg {
+	return Leg{Account: account, Bucket: bucket, Direction: model.LedgerDirectionCredit, Amount: amount}
+}
+
+// 在事务中记一笔账, 必须与钱包的变动在同一个事务中
+// 所有分录的借方合计必须等于贷方合计
+func Post(tx *gorm.DB, currency string, orderId string, financeType model.FinanceType, note *string, legs ...Leg) (posting model.LedgerPosting, err error) {
+	debit := decimal.Zero
+	credit := decimal.Zero
+
+	for _, leg := range legs {
+		if !leg.Amount.IsPositive() {
+			err = exception.LedgerInvalidAmount
+			return
+		}
+
+		if leg.Direction == model.LedgerDirectionDebit {
+			debit = debit.Add(leg.Amount)
+		} else {
+			credit = credit.Add(leg.Amount)
+		}
+	}
+
+	if len(legs) == 0 || !debit.Equal(credit) {
+		err = exception.LedgerUnbalanced
+		return
+	}
+
+	currency = strings.ToUpper(currency)
+
+	posting = model.LedgerPosting{
+		Currency: currency,
+		OrderId:  orderId,
+		Type:     financeType,
+		Note:     note,
+	}
+
+	if err = tx.Create(&posting).Error; err != nil {
+		return
+	}
+
+	for _, leg := range legs {
+		if err = tx.Create(&model.LedgerEntry{
+			PostingId: posting.Id,
+			Currency:  currency,
+			Account:   leg.Account,
+			Bucket:    leg.Bucket,
+			Direction: leg.Direction,
+			Amount:    leg.Amount,
+		}).Error; err != nil {
+			return
+		}
+	}
+
+	return
+}
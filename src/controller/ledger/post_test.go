@@ -0,0 +1,54 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package ledger_test
+
+import (
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPost(t *testing.T) {
+	tx := database.Db.Begin()
+
+	defer tx.Rollback()
+
+	amount := decimal.New(10, 0)
+
+	// 借贷不平衡
+	_, err := ledger.Post(tx, model.WalletCNY, "", model.FinanceTypeAdminCredit, nil,
+		ledger.Debit(model.LedgerAccountAdjustment, model.LedgerBucketBalance, amount),
+		ledger.Credit("1", model.LedgerBucketBalance, decimal.New(9, 0)),
+	)
+
+	assert.Equal(t, exception.LedgerUnbalanced, err)
+
+	// 没有分录
+	_, err = ledger.Post(tx, model.WalletCNY, "", model.FinanceTypeAdminCredit, nil)
+
+	assert.Equal(t, exception.LedgerUnbalanced, err)
+
+	// 数量必须是正数
+	_, err = ledger.Post(tx, model.WalletCNY, "", model.FinanceTypeAdminCredit, nil,
+		ledger.Debit(model.LedgerAccountAdjustment, model.LedgerBucketBalance, amount.Neg()),
+		ledger.Credit("1", model.LedgerBucketBalance, amount.Neg()),
+	)
+
+	assert.Equal(t, exception.LedgerInvalidAmount, err)
+
+	posting, err := ledger.Post(tx, "cny", "", model.FinanceTypeAdminCredit, nil,
+		ledger.Debit(model.LedgerAccountAdjustment, model.LedgerBucketBalance, amount),
+		ledger.Credit("1", model.LedgerBucketBalance, amount),
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.WalletCNY, posting.Currency)
+
+	entries := make([]model.LedgerEntry, 0)
+
+	assert.Nil(t, tx.Where("posting_id = ?", posting.Id).Find(&entries).Error)
+	assert.Len(t, entries, 2)
+}
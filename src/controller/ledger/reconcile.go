@@ -0,0 +1,215 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package ledger
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"time"
+)
+
+// 按钱包汇总所有分录, 贷方为加, 借方为减
+const journalSQL = `SELECT w.id AS uid, w.currency, w.balance, w.frozen,
+	COALESCE(SUM(CASE WHEN e.bucket = 'balance' AND e.direction = 'credit' THEN e.amount WHEN e.bucket = 'balance' THEN -e.amount ELSE 0 END), 0) AS journal_balance,
+	COALESCE(SUM(CASE WHEN e.bucket = 'frozen' AND e.direction = 'credit' THEN e.amount WHEN e.bucket = 'frozen' THEN -e.amount ELSE 0 END), 0) AS journal_frozen
+FROM "wallet" w LEFT JOIN "ledger_entry" e ON e.account = w.id AND e.currency = w.currency
+WHERE w.deleted_at IS NULL
+GROUP BY w.id, w.currency, w.balance, w.frozen`
+
+// 借贷不平衡的凭证
+const unbalancedSQL = `SELECT posting_id FROM "ledger_entry" GROUP BY posting_id
+HAVING SUM(CASE WHEN direction = 'debit' THEN amount ELSE -amount END) <> 0`
+
+type journal struct {
+	Uid            string
+	Currency       string
+	Balance        decimal.Decimal
+	Frozen         decimal.Decimal
+	JournalBalance decimal.Decimal
+	JournalFrozen  decimal.Decimal
+}
+
+func mapReconciliationToSchema(model model.LedgerReconciliation, d *schema.LedgerReconciliation) (err error) {
+	d.Id = model.Id
+	d.Wallets = model.Wallets
+	d.Drifts = model.Drifts
+	d.UnbalancedPostings = model.UnbalancedPostings
+	if err = json.Unmarshal([]byte(model.Detail), &d.Detail); err != nil {
+		return
+	}
+	d.StartedAt = model.StartedAt.Format(time.RFC3339Nano)
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	return
+}
+
+// 对账, 根据分录重新计算每个钱包的余额, 与钱包当前的余额比较, 并保存对账报告
+func Reconcile() (report model.LedgerReconciliation, err error) {
+	var (
+		rows   *sql.Rows
+		detail = schema.LedgerReconciliationDetail{
+			Drifts:             make([]schema.LedgerDrift, 0),
+			UnbalancedPostings: make([]string, 0),
+		}
+	)
+
+	report.StartedAt = time.Now()
+
+	if rows, err = database.Db.Raw(journalSQL).Rows(); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		j := journal{}
+
+		if err = database.Db.ScanRows(rows, &j); err != nil {
+			return
+		}
+
+		report.Wallets = report.Wallets + 1
+
+		if j.Balance.Equal(j.JournalBalance) && j.Frozen.Equal(j.JournalFrozen) {
+			continue
+		}
+
+		detail.Drifts = append(detail.Drifts, schema.LedgerDrift{
+			Uid:            j.Uid,
+			Currency:       j.Currency,
+			Balance:        util.AmountToStr(j.Balance),
+			Frozen:         util.AmountToStr(j.Frozen),
+			JournalBalance: util.AmountToStr(j.JournalBalance),
+			JournalFrozen:  util.AmountToStr(j.JournalFrozen),
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if detail.UnbalancedPostings, err = unbalancedPostings(); err != nil {
+		return
+	}
+
+	b, err := json.Marshal(detail)
+
+	if err != nil {
+		return
+	}
+
+	report.Drifts = len(detail.Drifts)
+	report.UnbalancedPostings = len(detail.UnbalancedPostings)
+	report.Detail = string(b)
+
+	if err = database.Db.Create(&report).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func unbalancedPostings() (list []string, err error) {
+	list = make([]string, 0)
+
+	rows, err := database.Db.Raw(unbalancedSQL).Rows()
+
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+
+		if err = rows.Scan(&id); err != nil {
+			return
+		}
+
+		list = append(list, id)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// 获取最近一次的对账报告
+func GetLatestReconciliation(context controller.Context) (res schema.Response) {
+	var (
+		err  error
+		data schema.LedgerReconciliation
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminLedgerGet); err != nil {
+		return
+	}
+
+	report := model.LedgerReconciliation{}
+
+	if err = database.Db.Order("created_at DESC").First(&report).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.LedgerReconciliationNotExist
+		}
+		return
+	}
+
+	if err = mapReconciliationToSchema(report, &data); err != nil {
+		return
+	}
+
+	return
+}
+
+func GetLatestReconciliationRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetLatestReconciliation(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
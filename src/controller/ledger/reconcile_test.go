@@ -0,0 +1,81 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package ledger_test
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func findDrift(t *testing.T, uid string) *schema.LedgerDrift {
+	r := ledger.GetLatestReconciliation(controller.Context{Uid: adminUid(t)})
+	report := schema.LedgerReconciliation{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &report))
+
+	for _, d := range report.Detail.Drifts {
+		if d.Uid == uid && d.Currency == model.WalletCNY {
+			return &d
+		}
+	}
+
+	return nil
+}
+
+func adminUid(t *testing.T) string {
+	adminInfo, err := tester.LoginAdmin()
+	assert.Nil(t, err)
+	return adminInfo.Id
+}
+
+func TestReconcile(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+	config.Admin.WalletAdjustApproveThreshold = nil
+	defer func() {
+		config.Admin.WalletAdjustApproveThreshold = threshold
+	}()
+
+	// 通过调整充值, 会同时记账
+	r := wallet.Adjust(controller.Context{Uid: adminUid(t)}, wallet.AdjustParams{
+		Uid:      userInfo.Id,
+		Currency: model.WalletCNY,
+		Type:     model.FinanceTypeAdminCredit,
+		Amount:   "100",
+		Reason:   "test",
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	_, err := ledger.Reconcile()
+
+	assert.Nil(t, err)
+	assert.Nil(t, findDrift(t, userInfo.Id))
+
+	// 绕过记账直接修改钱包
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userInfo.Id, model.WalletCNY).Update("balance", decimal.New(200, 0)).Error)
+
+	_, err = ledger.Reconcile()
+
+	assert.Nil(t, err)
+
+	drift := findDrift(t, userInfo.Id)
+
+	if assert.NotNil(t, drift) {
+		assert.Equal(t, "200.00000000", drift.Balance)
+		assert.Equal(t, "100.00000000", drift.JournalBalance)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type BatchDeleteParams struct {
+	Ids []string `json:"ids" valid:"required~请选择要删除的消息"` // 要删除的消息ID
+}
+
+// 获取用户未读的消息数量
+func CountUnread(db *gorm.DB, uid string) (count int64, err error) {
+	err = db.Model(&model.Message{}).Where("uid = ? AND read = ?", uid, false).Count(&count).Error
+	return
+}
+
+// 把用户所有未读的消息标记为已读
+func ReadAll(context controller.Context) (res schema.Response) {
+	var (
+		err  error
+		data schema.InboxAffected
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	now := time.Now()
+
+	result := tx.Model(&model.Message{}).Where("uid = ? AND read = ?", context.Uid, false).UpdateColumns(map[string]interface{}{
+		"read":    true,
+		"read_at": now,
+	})
+
+	if err = result.Error; err != nil {
+		return
+	}
+
+	data.Count = result.RowsAffected
+
+	return
+}
+
+// 批量删除用户自己的消息, 不属于这个用户的消息会被忽略
+func DeleteBatchByUser(context controller.Context, input BatchDeleteParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.InboxAffected
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false || len(input.Ids) > schema.MaxLimit {
+		err = exception.InvalidParams
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	result := tx.Where("uid = ? AND id IN (?)", context.Uid, input.Ids).Delete(&model.Message{})
+
+	if err = result.Error; err != nil {
+		return
+	}
+
+	data.Count = result.RowsAffected
+
+	return
+}
+
+func ReadAllRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = ReadAll(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
+
+func DeleteBatchByUserRouter(context *gin.Context) {
+	var (
+		input BatchDeleteParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = DeleteBatchByUser(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
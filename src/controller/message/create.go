@@ -10,6 +10,7 @@ import (
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"github.com/mitchellh/mapstructure"
@@ -57,6 +58,9 @@ func Create(context controller.Context, input CreateMessageParams) (res schema.R
 		} else {
 			res.Data = data
 			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再推送给用户
+			_ = push.Publish(input.Uid, push.EventMessage, data)
 		}
 	}()
 
@@ -0,0 +1,309 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/axetroy/go-server/src/controller/preference"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/message_queue"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/axetroy/go-server/src/service/push"
+	"github.com/axetroy/go-server/src/service/sms"
+	"github.com/jinzhu/gorm"
+	"github.com/nsqio/go-nsq"
+	"time"
+)
+
+// 延迟投递的最长时间, 受限于 nsqd 的 --max-req-timeout, 免打扰时间更长时会多次延迟
+const maxDeferDelay = time.Hour
+
+// 要通知用户的事件, 站内消息在事务中创建, 其他渠道在事务提交之后通过消息队列发送
+type Notice struct {
+	Event     string                 `json:"event"`      // 事件名称
+	Uid       string                 `json:"uid"`        // 通知的用户
+	Locale    string                 `json:"locale"`     // 指定语言, 为空则使用用户设置的语言
+	Vars      map[string]interface{} `json:"vars"`       // 模版变量
+	MessageId *string                `json:"message_id"` // 已经创建的站内消息
+	Channel   string                 `json:"channel"`    // 发送的渠道, 为空时按用户的通知设置拆分为每个渠道一条通知
+}
+
+// 业务代码在事务中通知用户, 用户开启了站内消息时创建一条消息
+// 没有为这个事件设置模版, 或者模版渲染失败时不通知, 返回 nil, 不影响业务的事务. 调用方需要在事务提交之后调用 Dispatch
+func Notify(tx *gorm.DB, event string, uid string, vars map[string]interface{}) (*Notice, error) {
+	userInfo := model.User{Id: uid}
+
+	if err := tx.First(&userInfo).Error; err != nil {
+		return nil, err
+	}
+
+	t, err := findTemplate(tx, event, userLocale(userInfo))
+
+	if err == exception.MessageTemplateNotExist {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err = render(t.Title, vars); err == nil {
+		_, err = render(t.Content, vars)
+	}
+
+	if err != nil {
+		fmt.Printf("渲染消息模版 %s (%s) 失败: %s\n", t.Event, t.Locale, err.Error())
+		return nil, nil
+	}
+
+	n := Notice{
+		Event: event,
+		Uid:   uid,
+		Vars:  vars,
+	}
+
+	enabled, err := preference.Enabled(tx, uid, event, model.NotificationChannelInApp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		m, err := send(tx, event, userInfo, "", vars)
+
+		if err != nil {
+			return nil, err
+		}
+
+		n.MessageId = &m.Id
+	}
+
+	return &n, nil
+}
+
+// 把通知投递到消息队列, 由分发器发送到用户开启的其他渠道
+func Dispatch(n *Notice) {
+	if n == nil {
+		return
+	}
+
+	body, err := json.Marshal(n)
+
+	if err == nil {
+		err = message_queue.Publish(message_queue.TopicDispatchNotice, body)
+	}
+
+	if err != nil {
+		fmt.Printf("投递通知 %s 到用户 %s 失败: %s\n", n.Event, n.Uid, err.Error())
+	}
+}
+
+// 用户开启并且可以接收的推送, 邮件和短信渠道
+func channelsOf(userInfo model.User, channels map[string]bool) []string {
+	list := make([]string, 0)
+
+	if channels[model.NotificationChannelPush] {
+		list = append(list, model.NotificationChannelPush)
+	}
+
+	if channels[model.NotificationChannelEmail] && userInfo.Email != nil && *userInfo.Email != "" {
+		list = append(list, model.NotificationChannelEmail)
+	}
+
+	if channels[model.NotificationChannelSMS] && userInfo.Phone != nil && *userInfo.Phone != "" {
+		list = append(list, model.NotificationChannelSMS)
+	}
+
+	return list
+}
+
+// 把通知拆分为每个渠道一条, 每个渠道单独重试, 一个渠道失败时不会重复发送其他渠道
+func split(n Notice) (err error) {
+	userInfo := model.User{Id: n.Uid}
+
+	if err = database.Db.First(&userInfo).Error; err != nil {
+		// 用户已经不存在, 不再通知
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		return
+	}
+
+	channels, err := preference.Channels(database.Db, userInfo.Id, n.Event)
+
+	if err != nil {
+		return
+	}
+
+	bodies := make([][]byte, 0)
+
+	for _, channel := range channelsOf(userInfo, channels) {
+		n.Channel = channel
+
+		body, err := json.Marshal(n)
+
+		if err != nil {
+			return err
+		}
+
+		bodies = append(bodies, body)
+	}
+
+	if len(bodies) == 0 {
+		return
+	}
+
+	// 一次性投递所有渠道, 失败时都没有投递, 重试时不会重复
+	return message_queue.MultiPublish(message_queue.TopicDispatchNotice, bodies)
+}
+
+// 把通知发送到指定的渠道, 在免打扰时间内时返回需要延迟的时间
+func deliver(n Notice, now time.Time) (delay time.Duration, err error) {
+	userInfo := model.User{Id: n.Uid}
+
+	if err = database.Db.First(&userInfo).Error; err != nil {
+		// 用户已经不存在, 不再通知
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		return
+	}
+
+	channels, err := preference.Channels(database.Db, userInfo.Id, n.Event)
+
+	if err != nil {
+		return
+	}
+
+	// 用户已经关闭了这个渠道, 或者没有可以接收的联系方式
+	enabled := false
+
+	for _, channel := range channelsOf(userInfo, channels) {
+		if channel == n.Channel {
+			enabled = true
+		}
+	}
+
+	if !enabled {
+		return
+	}
+
+	p, err := preference.Find(database.Db, userInfo.Id)
+
+	if err != nil {
+		return
+	}
+
+	if delay = preference.QuietDelay(p, now); delay > 0 {
+		return
+	}
+
+	locale := n.Locale
+
+	if locale == "" {
+		locale = userLocale(userInfo)
+	}
+
+	data := schema.Message{}
+
+	if n.MessageId != nil {
+		m := model.Message{}
+
+		if err = database.Db.Where("id = ?", *n.MessageId).First(&m).Error; err != nil {
+			// 站内消息已经被删除, 不再通知
+			if err == gorm.ErrRecordNotFound {
+				err = nil
+			}
+			return
+		}
+
+		data.Id = m.Id
+		data.Title = m.Title
+		data.Content = m.Content
+		data.CreatedAt = m.CreatedAt.Format(time.RFC3339Nano)
+		data.UpdatedAt = m.UpdatedAt.Format(time.RFC3339Nano)
+	} else {
+		var t model.MessageTemplate
+
+		if t, err = findTemplate(database.Db, n.Event, locale); err != nil {
+			// 模版已经被删除, 不再通知
+			if err == exception.MessageTemplateNotExist {
+				err = nil
+			}
+			return
+		}
+
+		if data.Title, err = render(t.Title, n.Vars); err != nil {
+			return
+		}
+
+		if data.Content, err = render(t.Content, n.Vars); err != nil {
+			return
+		}
+	}
+
+	switch n.Channel {
+	case model.NotificationChannelPush:
+		err = push.Publish(userInfo.Id, push.EventMessage, data)
+	case model.NotificationChannelEmail:
+		// 与其他邮件一样通过邮件队列发送, 有发送记录并且失败时会重试
+		var log model.EmailLog
+
+		if log, err = message_queue.CreateEmail(database.Db, userInfo.Id, *userInfo.Email, locale, email.TemplateNotice, map[string]interface{}{
+			"Title":          data.Title,
+			"Content":        data.Content,
+			"UnsubscribeURL": preference.UnsubscribeURL(p, n.Event),
+		}); err != nil {
+			return
+		}
+
+		// 加入队列失败时记录已经标记为发送失败, 由管理员重新发送, 这条通知不再重试, 否则会重复记录
+		if e := message_queue.PublishEmail(log); e != nil {
+			fmt.Printf("投递通知 %s 的邮件到用户 %s 失败: %s\n", n.Event, n.Uid, e.Error())
+		}
+	case model.NotificationChannelSMS:
+		err = sms.Send(*userInfo.Phone, data.Content)
+	}
+
+	return
+}
+
+// 消费通知, 返回错误时消息会重新入队
+func handleNotice(message *nsq.Message) error {
+	n := Notice{}
+
+	if err := json.Unmarshal(message.Body, &n); err != nil {
+		// 无法解析的消息重试也没有用
+		fmt.Printf("无法解析的通知: %s\n", string(message.Body))
+		return nil
+	}
+
+	if n.Channel == "" {
+		return split(n)
+	}
+
+	delay, err := deliver(n, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	// 免打扰时间内, 延迟到免打扰结束之后再发送
+	if delay > 0 {
+		if delay > maxDeferDelay {
+			delay = maxDeferDelay
+		}
+
+		return message_queue.DeferredPublish(message_queue.TopicDispatchNotice, delay, message.Body)
+	}
+
+	return nil
+}
+
+// 启动分发通知的消费者
+func RunDispatchConsumer() {
+	if _, err := message_queue.CreateConsumer(message_queue.TopicDispatchNotice, message_queue.ChanelDispatchNotice, nsq.HandlerFunc(handleNotice)); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message
+
+import (
+	"github.com/axetroy/go-server/src/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChannelsOf(t *testing.T) {
+	mail, phone, empty := "test@example.com", "13800138000", ""
+
+	all := map[string]bool{
+		model.NotificationChannelInApp: true,
+		model.NotificationChannelPush:  true,
+		model.NotificationChannelEmail: true,
+		model.NotificationChannelSMS:   true,
+	}
+
+	// 站内消息在事务中创建, 不需要再分发
+	assert.Equal(t, []string{model.NotificationChannelPush, model.NotificationChannelEmail, model.NotificationChannelSMS}, channelsOf(model.User{Email: &mail, Phone: &phone}, all))
+
+	// 没有联系方式的渠道不发送
+	assert.Equal(t, []string{model.NotificationChannelPush}, channelsOf(model.User{Email: &empty}, all))
+
+	// 关闭的渠道不发送
+	assert.Equal(t, []string{model.NotificationChannelEmail}, channelsOf(model.User{Email: &mail, Phone: &phone}, map[string]bool{
+		model.NotificationChannelEmail: true,
+		model.NotificationChannelSMS:   false,
+	}))
+}
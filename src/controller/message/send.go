@@ -0,0 +1,174 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+	"net/http"
+	"time"
+)
+
+// 一次最多发送给多少个用户
+const MaxSendUsers = 1000
+
+type SendParams struct {
+	Event  string                 `json:"event" valid:"required~请选择消息事件"` // 事件名称
+	Locale *string                `json:"locale"`                         // 指定语言, 为空则使用每个用户设置的语言
+	Uids   []string               `json:"uids" valid:"required~请选择用户"`    // 接收消息的用户
+	Vars   map[string]interface{} `json:"vars"`                           // 模版变量
+}
+
+// 管理员用模版给一个或者多个用户发送消息, 任何一个用户发送失败时都不会发送
+// 站内消息总是会创建, 推送, 邮件和短信按照用户的通知设置发送
+func Send(context controller.Context, input SendParams) (res schema.Response) {
+	var (
+		err          error
+		data         = make([]schema.MessageAdmin, 0)
+		messages     = make([]model.Message, 0)
+		tx           *gorm.DB
+		locale       string
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再发送到用户开启的其他渠道
+			for _, m := range messages {
+				Dispatch(&Notice{
+					Event:     input.Event,
+					Uid:       m.Uid,
+					Locale:    locale,
+					Vars:      input.Vars,
+					MessageId: &m.Id,
+				})
+			}
+		}
+	}()
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false || len(input.Uids) > MaxSendUsers {
+		err = exception.InvalidParams
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminMessageTemplate); err != nil {
+		return
+	}
+
+	users := make([]model.User, 0)
+
+	if err = tx.Where("id IN (?)", input.Uids).Find(&users).Error; err != nil {
+		return
+	}
+
+	// 有用户不存在
+	if len(users) != len(unique(input.Uids)) {
+		err = exception.UserNotExist
+		return
+	}
+
+	if input.Locale != nil {
+		locale = *input.Locale
+	}
+
+	for _, userInfo := range users {
+		var m model.Message
+
+		if m, err = send(tx, input.Event, userInfo, locale, input.Vars); err != nil {
+			return
+		}
+
+		messages = append(messages, m)
+
+		d := schema.MessageAdmin{}
+
+		if err = mapstructure.Decode(m, &d.MessagePureAdmin); err != nil {
+			return
+		}
+
+		d.CreatedAt = m.CreatedAt.Format(time.RFC3339Nano)
+		d.UpdatedAt = m.UpdatedAt.Format(time.RFC3339Nano)
+
+		data = append(data, d)
+	}
+
+	return
+}
+
+func unique(list []string) []string {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(list))
+
+	for _, v := range list {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+func SendRouter(context *gin.Context) {
+	var (
+		input SendParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Send(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
@@ -0,0 +1,620 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message
+
+import (
+	"bytes"
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+var (
+	ParamsTemplateIdName = "template_id"
+)
+
+type CreateTemplateParams struct {
+	Event   string  `json:"event" valid:"required~请填写事件名称,length(1|64)~事件名称长度为1-64位"` // 事件名称
+	Locale  string  `json:"locale" valid:"required~请填写模版的语言,length(2|16)~语言长度为2-16位"` // 语言
+	Title   string  `json:"title" valid:"required~请填写标题模版"`                           // 标题模版
+	Content string  `json:"content" valid:"required~请填写内容模版"`                         // 内容模版
+	Note    *string `json:"note"`                                                     // 备注
+}
+
+type UpdateTemplateParams struct {
+	Title   *string `json:"title"`   // 标题模版
+	Content *string `json:"content"` // 内容模版
+	Note    *string `json:"note"`    // 备注
+}
+
+type TemplateQuery struct {
+	schema.Query
+	Event  *string `json:"event" form:"event"`   // 指定事件
+	Locale *string `json:"locale" form:"locale"` // 指定语言
+}
+
+// 用变量渲染模版, 模版中使用了不存在的变量时返回错误
+func render(text string, vars map[string]interface{}) (string, error) {
+	t, err := template.New("message").Option("missingkey=error").Parse(text)
+
+	if err != nil {
+		return "", exception.InvalidMessageTemplate
+	}
+
+	buf := bytes.Buffer{}
+
+	if err = t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// 检查模版的语法, 系统事件的模版还需要只使用这个事件提供的变量
+func checkTemplate(event string, texts ...string) error {
+	names, ok := model.MessageEventVars[event]
+
+	vars := map[string]interface{}{}
+
+	for _, name := range names {
+		vars[name] = name
+	}
+
+	for _, text := range texts {
+		t, err := template.New("message").Option("missingkey=error").Parse(text)
+
+		if err != nil {
+			return exception.InvalidMessageTemplate
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err = t.Execute(ioutil.Discard, vars); err != nil {
+			return exception.InvalidMessageVariable
+		}
+	}
+
+	return nil
+}
+
+// 查找事件的模版, 没有对应语言的模版时使用默认语言的模版
+func findTemplate(db *gorm.DB, event string, locale string) (t model.MessageTemplate, err error) {
+	for _, l := range []string{locale, config.Message.DefaultLocale} {
+		if l == "" {
+			continue
+		}
+
+		if err = db.Where("event = ? AND locale = ?", event, l).First(&t).Error; err != gorm.ErrRecordNotFound {
+			return
+		}
+	}
+
+	err = exception.MessageTemplateNotExist
+
+	return
+}
+
+// 用户设置的语言
+func userLocale(userInfo model.User) string {
+	if userInfo.Locale != nil {
+		return *userInfo.Locale
+	}
+
+	return config.Message.DefaultLocale
+}
+
+// 在事务中用模版给用户创建一条消息, locale 为空时使用用户设置的语言
+func send(tx *gorm.DB, event string, userInfo model.User, locale string, vars map[string]interface{}) (m model.Message, err error) {
+	if locale == "" {
+		locale = userLocale(userInfo)
+	}
+
+	t, err := findTemplate(tx, event, locale)
+
+	if err != nil {
+		return
+	}
+
+	m = model.Message{
+		Uid:    userInfo.Id,
+		Status: model.MessageStatusActive,
+		Note:   &event,
+	}
+
+	if m.Title, err = render(t.Title, vars); err != nil {
+		return
+	}
+
+	if m.Content, err = render(t.Content, vars); err != nil {
+		return
+	}
+
+	err = tx.Create(&m).Error
+
+	return
+}
+
+func mapTemplateToSchema(t model.MessageTemplate, data *schema.MessageTemplate) error {
+	if err := mapstructure.Decode(t, &data.MessageTemplatePure); err != nil {
+		return err
+	}
+
+	data.CreatedAt = t.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = t.UpdatedAt.Format(time.RFC3339Nano)
+
+	return nil
+}
+
+func CreateTemplate(context controller.Context, input CreateTemplateParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.MessageTemplate
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	if err = checkTemplate(input.Event, input.Title, input.Content); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminMessageTemplate); err != nil {
+		return
+	}
+
+	var count int
+
+	if err = tx.Model(&model.MessageTemplate{}).Where("event = ? AND locale = ?", input.Event, input.Locale).Count(&count).Error; err != nil {
+		return
+	}
+
+	if count > 0 {
+		err = exception.MessageTemplateExist
+		return
+	}
+
+	t := model.MessageTemplate{
+		Event:   input.Event,
+		Locale:  input.Locale,
+		Title:   input.Title,
+		Content: input.Content,
+		Note:    input.Note,
+	}
+
+	if err = tx.Create(&t).Error; err != nil {
+		return
+	}
+
+	err = mapTemplateToSchema(t, &data)
+
+	return
+}
+
+func UpdateTemplate(context controller.Context, templateId string, input UpdateTemplateParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.MessageTemplate
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminMessageTemplate); err != nil {
+		return
+	}
+
+	t := model.MessageTemplate{}
+
+	if err = tx.Where("id = ?", templateId).First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.MessageTemplateNotExist
+		}
+		return
+	}
+
+	updateModel := map[string]interface{}{}
+
+	if input.Title != nil && len(*input.Title) != 0 {
+		if err = checkTemplate(t.Event, *input.Title); err != nil {
+			return
+		}
+		updateModel["title"] = *input.Title
+	}
+
+	if input.Content != nil && len(*input.Content) != 0 {
+		if err = checkTemplate(t.Event, *input.Content); err != nil {
+			return
+		}
+		updateModel["content"] = *input.Content
+	}
+
+	if input.Note != nil {
+		updateModel["note"] = input.Note
+	}
+
+	if len(updateModel) != 0 {
+		if err = tx.Model(&t).Updates(updateModel).Error; err != nil {
+			return
+		}
+	}
+
+	err = mapTemplateToSchema(t, &data)
+
+	return
+}
+
+func DeleteTemplate(context controller.Context, templateId string) (res schema.Response) {
+	var (
+		err  error
+		data schema.MessageTemplate
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminMessageTemplate); err != nil {
+		return
+	}
+
+	t := model.MessageTemplate{}
+
+	if err = tx.Where("id = ?", templateId).First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.MessageTemplateNotExist
+		}
+		return
+	}
+
+	// 直接删除, 否则同一个事件和语言不能再创建新的模版
+	if err = tx.Unscoped().Delete(&t).Error; err != nil {
+		return
+	}
+
+	err = mapTemplateToSchema(t, &data)
+
+	return
+}
+
+func GetTemplate(context controller.Context, templateId string) (res schema.Response) {
+	var (
+		err  error
+		data schema.MessageTemplate
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminMessageTemplate); err != nil {
+		return
+	}
+
+	t := model.MessageTemplate{}
+
+	if err = database.Db.Where("id = ?", templateId).First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.MessageTemplateNotExist
+		}
+		return
+	}
+
+	err = mapTemplateToSchema(t, &data)
+
+	return
+}
+
+func GetTemplateList(context controller.Context, input TemplateQuery) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.MessageTemplate, 0)
+		list = make([]model.MessageTemplate, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminMessageTemplate); err != nil {
+		return
+	}
+
+	query := input.Query
+
+	query.Normalize()
+
+	var total int64
+
+	filter := map[string]interface{}{}
+
+	if input.Event != nil {
+		filter["event"] = *input.Event
+	}
+
+	if input.Locale != nil {
+		filter["locale"] = *input.Locale
+	}
+
+	if err = database.Db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.MessageTemplate{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.MessageTemplate{}
+		if err = mapTemplateToSchema(v, &d); err != nil {
+			return
+		}
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+func CreateTemplateRouter(context *gin.Context) {
+	var (
+		input CreateTemplateParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = CreateTemplate(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func UpdateTemplateRouter(context *gin.Context) {
+	var (
+		input UpdateTemplateParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = UpdateTemplate(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param(ParamsTemplateIdName), input)
+}
+
+func DeleteTemplateRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = DeleteTemplate(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param(ParamsTemplateIdName))
+}
+
+func GetTemplateRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetTemplate(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param(ParamsTemplateIdName))
+}
+
+func GetTemplateListRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input TemplateQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetTemplateList(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/message"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+	otherInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer auth.DeleteUserByUserName(otherInfo.Username)
+	defer database.DeleteRowByTable("message", "uid", userInfo.Id)
+	defer database.DeleteRowByTable("message", "uid", otherInfo.Id)
+	defer database.DeleteRowByTable("message_template", "event", "test.template")
+
+	context := controller.Context{Uid: adminInfo.Id}
+
+	// 一个用户使用英文
+	assert.Nil(t, database.Db.Model(&model.User{}).Where("id = ?", otherInfo.Id).Update("locale", "en-US").Error)
+
+	for locale, title := range map[string]string{
+		"zh-CN": "收到 {{.Amount}} {{.Currency}}",
+		"en-US": "Received {{.Amount}} {{.Currency}}",
+	} {
+		r := message.CreateTemplate(context, message.CreateTemplateParams{
+			Event:   "test.template",
+			Locale:  locale,
+			Title:   title,
+			Content: "{{.From}}",
+		})
+
+		assert.Equal(t, "", r.Message)
+	}
+
+	// 同一个事件和语言只能有一个模版
+	r := message.CreateTemplate(context, message.CreateTemplateParams{
+		Event:   "test.template",
+		Locale:  "zh-CN",
+		Title:   "test",
+		Content: "test",
+	})
+
+	assert.Equal(t, exception.MessageTemplateExist.Error(), r.Message)
+
+	// 模版语法错误
+	r = message.CreateTemplate(context, message.CreateTemplateParams{
+		Event:   "test.template",
+		Locale:  "ja-JP",
+		Title:   "{{.Amount",
+		Content: "test",
+	})
+
+	assert.Equal(t, exception.InvalidMessageTemplate.Error(), r.Message)
+
+	// 批量发送, 每个用户使用自己的语言
+	r = message.Send(context, message.SendParams{
+		Event: "test.template",
+		Uids:  []string{userInfo.Id, otherInfo.Id},
+		Vars: map[string]interface{}{
+			"Amount":   "10",
+			"Currency": "CNY",
+			"From":     "tester",
+		},
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	messages := make([]schema.MessageAdmin, 0)
+
+	assert.Nil(t, tester.Decode(r.Data, &messages))
+	assert.Len(t, messages, 2)
+
+	for _, m := range messages {
+		if m.Uid == otherInfo.Id {
+			assert.Equal(t, "Received 10 CNY", m.Title)
+		} else {
+			assert.Equal(t, "收到 10 CNY", m.Title)
+		}
+		assert.Equal(t, "tester", m.Content)
+	}
+
+	// 缺少模版变量
+	r = message.Send(context, message.SendParams{
+		Event: "test.template",
+		Uids:  []string{userInfo.Id},
+	})
+
+	assert.NotEqual(t, "", r.Message)
+
+	// 没有模版的事件不发送
+	m, err := message.Notify(database.Db, "test.unknown", userInfo.Id, nil)
+
+	assert.Nil(t, err)
+	assert.Nil(t, m)
+}
+
+func TestTemplateVariables(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer database.DeleteRowByTable("message_template", "locale", "te-ST")
+
+	context := controller.Context{Uid: adminInfo.Id}
+
+	// 系统事件的模版只能使用事件提供的变量
+	r := message.CreateTemplate(context, message.CreateTemplateParams{
+		Event:   model.MessageEventRoleChanged,
+		Locale:  "te-ST",
+		Title:   "角色变更",
+		Content: "{{.Amount}}",
+	})
+
+	assert.Equal(t, exception.InvalidMessageVariable.Error(), r.Message)
+
+	r = message.CreateTemplate(context, message.CreateTemplateParams{
+		Event:   model.MessageEventRoleChanged,
+		Locale:  "te-ST",
+		Title:   "角色变更",
+		Content: "你的角色变更为 {{.Roles}}",
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	tpl := schema.MessageTemplate{}
+
+	assert.Nil(t, tester.Decode(r.Data, &tpl))
+
+	invalid := "{{.Role}}"
+
+	r = message.UpdateTemplate(context, tpl.Id, message.UpdateTemplateParams{Content: &invalid})
+
+	assert.Equal(t, exception.InvalidMessageVariable.Error(), r.Message)
+
+	// 模版渲染失败时不通知, 也不返回错误, 不影响业务的事务
+	assert.Nil(t, database.Db.Model(&model.User{}).Where("id = ?", userInfo.Id).Update("locale", "te-ST").Error)
+	assert.Nil(t, database.Db.Model(&model.MessageTemplate{}).Where("id = ?", tpl.Id).UpdateColumn("content", invalid).Error)
+
+	notice, err := message.Notify(database.Db, model.MessageEventRoleChanged, userInfo.Id, map[string]interface{}{
+		"Roles": "admin",
+	})
+
+	assert.Nil(t, err)
+	assert.Nil(t, notice)
+}
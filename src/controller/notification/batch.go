@@ -0,0 +1,115 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package notification
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+// 筛选用户可以看到但是还没有读过的通知
+func unreadBy(db *gorm.DB, userInfo model.User, now time.Time) *gorm.DB {
+	return visibleTo(db, userInfo, now).
+		Where("NOT EXISTS (SELECT 1 FROM notification_mark WHERE notification_mark.id = notification.id AND notification_mark.uid = ? AND notification_mark.deleted_at IS NULL)", userInfo.Id)
+}
+
+// 获取用户未读的通知数量
+func CountUnread(db *gorm.DB, userInfo model.User) (count int64, err error) {
+	err = unreadBy(db, userInfo, time.Now()).Count(&count).Error
+	return
+}
+
+// 把用户可以看到的通知全部标记为已读
+func ReadAll(context controller.Context) (res schema.Response) {
+	var (
+		err  error
+		data schema.InboxAffected
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	userInfo := model.User{
+		Id: context.Uid,
+	}
+
+	if err = tx.Where(&userInfo).First(&userInfo).Error; err != nil {
+		// 没有找到用户
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	now := time.Now()
+
+	// 一条语句为所有未读的通知写入已读记录, 参数需要显式转换类型, 否则会被当作文本插入
+	// 已删除的已读记录仍然占用主键, 冲突时恢复这条记录
+	unread := unreadBy(tx, userInfo, now).Select("notification.id, CAST(? AS varchar(32)), TRUE, CAST(? AS timestamptz), CAST(? AS timestamptz)", userInfo.Id, now, now).QueryExpr()
+
+	result := tx.Exec("INSERT INTO notification_mark (id, uid, read, created_at, updated_at) ? ON CONFLICT (id, uid) DO UPDATE SET read = TRUE, updated_at = EXCLUDED.updated_at, deleted_at = NULL", unread)
+
+	if err = result.Error; err != nil {
+		return
+	}
+
+	data.Count = result.RowsAffected
+
+	return
+}
+
+func ReadAllRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = ReadAll(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
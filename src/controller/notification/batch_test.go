@@ -0,0 +1,69 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package notification_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/notification"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReadAll(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer database.DeleteRowByTable("notification_mark", "uid", userInfo.Id)
+
+	r := notification.Create(controller.Context{Uid: adminInfo.Id}, notification.CreateParams{
+		Title:   "TestReadAll",
+		Content: "TestReadAll",
+		Target:  &notification.TargetParams{Users: []string{userInfo.Id}},
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	n := schema.Notification{}
+
+	assert.Nil(t, tester.Decode(r.Data, &n))
+
+	defer notification.DeleteNotificationById(n.Id)
+
+	// 已读之后删除的已读记录, 仍然占用主键
+	assert.Equal(t, "", notification.MarkRead(controller.Context{Uid: userInfo.Id}, n.Id).Message)
+	assert.Nil(t, database.Db.Model(&model.NotificationMark{}).Where("id = ? AND uid = ?", n.Id, userInfo.Id).UpdateColumn("deleted_at", time.Now()).Error)
+
+	user := model.User{}
+
+	assert.Nil(t, database.Db.Where("id = ?", userInfo.Id).First(&user).Error)
+
+	unread, err := notification.CountUnread(database.Db, user)
+
+	assert.Nil(t, err)
+	assert.True(t, unread > 0)
+
+	r = notification.ReadAll(controller.Context{Uid: userInfo.Id})
+
+	affected := schema.InboxAffected{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &affected))
+	assert.Equal(t, unread, affected.Count)
+
+	unread, err = notification.CountUnread(database.Db, user)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), unread)
+
+	// 删除的已读记录被恢复
+	mark := model.NotificationMark{}
+
+	assert.Nil(t, database.Db.Where("id = ? AND uid = ?", n.Id, userInfo.Id).First(&mark).Error)
+	assert.True(t, mark.Read)
+}
@@ -12,15 +12,15 @@ import (
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
 	"net/http"
-	"time"
 )
 
 type CreateParams struct {
 	Title   string  `json:"title" valid:"required~请输入公告标题"`   // 公告标题
 	Content string  `json:"content" valid:"required~请输入公告内容"` // 公告内容
 	Note    *string `json:"note"`                             // 备注
+	WindowParams
+	Target *TargetParams `json:"target"` // 通知对象, 为空则通知所有用户
 }
 
 func Create(context controller.Context, input CreateParams) (res schema.Response) {
@@ -57,6 +57,11 @@ func Create(context controller.Context, input CreateParams) (res schema.Response
 		} else {
 			res.Data = data
 			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再推送给在线的用户, 还没到发布时间的通知交给定时任务推送
+			go func(id string) {
+				_ = deliver(id)
+			}(data.Id)
 		}
 	}()
 
@@ -88,17 +93,30 @@ func Create(context controller.Context, input CreateParams) (res schema.Response
 		Note:    input.Note,
 	}
 
-	if err = tx.Create(&notificationInfo).Error; err != nil {
+	if input.Target != nil {
+		if err = setTarget(&notificationInfo, *input.Target); err != nil {
+			return
+		}
+	}
+
+	if notificationInfo.PublishAt, err = parseTime(input.PublishAt); err != nil {
 		return
 	}
 
-	if er := mapstructure.Decode(notificationInfo, &data.NotificationPure); er != nil {
-		err = er
+	if notificationInfo.ExpireAt, err = parseTime(input.ExpireAt); err != nil {
 		return
 	}
 
-	data.CreatedAt = notificationInfo.CreatedAt.Format(time.RFC3339Nano)
-	data.UpdatedAt = notificationInfo.UpdatedAt.Format(time.RFC3339Nano)
+	if err = checkWindow(notificationInfo); err != nil {
+		return
+	}
+
+	if err = tx.Create(&notificationInfo).Error; err != nil {
+		return
+	}
+
+	err = mapToSchema(notificationInfo, &data)
+
 	return
 }
 
@@ -3,16 +3,16 @@ package notification
 
 import (
 	"errors"
-	"fmt"
 	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
 	"github.com/axetroy/go-server/src/exception"
 	"github.com/axetroy/go-server/src/middleware"
 	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
 	"net/http"
 	"time"
 )
@@ -56,37 +56,105 @@ func Get(context controller.Context, id string) (res schema.Response) {
 
 	tx = database.Db.Begin()
 
-	notificationInfo := model.Notification{}
-	NotificationMark := model.NotificationMark{Id: id}
+	userInfo := model.User{
+		Id: context.Uid,
+	}
+
+	if err = tx.Where(&userInfo).First(&userInfo).Error; err != nil {
+		// 没有找到用户
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
 
-	sql := fmt.Sprintf("LEFT JOIN notification_mark ON notification_mark.id = notification.id AND notification.id = '%s'", notificationInfo.Id)
+	notificationInfo := model.Notification{}
 
-	if err = tx.Table(notificationInfo.TableName()).Joins(sql).Last(&notificationInfo).Error; err != nil {
+	// 用户只能看到发布给自己并且在发布时间内的通知
+	if err = visibleTo(tx, userInfo, time.Now()).Where("id = ?", id).Last(&notificationInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			err = exception.NoData
 		}
 		return
 	}
 
-	if er := mapstructure.Decode(notificationInfo, &data.NotificationPure); er != nil {
-		err = er
+	if err = mapToSchema(notificationInfo, &data); err != nil {
 		return
 	}
 
-	if err = tx.Where(&NotificationMark).Last(&NotificationMark).Error; err != nil {
+	mark := model.NotificationMark{
+		Id:  notificationInfo.Id,
+		Uid: userInfo.Id,
+	}
+
+	if err = tx.Where(&mark).Last(&mark).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			data.NotificationPure.Read = false
+			data.Read = false
 			err = nil
 		} else {
 			return
 		}
 	} else {
-		data.NotificationPure.Read = NotificationMark.Read
-		data.ReadAt = NotificationMark.CreatedAt.Format(time.RFC3339Nano)
+		data.Read = mark.Read
+		data.ReadAt = mark.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return
+}
+
+// 管理员获取通知详情, 包括通知对象和推送状态
+func GetByAdmin(context controller.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.NotificationAdmin
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminNotificationGet); err != nil {
+		return
+	}
+
+	notificationInfo := model.Notification{}
+
+	if err = tx.Where("id = ?", id).Last(&notificationInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.NoData
+		}
+		return
 	}
 
-	data.CreatedAt = notificationInfo.CreatedAt.Format(time.RFC3339Nano)
-	data.UpdatedAt = notificationInfo.UpdatedAt.Format(time.RFC3339Nano)
+	err = mapToAdminSchema(notificationInfo, &data)
 
 	return
 }
@@ -112,3 +180,22 @@ func GetRouter(context *gin.Context) {
 		Uid: context.GetString(middleware.ContextUidField),
 	}, id)
 }
+
+func GetByAdminRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("id"))
+}
@@ -51,7 +51,7 @@ func TestGet(t *testing.T) {
 
 	// 获取详情
 	{
-		r := notification.Get(context, testNotification.Id)
+		r := notification.GetByAdmin(context, testNotification.Id)
 
 		assert.Equal(t, schema.StatusSuccess, r.Status)
 		assert.Equal(t, "", r.Message)
@@ -11,7 +11,6 @@ import (
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
 	"net/http"
 	"time"
 )
@@ -21,6 +20,12 @@ type Query struct {
 	schema.Query
 }
 
+// 带有已读时间的通知
+type notificationWithMark struct {
+	model.Notification
+	MarkedAt *time.Time
+}
+
 // GetList get notification list
 func GetNotificationListByUser(context controller.Context, input Query) (res schema.List) {
 	var (
@@ -67,46 +72,47 @@ func GetNotificationListByUser(context controller.Context, input Query) (res sch
 
 	tx = database.Db.Begin()
 
-	list := make([]model.Notification, 0)
+	userInfo := model.User{
+		Id: context.Uid,
+	}
+
+	if err = tx.Where(&userInfo).First(&userInfo).Error; err != nil {
+		// 没有找到用户
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	list := make([]notificationWithMark, 0)
 
 	filter := map[string]interface{}{}
 
-	if err = tx.Limit(query.Limit).Offset(query.Limit * query.Page).Where(filter).Order(query.Sort).Find(&list).Error; err != nil {
+	// 只列出发布给这个用户并且在发布时间内的通知
+	visible := visibleTo(tx, userInfo, time.Now()).Where(filter)
+
+	// 用一次连表查询带出已读状态, 已读记录的字段重命名避免和通知的字段冲突
+	mark := "LEFT JOIN (SELECT id AS mark_id, created_at AS marked_at FROM notification_mark WHERE uid = ? AND deleted_at IS NULL) AS mark ON mark.mark_id = notification.id"
+
+	if err = visible.Select("notification.*, mark.marked_at").Joins(mark, userInfo.Id).Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Scan(&list).Error; err != nil {
 		return
 	}
 
 	var total int64
 
-	if err = tx.Model(&model.Notification{}).Count(&total).Error; err != nil {
+	if err = visible.Count(&total).Error; err != nil {
 		return
 	}
 
 	for _, v := range list {
 		d := schema.Notification{}
-		if er := mapstructure.Decode(v, &d.NotificationPure); er != nil {
-			err = er
+		if err = mapToSchema(v.Notification, &d); err != nil {
 			return
 		}
-		d.CreatedAt = v.CreatedAt.Format(time.RFC3339Nano)
-		d.UpdatedAt = v.UpdatedAt.Format(time.RFC3339Nano)
-
-		// 查询用户是否已读通知
-		mark := model.NotificationMark{
-			Id:  v.Id,
-			Uid: context.Uid,
-		}
 
-		if err = tx.Last(&mark).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				d.Read = false
-				d.ReadAt = ""
-				err = nil
-			} else {
-				break
-			}
-		} else {
+		if v.MarkedAt != nil {
 			d.Read = true
-			d.ReadAt = mark.CreatedAt.Format(time.RFC3339Nano)
+			d.ReadAt = v.MarkedAt.Format(time.RFC3339Nano)
 		}
 
 		data = append(data, d)
@@ -182,12 +188,9 @@ func GetNotificationListByAdmin(context controller.Context, input Query) (res sc
 
 	for _, v := range list {
 		d := schema.NotificationAdmin{}
-		if er := mapstructure.Decode(v, &d.NotificationPureAdmin); er != nil {
-			err = er
+		if err = mapToAdminSchema(v, &d); err != nil {
 			return
 		}
-		d.CreatedAt = v.CreatedAt.Format(time.RFC3339Nano)
-		d.UpdatedAt = v.UpdatedAt.Format(time.RFC3339Nano)
 		data = append(data, d)
 	}
 
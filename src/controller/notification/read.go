@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"net/http"
+	"time"
 )
 
 // MarkRead mark notification as read
@@ -64,12 +65,10 @@ func MarkRead(context controller.Context, notificationID string) (res schema.Res
 		return
 	}
 
-	notificationInfo := model.Notification{
-		Id: notificationID,
-	}
+	notificationInfo := model.Notification{}
 
-	// 先获取通知
-	if err = tx.Where(&notificationInfo).Last(&notificationInfo).Error; err != nil {
+	// 先获取通知, 只能标记用户可以看到的通知
+	if err = visibleTo(tx, userInfo, time.Now()).Where("id = ?", notificationID).Last(&notificationInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			err = exception.NoData
 		}
@@ -87,7 +87,7 @@ func TestMarkRead(t *testing.T) {
 
 	{
 		// 获取详情
-		r := notification.Get(context, testNotification.Id)
+		r := notification.Get(controller.Context{Uid: userInfo.Id}, testNotification.Id)
 
 		assert.Equal(t, schema.StatusSuccess, r.Status)
 		assert.Equal(t, "", r.Message)
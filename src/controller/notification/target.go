@@ -0,0 +1,340 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package notification
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"net/http"
+	"time"
+)
+
+// 通知的对象, 都为空时通知所有用户, 设置了多个条件时用户需要同时满足
+type TargetParams struct {
+	Roles            []string `json:"roles"`             // 指定角色, 用户拥有其中任意一个角色即可
+	Users            []string `json:"users"`             // 指定用户ID
+	MinLevel         *int32   `json:"min_level"`         // 用户等级的下限, 包括这个等级
+	MaxLevel         *int32   `json:"max_level"`         // 用户等级的上限, 包括这个等级
+	RegisteredAfter  *string  `json:"registered_after"`  // 在这个时间之后注册, RFC3339 格式, 包括这个时间
+	RegisteredBefore *string  `json:"registered_before"` // 在这个时间之前注册, RFC3339 格式, 不包括这个时间
+}
+
+// 通知的发布时间窗口
+type WindowParams struct {
+	PublishAt *string `json:"publish_at"` // 发布时间, RFC3339 格式, 为空则立即发布
+	ExpireAt  *string `json:"expire_at"`  // 过期时间, RFC3339 格式, 为空则不过期
+}
+
+// 解析可选的时间, 空字符串表示清空
+func parseTime(s *string) (*time.Time, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, *s)
+
+	if err != nil {
+		return nil, exception.InvalidParams
+	}
+
+	return &t, nil
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	s := t.Format(time.RFC3339Nano)
+
+	return &s
+}
+
+// 空的数组存为 NULL, 方便判断是否设置了条件
+func toArray(list []string) pq.StringArray {
+	if len(list) == 0 {
+		return nil
+	}
+
+	return list
+}
+
+// 把通知对象写入通知
+func setTarget(n *model.Notification, input TargetParams) (err error) {
+	if input.MinLevel != nil && input.MaxLevel != nil && *input.MinLevel > *input.MaxLevel {
+		return exception.InvalidNotificationTarget
+	}
+
+	n.TargetRoles = toArray(input.Roles)
+	n.TargetUsers = toArray(input.Users)
+	n.MinLevel = input.MinLevel
+	n.MaxLevel = input.MaxLevel
+
+	if n.RegisteredAfter, err = parseTime(input.RegisteredAfter); err != nil {
+		return
+	}
+
+	if n.RegisteredBefore, err = parseTime(input.RegisteredBefore); err != nil {
+		return
+	}
+
+	if n.RegisteredAfter != nil && n.RegisteredBefore != nil && !n.RegisteredAfter.Before(*n.RegisteredBefore) {
+		return exception.InvalidNotificationTarget
+	}
+
+	return
+}
+
+// 检查发布时间窗口
+func checkWindow(n model.Notification) error {
+	if n.PublishAt != nil && n.ExpireAt != nil && !n.PublishAt.Before(*n.ExpireAt) {
+		return exception.InvalidNotificationWindow
+	}
+
+	return nil
+}
+
+// 筛选匹配通知对象的用户
+func matchUsers(db *gorm.DB, n model.Notification) *gorm.DB {
+	db = db.Model(&model.User{})
+
+	if len(n.TargetRoles) > 0 {
+		db = db.Where("role && ?", n.TargetRoles)
+	}
+
+	if len(n.TargetUsers) > 0 {
+		db = db.Where("id IN (?)", []string(n.TargetUsers))
+	}
+
+	if n.MinLevel != nil {
+		db = db.Where("level >= ?", *n.MinLevel)
+	}
+
+	if n.MaxLevel != nil {
+		db = db.Where("level <= ?", *n.MaxLevel)
+	}
+
+	if n.RegisteredAfter != nil {
+		db = db.Where("created_at >= ?", *n.RegisteredAfter)
+	}
+
+	if n.RegisteredBefore != nil {
+		db = db.Where("created_at < ?", *n.RegisteredBefore)
+	}
+
+	return db
+}
+
+// 筛选用户在当前时间可以看到的通知: 已启用, 在发布时间窗口内, 并且用户匹配通知对象
+func visibleTo(db *gorm.DB, userInfo model.User, now time.Time) *gorm.DB {
+	return db.Model(&model.Notification{}).
+		Where("status = ?", model.NotificationStatusActive).
+		Where("(publish_at IS NULL OR publish_at <= ?) AND (expire_at IS NULL OR expire_at > ?)", now, now).
+		Where("(target_roles IS NULL OR target_roles && ?)", userInfo.Role).
+		Where("(target_users IS NULL OR ? = ANY(target_users))", userInfo.Id).
+		Where("(min_level IS NULL OR min_level <= ?) AND (max_level IS NULL OR max_level >= ?)", userInfo.Level, userInfo.Level).
+		Where("(registered_after IS NULL OR registered_after <= ?) AND (registered_before IS NULL OR registered_before > ?)", userInfo.CreatedAt, userInfo.CreatedAt)
+}
+
+// 把到达发布时间的通知推送给匹配的在线用户, 每条通知只推送一次
+// 推送成功之后才标记为已推送, 失败时由下一次定时任务重试
+// 按用户推送时记录推送到的最后一个用户, 重试时不会重复推送给已经推送过的用户
+func deliver(id string) (err error) {
+	var (
+		tx      *gorm.DB
+		rows    *sql.Rows
+		pushErr error
+	)
+
+	n := model.Notification{}
+	now := time.Now()
+
+	tx = database.Db.Begin()
+
+	defer func() {
+		if rows != nil {
+			_ = rows.Close()
+		}
+
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else if err = tx.Commit().Error; err == nil {
+			// 推送失败时也要提交已经推送的进度
+			err = pushErr
+		}
+	}()
+
+	// 锁定这条通知, 多个实例同时执行时, 其他实例会跳过正在推送的通知
+	if err = tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("id = ? AND pushed_at IS NULL AND status = ?", id, model.NotificationStatusActive).
+		Where("(publish_at IS NULL OR publish_at <= ?) AND (expire_at IS NULL OR expire_at > ?)", now, now).
+		First(&n).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		return
+	}
+
+	data := schema.Notification{}
+
+	if err = mapToSchema(n, &data); err != nil {
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	if len(n.TargetRoles) == 0 && len(n.TargetUsers) == 0 && n.MinLevel == nil && n.MaxLevel == nil && n.RegisteredAfter == nil && n.RegisteredBefore == nil {
+		pushErr = push.Broadcast(push.EventNotification, data)
+	} else {
+		users := matchUsers(database.Db, n)
+
+		if n.PushedUid != nil {
+			users = users.Where("id > ?", *n.PushedUid)
+		}
+
+		if rows, err = users.Order("id ASC").Select("id").Rows(); err != nil {
+			return
+		}
+
+		for rows.Next() {
+			var uid string
+
+			if err = rows.Scan(&uid); err != nil {
+				return
+			}
+
+			if pushErr = push.Publish(uid, push.EventNotification, data); pushErr != nil {
+				break
+			}
+
+			updates["pushed_uid"] = uid
+		}
+
+		if err = rows.Err(); err != nil {
+			return
+		}
+	}
+
+	if pushErr == nil {
+		updates["pushed_at"] = now
+	}
+
+	if len(updates) > 0 {
+		err = tx.Model(&n).UpdateColumns(updates).Error
+	}
+
+	return
+}
+
+// 推送所有到达发布时间并且还没有推送的通知, 一条通知推送失败不影响其他通知
+func DeliverNotifications() (err error) {
+	ids := make([]string, 0)
+	now := time.Now()
+
+	if err = database.Db.Model(&model.Notification{}).
+		Where("pushed_at IS NULL AND status = ?", model.NotificationStatusActive).
+		Where("(publish_at IS NULL OR publish_at <= ?) AND (expire_at IS NULL OR expire_at > ?)", now, now).
+		Pluck("id", &ids).Error; err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		if e := deliver(id); e != nil {
+			fmt.Printf("推送系统通知 %s 失败: %s\n", id, e.Error())
+		}
+	}
+
+	return
+}
+
+// 定时推送到达发布时间的通知
+func RunDeliverWorker() {
+	ticker := time.NewTicker(config.Notification.PushInterval)
+
+	for range ticker.C {
+		if err := DeliverNotifications(); err != nil {
+			fmt.Printf("推送系统通知失败: %s\n", err.Error())
+		}
+	}
+}
+
+// 管理员预览通知对象匹配的用户数量
+func PreviewTarget(context controller.Context, input TargetParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.NotificationTargetPreview
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminNotificationCreate); err != nil {
+		return
+	}
+
+	n := model.Notification{}
+
+	if err = setTarget(&n, input); err != nil {
+		return
+	}
+
+	err = matchUsers(database.Db, n).Count(&data.Count).Error
+
+	return
+}
+
+func PreviewTargetRouter(context *gin.Context) {
+	var (
+		input TargetParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = PreviewTarget(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
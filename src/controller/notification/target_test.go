@@ -0,0 +1,158 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package notification_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/notification"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTarget(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+	otherInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer auth.DeleteUserByUserName(otherInfo.Username)
+
+	context := controller.Context{
+		Uid: adminInfo.Id,
+	}
+
+	target := notification.TargetParams{
+		Users: []string{userInfo.Id},
+	}
+
+	// 预览匹配的用户数量
+	{
+		r := notification.PreviewTarget(context, target)
+		preview := schema.NotificationTargetPreview{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &preview))
+		assert.Equal(t, int64(1), preview.Count)
+	}
+
+	// 等级范围不合法
+	{
+		var min, max int32 = 3, 1
+
+		r := notification.PreviewTarget(context, notification.TargetParams{MinLevel: &min, MaxLevel: &max})
+
+		assert.Equal(t, exception.InvalidNotificationTarget.Error(), r.Message)
+	}
+
+	// 只通知指定的用户
+	{
+		r := notification.Create(context, notification.CreateParams{
+			Title:   "TestTarget",
+			Content: "TestTarget",
+			Target:  &target,
+		})
+
+		assert.Equal(t, "", r.Message)
+
+		n := schema.Notification{}
+
+		assert.Nil(t, tester.Decode(r.Data, &n))
+
+		defer notification.DeleteNotificationById(n.Id)
+
+		assert.Equal(t, "", notification.Get(controller.Context{Uid: userInfo.Id}, n.Id).Message)
+		assert.Equal(t, exception.NoData.Error(), notification.Get(controller.Context{Uid: otherInfo.Id}, n.Id).Message)
+		assert.Equal(t, exception.NoData.Error(), notification.MarkRead(controller.Context{Uid: otherInfo.Id}, n.Id).Message)
+	}
+
+	// 还没到发布时间的通知
+	{
+		publishAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+		expireAt := time.Now().Format(time.RFC3339)
+
+		r := notification.Create(context, notification.CreateParams{
+			Title:   "TestTarget",
+			Content: "TestTarget",
+			WindowParams: notification.WindowParams{
+				PublishAt: &publishAt,
+				ExpireAt:  &expireAt,
+			},
+		})
+
+		assert.Equal(t, exception.InvalidNotificationWindow.Error(), r.Message)
+
+		r = notification.Create(context, notification.CreateParams{
+			Title:   "TestTarget",
+			Content: "TestTarget",
+			WindowParams: notification.WindowParams{
+				PublishAt: &publishAt,
+			},
+		})
+
+		assert.Equal(t, "", r.Message)
+
+		n := schema.Notification{}
+
+		assert.Nil(t, tester.Decode(r.Data, &n))
+
+		defer notification.DeleteNotificationById(n.Id)
+
+		assert.Equal(t, exception.NoData.Error(), notification.Get(controller.Context{Uid: userInfo.Id}, n.Id).Message)
+
+		list := notification.GetNotificationListByUser(controller.Context{Uid: userInfo.Id}, notification.Query{})
+		data := make([]schema.Notification, 0)
+
+		assert.Equal(t, "", list.Message)
+		assert.Nil(t, tester.Decode(list.Data, &data))
+
+		for _, v := range data {
+			assert.NotEqual(t, n.Id, v.Id)
+		}
+
+		// 管理员可以看到推送状态
+		detail := schema.NotificationAdmin{}
+		r = notification.GetByAdmin(context, n.Id)
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &detail))
+		assert.Nil(t, detail.PushedAt)
+		assert.NotNil(t, detail.PublishAt)
+	}
+
+	// 到达发布时间的通知推送之后才标记为已推送
+	{
+		r := notification.Create(context, notification.CreateParams{
+			Title:   "TestTarget",
+			Content: "TestTarget",
+			Target:  &target,
+		})
+
+		assert.Equal(t, "", r.Message)
+
+		n := schema.Notification{}
+
+		assert.Nil(t, tester.Decode(r.Data, &n))
+
+		defer notification.DeleteNotificationById(n.Id)
+
+		detail := schema.NotificationAdmin{}
+
+		// 创建之后的推送在另一个协程中执行, 正在推送的通知会被定时任务跳过
+		for i := 0; i < 10 && detail.PushedAt == nil; i++ {
+			assert.Nil(t, notification.DeliverNotifications())
+
+			r = notification.GetByAdmin(context, n.Id)
+
+			assert.Equal(t, "", r.Message)
+			assert.Nil(t, tester.Decode(r.Data, &detail))
+
+			time.Sleep(time.Millisecond * 100)
+		}
+
+		assert.NotNil(t, detail.PushedAt)
+	}
+}
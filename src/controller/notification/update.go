@@ -12,15 +12,15 @@ import (
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
 	"net/http"
-	"time"
 )
 
 type UpdateParams struct {
 	Title   *string `json:"title"`   // 公告标题
 	Content *string `json:"content"` // 公告内容
 	Note    *string `json:"note"`    // 备注
+	WindowParams
+	Target *TargetParams `json:"target"` // 通知对象, 传入时会替换原有的通知对象
 }
 
 func Update(context controller.Context, notificationId string, input UpdateParams) (res schema.Response) {
@@ -92,30 +92,64 @@ func Update(context controller.Context, notificationId string, input UpdateParam
 		return
 	}
 
-	updateModel := model.Notification{}
+	updated := notificationInfo
+	updateModel := map[string]interface{}{}
 
 	if input.Title != nil && len(*input.Title) != 0 {
-		updateModel.Title = *input.Title
+		updateModel["title"] = *input.Title
 	}
 
 	if input.Content != nil && len(*input.Content) != 0 {
-		updateModel.Content = *input.Content
+		updateModel["content"] = *input.Content
 	}
 
 	if input.Note != nil {
-		updateModel.Note = input.Note
+		updateModel["note"] = input.Note
 	}
 
-	if err = tx.Model(&notificationInfo).Updates(&updateModel).Error; err != nil {
+	if input.Target != nil {
+		if err = setTarget(&updated, *input.Target); err != nil {
+			return
+		}
+
+		updateModel["target_roles"] = updated.TargetRoles
+		updateModel["target_users"] = updated.TargetUsers
+		updateModel["min_level"] = updated.MinLevel
+		updateModel["max_level"] = updated.MaxLevel
+		updateModel["registered_after"] = updated.RegisteredAfter
+		updateModel["registered_before"] = updated.RegisteredBefore
+	}
+
+	// 传入空字符串表示清空发布时间或者过期时间
+	if input.PublishAt != nil {
+		if updated.PublishAt, err = parseTime(input.PublishAt); err != nil {
+			return
+		}
+		updateModel["publish_at"] = updated.PublishAt
+	}
+
+	if input.ExpireAt != nil {
+		if updated.ExpireAt, err = parseTime(input.ExpireAt); err != nil {
+			return
+		}
+		updateModel["expire_at"] = updated.ExpireAt
+	}
+
+	if err = checkWindow(updated); err != nil {
 		return
 	}
 
-	if err = mapstructure.Decode(notificationInfo, &data.NotificationPure); err != nil {
+	if len(updateModel) != 0 {
+		if err = tx.Model(&notificationInfo).Updates(updateModel).Error; err != nil {
+			return
+		}
+	}
+
+	if err = tx.Where("id = ?", notificationInfo.Id).Last(&notificationInfo).Error; err != nil {
 		return
 	}
 
-	data.CreatedAt = notificationInfo.CreatedAt.Format(time.RFC3339Nano)
-	data.UpdatedAt = notificationInfo.UpdatedAt.Format(time.RFC3339Nano)
+	err = mapToSchema(notificationInfo, &data)
 
 	return
 }
@@ -0,0 +1,38 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package notification
+
+import (
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/mitchellh/mapstructure"
+	"time"
+)
+
+func mapToSchema(n model.Notification, data *schema.Notification) error {
+	if err := mapstructure.Decode(n, &data.NotificationPure); err != nil {
+		return err
+	}
+
+	data.PublishAt = formatTime(n.PublishAt)
+	data.ExpireAt = formatTime(n.ExpireAt)
+	data.CreatedAt = n.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = n.UpdatedAt.Format(time.RFC3339Nano)
+
+	return nil
+}
+
+func mapToAdminSchema(n model.Notification, data *schema.NotificationAdmin) error {
+	if err := mapstructure.Decode(n, &data.NotificationPureAdmin); err != nil {
+		return err
+	}
+
+	data.RegisteredAfter = formatTime(n.RegisteredAfter)
+	data.RegisteredBefore = formatTime(n.RegisteredBefore)
+	data.PublishAt = formatTime(n.PublishAt)
+	data.ExpireAt = formatTime(n.ExpireAt)
+	data.PushedAt = formatTime(n.PushedAt)
+	data.CreatedAt = n.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = n.UpdatedAt.Format(time.RFC3339Nano)
+
+	return nil
+}
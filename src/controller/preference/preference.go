@@ -0,0 +1,281 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package preference
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type SubscriptionParams struct {
+	Event   string `json:"event" valid:"required~请选择通知事件"`   // 事件名称
+	Channel string `json:"channel" valid:"required~请选择通知渠道"` // 通知渠道
+	Enabled bool   `json:"enabled"`                          // 是否开启
+}
+
+type UpdateParams struct {
+	QuietStart    *string              `json:"quiet_start"`   // 免打扰的开始时间, 格式为 HH:MM, 开始和结束时间都为空字符串时关闭免打扰
+	QuietEnd      *string              `json:"quiet_end"`     // 免打扰的结束时间, 格式为 HH:MM
+	Timezone      *string              `json:"timezone"`      // 免打扰时间使用的时区, 例如 Asia/Shanghai
+	Subscriptions []SubscriptionParams `json:"subscriptions"` // 要修改的事件和渠道
+}
+
+func mapToSchema(db *gorm.DB, p model.NotificationPreference, data *schema.NotificationPreference) error {
+	list := make([]model.NotificationSubscription, 0)
+
+	if err := db.Where("uid = ?", p.Uid).Find(&list).Error; err != nil {
+		return err
+	}
+
+	enabled := map[string]bool{}
+
+	for _, s := range list {
+		enabled[s.Event+"/"+s.Channel] = s.Enabled
+	}
+
+	data.QuietStart = p.QuietStart
+	data.QuietEnd = p.QuietEnd
+	data.Timezone = p.Timezone
+	data.Subscriptions = make([]schema.NotificationSubscription, 0)
+
+	for _, event := range model.MessageEvents {
+		for _, channel := range channelNames() {
+			v, ok := enabled[event+"/"+channel]
+
+			if !ok {
+				v = model.NotificationChannels[channel]
+			}
+
+			data.Subscriptions = append(data.Subscriptions, schema.NotificationSubscription{
+				Event:   event,
+				Channel: channel,
+				Enabled: v,
+			})
+		}
+	}
+
+	return nil
+}
+
+// 获取我的通知设置
+func Get(context controller.Context) (res schema.Response) {
+	var (
+		err  error
+		data schema.NotificationPreference
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	p, err := Find(database.Db, context.Uid)
+
+	if err != nil {
+		return
+	}
+
+	err = mapToSchema(database.Db, p, &data)
+
+	return
+}
+
+// 修改我的通知设置
+func Update(context controller.Context, input UpdateParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.NotificationPreference
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	for _, s := range input.Subscriptions {
+		if !isEvent(s.Event) {
+			err = exception.InvalidNotificationEvent
+			return
+		}
+
+		if _, ok := model.NotificationChannels[s.Channel]; !ok {
+			err = exception.InvalidNotificationChannel
+			return
+		}
+	}
+
+	tx = database.Db.Begin()
+
+	userInfo := model.User{Id: context.Uid}
+
+	if err = tx.First(&userInfo).Error; err != nil {
+		// 没有找到用户
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	p, err := Find(tx, userInfo.Id)
+
+	if err != nil {
+		return
+	}
+
+	updated := map[string]interface{}{}
+
+	if input.QuietStart != nil || input.QuietEnd != nil {
+		if input.QuietStart == nil || input.QuietEnd == nil {
+			err = exception.InvalidQuietHours
+			return
+		}
+
+		if *input.QuietStart == "" && *input.QuietEnd == "" {
+			// 关闭免打扰
+			updated["quiet_start"] = nil
+			updated["quiet_end"] = nil
+		} else {
+			if _, err = parseClock(*input.QuietStart); err != nil {
+				return
+			}
+
+			if _, err = parseClock(*input.QuietEnd); err != nil {
+				return
+			}
+
+			updated["quiet_start"] = *input.QuietStart
+			updated["quiet_end"] = *input.QuietEnd
+		}
+	}
+
+	if input.Timezone != nil {
+		if _, er := time.LoadLocation(*input.Timezone); er != nil || *input.Timezone == "" {
+			err = exception.InvalidTimezone
+			return
+		}
+
+		updated["timezone"] = *input.Timezone
+	}
+
+	if len(updated) != 0 {
+		if err = tx.Model(&p).Updates(updated).Error; err != nil {
+			return
+		}
+	}
+
+	for _, s := range input.Subscriptions {
+		if err = subscribe(tx, userInfo.Id, s.Event, s.Channel, s.Enabled); err != nil {
+			return
+		}
+	}
+
+	if err = tx.Where("uid = ?", userInfo.Id).First(&p).Error; err != nil {
+		return
+	}
+
+	err = mapToSchema(tx, p, &data)
+
+	return
+}
+
+func GetRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Get(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	})
+}
+
+func UpdateRouter(context *gin.Context) {
+	var (
+		input UpdateParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Update(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package preference_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/preference"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuietDelay(t *testing.T) {
+	start, end := "22:00", "08:00"
+
+	p := model.NotificationPreference{
+		QuietStart: &start,
+		QuietEnd:   &end,
+		Timezone:   "UTC",
+	}
+
+	// 跨天的免打扰时间
+	assert.Equal(t, 10*time.Hour, preference.QuietDelay(p, time.Date(2019, 1, 1, 22, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 90*time.Minute, preference.QuietDelay(p, time.Date(2019, 1, 1, 6, 30, 0, 0, time.UTC)))
+	assert.Equal(t, time.Duration(0), preference.QuietDelay(p, time.Date(2019, 1, 1, 8, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Duration(0), preference.QuietDelay(p, time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)))
+
+	// 同一天的免打扰时间
+	start, end = "12:00", "14:00"
+
+	assert.Equal(t, 30*time.Minute, preference.QuietDelay(p, time.Date(2019, 1, 1, 13, 30, 0, 0, time.UTC)))
+	assert.Equal(t, time.Duration(0), preference.QuietDelay(p, time.Date(2019, 1, 1, 22, 0, 0, 0, time.UTC)))
+
+	// 没有设置免打扰
+	assert.Equal(t, time.Duration(0), preference.QuietDelay(model.NotificationPreference{}, time.Now()))
+}
+
+func TestUpdate(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer database.DeleteRowByTable("notification_preference", "uid", userInfo.Id)
+	defer database.DeleteRowByTable("notification_subscription", "uid", userInfo.Id)
+
+	context := controller.Context{Uid: userInfo.Id}
+
+	start, end, timezone := "22:00", "08:00", "UTC"
+
+	r := preference.Update(context, preference.UpdateParams{
+		QuietStart: &start,
+		QuietEnd:   &end,
+		Timezone:   &timezone,
+		Subscriptions: []preference.SubscriptionParams{
+			{Event: model.MessageEventTransferIn, Channel: model.NotificationChannelSMS, Enabled: true},
+			{Event: model.MessageEventTransferIn, Channel: model.NotificationChannelPush, Enabled: false},
+		},
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	data := schema.NotificationPreference{}
+
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, start, *data.QuietStart)
+	assert.Equal(t, "UTC", data.Timezone)
+	assert.Len(t, data.Subscriptions, len(model.MessageEvents)*len(model.NotificationChannels))
+
+	channels, err := preference.Channels(database.Db, userInfo.Id, model.MessageEventTransferIn)
+
+	assert.Nil(t, err)
+	assert.True(t, channels[model.NotificationChannelSMS])
+	assert.False(t, channels[model.NotificationChannelPush])
+	assert.True(t, channels[model.NotificationChannelEmail])
+
+	// 无效的参数
+	invalid := "25:00"
+
+	r = preference.Update(context, preference.UpdateParams{QuietStart: &invalid, QuietEnd: &end})
+	assert.Equal(t, exception.InvalidQuietHours.Error(), r.Message)
+
+	r = preference.Update(context, preference.UpdateParams{Subscriptions: []preference.SubscriptionParams{
+		{Event: "unknown", Channel: model.NotificationChannelSMS},
+	}})
+	assert.Equal(t, exception.InvalidNotificationEvent.Error(), r.Message)
+
+	// 通过退订链接关闭邮件通知
+	p, err := preference.Find(database.Db, userInfo.Id)
+
+	assert.Nil(t, err)
+
+	r = preference.Unsubscribe(p.UnsubscribeToken, model.MessageEventTransferIn)
+	assert.Equal(t, "", r.Message)
+
+	enabled, err := preference.Enabled(database.Db, userInfo.Id, model.MessageEventTransferIn, model.NotificationChannelEmail)
+
+	assert.Nil(t, err)
+	assert.False(t, enabled)
+
+	r = preference.Unsubscribe("invalid", "")
+	assert.Equal(t, exception.InvalidUnsubscribeToken.Error(), r.Message)
+}
+
+func TestFindConcurrent(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer database.DeleteRowByTable("notification_preference", "uid", userInfo.Id)
+
+	const n = 10
+
+	var wg sync.WaitGroup
+
+	tokens := make([]string, n)
+	errs := make([]error, n)
+
+	// 同时创建默认设置, 都应该成功并且拿到同一条记录
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			p, err := preference.Find(database.Db, userInfo.Id)
+
+			tokens[i] = p.UnsubscribeToken
+			errs[i] = err
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		assert.Nil(t, errs[i])
+		assert.NotEqual(t, "", tokens[i])
+		assert.Equal(t, tokens[0], tokens[i])
+	}
+}
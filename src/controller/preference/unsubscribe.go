@@ -0,0 +1,109 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package preference
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"net/url"
+)
+
+// 邮件中的退订链接, 不需要登陆
+func UnsubscribeURL(p model.NotificationPreference, event string) string {
+	return config.User.Domain + "/v1/unsubscribe/" + p.UnsubscribeToken + "?event=" + url.QueryEscape(event)
+}
+
+// 通过退订链接关闭邮件通知, 没有指定事件时关闭所有事件的邮件通知
+func Unsubscribe(token string, event string) (res schema.Response) {
+	var (
+		err error
+		tx  *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = false
+			res.Message = err.Error()
+		} else {
+			res.Data = true
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	events := model.MessageEvents
+
+	if event != "" {
+		if !isEvent(event) {
+			err = exception.InvalidNotificationEvent
+			return
+		}
+
+		events = []string{event}
+	}
+
+	if token == "" {
+		err = exception.InvalidUnsubscribeToken
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	p := model.NotificationPreference{}
+
+	if err = tx.Where("unsubscribe_token = ?", token).First(&p).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.InvalidUnsubscribeToken
+		}
+		return
+	}
+
+	for _, e := range events {
+		if err = subscribe(tx, p.Uid, e, model.NotificationChannelEmail, false); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func UnsubscribeRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Unsubscribe(context.Param("token"), context.Query("event"))
+}
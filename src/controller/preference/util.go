@@ -0,0 +1,152 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package preference
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"time"
+)
+
+// 获取用户的通知设置, 没有时创建一条默认的设置
+func Find(db *gorm.DB, uid string) (p model.NotificationPreference, err error) {
+	if err = db.Where("uid = ?", uid).First(&p).Error; err != gorm.ErrRecordNotFound {
+		return
+	}
+
+	// 同时有多个请求创建时只有一个会成功, 其他请求忽略冲突之后重新查询
+	if err = db.Set("gorm:insert_option", "ON CONFLICT (uid) DO NOTHING").Create(&model.NotificationPreference{
+		Uid:      uid,
+		Timezone: config.Message.DefaultTimezone,
+	}).Error; err != nil {
+		return
+	}
+
+	err = db.Where("uid = ?", uid).First(&p).Error
+
+	return
+}
+
+// 获取用户某个事件每个渠道是否开启, 没有设置的渠道使用默认设置
+func Channels(db *gorm.DB, uid string, event string) (channels map[string]bool, err error) {
+	list := make([]model.NotificationSubscription, 0)
+
+	if err = db.Where("uid = ? AND event = ?", uid, event).Find(&list).Error; err != nil {
+		return
+	}
+
+	channels = map[string]bool{}
+
+	for channel, enabled := range model.NotificationChannels {
+		channels[channel] = enabled
+	}
+
+	for _, s := range list {
+		channels[s.Channel] = s.Enabled
+	}
+
+	return
+}
+
+// 用户的某个事件是否开启了这个渠道
+func Enabled(db *gorm.DB, uid string, event string, channel string) (bool, error) {
+	channels, err := Channels(db, uid, event)
+
+	if err != nil {
+		return false, err
+	}
+
+	return channels[channel], nil
+}
+
+// 现在到免打扰结束还有多久, 不在免打扰时间内返回 0
+func QuietDelay(p model.NotificationPreference, now time.Time) time.Duration {
+	if p.QuietStart == nil || p.QuietEnd == nil {
+		return 0
+	}
+
+	start, err := parseClock(*p.QuietStart)
+
+	if err != nil {
+		return 0
+	}
+
+	end, err := parseClock(*p.QuietEnd)
+
+	if err != nil || start == end {
+		return 0
+	}
+
+	local := now.In(location(p.Timezone))
+	minute := local.Hour()*60 + local.Minute()
+
+	// 结束时间小于开始时间表示跨天, 例如 22:00 到 08:00
+	if start < end && (minute < start || minute >= end) {
+		return 0
+	} else if start > end && minute < start && minute >= end {
+		return 0
+	}
+
+	remaining := end - minute
+
+	if remaining <= 0 {
+		remaining += 24 * 60
+	}
+
+	return time.Duration(remaining)*time.Minute - time.Duration(local.Second())*time.Second
+}
+
+// 解析 HH:MM 格式的时间, 返回这一天的第几分钟
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+
+	if err != nil {
+		return 0, exception.InvalidQuietHours
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func location(timezone string) *time.Location {
+	if loc, err := time.LoadLocation(timezone); err == nil {
+		return loc
+	}
+
+	return time.Local
+}
+
+func isEvent(event string) bool {
+	for _, e := range model.MessageEvents {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 按照名称排序的渠道, 保证接口输出的顺序稳定
+func channelNames() []string {
+	names := make([]string, 0, len(model.NotificationChannels))
+
+	for channel := range model.NotificationChannels {
+		names = append(names, channel)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// 更新用户某个事件某个渠道的设置
+func subscribe(tx *gorm.DB, uid string, event string, channel string, enabled bool) error {
+	return tx.Where(model.NotificationSubscription{
+		Uid:     uid,
+		Event:   event,
+		Channel: channel,
+	}).Assign(map[string]interface{}{
+		"enabled": enabled,
+	}).FirstOrCreate(&model.NotificationSubscription{}).Error
+}
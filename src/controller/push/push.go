@@ -0,0 +1,165 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package push
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/push"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ping, _ = json.Marshal(push.Event{Type: push.EventPing})
+
+// 检查网页的来源, 防止其他网站借助浏览器自动携带的 Cookie 建立推送连接
+// 没有 Origin 头的请求不是来自浏览器的跨站请求, 身份已经通过 Token 验证
+func isAllowedOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	allowed := config.Push.Origins
+
+	if len(allowed) == 0 {
+		allowed = []string{config.User.Domain}
+	}
+
+	for _, item := range allowed {
+		if a, err := url.Parse(item); err == nil && strings.EqualFold(a.Scheme, u.Scheme) && strings.EqualFold(a.Host, u.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func rejectOrigin(context *gin.Context) {
+	context.JSON(http.StatusForbidden, schema.Response{
+		Message: exception.InvalidOrigin.Error(),
+		Data:    nil,
+	})
+}
+
+// 通过 WebSocket 推送事件, 客户端不需要发送任何数据
+// 浏览器无法设置 WebSocket 的请求头, 可以通过 Cookie 或者查询参数传递 Token
+func WebSocketRouter(context *gin.Context) {
+	uid := context.GetString(middleware.ContextUidField)
+
+	if !isAllowedOrigin(context.GetHeader("Origin")) {
+		rejectOrigin(context)
+		return
+	}
+
+	server := websocket.Server{
+		// 来源已经在上面检查过
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+
+			// 清除 HTTP 服务器设置的读写超时, 连接由心跳维持
+			_ = conn.SetDeadline(time.Time{})
+
+			s := push.Subscribe(uid)
+
+			defer push.Unsubscribe(s)
+
+			// 读取客户端的数据, 只用于发现连接已经断开
+			closed := make(chan bool)
+
+			go func() {
+				var discard string
+				for {
+					if err := websocket.Message.Receive(conn, &discard); err != nil {
+						close(closed)
+						return
+					}
+				}
+			}()
+
+			ticker := time.NewTicker(config.Push.Heartbeat)
+
+			defer ticker.Stop()
+
+			for {
+				var payload []byte
+
+				select {
+				case <-closed:
+					return
+				case payload = <-s.C:
+				case <-ticker.C:
+					payload = ping
+				}
+
+				if err := websocket.Message.Send(conn, string(payload)); err != nil {
+					return
+				}
+			}
+		},
+	}
+
+	server.ServeHTTP(context.Writer, context.Request)
+}
+
+// 不支持 WebSocket 时的降级方案, 通过 Server-Sent Events 推送事件
+func SSERouter(context *gin.Context) {
+	uid := context.GetString(middleware.ContextUidField)
+
+	if !isAllowedOrigin(context.GetHeader("Origin")) {
+		rejectOrigin(context)
+		return
+	}
+
+	s := push.Subscribe(uid)
+
+	defer push.Unsubscribe(s)
+
+	ticker := time.NewTicker(config.Push.Heartbeat)
+
+	defer ticker.Stop()
+
+	header := context.Writer.Header()
+
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 禁止 nginx 缓存响应
+
+	context.Status(http.StatusOK)
+
+	// HTTP 服务器的写超时会断开长连接, 让客户端尽快重连
+	_, _ = context.Writer.Write([]byte("retry: 1000\n\n"))
+
+	context.Stream(func(w io.Writer) bool {
+		var err error
+
+		select {
+		case <-context.Request.Context().Done():
+			return false
+		case payload := <-s.C:
+			// 事件都是 JSON, 不会包含换行
+			_, err = w.Write([]byte("data: " + string(payload) + "\n\n"))
+		case <-ticker.C:
+			// 注释行, 客户端会忽略
+			_, err = w.Write([]byte(": ping\n\n"))
+		}
+
+		return err == nil
+	})
+}
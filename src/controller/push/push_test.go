@@ -0,0 +1,34 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package push
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsAllowedOrigin(t *testing.T) {
+	origins := config.Push.Origins
+
+	defer func() {
+		config.Push.Origins = origins
+	}()
+
+	// 默认只允许用户端的域名
+	config.Push.Origins = nil
+
+	assert.True(t, isAllowedOrigin(""))
+	assert.True(t, isAllowedOrigin(config.User.Domain))
+	assert.False(t, isAllowedOrigin("https://evil.example.com"))
+	assert.False(t, isAllowedOrigin("null"))
+
+	config.Push.Origins = []string{"https://example.com", "https://app.example.com:8443"}
+
+	assert.True(t, isAllowedOrigin("https://example.com"))
+	assert.True(t, isAllowedOrigin("HTTPS://Example.com"))
+	assert.True(t, isAllowedOrigin("https://app.example.com:8443"))
+	assert.False(t, isAllowedOrigin("http://example.com"))
+	assert.False(t, isAllowedOrigin("https://example.com.evil.com"))
+	assert.False(t, isAllowedOrigin("https://app.example.com"))
+	assert.False(t, isAllowedOrigin(config.User.Domain))
+}
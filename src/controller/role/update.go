@@ -5,16 +5,19 @@ import (
 	"errors"
 	"github.com/asaskevich/govalidator"
 	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/message"
 	"github.com/axetroy/go-server/src/exception"
 	"github.com/axetroy/go-server/src/middleware"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/rbac/accession"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"github.com/mitchellh/mapstructure"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -179,9 +182,10 @@ func UpdateRouter(context *gin.Context) {
 
 func UpdateUserRole(context controller.Context, userId string, input UpdateUserRoleParams) (res schema.Response) {
 	var (
-		err  error
-		data schema.Profile
-		tx   *gorm.DB
+		err    error
+		data   schema.Profile
+		tx     *gorm.DB
+		notice *message.Notice
 	)
 
 	defer func() {
@@ -210,6 +214,12 @@ func UpdateUserRole(context controller.Context, userId string, input UpdateUserR
 		} else {
 			res.Data = data
 			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再发送到用户开启的其他渠道
+			message.Dispatch(notice)
+
+			// 角色变更之后权限也变了, 强制用户重新登陆
+			_ = push.Publish(userId, push.EventLogout, nil)
 		}
 	}()
 
@@ -266,6 +276,13 @@ func UpdateUserRole(context controller.Context, userId string, input UpdateUserR
 		return
 	}
 
+	// 通知用户角色变更
+	if notice, err = message.Notify(tx, model.MessageEventRoleChanged, userInfo.Id, map[string]interface{}{
+		"Roles": strings.Join(input.Roles, ", "),
+	}); err != nil {
+		return
+	}
+
 	if err = mapstructure.Decode(userInfo, &data.ProfilePure); err != nil {
 		return
 	}
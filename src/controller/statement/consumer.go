@@ -0,0 +1,38 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package statement
+
+import (
+	"github.com/axetroy/go-server/src/message_queue"
+	"github.com/nsqio/go-nsq"
+	"time"
+)
+
+// 消费生成对账单的消息, 返回错误时消息会重新入队
+func handleMessage(message *nsq.Message) error {
+	done := make(chan struct{})
+
+	defer close(done)
+
+	// 日期范围大的对账单生成时间可能会超过消息的超时时间, 定时告诉消息队列还在处理
+	go func() {
+		ticker := time.NewTicker(message_queue.Config.MsgTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				message.Touch()
+			}
+		}
+	}()
+
+	return Generate(string(message.Body))
+}
+
+// 启动生成对账单的消费者
+func RunStatementConsumer() {
+	if _, err := message_queue.CreateConsumer(message_queue.TopicGenerateStatement, message_queue.ChanelGenerateStatement, nsq.HandlerFunc(handleMessage)); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package statement
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/message_queue"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+type CreateParams struct {
+	Currency string `json:"currency" valid:"required~请选择币种"`                     // 哪个币种的钱包
+	Format   string `json:"format" valid:"required~请选择格式,in(csv|pdf)~不支持的对账单格式"` // 文件格式, csv/pdf
+	Start    string `json:"start" valid:"required~请输入开始日期"`                      // 开始日期, 格式 2006-01-02
+	End      string `json:"end" valid:"required~请输入结束日期"`                        // 结束日期, 格式 2006-01-02, 包括这一天
+}
+
+type CreateByAdminParams struct {
+	CreateParams
+	Uid string `json:"uid" valid:"required~请输入用户ID"` // 哪个用户的钱包
+}
+
+func mapToSchema(model model.Statement, d *schema.Statement) {
+	d.Id = model.Id
+	d.Uid = model.Uid
+	d.Currency = model.Currency
+	d.Format = model.Format
+	d.Status = model.Status
+	d.Filename = model.Filename
+	d.Error = model.Error
+	d.Creator = model.Creator
+	d.StartAt = model.StartAt.Format(time.RFC3339Nano)
+	d.EndAt = model.EndAt.Format(time.RFC3339Nano)
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 创建对账单, 日期范围小的直接生成, 大的交给消息队列生成
+func create(uid string, creator string, input CreateParams) (data schema.Statement, err error) {
+	var (
+		isValidInput bool
+		c            model.Currency
+	)
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	s := model.Statement{
+		Uid:     uid,
+		Format:  model.StatementFormat(input.Format),
+		Status:  model.StatementStatusPending,
+		Creator: creator,
+	}
+
+	if s.StartAt, err = time.ParseInLocation(dateFormat, input.Start, time.Local); err != nil {
+		err = exception.InvalidStatementRange
+		return
+	}
+
+	if s.EndAt, err = time.ParseInLocation(dateFormat, input.End, time.Local); err != nil {
+		err = exception.InvalidStatementRange
+		return
+	}
+
+	// 结束日期当天也包括在内
+	s.EndAt = s.EndAt.AddDate(0, 0, 1)
+
+	if !s.EndAt.After(s.StartAt) {
+		err = exception.InvalidStatementRange
+		return
+	}
+
+	days := int(s.EndAt.Sub(s.StartAt).Hours()/24 + 0.5)
+
+	if days > config.Statement.MaxDays {
+		err = exception.StatementRangeTooLarge
+		return
+	}
+
+	// 已经停用的币种也可以查看历史的对账单
+	if err = database.Db.Where("code = ?", strings.ToUpper(input.Currency)).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.CurrencyNotExist
+		}
+		return
+	}
+
+	s.Currency = c.Code
+
+	if err = database.Db.Create(&s).Error; err != nil {
+		return
+	}
+
+	if days <= config.Statement.SyncDays {
+		if err = Generate(s.Id); err != nil {
+			return
+		}
+	} else if err = message_queue.Publish(message_queue.TopicGenerateStatement, []byte(s.Id)); err != nil {
+		// 消息发不出去的话, 这份对账单永远不会生成
+		reason := err.Error()
+		_ = database.Db.Model(&s).Updates(map[string]interface{}{
+			"status": model.StatementStatusFailed,
+			"error":  &reason,
+		}).Error
+		return
+	}
+
+	if err = database.Db.Where("id = ?", s.Id).First(&s).Error; err != nil {
+		return
+	}
+
+	mapToSchema(s, &data)
+
+	return
+}
+
+// 用户生成自己钱包的对账单
+func Create(context controller.Context, input CreateParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.Statement
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	data, err = create(context.Uid, context.Uid, input)
+
+	return
+}
+
+// 管理员生成用户钱包的对账单
+func CreateByAdmin(context controller.Context, input CreateByAdminParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.Statement
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminFinanceStatement); err != nil {
+		return
+	}
+
+	userInfo := model.User{Id: input.Uid}
+
+	if err = database.Db.First(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	data, err = create(userInfo.Id, context.Uid, input.CreateParams)
+
+	return
+}
+
+func CreateRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input CreateParams
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Create(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func CreateByAdminRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input CreateByAdminParams
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = CreateByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
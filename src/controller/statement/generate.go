@@ -0,0 +1,358 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/axetroy/go-server/src/controller/uploader"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"io/ioutil"
+	"path"
+	"time"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+var (
+	// 流水类型在对账单中显示的名字
+	typeNames = map[model.FinanceType]string{
+		model.FinanceTypeTransferIn:     "转入",
+		model.FinanceTypeTransferOut:    "转出",
+		model.FinanceTypeTransferFrozen: "转出待确认",
+		model.FinanceTypeTransferRefund: "转账退回",
+		model.FinanceTypeReversalOut:    "转账撤回",
+		model.FinanceTypeReversalIn:     "转账撤回退款",
+		model.FinanceTypeAdminCredit:    "系统充值",
+		model.FinanceTypeAdminDebit:     "系统扣除",
+		model.FinanceTypeFreeze:         "冻结",
+		model.FinanceTypeUnfreeze:       "解冻",
+		model.FinanceTypeOpening:        "期初余额",
+		model.FinanceTypeExchangeOut:    "兑换转出",
+		model.FinanceTypeExchangeIn:     "兑换转入",
+		model.FinanceTypeInviteReward:   "邀请奖励",
+	}
+
+	// 这些类型的流水, orderId 是转账记录的ID, 交易对方是转账的另一方
+	transferTypes = map[model.FinanceType]bool{
+		model.FinanceTypeTransferIn:     true,
+		model.FinanceTypeTransferOut:    true,
+		model.FinanceTypeTransferFrozen: true,
+		model.FinanceTypeTransferRefund: true,
+		model.FinanceTypeReversalOut:    true,
+		model.FinanceTypeReversalIn:     true,
+	}
+)
+
+const (
+	counterpartySystem   = "系统"
+	counterpartyExchange = "兑换"
+)
+
+// 对账单的内容
+type content struct {
+	Statement model.Statement
+	Username  string
+	Opening   decimal.Decimal // 期初余额
+	Closing   decimal.Decimal // 期末余额
+	Rows      []schema.StatementRow
+}
+
+// 根据流水生成对账单的内容
+func build(db *gorm.DB, s model.Statement) (c content, err error) {
+	var (
+		logs      = make([]model.FinanceLog, 0)
+		transfers = make([]model.TransferLog, 0)
+		users     = make([]model.User, 0)
+		orderIds  = make([]string, 0)
+		userInfo  = model.User{Id: s.Uid}
+	)
+
+	c.Statement = s
+	c.Rows = make([]schema.StatementRow, 0)
+
+	if err = db.First(&userInfo).Error; err != nil {
+		return
+	}
+
+	c.Username = userInfo.Username
+
+	if err = db.Where("uid = ? AND currency = ? AND created_at >= ? AND created_at < ?", s.Uid, s.Currency, s.StartAt, s.EndAt).Order("sequence ASC").Find(&logs).Error; err != nil {
+		return
+	}
+
+	if len(logs) > 0 {
+		c.Opening = logs[0].BeforeBalance
+		c.Closing = logs[len(logs)-1].AfterBalance
+	} else {
+		// 这段时间没有流水, 余额等于之前最后一条流水之后的余额
+		last := model.FinanceLog{}
+
+		if err = db.Where("uid = ? AND currency = ? AND created_at < ?", s.Uid, s.Currency, s.StartAt).Order("sequence DESC").First(&last).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				return
+			}
+			err = nil
+		}
+
+		c.Opening = last.AfterBalance
+		c.Closing = last.AfterBalance
+	}
+
+	for _, log := range logs {
+		if transferTypes[log.Type] && log.OrderId != "" {
+			orderIds = append(orderIds, log.OrderId)
+		}
+	}
+
+	transferMap := map[string]model.TransferLog{}
+	usernameMap := map[string]string{}
+
+	if len(orderIds) > 0 {
+		if err = db.Where("id IN (?)", orderIds).Find(&transfers).Error; err != nil {
+			return
+		}
+
+		uids := make([]string, 0)
+
+		for _, t := range transfers {
+			transferMap[t.Id] = t
+			uids = append(uids, t.From, t.To)
+		}
+
+		if len(uids) > 0 {
+			if err = db.Where("id IN (?)", uids).Find(&users).Error; err != nil {
+				return
+			}
+		}
+
+		for _, u := range users {
+			usernameMap[u.Id] = u.Username
+		}
+	}
+
+	for _, log := range logs {
+		row := schema.StatementRow{
+			Time:    log.CreatedAt.Format(timeFormat),
+			Type:    string(log.Type),
+			OrderId: log.OrderId,
+			Amount:  util.AmountToStr(log.BalanceMutation),
+			Frozen:  util.AmountToStr(log.FrozenMutation),
+			Balance: util.AmountToStr(log.AfterBalance),
+			Note:    log.Note,
+		}
+
+		if name, ok := typeNames[log.Type]; ok {
+			row.Type = name
+		}
+
+		switch {
+		case transferTypes[log.Type]:
+			if t, ok := transferMap[log.OrderId]; ok {
+				other := t.To
+				if other == s.Uid {
+					other = t.From
+				}
+				row.Counterparty = usernameMap[other]
+				if row.Note == nil {
+					row.Note = t.Note
+				}
+			}
+		case log.Type == model.FinanceTypeExchangeIn || log.Type == model.FinanceTypeExchangeOut:
+			row.Counterparty = counterpartyExchange
+		default:
+			row.Counterparty = counterpartySystem
+		}
+
+		c.Rows = append(c.Rows, row)
+	}
+
+	return
+}
+
+func (c content) period() string {
+	// 结束时间不包含在内, 显示的时候显示为前一天
+	return fmt.Sprintf("%s ~ %s", c.Statement.StartAt.Format("2006-01-02"), c.Statement.EndAt.Add(-time.Nanosecond).Format("2006-01-02"))
+}
+
+// 生成 CSV 格式的对账单
+func renderCSV(c content) ([]byte, error) {
+	buf := bytes.Buffer{}
+
+	// 带上 BOM, 否则 Excel 打开中文会乱码
+	buf.WriteString("\xEF\xBB\xBF")
+
+	w := csv.NewWriter(&buf)
+
+	records := [][]string{
+		{"用户", c.Username},
+		{"币种", c.Statement.Currency},
+		{"期间", c.period()},
+		{"期初余额", util.AmountToStr(c.Opening)},
+		{},
+		{"时间", "类型", "订单号", "交易对方", "金额", "冻结变动", "余额", "备注"},
+	}
+
+	for _, row := range c.Rows {
+		note := ""
+		if row.Note != nil {
+			note = *row.Note
+		}
+		records = append(records, []string{row.Time, row.Type, row.OrderId, row.Counterparty, row.Amount, row.Frozen, row.Balance, note})
+	}
+
+	records = append(records, []string{}, []string{"期末余额", util.AmountToStr(c.Closing)})
+
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// 生成 PDF 格式的对账单
+func renderPDF(c content) []byte {
+	const (
+		margin     = 40.0
+		fontSize   = 8.0
+		lineHeight = 14.0
+	)
+
+	type column struct {
+		title string
+		x     float64
+		width float64
+	}
+
+	columns := []column{
+		{"时间", margin, 76},
+		{"类型", 118, 48},
+		{"交易对方", 168, 72},
+		{"金额", 242, 64},
+		{"冻结变动", 308, 56},
+		{"余额", 366, 64},
+		{"备注", 432, util.PDFPageWidth - margin - 432},
+	}
+
+	pdf := util.NewPDF()
+
+	y := 0.0
+
+	// 表头, 每一页都有
+	header := func() {
+		for _, col := range columns {
+			pdf.Text(col.x, y, fontSize, col.title)
+		}
+		y = y - 4
+		pdf.Line(margin, y, util.PDFPageWidth-margin, y)
+		y = y - lineHeight
+	}
+
+	newPage := func() {
+		pdf.AddPage()
+		y = util.PDFPageHeight - margin
+		header()
+	}
+
+	// 第一页的标题
+	pdf.AddPage()
+	y = util.PDFPageHeight - margin - 4
+	pdf.Text(margin, y, 16, "对账单")
+	y = y - 28
+	for _, line := range []string{
+		"用户: " + c.Username,
+		"币种: " + c.Statement.Currency,
+		"期间: " + c.period(),
+		"期初余额: " + util.AmountToStr(c.Opening),
+	} {
+		pdf.Text(margin, y, 10, line)
+		y = y - 16
+	}
+	y = y - 8
+	header()
+
+	for _, row := range c.Rows {
+		if y < margin {
+			newPage()
+		}
+
+		note := ""
+		if row.Note != nil {
+			note = *row.Note
+		}
+
+		for i, text := range []string{row.Time, row.Type, row.Counterparty, row.Amount, row.Frozen, row.Balance, note} {
+			pdf.Text(columns[i].x, y, fontSize, util.PDFTruncate(text, fontSize, columns[i].width))
+		}
+
+		y = y - lineHeight
+	}
+
+	if y < margin {
+		newPage()
+	}
+
+	pdf.Line(margin, y+lineHeight-4, util.PDFPageWidth-margin, y+lineHeight-4)
+	y = y - 4
+	pdf.Text(margin, y, 10, "期末余额: "+util.AmountToStr(c.Closing))
+
+	return pdf.Bytes()
+}
+
+// 对账单文件的存放路径
+func filePath(filename string) string {
+	return path.Join(uploader.Config.Path, uploader.Config.Statement.Path, filename)
+}
+
+// 生成对账单文件, 已经生成过的对账单会直接跳过
+// 只有数据库出错时返回错误, 生成失败的原因记录在对账单上
+func Generate(id string) (err error) {
+	s := model.Statement{}
+
+	if err = database.Db.Where("id = ?", id).First(&s).Error; err != nil {
+		return
+	}
+
+	if s.Status != model.StatementStatusPending {
+		return
+	}
+
+	var (
+		c    content
+		data []byte
+	)
+
+	if c, err = build(database.Db, s); err == nil {
+		switch s.Format {
+		case model.StatementFormatPDF:
+			data = renderPDF(c)
+		default:
+			data, err = renderCSV(c)
+		}
+	}
+
+	filename := fmt.Sprintf("%s.%s", s.Id, s.Format)
+
+	if err == nil {
+		err = ioutil.WriteFile(filePath(filename), data, 0644)
+	}
+
+	if err != nil {
+		reason := err.Error()
+
+		return database.Db.Model(&s).Updates(map[string]interface{}{
+			"status": model.StatementStatusFailed,
+			"error":  &reason,
+		}).Error
+	}
+
+	return database.Db.Model(&s).Updates(map[string]interface{}{
+		"status":   model.StatementStatusDone,
+		"filename": &filename,
+	}).Error
+}
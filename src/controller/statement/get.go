@@ -0,0 +1,316 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Query struct {
+	schema.Query
+	Currency *string                `json:"currency" form:"currency"` // 指定币种
+	Status   *model.StatementStatus `json:"status" form:"status"`     // 指定状态
+}
+
+type QueryAdmin struct {
+	Query
+	Uid *string `json:"uid" form:"uid"` // 指定用户
+}
+
+// 获取对账单, uid 为空时不限制用户
+func get(uid *string, id string) (s model.Statement, err error) {
+	db := database.Db.Where("id = ?", id)
+
+	if uid != nil {
+		db = db.Where("uid = ?", *uid)
+	}
+
+	if err = db.First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.StatementNotExist
+		}
+		return
+	}
+
+	return
+}
+
+func list(input Query, filter map[string]interface{}) (data []schema.Statement, meta schema.Meta, err error) {
+	var (
+		total int64
+		items = make([]model.Statement, 0)
+	)
+
+	data = make([]schema.Statement, 0)
+
+	query := input.Query
+
+	query.Normalize()
+
+	if input.Currency != nil {
+		filter["currency"] = strings.ToUpper(*input.Currency)
+	}
+
+	if input.Status != nil {
+		filter["status"] = *input.Status
+	}
+
+	if err = database.Db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(filter).Find(&items).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.Statement{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range items {
+		d := schema.Statement{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+// 获取对账单文件的路径和下载时的文件名, uid 为空时不限制用户
+func open(uid *string, id string) (filepath string, name string, err error) {
+	s, err := get(uid, id)
+
+	if err != nil {
+		return
+	}
+
+	if s.Status != model.StatementStatusDone || s.Filename == nil {
+		err = exception.StatementNotReady
+		return
+	}
+
+	filepath = filePath(*s.Filename)
+	name = fmt.Sprintf("statement-%s-%s-%s.%s", s.Currency, s.StartAt.Format("20060102"), s.EndAt.Add(-time.Nanosecond).Format("20060102"), s.Format)
+
+	return
+}
+
+// 用户下载自己的对账单文件
+func Open(uid string, id string) (filepath string, name string, err error) {
+	return open(&uid, id)
+}
+
+// 管理员下载用户的对账单文件
+func OpenByAdmin(uid string, id string) (filepath string, name string, err error) {
+	if _, err = admin.Check(database.Db, uid, *accession.AdminFinanceStatement); err != nil {
+		return
+	}
+
+	return open(nil, id)
+}
+
+// 获取自己的一份对账单
+func GetStatement(context controller.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data = schema.Statement{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	s, err := get(&context.Uid, id)
+
+	if err != nil {
+		return
+	}
+
+	mapToSchema(s, &data)
+
+	return
+}
+
+// 获取自己的对账单列表
+func GetStatements(context controller.Context, input Query) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.Statement, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	data, *meta, err = list(input, map[string]interface{}{
+		"uid": context.Uid,
+	})
+
+	return
+}
+
+// 管理员获取对账单列表
+func GetStatementsByAdmin(context controller.Context, input QueryAdmin) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.Statement, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminFinanceStatement); err != nil {
+		return
+	}
+
+	filter := map[string]interface{}{}
+
+	if input.Uid != nil {
+		filter["uid"] = *input.Uid
+	}
+
+	data, *meta, err = list(input.Query, filter)
+
+	return
+}
+
+func GetStatementRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = GetStatement(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("statement_id"))
+}
+
+func GetStatementsRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input Query
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetStatements(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func GetStatementsByAdminRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input QueryAdmin
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetStatementsByAdmin(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
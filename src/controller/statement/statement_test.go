@@ -0,0 +1,129 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package statement_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/statement"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+	defer database.DeleteRowByTable("statement", "uid", userFrom.Id)
+
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	note := "房租"
+
+	r := transfer.To(controller.Context{Uid: userFrom.Id}, transfer.ToParams{
+		Currency: model.WalletCNY,
+		To:       userTo.Id,
+		Amount:   "10",
+		Note:     &note,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	today := time.Now().Format("2006-01-02")
+
+	create := func(format string, start string, end string) (schema.Statement, string) {
+		data := schema.Statement{}
+		r := statement.Create(controller.Context{Uid: userFrom.Id}, statement.CreateParams{
+			Currency: model.WalletCNY,
+			Format:   format,
+			Start:    start,
+			End:      end,
+		})
+		if r.Data != nil {
+			assert.Nil(t, tester.Decode(r.Data, &data))
+		}
+		return data, r.Message
+	}
+
+	// 日期范围小的对账单直接生成
+	s, message := create("csv", today, today)
+
+	assert.Equal(t, "", message)
+	assert.Equal(t, model.StatementStatusDone, s.Status)
+
+	filePath, name, err := statement.Open(userFrom.Id, s.Id)
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(name, ".csv"))
+
+	defer os.Remove(filePath)
+
+	b, err := ioutil.ReadFile(filePath)
+
+	assert.Nil(t, err)
+
+	content := string(b)
+
+	assert.Contains(t, content, "期初余额,100")
+	assert.Contains(t, content, "期末余额,90")
+	assert.Contains(t, content, userTo.Username)
+	assert.Contains(t, content, note)
+
+	// 其他用户不能下载
+	_, _, err = statement.Open(userTo.Id, s.Id)
+
+	assert.Equal(t, exception.StatementNotExist, err)
+
+	// PDF 格式
+	s, message = create("pdf", today, today)
+
+	assert.Equal(t, "", message)
+
+	filePath, _, err = statement.OpenByAdmin(adminInfo.Id, s.Id)
+
+	assert.Nil(t, err)
+
+	defer os.Remove(filePath)
+
+	b, err = ioutil.ReadFile(filePath)
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "%PDF-"))
+
+	// 日期范围不正确
+	_, message = create("csv", today, "2000-01-01")
+
+	assert.Equal(t, exception.InvalidStatementRange.Error(), message)
+
+	_, message = create("csv", "2000-01-01", today)
+
+	assert.Equal(t, exception.StatementRangeTooLarge.Error(), message)
+
+	_, message = create("xls", today, today)
+
+	assert.NotEqual(t, "", message)
+
+	// 管理员查看用户的对账单
+	uid := userFrom.Id
+
+	list := statement.GetStatementsByAdmin(controller.Context{Uid: adminInfo.Id}, statement.QueryAdmin{Uid: &uid})
+
+	assert.Equal(t, "", list.Message)
+	assert.Equal(t, int64(2), list.Meta.Total)
+}
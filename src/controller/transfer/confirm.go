@@ -0,0 +1,328 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 在事务中锁定一条转账记录
+func lockTransferLog(tx *gorm.DB, transferId string) (log model.TransferLog, err error) {
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", transferId).First(&log).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.TransferNotExist
+		}
+		return
+	}
+
+	return
+}
+
+// 结算一笔等待确认或者等待风控审核的转账
+// 收款方接受或者审核通过时, 冻结的钱转给收款方. 拒绝或者过期时, 冻结的钱退回给转账方
+func settle(tx *gorm.DB, log *model.TransferLog, status model.TransferStatus) (err error) {
+	if log.Status != model.TransferStatusWaitForConfirm && log.Status != model.TransferStatusHold {
+		err = exception.TransferNotWaitForConfirm
+		return
+	}
+
+	keys := []wallet.LockKey{{Currency: log.Currency, Uid: log.From}, {Currency: log.Currency, Uid: log.To}}
+
+	// 确认之后结算首次支付的邀请奖励, 奖励的钱包一起按固定顺序锁定
+	if status == model.TransferStatusConfirmed {
+		rewards, er := invite.RewardWallets(tx, log.From, model.StatusInvitePay)
+
+		if er != nil {
+			err = er
+			return
+		}
+
+		keys = append(keys, rewards...)
+	}
+
+	locked, err := wallet.LockAll(tx, keys...)
+
+	if err != nil {
+		return
+	}
+
+	wallets := locked[strings.ToUpper(log.Currency)]
+	fromUserWallet := wallets[log.From]
+	toUserWallet := wallets[log.To]
+
+	fromUserBefore := *fromUserWallet
+	toUserBefore := *toUserWallet
+
+	// 转账方的冻结余额不足, 说明数据已经不一致了
+	if fromUserWallet.Frozen.LessThan(log.Amount) {
+		err = exception.NotEnoughBalance
+		return
+	}
+
+	fromUserWallet.Frozen = fromUserWallet.Frozen.Sub(log.Amount)
+
+	if status == model.TransferStatusConfirmed {
+		toUserWallet.Balance = toUserWallet.Balance.Add(log.Amount)
+	} else {
+		fromUserWallet.Balance = fromUserWallet.Balance.Add(log.Amount)
+	}
+
+	if err = wallet.Update(tx, log.Currency, fromUserWallet); err != nil {
+		return
+	}
+
+	if status == model.TransferStatusConfirmed {
+		if err = wallet.Update(tx, log.Currency, toUserWallet); err != nil {
+			return
+		}
+
+		if err = finance.CreateLog(tx, log.Currency, fromUserBefore, *fromUserWallet, log.Id, model.FinanceTypeTransferOut, nil); err != nil {
+			return
+		}
+
+		if err = finance.CreateLog(tx, log.Currency, toUserBefore, *toUserWallet, log.Id, model.FinanceTypeTransferIn, nil); err != nil {
+			return
+		}
+
+		if _, err = ledger.Post(tx, log.Currency, log.Id, model.FinanceTypeTransferOut, nil,
+			ledger.Debit(log.From, model.LedgerBucketFrozen, log.Amount),
+			ledger.Credit(log.To, model.LedgerBucketBalance, log.Amount),
+		); err != nil {
+			return
+		}
+	} else {
+		if err = finance.CreateLog(tx, log.Currency, fromUserBefore, *fromUserWallet, log.Id, model.FinanceTypeTransferRefund, nil); err != nil {
+			return
+		}
+
+		if _, err = ledger.Post(tx, log.Currency, log.Id, model.FinanceTypeTransferRefund, nil,
+			ledger.Debit(log.From, model.LedgerBucketFrozen, log.Amount),
+			ledger.Credit(log.From, model.LedgerBucketBalance, log.Amount),
+		); err != nil {
+			return
+		}
+	}
+
+	if err = tx.Model(&model.TransferLog{}).Where("id = ?", log.Id).UpdateColumn("status", status).Error; err != nil {
+		return
+	}
+
+	log.Status = status
+
+	if status == model.TransferStatusConfirmed {
+		// 被邀请人的第一笔转账, 结算首次支付的邀请奖励
+		if err = invite.Settle(tx, log.From, model.StatusInvitePay); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// 收款方确认/拒绝一笔转账
+func confirm(context controller.Context, transferId string, status model.TransferStatus) (res schema.Response) {
+	var (
+		err  error
+		tx   *gorm.DB
+		data = schema.TransferLog{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Status = schema.StatusSuccess
+			res.Data = data
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	log, err := lockTransferLog(tx, transferId)
+
+	if err != nil {
+		return
+	}
+
+	// 只有收款方才能确认
+	if log.To != context.Uid {
+		err = exception.NoPermission
+		return
+	}
+
+	// 等待风控审核的转账, 收款方不能处理
+	if log.Status != model.TransferStatusWaitForConfirm {
+		err = exception.TransferNotWaitForConfirm
+		return
+	}
+
+	// 已过期的转账只能由过期任务退回
+	if log.ExpiredAt != nil && log.ExpiredAt.Before(time.Now()) {
+		err = exception.TransferExpired
+		return
+	}
+
+	if err = settle(tx, &log, status); err != nil {
+		return
+	}
+
+	mapToSchema(log, &data)
+
+	return
+}
+
+// 收款方接受转账
+func Accept(context controller.Context, transferId string) (res schema.Response) {
+	return confirm(context, transferId, model.TransferStatusConfirmed)
+}
+
+// 收款方拒绝转账
+func Reject(context controller.Context, transferId string) (res schema.Response) {
+	return confirm(context, transferId, model.TransferStatusReject)
+}
+
+// 退回一笔已过期的转账
+func expire(transferId string) (err error) {
+	var (
+		tx *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	log, err := lockTransferLog(tx, transferId)
+
+	if err != nil {
+		return
+	}
+
+	// 在加锁之前可能已经被收款方处理了
+	if log.Status != model.TransferStatusWaitForConfirm || log.ExpiredAt == nil || log.ExpiredAt.After(time.Now()) {
+		return
+	}
+
+	return settle(tx, &log, model.TransferStatusExpired)
+}
+
+// 退回所有已过期的待确认转账
+func ExpireTransfers() (err error) {
+	ids := make([]string, 0)
+
+	if err = database.Db.Model(&model.TransferLog{}).Where("status = ? AND expired_at < ?", model.TransferStatusWaitForConfirm, time.Now()).Pluck("id", &ids).Error; err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		// 单笔转账失败不影响其他转账, 下一次再重试
+		if e := expire(id); e != nil {
+			fmt.Printf("退回过期的转账 %s 失败: %s\n", id, e.Error())
+		}
+	}
+
+	return
+}
+
+// 定时退回过期的转账
+func RunExpireWorker() {
+	ticker := time.NewTicker(config.Transfer.ExpireInterval)
+
+	for range ticker.C {
+		if err := ExpireTransfers(); err != nil {
+			fmt.Printf("退回过期的转账失败: %s\n", err.Error())
+		}
+	}
+}
+
+func AcceptRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Accept(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("transfer_id"))
+}
+
+func RejectRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = Reject(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("transfer_id"))
+}
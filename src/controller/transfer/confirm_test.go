@@ -0,0 +1,145 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// 创建一笔需要对方确认的转账
+func createConfirmTransfer(t *testing.T, from string, to string) schema.TransferLog {
+	// 给账户充钱
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", from, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	r := transfer.To(controller.Context{
+		Uid: from,
+	}, transfer.ToParams{
+		Currency: "CNY",
+		To:       to,
+		Amount:   "20",
+		Confirm:  true,
+	})
+
+	log := schema.TransferLog{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, tester.Decode(r.Data, &log))
+	assert.Equal(t, model.TransferStatusWaitForConfirm, log.Status)
+	assert.NotNil(t, log.ExpiredAt)
+
+	// 转账的钱被冻结, 对方还没有收到
+	assertWallet(t, from, "80.00000000", "20.00000000")
+	assertWallet(t, to, "0.00000000", "0.00000000")
+
+	return log
+}
+
+func assertWallet(t *testing.T, uid string, balance string, frozen string) {
+	r := wallet.GetWallet(controller.Context{Uid: uid}, "CNY")
+	w := schema.Wallet{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &w))
+	assert.Equal(t, balance, w.Balance)
+	assert.Equal(t, frozen, w.Frozen)
+}
+
+func TestAccept(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	log := createConfirmTransfer(t, userFrom.Id, userTo.Id)
+
+	// 转账人不能确认
+	{
+		r := transfer.Accept(controller.Context{Uid: userFrom.Id}, log.Id)
+		assert.Equal(t, exception.NoPermission.Error(), r.Message)
+	}
+
+	r := transfer.Accept(controller.Context{Uid: userTo.Id}, log.Id)
+	detail := schema.TransferLog{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, tester.Decode(r.Data, &detail))
+	assert.Equal(t, model.TransferStatusConfirmed, detail.Status)
+
+	assertWallet(t, userFrom.Id, "80.00000000", "0.00000000")
+	assertWallet(t, userTo.Id, "20.00000000", "0.00000000")
+
+	// 不能重复确认
+	{
+		r := transfer.Accept(controller.Context{Uid: userTo.Id}, log.Id)
+		assert.Equal(t, exception.TransferNotWaitForConfirm.Error(), r.Message)
+	}
+}
+
+func TestReject(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	log := createConfirmTransfer(t, userFrom.Id, userTo.Id)
+
+	r := transfer.Reject(controller.Context{Uid: userTo.Id}, log.Id)
+	detail := schema.TransferLog{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, tester.Decode(r.Data, &detail))
+	assert.Equal(t, model.TransferStatusReject, detail.Status)
+
+	// 钱退回给转账人
+	assertWallet(t, userFrom.Id, "100.00000000", "0.00000000")
+	assertWallet(t, userTo.Id, "0.00000000", "0.00000000")
+}
+
+func TestExpireTransfers(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	log := createConfirmTransfer(t, userFrom.Id, userTo.Id)
+
+	// 让这笔转账过期
+	assert.Nil(t, database.Db.Model(&model.TransferLog{}).Where("id = ?", log.Id).UpdateColumn("expired_at", time.Now().Add(-time.Minute)).Error)
+
+	// 过期之后不能再接受
+	{
+		r := transfer.Accept(controller.Context{Uid: userTo.Id}, log.Id)
+		assert.Equal(t, exception.TransferExpired.Error(), r.Message)
+	}
+
+	assert.Nil(t, transfer.ExpireTransfers())
+
+	r := transfer.GetDetail(controller.Context{Uid: userFrom.Id}, log.Id)
+	detail := schema.TransferLog{}
+
+	assert.Nil(t, tester.Decode(r.Data, &detail))
+	assert.Equal(t, model.TransferStatusExpired, detail.Status)
+
+	assertWallet(t, userFrom.Id, "100.00000000", "0.00000000")
+	assertWallet(t, userTo.Id, "0.00000000", "0.00000000")
+}
@@ -11,9 +11,7 @@ import (
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
 	"net/http"
-	"time"
 )
 
 func GetDetail(context controller.Context, transferId string) (res schema.Response) {
@@ -65,13 +63,10 @@ func GetDetail(context controller.Context, transferId string) (res schema.Respon
 
 	log := model.TransferLog{}
 
-	// 联表查询
-	// 只能获取自己转给别人的
-	sql := GenerateTransferLogSQL(QueryParams{
-		Id: &transferId,
-	}, 1, false)
-
-	if err = tx.Raw(sql).Scan(&log).Error; err != nil {
+	if err = tx.Where("id = ?", transferId).First(&log).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.TransferNotExist
+		}
 		return
 	}
 
@@ -83,12 +78,7 @@ func GetDetail(context controller.Context, transferId string) (res schema.Respon
 		}
 	}
 
-	if err = mapstructure.Decode(log, &data.TransferLogPure); err != nil {
-		return
-	}
-
-	data.CreatedAt = log.CreatedAt.Format(time.RFC3339Nano)
-	data.UpdatedAt = log.UpdatedAt.Format(time.RFC3339Nano)
+	mapToSchema(log, &data)
 	return
 }
 
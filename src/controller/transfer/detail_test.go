@@ -5,11 +5,11 @@ import (
 	"github.com/axetroy/go-server/src/controller"
 	"github.com/axetroy/go-server/src/controller/auth"
 	"github.com/axetroy/go-server/src/controller/transfer"
-	"github.com/axetroy/go-server/src/controller/wallet"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -24,8 +24,8 @@ func TestGetDetail(t *testing.T) {
 
 	// 给账户充钱
 	{
-		assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-			Balance:  100,
+		assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+			Balance:  decimal.New(100, 0),
 			Currency: model.WalletCNY,
 		}).Error)
 	}
@@ -11,9 +11,7 @@ import (
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
 	"net/http"
-	"time"
 )
 
 type Query struct {
@@ -76,32 +74,23 @@ func GetHistory(context controller.Context, input Query) (res schema.List) {
 
 	list := make([]model.TransferLog, 0)
 
-	condition := QueryParams{
-		From: &context.Uid,
+	filter := model.TransferLog{
+		From: context.Uid,
 	}
 
-	// 联表查询
-	countSQL := GenerateTransferLogSQL(condition, query.Limit, true)
-	listSQL := GenerateTransferLogSQL(condition, query.Limit, false)
-
 	var total int64
 
-	if err = tx.Raw(countSQL).Count(&total).Error; err != nil {
+	if err = tx.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(&filter).Find(&list).Error; err != nil {
 		return
 	}
 
-	if err = tx.Raw(listSQL).Scan(&list).Error; err != nil {
+	if err = tx.Model(&model.TransferLog{}).Where(&filter).Count(&total).Error; err != nil {
 		return
 	}
 
 	for _, v := range list {
 		d := schema.TransferLog{}
-		if er := mapstructure.Decode(v, &d.TransferLogPure); er != nil {
-			err = er
-			return
-		}
-		d.CreatedAt = v.CreatedAt.Format(time.RFC3339Nano)
-		d.UpdatedAt = v.UpdatedAt.Format(time.RFC3339Nano)
+		mapToSchema(v, &d)
 		data = append(data, d)
 	}
 
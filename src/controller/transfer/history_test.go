@@ -6,13 +6,13 @@ import (
 	"github.com/axetroy/go-server/src/controller"
 	"github.com/axetroy/go-server/src/controller/auth"
 	"github.com/axetroy/go-server/src/controller/transfer"
-	"github.com/axetroy/go-server/src/controller/wallet"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/axetroy/go-server/src/service/token"
 	"github.com/axetroy/go-server/tester"
 	"github.com/axetroy/mocker"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -25,8 +25,8 @@ func TestGetHistory(t *testing.T) {
 	defer auth.DeleteUserByUserName(userTo.Username)
 
 	// 给账户充钱
-	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -78,8 +78,8 @@ func TestGetHistoryRouter(t *testing.T) {
 	defer auth.DeleteUserByUserName(userTo.Username)
 
 	// 给账户充钱
-	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -0,0 +1,152 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strings"
+)
+
+type RecipientQuery struct {
+	To string `json:"to" form:"to"` // 用户ID, 用户名, 邮箱, 手机号或者邀请码
+}
+
+// 根据输入的格式决定查找收款方的字段
+// 用户名不能是邮箱, 手机号或者用户ID的格式, 但是可以与别人的邀请码相同
+func recipientFields(to string) []string {
+	switch {
+	case govalidator.IsEmail(to):
+		return []string{"email"}
+	case util.IsId(to):
+		return []string{"id"}
+	case util.IsPhone(to):
+		return []string{"phone"}
+	case util.IsInviteCode(to):
+		return []string{"invite_code", "username"}
+	default:
+		return []string{"username"}
+	}
+}
+
+// 根据用户输入查找收款方, 不能转账给自己
+// 匹配到多个用户时不猜测收款方, 返回错误
+func findRecipient(tx *gorm.DB, uid string, to string) (userInfo model.User, err error) {
+	if to = strings.TrimSpace(to); to == "" {
+		err = exception.UserNotExist
+		return
+	}
+
+	fields := recipientFields(to)
+	conditions := make([]string, 0, len(fields))
+	args := make([]interface{}, 0, len(fields))
+
+	for _, field := range fields {
+		conditions = append(conditions, field+" = ?")
+		args = append(args, to)
+	}
+
+	list := make([]model.User, 0)
+
+	if err = tx.Where(strings.Join(conditions, " OR "), args...).Limit(2).Find(&list).Error; err != nil {
+		return
+	}
+
+	switch len(list) {
+	case 0:
+		err = exception.UserNotExist
+		return
+	case 1:
+		userInfo = list[0]
+	default:
+		err = exception.RecipientAmbiguous
+		return
+	}
+
+	if userInfo.Id == uid {
+		err = exception.TransferToSelf
+		return
+	}
+
+	return
+}
+
+// 转账之前预览收款方, 只返回隐藏了部分字符的名称和头像
+func GetRecipient(context controller.Context, input RecipientQuery) (res schema.Response) {
+	var (
+		err  error
+		data schema.TransferRecipient
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Status = schema.StatusSuccess
+			res.Data = data
+		}
+	}()
+
+	userInfo, err := findRecipient(database.Db, context.Uid, input.To)
+
+	if err != nil {
+		return
+	}
+
+	name := userInfo.Username
+
+	if userInfo.Nickname != nil && *userInfo.Nickname != "" {
+		name = *userInfo.Nickname
+	}
+
+	data.DisplayName = util.MaskName(name)
+	data.Avatar = userInfo.Avatar
+
+	return
+}
+
+func GetRecipientRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input RecipientQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetRecipient(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
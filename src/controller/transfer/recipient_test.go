@@ -0,0 +1,103 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetRecipient(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	email := "test-" + util.RandomString(6) + "@example.com"
+
+	assert.Nil(t, database.Db.Model(&model.User{}).Where("id = ?", userTo.Id).Update("email", email).Error)
+
+	preview := func(to string) schema.Response {
+		return transfer.GetRecipient(controller.Context{Uid: userFrom.Id}, transfer.RecipientQuery{To: to})
+	}
+
+	for _, to := range []string{userTo.Id, userTo.Username, email, userTo.InviteCode} {
+		r := preview(to)
+		data := schema.TransferRecipient{}
+
+		assert.Equal(t, "", r.Message, to)
+		assert.Nil(t, tester.Decode(r.Data, &data))
+		assert.Equal(t, util.MaskName(userTo.Username), data.DisplayName)
+		assert.NotEqual(t, userTo.Username, data.DisplayName)
+	}
+
+	assert.Equal(t, exception.TransferToSelf.Error(), preview(userFrom.Username).Message)
+	assert.Equal(t, exception.UserNotExist.Error(), preview("not-exist-"+util.RandomString(6)).Message)
+	assert.Equal(t, exception.UserNotExist.Error(), preview("").Message)
+}
+
+func TestToByUsername(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	r := transfer.To(controller.Context{Uid: userFrom.Id}, transfer.ToParams{
+		Currency: model.WalletCNY,
+		To:       userTo.Username,
+		Amount:   "1",
+	})
+
+	data := schema.TransferLog{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, userTo.Id, data.To)
+
+	// 不能转账给自己
+	r = transfer.To(controller.Context{Uid: userFrom.Id}, transfer.ToParams{
+		Currency: model.WalletCNY,
+		To:       userFrom.InviteCode,
+		Amount:   "1",
+	})
+
+	assert.Equal(t, exception.TransferToSelf.Error(), r.Message)
+}
+
+func TestGetRecipientAmbiguous(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+	squatter, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+	defer auth.DeleteUserByUserName(userTo.InviteCode)
+
+	preview := func(to string) schema.Response {
+		return transfer.GetRecipient(controller.Context{Uid: userFrom.Id}, transfer.RecipientQuery{To: to})
+	}
+
+	// 另一个用户把用户名改成收款方的邀请码, 不会猜测收款方
+	assert.Nil(t, database.Db.Model(&model.User{}).Where("id = ?", squatter.Id).Update("username", userTo.InviteCode).Error)
+
+	assert.Equal(t, exception.RecipientAmbiguous.Error(), preview(userTo.InviteCode).Message)
+
+	// 用户ID的格式只匹配用户ID
+	assert.Equal(t, "", preview(userTo.Id).Message)
+}
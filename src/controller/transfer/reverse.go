@@ -0,0 +1,234 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/controller/message"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type ReverseParams struct {
+	Reason string  `json:"reason" valid:"required~请输入撤回原因,length(1|128)~撤回原因不能超过128个字符"` // 撤回原因, 必填
+	Policy *string `json:"policy"`                                                       // 收款方余额不足时的处理方式, 默认使用 TRANSFER_REVERSAL_POLICY
+}
+
+// 管理员撤回一笔已完成的转账
+// 生成一笔从收款方到转账方的反向转账, 原来的转账标记为已撤回. 收款方余额不足时按照策略部分撤回或者允许余额为负数
+func Reverse(context controller.Context, transferId string, input ReverseParams) (res schema.Response) {
+	var (
+		err          error
+		tx           *gorm.DB
+		data         = schema.TransferLog{}
+		notices      = make([]*message.Notice, 0)
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Status = schema.StatusSuccess
+			res.Data = data
+
+			// 反向转账的收款方是原来的转账方
+			_ = push.Publish(data.To, push.EventTransfer, data)
+
+			// 事务提交之后再发送到用户开启的渠道
+			for _, notice := range notices {
+				message.Dispatch(notice)
+			}
+		}
+	}()
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	policy := config.Transfer.ReversalPolicy
+
+	if input.Policy != nil {
+		policy = *input.Policy
+	}
+
+	if policy != config.ReversalPolicyPartial && policy != config.ReversalPolicyNegative {
+		err = exception.InvalidReversalPolicy
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminTransferReverse); err != nil {
+		return
+	}
+
+	original, err := lockTransferLog(tx, transferId)
+
+	if err != nil {
+		return
+	}
+
+	// 反向转账本身不能再撤回
+	if original.Status != model.TransferStatusConfirmed || original.ReversalOf != nil {
+		err = exception.TransferNotReversible
+		return
+	}
+
+	wallets, err := wallet.Lock(tx, original.Currency, original.From, original.To)
+
+	if err != nil {
+		return
+	}
+
+	fromUserWallet := wallets[original.To] // 反向转账的转出方是原来的收款方
+	toUserWallet := wallets[original.From]
+
+	amount := original.Amount
+
+	if policy == config.ReversalPolicyPartial && fromUserWallet.Balance.LessThan(amount) {
+		amount = fromUserWallet.Balance
+	}
+
+	if !amount.IsPositive() {
+		err = exception.TransferNothingToReverse
+		return
+	}
+
+	fromUserBefore := *fromUserWallet
+	toUserBefore := *toUserWallet
+
+	fromUserWallet.Balance = fromUserWallet.Balance.Sub(amount)
+	toUserWallet.Balance = toUserWallet.Balance.Add(amount)
+
+	if err = wallet.Update(tx, original.Currency, fromUserWallet); err != nil {
+		return
+	}
+
+	if err = wallet.Update(tx, original.Currency, toUserWallet); err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	reversal := model.TransferLog{
+		Currency:   original.Currency,
+		From:       original.To,
+		To:         original.From,
+		Amount:     amount,
+		Status:     model.TransferStatusConfirmed,
+		Note:       &input.Reason,
+		Reviewer:   &context.Uid,
+		ReviewedAt: &now,
+		ReversalOf: &original.Id,
+	}
+
+	if err = tx.Create(&reversal).Error; err != nil {
+		return
+	}
+
+	if err = finance.CreateLog(tx, original.Currency, fromUserBefore, *fromUserWallet, reversal.Id, model.FinanceTypeReversalOut, &input.Reason); err != nil {
+		return
+	}
+
+	if err = finance.CreateLog(tx, original.Currency, toUserBefore, *toUserWallet, reversal.Id, model.FinanceTypeReversalIn, &input.Reason); err != nil {
+		return
+	}
+
+	if _, err = ledger.Post(tx, original.Currency, reversal.Id, model.FinanceTypeReversalOut, &input.Reason,
+		ledger.Debit(original.To, model.LedgerBucketBalance, amount),
+		ledger.Credit(original.From, model.LedgerBucketBalance, amount),
+	); err != nil {
+		return
+	}
+
+	if err = tx.Model(&model.TransferLog{}).Where("id = ?", original.Id).UpdateColumn("status", model.TransferStatusReversed).Error; err != nil {
+		return
+	}
+
+	// 通知双方, 收款方余额不足时实际撤回的数量 Reversed 小于原来的转账数量 Amount
+	for _, uid := range []string{original.From, original.To} {
+		notice, er := message.Notify(tx, model.MessageEventTransferReversed, uid, map[string]interface{}{
+			"Amount":     util.AmountToStr(original.Amount),
+			"Currency":   original.Currency,
+			"Reversed":   util.AmountToStr(amount),
+			"Reason":     input.Reason,
+			"TransferId": original.Id,
+		})
+
+		if er != nil {
+			err = er
+			return
+		}
+
+		notices = append(notices, notice)
+	}
+
+	mapToSchema(reversal, &data)
+
+	return
+}
+
+func ReverseRouter(context *gin.Context) {
+	var (
+		err   error
+		input ReverseParams
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Reverse(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("transfer_id"), input)
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer_test
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+	userOther, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+	defer auth.DeleteUserByUserName(userOther.Username)
+	defer database.DeleteRowByTable("message", "uid", userFrom.Id)
+	defer database.DeleteRowByTable("message", "uid", userTo.Id)
+
+	// 撤回的通知需要有消息模版
+	tpl := model.MessageTemplate{Event: model.MessageEventTransferReversed, Locale: "zh-CN"}
+
+	if database.Db.Where(&tpl).First(&tpl).RecordNotFound() {
+		tpl.Title = "转账已被撤回"
+		tpl.Content = "转账 {{.TransferId}} ({{.Amount}} {{.Currency}}) 已撤回 {{.Reversed}}. 原因: {{.Reason}}"
+		assert.Nil(t, database.Db.Create(&tpl).Error)
+		defer database.DeleteRowByTable("message_template", "id", tpl.Id)
+	}
+
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	getBalance := func(uid string) string {
+		w := model.Wallet{}
+		assert.Nil(t, database.Db.Where("id = ? AND currency = ?", uid, model.WalletCNY).First(&w).Error)
+		return w.Balance.String()
+	}
+
+	to := func(uid string, recipient string, amount string) schema.TransferLog {
+		data := schema.TransferLog{}
+		r := transfer.To(controller.Context{Uid: uid}, transfer.ToParams{
+			Currency: model.WalletCNY,
+			To:       recipient,
+			Amount:   amount,
+		})
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &data))
+		return data
+	}
+
+	reverse := func(id string, policy *string) schema.Response {
+		return transfer.Reverse(controller.Context{Uid: adminInfo.Id}, id, transfer.ReverseParams{
+			Reason: "转错了",
+			Policy: policy,
+		})
+	}
+
+	// 全额撤回
+	log := to(userFrom.Id, userTo.Id, "10")
+
+	r := reverse(log.Id, nil)
+	reversal := schema.TransferLog{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &reversal))
+	assert.Equal(t, log.Id, *reversal.ReversalOf)
+	assert.Equal(t, userTo.Id, reversal.From)
+	assert.Equal(t, userFrom.Id, reversal.To)
+	assert.Equal(t, "100", getBalance(userFrom.Id))
+	assert.Equal(t, "0", getBalance(userTo.Id))
+
+	original := model.TransferLog{}
+
+	assert.Nil(t, database.Db.Where("id = ?", log.Id).First(&original).Error)
+	assert.Equal(t, model.TransferStatusReversed, original.Status)
+
+	// 双方都收到了通知
+	var count int
+
+	assert.Nil(t, database.Db.Model(&model.Message{}).Where("uid IN (?) AND note = ?", []string{userFrom.Id, userTo.Id}, model.MessageEventTransferReversed).Count(&count).Error)
+	assert.Equal(t, 2, count)
+
+	// 不能重复撤回, 反向转账也不能撤回
+	assert.Equal(t, exception.TransferNotReversible.Error(), reverse(log.Id, nil).Message)
+	assert.Equal(t, exception.TransferNotReversible.Error(), reverse(reversal.Id, nil).Message)
+
+	// 收款方已经花掉了一部分, 部分撤回
+	log = to(userFrom.Id, userTo.Id, "10")
+	to(userTo.Id, userOther.Id, "6")
+
+	partial := config.ReversalPolicyPartial
+	r = reverse(log.Id, &partial)
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &reversal))
+	assert.Equal(t, "4.00000000", reversal.Amount)
+	assert.Equal(t, "94", getBalance(userFrom.Id))
+	assert.Equal(t, "0", getBalance(userTo.Id))
+
+	// 收款方没有余额时不能部分撤回
+	log = to(userFrom.Id, userTo.Id, "10")
+	to(userTo.Id, userOther.Id, "10")
+
+	assert.Equal(t, exception.TransferNothingToReverse.Error(), reverse(log.Id, &partial).Message)
+
+	// 允许余额为负数
+	negative := config.ReversalPolicyNegative
+
+	assert.Equal(t, "", reverse(log.Id, &negative).Message)
+	assert.Equal(t, "94", getBalance(userFrom.Id))
+	assert.Equal(t, "-10", getBalance(userTo.Id))
+
+	invalid := "unknown"
+
+	assert.Equal(t, exception.InvalidReversalPolicy.Error(), reverse(log.Id, &invalid).Message)
+}
@@ -0,0 +1,283 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/controller/message"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type HoldQuery struct {
+	schema.Query
+	Currency *string `json:"currency" form:"currency"` // 指定币种
+	From     *string `json:"from" form:"from"`         // 指定转账方
+}
+
+// 审核一笔触发风控规则的转账
+func review(context controller.Context, transferId string, approve bool) (res schema.Response) {
+	var (
+		err    error
+		tx     *gorm.DB
+		data   = schema.TransferLog{}
+		notice *message.Notice
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Status = schema.StatusSuccess
+			res.Data = data
+
+			if approve {
+				_ = push.Publish(data.To, push.EventTransfer, data)
+			}
+
+			// 事务提交之后再发送到用户开启的其他渠道
+			message.Dispatch(notice)
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminTransferReview); err != nil {
+		return
+	}
+
+	log, err := lockTransferLog(tx, transferId)
+
+	if err != nil {
+		return
+	}
+
+	if log.Status != model.TransferStatusHold {
+		err = exception.TransferNotHold
+		return
+	}
+
+	now := time.Now()
+
+	log.Reviewer = &context.Uid
+	log.ReviewedAt = &now
+
+	if err = tx.Model(&model.TransferLog{}).Where("id = ?", log.Id).Updates(map[string]interface{}{
+		"reviewer":    log.Reviewer,
+		"reviewed_at": log.ReviewedAt,
+	}).Error; err != nil {
+		return
+	}
+
+	switch {
+	case !approve:
+		err = settle(tx, &log, model.TransferStatusDeclined)
+	case log.NeedConfirm:
+		// 需要收款方确认的转账, 审核通过之后才开始等待确认, 钱仍然冻结
+		expiredAt := now.Add(config.Transfer.ConfirmTTL)
+
+		log.Status = model.TransferStatusWaitForConfirm
+		log.ExpiredAt = &expiredAt
+
+		err = tx.Model(&model.TransferLog{}).Where("id = ?", log.Id).Updates(map[string]interface{}{
+			"status":     log.Status,
+			"expired_at": log.ExpiredAt,
+		}).Error
+	default:
+		err = settle(tx, &log, model.TransferStatusConfirmed)
+	}
+
+	if err != nil {
+		return
+	}
+
+	if approve {
+		if notice, err = notifyTransferIn(tx, log); err != nil {
+			return
+		}
+	}
+
+	mapToSchema(log, &data)
+
+	return
+}
+
+// 审核通过, 冻结的钱转给收款方
+func ApproveHold(context controller.Context, transferId string) (res schema.Response) {
+	return review(context, transferId, true)
+}
+
+// 审核拒绝, 冻结的钱退回给转账方
+func DeclineHold(context controller.Context, transferId string) (res schema.Response) {
+	return review(context, transferId, false)
+}
+
+// 获取等待风控审核的转账
+func GetHoldList(context controller.Context, input HoldQuery) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.TransferLog, 0)
+		list = make([]model.TransferLog, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminTransferReview); err != nil {
+		return
+	}
+
+	query := input.Query
+
+	query.Normalize()
+
+	filter := map[string]interface{}{
+		"status": model.TransferStatusHold,
+	}
+
+	if input.Currency != nil {
+		filter["currency"] = *input.Currency
+	}
+
+	if input.From != nil {
+		filter["from"] = *input.From
+	}
+
+	var total int64
+
+	if err = database.Db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.TransferLog{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.TransferLog{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+func ApproveHoldRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = ApproveHold(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("transfer_id"))
+}
+
+func DeclineHoldRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = DeclineHold(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("transfer_id"))
+}
+
+func GetHoldListRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input HoldQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetHoldList(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
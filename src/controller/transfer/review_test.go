@@ -0,0 +1,158 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestToWithRuleHold(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	// 使用单独的币种, 避免规则影响其他测试
+	c := model.Currency{
+		Code:         "T" + strings.ToUpper(util.RandomString(6)),
+		Name:         "测试币",
+		Scale:        2,
+		Enabled:      true,
+		Transferable: true,
+	}
+
+	assert.Nil(t, database.Db.Create(&c).Error)
+
+	defer database.DeleteRowByTable("currency", "code", c.Code)
+	defer database.DeleteRowByTable("wallet", "currency", c.Code)
+	defer database.DeleteRowByTable("transfer_log", "currency", c.Code)
+	defer database.DeleteRowByTable("transfer_rule", "currency", c.Code)
+	defer database.DeleteRowByTable("finance_log", "currency", c.Code)
+
+	assert.Nil(t, database.Db.Create(&model.Wallet{
+		Id:       userFrom.Id,
+		Currency: c.Code,
+		Balance:  decimal.New(100, 0),
+		Frozen:   decimal.Zero,
+	}).Error)
+
+	maxAmount := "10"
+	dailyLimit := "15"
+
+	r := transfer.SetRule(controller.Context{Uid: adminInfo.Id}, transfer.SetRuleParams{
+		Currency:   c.Code,
+		MaxAmount:  &maxAmount,
+		DailyLimit: &dailyLimit,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	to := func(amount string, confirm bool) schema.TransferLog {
+		data := schema.TransferLog{}
+
+		r := transfer.To(controller.Context{
+			Uid: userFrom.Id,
+		}, transfer.ToParams{
+			Currency: c.Code,
+			To:       userTo.Id,
+			Amount:   amount,
+			Confirm:  confirm,
+		})
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, tester.Decode(r.Data, &data))
+
+		return data
+	}
+
+	getWallet := func(uid string) model.Wallet {
+		w := model.Wallet{}
+		_ = database.Db.Where("id = ? AND currency = ?", uid, c.Code).First(&w).Error
+		return w
+	}
+
+	assert.Equal(t, model.TransferStatusConfirmed, to("5", false).Status)
+
+	// 超过单笔最大数量, 冻结等待审核
+	held := to("11", false)
+
+	assert.Equal(t, model.TransferStatusHold, held.Status)
+	assert.NotNil(t, held.HoldReason)
+	assert.Equal(t, "84", getWallet(userFrom.Id).Balance.String())
+	assert.Equal(t, "11", getWallet(userFrom.Id).Frozen.String())
+
+	// 审核队列
+	list := transfer.GetHoldList(controller.Context{Uid: adminInfo.Id}, transfer.HoldQuery{Currency: &c.Code})
+
+	assert.Equal(t, "", list.Message)
+	assert.Equal(t, int64(1), list.Meta.Total)
+
+	// 审核拒绝, 退回转账方
+	r = transfer.DeclineHold(controller.Context{Uid: adminInfo.Id}, held.Id)
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, "95", getWallet(userFrom.Id).Balance.String())
+	assert.Equal(t, "0", getWallet(userFrom.Id).Frozen.String())
+
+	// 不能重复审核
+	r = transfer.ApproveHold(controller.Context{Uid: adminInfo.Id}, held.Id)
+
+	assert.Equal(t, exception.TransferNotHold.Error(), r.Message)
+
+	// 被拒绝的转账不计入当天的累计数量
+	assert.Equal(t, model.TransferStatusConfirmed, to("10", false).Status)
+
+	// 超过当天的累计数量
+	held = to("1", false)
+
+	assert.Equal(t, model.TransferStatusHold, held.Status)
+
+	r = transfer.ApproveHold(controller.Context{Uid: adminInfo.Id}, held.Id)
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, "16", getWallet(userTo.Id).Balance.String())
+
+	// 需要收款方确认的转账, 审核通过之后才能确认
+	held = to("1", true)
+
+	assert.Equal(t, model.TransferStatusHold, held.Status)
+
+	r = transfer.Accept(controller.Context{Uid: userTo.Id}, held.Id)
+
+	assert.Equal(t, exception.TransferNotWaitForConfirm.Error(), r.Message)
+
+	r = transfer.ApproveHold(controller.Context{Uid: adminInfo.Id}, held.Id)
+
+	data := schema.TransferLog{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, model.TransferStatusWaitForConfirm, data.Status)
+
+	r = transfer.Accept(controller.Context{Uid: userTo.Id}, held.Id)
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, "17", getWallet(userTo.Id).Balance.String())
+
+	// 用户等级单独设置的规则优先, 不设置任何限制
+	r = transfer.SetRule(controller.Context{Uid: adminInfo.Id}, transfer.SetRuleParams{
+		Currency: c.Code,
+		Level:    userFrom.Level,
+	})
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, model.TransferStatusConfirmed, to("20", false).Status)
+}
@@ -0,0 +1,468 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"time"
+)
+
+var (
+	// 计入累计额度和次数的转账状态, 被拒绝/退回的转账不计入
+	ruleCountedStatus = []model.TransferStatus{
+		model.TransferStatusWaitForConfirm,
+		model.TransferStatusConfirmed,
+		model.TransferStatusHold,
+	}
+)
+
+type SetRuleParams struct {
+	Currency     string  `json:"currency" valid:"required~请选择币种"` // 币种
+	Level        int32   `json:"level"`                           // 用户等级, 0 表示所有等级
+	MinAmount    *string `json:"min_amount"`                      // 单笔最小数量, 为空表示不限制
+	MaxAmount    *string `json:"max_amount"`                      // 单笔最大数量, 为空表示不限制
+	DailyLimit   *string `json:"daily_limit"`                     // 每天的累计转出数量, 为空表示不限制
+	MonthlyLimit *string `json:"monthly_limit"`                   // 每月的累计转出数量, 为空表示不限制
+	HourlyCount  *int    `json:"hourly_count"`                    // 一小时内的转账次数, 为空表示不限制
+}
+
+type RuleQuery struct {
+	Currency *string `json:"currency" form:"currency"` // 指定币种
+}
+
+func mapRuleToSchema(model model.TransferRule, d *schema.TransferRule) {
+	amountToStr := func(a *decimal.Decimal) *string {
+		if a == nil {
+			return nil
+		}
+		s := util.AmountToStr(*a)
+		return &s
+	}
+
+	d.Id = model.Id
+	d.Currency = model.Currency
+	d.Level = model.Level
+	d.MinAmount = amountToStr(model.MinAmount)
+	d.MaxAmount = amountToStr(model.MaxAmount)
+	d.DailyLimit = amountToStr(model.DailyLimit)
+	d.MonthlyLimit = amountToStr(model.MonthlyLimit)
+	d.HourlyCount = model.HourlyCount
+	d.Updater = model.Updater
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 获取用户适用的转账规则, 优先使用该等级单独设置的规则
+func getRule(tx *gorm.DB, currency string, level int32) (*model.TransferRule, error) {
+	rule := model.TransferRule{}
+
+	if err := tx.Where("currency = ? AND level IN (?)", currency, []int32{0, level}).Order("level DESC").First(&rule).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// 用户从某个时间开始累计转出的数量
+func sumTransfer(tx *gorm.DB, currency string, uid string, since time.Time) (sum decimal.Decimal, err error) {
+	err = tx.Model(&model.TransferLog{}).
+		Where(`"from" = ? AND currency = ? AND status IN (?) AND created_at >= ?`, uid, currency, ruleCountedStatus, since).
+		Select("COALESCE(SUM(amount), 0)").
+		Row().
+		Scan(&sum)
+
+	return
+}
+
+// 检查转账是否触发风控规则, 返回触发的原因, 没有触发则为空
+// 调用之前需要锁定转账方的钱包, 保证同一个用户的转账串行统计
+func checkRule(tx *gorm.DB, currency string, userInfo model.User, amount decimal.Decimal) (reason *string, err error) {
+	rule, err := getRule(tx, currency, userInfo.Level)
+
+	if err != nil || rule == nil {
+		return
+	}
+
+	hold := func(format string, a ...interface{}) {
+		s := fmt.Sprintf(format, a...)
+		reason = &s
+	}
+
+	if rule.MinAmount != nil && amount.LessThan(*rule.MinAmount) {
+		hold("单笔转账数量小于 %s", rule.MinAmount.String())
+		return
+	}
+
+	if rule.MaxAmount != nil && amount.GreaterThan(*rule.MaxAmount) {
+		hold("单笔转账数量大于 %s", rule.MaxAmount.String())
+		return
+	}
+
+	now := time.Now()
+
+	if rule.HourlyCount != nil {
+		var count int
+
+		if err = tx.Model(&model.TransferLog{}).Where(`"from" = ? AND currency = ? AND status IN (?) AND created_at >= ?`, userInfo.Id, currency, ruleCountedStatus, now.Add(-time.Hour)).Count(&count).Error; err != nil {
+			return
+		}
+
+		if count >= *rule.HourlyCount {
+			hold("一小时内转账超过 %d 次", *rule.HourlyCount)
+			return
+		}
+	}
+
+	if rule.DailyLimit != nil {
+		var sum decimal.Decimal
+
+		if sum, err = sumTransfer(tx, currency, userInfo.Id, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())); err != nil {
+			return
+		}
+
+		if sum.Add(amount).GreaterThan(*rule.DailyLimit) {
+			hold("当天累计转账超过 %s", rule.DailyLimit.String())
+			return
+		}
+	}
+
+	if rule.MonthlyLimit != nil {
+		var sum decimal.Decimal
+
+		if sum, err = sumTransfer(tx, currency, userInfo.Id, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())); err != nil {
+			return
+		}
+
+		if sum.Add(amount).GreaterThan(*rule.MonthlyLimit) {
+			hold("当月累计转账超过 %s", rule.MonthlyLimit.String())
+			return
+		}
+	}
+
+	return
+}
+
+// 管理员获取转账规则
+func GetRules(context controller.Context, input RuleQuery) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.TransferRule, 0)
+		list = make([]model.TransferRule, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminTransferRule); err != nil {
+		return
+	}
+
+	filter := map[string]interface{}{}
+
+	if input.Currency != nil {
+		filter["currency"] = *input.Currency
+	}
+
+	if err = database.Db.Where(filter).Order("currency ASC, level ASC").Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.TransferRule{}
+		mapRuleToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	return
+}
+
+// 管理员设置转账规则, 同一个币种和等级只有一条规则, 已存在则覆盖
+func SetRule(context controller.Context, input SetRuleParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.TransferRule
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	// 参数校验
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	if input.Level < 0 || (input.HourlyCount != nil && *input.HourlyCount <= 0) {
+		err = exception.InvalidTransferRule
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminTransferRule); err != nil {
+		return
+	}
+
+	c := model.Currency{}
+
+	if err = tx.Where("code = ?", input.Currency).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.CurrencyNotExist
+		}
+		return
+	}
+
+	rule := model.TransferRule{
+		Currency:    c.Code,
+		Level:       input.Level,
+		HourlyCount: input.HourlyCount,
+		Updater:     context.Uid,
+	}
+
+	for _, v := range []struct {
+		input *string
+		field **decimal.Decimal
+	}{
+		{input.MinAmount, &rule.MinAmount},
+		{input.MaxAmount, &rule.MaxAmount},
+		{input.DailyLimit, &rule.DailyLimit},
+		{input.MonthlyLimit, &rule.MonthlyLimit},
+	} {
+		if v.input == nil || *v.input == "" {
+			continue
+		}
+
+		d, er := util.ParseAmount(*v.input, c.Scale)
+
+		if er != nil {
+			err = er
+			return
+		}
+
+		*v.field = &d
+	}
+
+	if rule.MinAmount != nil && rule.MaxAmount != nil && rule.MinAmount.GreaterThan(*rule.MaxAmount) {
+		err = exception.InvalidTransferRule
+		return
+	}
+
+	exist := model.TransferRule{}
+
+	if err = tx.Where("currency = ? AND level = ?", rule.Currency, rule.Level).First(&exist).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+
+		if err = tx.Create(&rule).Error; err != nil {
+			return
+		}
+	} else {
+		rule.Id = exist.Id
+		rule.CreatedAt = exist.CreatedAt
+
+		// 使用 Save 覆盖所有字段, 为空的限额会被清除
+		if err = tx.Save(&rule).Error; err != nil {
+			return
+		}
+	}
+
+	mapRuleToSchema(rule, &data)
+
+	return
+}
+
+// 管理员删除转账规则
+func DeleteRule(context controller.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.TransferRule
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminTransferRule); err != nil {
+		return
+	}
+
+	rule := model.TransferRule{}
+
+	if err = tx.Where("id = ?", id).First(&rule).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.TransferRuleNotExist
+		}
+		return
+	}
+
+	if err = tx.Delete(&rule).Error; err != nil {
+		return
+	}
+
+	mapRuleToSchema(rule, &data)
+
+	return
+}
+
+func GetRulesRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.Response{}
+		input RuleQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetRules(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func SetRuleRouter(context *gin.Context) {
+	var (
+		input SetRuleParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = SetRule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func DeleteRuleRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = DeleteRule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("rule_id"))
+}
@@ -0,0 +1,495 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/currency"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type CreateScheduleParams struct {
+	Currency   string  `json:"currency" valid:"required~请选择币种"`                   // 币种
+	To         string  `json:"to" valid:"required~请输入转账对象"`                       // 转账给谁, 用户ID, 用户名, 邮箱, 手机号或者邀请码
+	Amount     string  `json:"amount" valid:"required~请输入转账数量,float~请输入纯数字的转账数量"` // 每次转账的数量
+	Note       *string `json:"note"`                                              // 转账备注
+	Confirm    bool    `json:"confirm"`                                           // 是否需要收款方确认
+	RunAt      *string `json:"run_at"`                                            // 执行时间, RFC3339 格式. 有重复规则时为开始时间, 默认为当前时间
+	Recurrence *string `json:"recurrence"`                                        // 重复规则, cron 或者 RRULE, 为空表示只执行一次
+}
+
+type ScheduleQuery struct {
+	schema.Query
+	Status *model.TransferScheduleStatus `json:"status" form:"status"` // 指定状态
+}
+
+func mapScheduleToSchema(model model.TransferSchedule, d *schema.TransferSchedule) {
+	formatTime := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		s := t.Format(time.RFC3339Nano)
+		return &s
+	}
+
+	d.Id = model.Id
+	d.Uid = model.Uid
+	d.Currency = model.Currency
+	d.To = model.To
+	d.Amount = util.AmountToStr(model.Amount)
+	d.Note = model.Note
+	d.Confirm = model.Confirm
+	d.Recurrence = model.Recurrence
+	d.Status = model.Status
+	d.RunCount = model.RunCount
+	d.Attempts = model.Attempts
+	d.LastTransferId = model.LastTransferId
+	d.LastError = model.LastError
+	d.StartAt = model.StartAt.Format(time.RFC3339Nano)
+	d.NextRunAt = formatTime(model.NextRunAt)
+	d.LastRunAt = formatTime(model.LastRunAt)
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 计算定时转账在 after 之后的下一次执行时间, 不会再执行时返回 nil
+func nextRunAt(s model.TransferSchedule, after time.Time) (*time.Time, error) {
+	if s.Recurrence == nil {
+		if s.StartAt.After(after) {
+			return &s.StartAt, nil
+		}
+		return nil, nil
+	}
+
+	r, err := util.ParseRecurrence(*s.Recurrence, s.StartAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if next, ok := r.Next(after); ok {
+		return &next, nil
+	}
+
+	return nil, nil
+}
+
+// 锁定用户自己的定时转账
+func lockSchedule(tx *gorm.DB, uid string, id string) (s model.TransferSchedule, err error) {
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ? AND uid = ?", id, uid).First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.TransferScheduleNotExist
+		}
+		return
+	}
+
+	return
+}
+
+// 创建定时转账
+func CreateSchedule(context controller.Context, input CreateScheduleParams) (res schema.Response) {
+	var (
+		err          error
+		tx           *gorm.DB
+		data         = schema.TransferSchedule{}
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Status = schema.StatusSuccess
+			res.Data = data
+		}
+	}()
+
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	now := time.Now()
+
+	schedule := model.TransferSchedule{
+		Uid:        context.Uid,
+		Note:       input.Note,
+		Confirm:    input.Confirm,
+		Recurrence: input.Recurrence,
+		StartAt:    now,
+		Status:     model.TransferScheduleStatusActive,
+	}
+
+	if schedule.Recurrence != nil && *schedule.Recurrence == "" {
+		schedule.Recurrence = nil
+	}
+
+	if input.RunAt != nil {
+		if schedule.StartAt, err = time.Parse(time.RFC3339, *input.RunAt); err != nil {
+			err = exception.InvalidParams
+			return
+		}
+	}
+
+	// 一次性的定时转账必须指定一个将来的时间
+	if schedule.Recurrence == nil && !schedule.StartAt.After(now) {
+		err = exception.InvalidScheduleTime
+		return
+	}
+
+	// 包括开始时间本身
+	if schedule.NextRunAt, err = nextRunAt(schedule, schedule.StartAt.Add(-time.Nanosecond)); err != nil {
+		return
+	} else if schedule.NextRunAt == nil {
+		err = exception.InvalidRecurrence
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	toUserInfo, err := findRecipient(tx, context.Uid, input.To)
+
+	if err != nil {
+		return
+	}
+
+	// 保存查找到的用户ID, 之后收款方修改用户名等不会影响定时转账
+	schedule.To = toUserInfo.Id
+
+	c, err := currency.Get(tx, input.Currency)
+
+	if err != nil {
+		return
+	}
+
+	schedule.Currency = c.Code
+
+	if schedule.Amount, err = util.ParseAmount(input.Amount, c.Scale); err != nil {
+		return
+	}
+
+	if err = currency.CheckTransferAmount(c, schedule.Amount); err != nil {
+		return
+	}
+
+	if err = tx.Create(&schedule).Error; err != nil {
+		return
+	}
+
+	mapScheduleToSchema(schedule, &data)
+
+	return
+}
+
+// 获取我的定时转账
+func GetSchedules(context controller.Context, input ScheduleQuery) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.TransferSchedule, 0)
+		list = make([]model.TransferSchedule, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	query := input.Query
+
+	query.Normalize()
+
+	filter := map[string]interface{}{
+		"uid": context.Uid,
+	}
+
+	if input.Status != nil {
+		filter["status"] = *input.Status
+	}
+
+	var total int64
+
+	if err = database.Db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.TransferSchedule{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.TransferSchedule{}
+		mapScheduleToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+// 修改定时转账的状态
+func setScheduleStatus(context controller.Context, id string, status model.TransferScheduleStatus) (res schema.Response) {
+	var (
+		err  error
+		tx   *gorm.DB
+		data = schema.TransferSchedule{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+		} else {
+			res.Status = schema.StatusSuccess
+			res.Data = data
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	schedule, err := lockSchedule(tx, context.Uid, id)
+
+	if err != nil {
+		return
+	}
+
+	switch status {
+	case model.TransferScheduleStatusPaused:
+		if schedule.Status != model.TransferScheduleStatusActive {
+			err = exception.TransferScheduleInvalid
+			return
+		}
+	case model.TransferScheduleStatusActive:
+		if schedule.Status != model.TransferScheduleStatusPaused {
+			err = exception.TransferScheduleInvalid
+			return
+		}
+
+		// 暂停期间错过的不再补执行, 一次性的定时转账恢复后立即执行
+		if schedule.Recurrence != nil {
+			if schedule.NextRunAt, err = nextRunAt(schedule, time.Now()); err != nil {
+				return
+			} else if schedule.NextRunAt == nil {
+				status = model.TransferScheduleStatusFinished
+			}
+		}
+
+		schedule.Attempts = 0
+	case model.TransferScheduleStatusCancelled:
+		if schedule.Status != model.TransferScheduleStatusActive && schedule.Status != model.TransferScheduleStatusPaused {
+			err = exception.TransferScheduleInvalid
+			return
+		}
+
+		schedule.NextRunAt = nil
+	}
+
+	schedule.Status = status
+
+	if err = tx.Model(&schedule).Updates(map[string]interface{}{
+		"status":      schedule.Status,
+		"next_run_at": schedule.NextRunAt,
+		"attempts":    schedule.Attempts,
+	}).Error; err != nil {
+		return
+	}
+
+	mapScheduleToSchema(schedule, &data)
+
+	return
+}
+
+// 暂停定时转账
+func PauseSchedule(context controller.Context, id string) (res schema.Response) {
+	return setScheduleStatus(context, id, model.TransferScheduleStatusPaused)
+}
+
+// 恢复已暂停的定时转账
+func ResumeSchedule(context controller.Context, id string) (res schema.Response) {
+	return setScheduleStatus(context, id, model.TransferScheduleStatusActive)
+}
+
+// 取消定时转账, 取消之后不能恢复
+func CancelSchedule(context controller.Context, id string) (res schema.Response) {
+	return setScheduleStatus(context, id, model.TransferScheduleStatusCancelled)
+}
+
+func CreateScheduleRouter(context *gin.Context) {
+	var (
+		err   error
+		input CreateScheduleParams
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = CreateSchedule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func GetSchedulesRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input ScheduleQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetSchedules(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func PauseScheduleRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = PauseSchedule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("schedule_id"))
+}
+
+func ResumeScheduleRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = ResumeSchedule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("schedule_id"))
+}
+
+func CancelScheduleRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = CancelSchedule(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("schedule_id"))
+}
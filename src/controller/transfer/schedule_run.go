@@ -0,0 +1,273 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller/message"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 给用户发送定时转账的执行结果, 没有设置这个事件的消息模版时不通知
+func notifySchedule(tx *gorm.DB, s model.TransferSchedule, event string, vars map[string]interface{}) (*message.Notice, error) {
+	vars["Amount"] = util.AmountToStr(s.Amount)
+	vars["Currency"] = s.Currency
+	vars["To"] = s.To
+	vars["ScheduleId"] = s.Id
+
+	return message.Notify(tx, event, s.Uid, vars)
+}
+
+// 进入下一次执行, 没有下一次时结束
+func advanceSchedule(s *model.TransferSchedule, now time.Time) (err error) {
+	s.Attempts = 0
+
+	if s.NextRunAt, err = nextRunAt(*s, now); err != nil {
+		return
+	}
+
+	if s.NextRunAt == nil {
+		s.Status = model.TransferScheduleStatusFinished
+	}
+
+	return
+}
+
+func updateSchedule(tx *gorm.DB, s model.TransferSchedule) error {
+	return tx.Model(&model.TransferSchedule{}).Where("id = ?", s.Id).Updates(map[string]interface{}{
+		"status":           s.Status,
+		"next_run_at":      s.NextRunAt,
+		"run_count":        s.RunCount,
+		"attempts":         s.Attempts,
+		"last_run_at":      s.LastRunAt,
+		"last_transfer_id": s.LastTransferId,
+		"last_error":       s.LastError,
+	}).Error
+}
+
+// 锁定一条到期的定时转账, 已经被处理过的返回 nil
+func lockDueSchedule(tx *gorm.DB, id string) (*model.TransferSchedule, error) {
+	s := model.TransferSchedule{}
+
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if s.Status != model.TransferScheduleStatusActive || s.NextRunAt == nil || s.NextRunAt.After(time.Now()) {
+		return nil, nil
+	}
+
+	return &s, nil
+}
+
+// 执行一条到期的定时转账
+// 转账和更新定时转账在同一个事务中, 转账失败时回滚, 在新的事务中记录失败
+func runSchedule(id string) (err error) {
+	var (
+		tx     *gorm.DB
+		log    model.TransferLog
+		result *message.Notice
+		notice *message.Notice
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+
+			// 事务提交之后再推送
+			if err == nil && log.Id != "" {
+				if log.Status != model.TransferStatusHold {
+					data := schema.TransferLog{}
+					mapToSchema(log, &data)
+					_ = push.Publish(log.To, push.EventTransfer, data)
+				}
+
+				message.Dispatch(result)
+
+				// 与用户发起的转账一样, 发送到收款方开启的其他渠道
+				message.Dispatch(notice)
+			}
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	s, err := lockDueSchedule(tx, id)
+
+	if err != nil || s == nil {
+		return
+	}
+
+	log, er := to(tx, s.Uid, ToParams{
+		Currency: s.Currency,
+		To:       s.To,
+		Amount:   s.Amount.String(),
+		Note:     s.Note,
+		Confirm:  s.Confirm,
+	}, &s.Id)
+
+	if er != nil {
+		_ = tx.Rollback().Error
+		tx = nil
+		return failSchedule(id, er)
+	}
+
+	if log.Status != model.TransferStatusHold {
+		if notice, err = notifyTransferIn(tx, log); err != nil {
+			return
+		}
+	}
+
+	now := time.Now()
+
+	s.RunCount++
+	s.LastRunAt = &now
+	s.LastTransferId = &log.Id
+	s.LastError = nil
+
+	if err = advanceSchedule(s, now); err != nil {
+		return
+	}
+
+	if err = updateSchedule(tx, *s); err != nil {
+		return
+	}
+
+	event := model.MessageEventScheduleRun
+
+	if log.Status == model.TransferStatusHold {
+		event = model.MessageEventScheduleHold
+	}
+
+	result, err = notifySchedule(tx, *s, event, map[string]interface{}{
+		"TransferId": log.Id,
+	})
+
+	return
+}
+
+// 记录定时转账的失败, 余额不足时稍后重试, 其他错误或者超过重试次数时跳过本次
+func failSchedule(id string, cause error) (err error) {
+	var (
+		tx     *gorm.DB
+		notice *message.Notice
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+
+			if err == nil {
+				message.Dispatch(notice)
+			}
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	s, err := lockDueSchedule(tx, id)
+
+	if err != nil || s == nil {
+		return
+	}
+
+	now := time.Now()
+	reason := cause.Error()
+
+	s.Attempts++
+	s.LastError = &reason
+
+	if cause == exception.NotEnoughBalance && s.Attempts <= config.Transfer.ScheduleMaxRetries {
+		retryAt := now.Add(config.Transfer.ScheduleRetryDelay)
+		s.NextRunAt = &retryAt
+
+		return updateSchedule(tx, *s)
+	}
+
+	if s.Recurrence == nil {
+		s.Status = model.TransferScheduleStatusFailed
+		s.NextRunAt = nil
+	} else if err = advanceSchedule(s, now); err != nil {
+		return
+	}
+
+	if err = updateSchedule(tx, *s); err != nil {
+		return
+	}
+
+	notice, err = notifySchedule(tx, *s, model.MessageEventScheduleFail, map[string]interface{}{
+		"Reason": reason,
+	})
+
+	return
+}
+
+// 执行所有到期的定时转账
+func RunSchedules() (err error) {
+	ids := make([]string, 0)
+
+	if err = database.Db.Model(&model.TransferSchedule{}).Where("status = ? AND next_run_at <= ?", model.TransferScheduleStatusActive, time.Now()).Order("next_run_at ASC").Pluck("id", &ids).Error; err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		// 单条定时转账失败不影响其他定时转账, 下一次再重试
+		if e := runSchedule(id); e != nil {
+			fmt.Printf("执行定时转账 %s 失败: %s\n", id, e.Error())
+		}
+	}
+
+	return
+}
+
+// 定时执行到期的定时转账
+func RunScheduleWorker() {
+	ticker := time.NewTicker(config.Transfer.ScheduleInterval)
+
+	for range ticker.C {
+		if err := RunSchedules(); err != nil {
+			fmt.Printf("执行定时转账失败: %s\n", err.Error())
+		}
+	}
+}
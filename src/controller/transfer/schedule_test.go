@@ -0,0 +1,188 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package transfer_test
+
+import (
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/transfer"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCreateSchedule(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+	defer database.DeleteRowByTable("transfer_schedule", "uid", userFrom.Id)
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	invalid := "FREQ=YEARLY"
+	weekly := "0 9 * * 1"
+
+	create := func(runAt *string, recurrence *string) schema.Response {
+		return transfer.CreateSchedule(controller.Context{Uid: userFrom.Id}, transfer.CreateScheduleParams{
+			Currency:   model.WalletCNY,
+			To:         userTo.Id,
+			Amount:     "1",
+			RunAt:      runAt,
+			Recurrence: recurrence,
+		})
+	}
+
+	// 一次性的定时转账必须是将来的时间
+	assert.Equal(t, exception.InvalidScheduleTime.Error(), create(nil, nil).Message)
+	assert.Equal(t, exception.InvalidScheduleTime.Error(), create(&past, nil).Message)
+	assert.Equal(t, exception.InvalidRecurrence.Error(), create(nil, &invalid).Message)
+
+	r := create(&future, nil)
+	data := schema.TransferSchedule{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, model.TransferScheduleStatusActive, data.Status)
+	assert.NotNil(t, data.NextRunAt)
+
+	r = create(nil, &weekly)
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &data))
+
+	nextRunAt, err := time.Parse(time.RFC3339Nano, *data.NextRunAt)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Monday, nextRunAt.Weekday())
+	assert.Equal(t, 9, nextRunAt.Hour())
+
+	list := transfer.GetSchedules(controller.Context{Uid: userFrom.Id}, transfer.ScheduleQuery{})
+
+	assert.Equal(t, "", list.Message)
+	assert.Equal(t, int64(2), list.Meta.Total)
+}
+
+func TestRunSchedules(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+	defer database.DeleteRowByTable("transfer_schedule", "uid", userFrom.Id)
+	defer database.DeleteRowByTable("message", "uid", userFrom.Id)
+	defer database.DeleteRowByTable("message", "uid", userTo.Id)
+
+	// 收款方收到转账的通知需要有消息模版
+	tpl := model.MessageTemplate{Event: model.MessageEventTransferIn, Locale: "zh-CN"}
+
+	if database.Db.Where(&tpl).First(&tpl).RecordNotFound() {
+		tpl.Title = "收到 {{.Amount}} {{.Currency}}"
+		tpl.Content = "{{.From}}"
+		assert.Nil(t, database.Db.Create(&tpl).Error)
+		defer database.DeleteRowByTable("message_template", "id", tpl.Id)
+	}
+
+	// 定时转账的执行结果也通过消息模版通知
+	runTpl := model.MessageTemplate{Event: model.MessageEventScheduleRun, Locale: "zh-CN"}
+
+	if database.Db.Where(&runTpl).First(&runTpl).RecordNotFound() {
+		runTpl.Title = "定时转账已执行"
+		runTpl.Content = "定时转账 {{.Amount}} {{.Currency}} 已转给 {{.To}}, 转账ID {{.TransferId}}"
+		assert.Nil(t, database.Db.Create(&runTpl).Error)
+		defer database.DeleteRowByTable("message_template", "id", runTpl.Id)
+	}
+
+	daily := "FREQ=DAILY"
+
+	r := transfer.CreateSchedule(controller.Context{Uid: userFrom.Id}, transfer.CreateScheduleParams{
+		Currency:   model.WalletCNY,
+		To:         userTo.Id,
+		Amount:     "10",
+		Recurrence: &daily,
+	})
+
+	assert.Equal(t, "", r.Message)
+
+	data := schema.TransferSchedule{}
+
+	assert.Nil(t, tester.Decode(r.Data, &data))
+
+	// 把下一次执行的时间改为已到期
+	due := func() {
+		assert.Nil(t, database.Db.Model(&model.TransferSchedule{}).Where("id = ?", data.Id).UpdateColumn("next_run_at", time.Now().Add(-time.Second)).Error)
+	}
+
+	get := func() model.TransferSchedule {
+		s := model.TransferSchedule{}
+		assert.Nil(t, database.Db.Where("id = ?", data.Id).First(&s).Error)
+		return s
+	}
+
+	// 余额不足, 稍后重试
+	due()
+	assert.Nil(t, transfer.RunSchedules())
+
+	s := get()
+
+	assert.Equal(t, model.TransferScheduleStatusActive, s.Status)
+	assert.Equal(t, 1, s.Attempts)
+	assert.Equal(t, 0, s.RunCount)
+	assert.Equal(t, exception.NotEnoughBalance.Error(), *s.LastError)
+	assert.True(t, s.NextRunAt.After(time.Now()))
+
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	due()
+	assert.Nil(t, transfer.RunSchedules())
+
+	s = get()
+
+	assert.Equal(t, 0, s.Attempts)
+	assert.Equal(t, 1, s.RunCount)
+	assert.NotNil(t, s.LastTransferId)
+	assert.True(t, s.NextRunAt.After(time.Now()))
+
+	// 产生了转账记录和站内消息
+	log := model.TransferLog{}
+
+	assert.Nil(t, database.Db.Where("id = ?", *s.LastTransferId).First(&log).Error)
+	assert.Equal(t, data.Id, *log.ScheduleId)
+	assert.Equal(t, model.TransferStatusConfirmed, log.Status)
+
+	var count int
+
+	assert.Nil(t, database.Db.Model(&model.Message{}).Where("uid = ? AND note = ?", userFrom.Id, model.MessageEventScheduleRun).Count(&count).Error)
+	assert.Equal(t, 1, count)
+
+	// 与用户发起的转账一样通知收款方
+	assert.Nil(t, database.Db.Model(&model.Message{}).Where("uid = ?", userTo.Id).Count(&count).Error)
+	assert.Equal(t, 1, count)
+
+	// 暂停之后不会执行
+	assert.Equal(t, "", transfer.PauseSchedule(controller.Context{Uid: userFrom.Id}, data.Id).Message)
+
+	due()
+	assert.Nil(t, transfer.RunSchedules())
+	assert.Equal(t, 1, get().RunCount)
+
+	// 只能操作自己的定时转账
+	assert.Equal(t, exception.TransferScheduleNotExist.Error(), transfer.ResumeSchedule(controller.Context{Uid: userTo.Id}, data.Id).Message)
+
+	// 恢复之后从当前时间重新计算下一次执行的时间
+	assert.Equal(t, "", transfer.ResumeSchedule(controller.Context{Uid: userFrom.Id}, data.Id).Message)
+	assert.True(t, get().NextRunAt.After(time.Now()))
+
+	assert.Equal(t, "", transfer.CancelSchedule(controller.Context{Uid: userFrom.Id}, data.Id).Message)
+	assert.Equal(t, exception.TransferScheduleInvalid.Error(), transfer.CancelSchedule(controller.Context{Uid: userFrom.Id}, data.Id).Message)
+	assert.Equal(t, exception.TransferScheduleInvalid.Error(), transfer.ResumeSchedule(controller.Context{Uid: userFrom.Id}, data.Id).Message)
+}
@@ -4,29 +4,34 @@ package transfer
 import (
 	"errors"
 	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/config"
 	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/currency"
 	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/controller/message"
 	"github.com/axetroy/go-server/src/controller/wallet"
 	"github.com/axetroy/go-server/src/exception"
 	"github.com/axetroy/go-server/src/middleware"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
 	"github.com/axetroy/go-server/src/util"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
 
 type ToParams struct {
 	Currency string  `json:"currency" valid:"required~请选择币种"`                   // 币种
-	To       string  `json:"to" valid:"required~请输入转账对象,numeric~请输入正确的接受人ID"`   // 转账给谁
+	To       string  `json:"to" valid:"required~请输入转账对象"`                       // 转账给谁, 用户ID, 用户名, 邮箱, 手机号或者邀请码
 	Amount   string  `json:"amount" valid:"required~请输入转账数量,float~请输入纯数字的转账数量"` // 转账数量
 	Note     *string `json:"note"`                                              // 转账备注
+	Confirm  bool    `json:"confirm"`                                           // 是否需要收款方确认, 确认之前转账的金额会被冻结
 }
 
 func To(context controller.Context, input ToParams) (res schema.Response) {
@@ -34,6 +39,7 @@ func To(context controller.Context, input ToParams) (res schema.Response) {
 		err          error
 		tx           *gorm.DB
 		data         = schema.TransferLog{}
+		notice       *message.Notice
 		isValidInput bool
 	)
 
@@ -63,6 +69,14 @@ func To(context controller.Context, input ToParams) (res schema.Response) {
 		} else {
 			res.Status = schema.StatusSuccess
 			res.Data = data
+
+			// 等待审核的转账, 审核通过之后才通知收款方
+			if data.Status != model.TransferStatusHold {
+				_ = push.Publish(data.To, push.EventTransfer, data)
+			}
+
+			// 事务提交之后再发送到用户开启的其他渠道
+			message.Dispatch(notice)
 		}
 	}()
 
@@ -75,150 +89,192 @@ func To(context controller.Context, input ToParams) (res schema.Response) {
 
 	tx = database.Db.Begin()
 
-	fromUserInfo := model.User{Id: context.Uid}
-	toUserInfo := model.User{Id: input.To}
+	transferLog, err := to(tx, context.Uid, input, nil)
 
-	if err = tx.Where(&fromUserInfo).Last(&fromUserInfo).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			err = exception.UserNotExist
-		}
+	if err != nil {
 		return
 	}
 
-	if err = tx.Where(&toUserInfo).Last(&toUserInfo).Error; err != nil {
+	if transferLog.Status != model.TransferStatusHold {
+		if notice, err = notifyTransferIn(tx, transferLog); err != nil {
+			return
+		}
+	}
+
+	mapToSchema(transferLog, &data)
+
+	return
+}
+
+// 在事务中执行转账, 用户发起的转账和定时转账都通过这里执行
+func to(tx *gorm.DB, uid string, input ToParams, scheduleId *string) (transferLog model.TransferLog, err error) {
+	fromUserInfo := model.User{Id: uid}
+
+	if err = tx.Where(&fromUserInfo).Last(&fromUserInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			err = exception.UserNotExist
 		}
 		return
 	}
 
-	walletTableName := wallet.GetTableName(input.Currency)      // 对应的钱包表名
-	transferTableName := GetTransferTableName(input.Currency)   // 对应的转账记录表名
-	financeLogTableName := finance.GetTableName(input.Currency) // 对应的财务日志表名
+	toUserInfo, err := findRecipient(tx, uid, input.To)
 
-	fromUserWallet := model.Wallet{
-		Id: context.Uid,
+	if err != nil {
+		return
 	}
 
-	toUserWallet := model.Wallet{
-		Id: input.To,
+	c, err := currency.Get(tx, input.Currency)
+
+	if err != nil {
+		return
 	}
 
-	if err = tx.Table(walletTableName).Where("id = ?", fromUserWallet.Id).FirstOrInit(&fromUserWallet).Error; err != nil {
+	// 转账数量, 精度不能超过该币种的精度
+	amount, err := util.ParseAmount(input.Amount, c.Scale)
+
+	if err != nil {
 		return
 	}
 
-	if err = tx.Table(walletTableName).Where("id = ?", toUserWallet.Id).FirstOrInit(&toUserWallet).Error; err != nil {
+	// 检查币种是否允许转账, 以及单笔转账的限额
+	if err = currency.CheckTransferAmount(c, amount); err != nil {
 		return
 	}
 
-	var amount float64 // 转账数量
+	// 首次支付的邀请奖励可能是其他币种, 与双方的钱包一起按固定顺序锁定
+	keys, err := invite.RewardWallets(tx, uid, model.StatusInvitePay)
 
-	if amount, err = strconv.ParseFloat(input.Amount, 64); err != nil {
+	if err != nil {
 		return
 	}
 
-	if fromUserWallet.Balance < amount {
-		err = exception.NotEnoughBalance
+	// 按固定顺序锁定双方的钱包, 防止并发转账时余额被覆盖
+	locked, err := wallet.LockAll(tx, append(keys, wallet.LockKey{Currency: c.Code, Uid: uid}, wallet.LockKey{Currency: c.Code, Uid: toUserInfo.Id})...)
+
+	if err != nil {
 		return
 	}
 
-	// 变动前的余额/冻结
-	fromUserBeforeBalance := fromUserWallet.Balance
-	fromUserBeforeFrozen := fromUserWallet.Frozen
-	toUserBeforeBalance := toUserWallet.Balance
-	toUserBeforeFrozen := toUserWallet.Frozen
+	wallets := locked[strings.ToUpper(c.Code)]
+	fromUserWallet := wallets[uid]
+	toUserWallet := wallets[toUserInfo.Id]
+
+	// 大额转账需要先完成实名认证, 锁定钱包之后统计, 保证同一个用户的转账串行累计
+	spent, err := sumTransfer(tx, c.Code, uid, time.Now().Add(-config.Kyc.TransferWindow))
 
-	fromUserWallet.Balance = fromUserWallet.Balance - amount // - 自己的钱包
-	toUserWallet.Balance = toUserWallet.Balance + amount     // + 对方的钱包
+	if err != nil {
+		return
+	}
 
-	// 变动后的余额/冻结
-	fromUserAfterBalance := fromUserWallet.Balance
-	fromUserAfterFrozen := fromUserWallet.Frozen
-	toUserAfterBalance := toUserWallet.Balance
-	toUserAfterFrozen := toUserWallet.Frozen
+	if err = currency.CheckKyc(c, fromUserInfo.KycLevel, spent, amount); err != nil {
+		return
+	}
 
-	// 余额不能为负数
-	if fromUserWallet.Balance < 0 {
+	if fromUserWallet.Balance.LessThan(amount) {
 		err = exception.NotEnoughBalance
 		return
 	}
 
-	// 扣除我方的钱
-	if err = tx.Table(walletTableName).Where("id = ?", fromUserWallet.Id).UpdateColumn("balance", fromUserWallet.Balance).Error; err != nil {
+	// 检查风控规则, 触发规则的转账不会被拒绝, 而是冻结等待管理员审核
+	holdReason, err := checkRule(tx, c.Code, fromUserInfo, amount)
+
+	if err != nil {
 		return
 	}
 
-	// 给对方加钱
-	if err = tx.Table(walletTableName).Where("id = ?", toUserWallet.Id).UpdateColumn("balance", toUserWallet.Balance).Error; err != nil {
-		return
+	// 是否先把钱冻结, 而不是直接转给对方
+	frozen := input.Confirm || holdReason != nil
+
+	// 变动前的钱包
+	fromUserBefore := *fromUserWallet
+	toUserBefore := *toUserWallet
+
+	transferLog = model.TransferLog{
+		Currency:    c.Code,
+		From:        uid,
+		To:          toUserInfo.Id,
+		Status:      model.TransferStatusConfirmed,
+		Amount:      amount,
+		Note:        input.Note,
+		NeedConfirm: input.Confirm,
+		HoldReason:  holdReason,
+		ScheduleId:  scheduleId,
 	}
 
-	// 如果转账记录的表不存在的话，那么就生成这个表
-	if tx.HasTable(transferTableName) == false {
-		if err = tx.CreateTable(model.TransferLogMap[strings.ToUpper(input.Currency)]).Error; err != nil {
-			return
+	if frozen {
+		if holdReason != nil {
+			// 审核通过之前不会过期
+			transferLog.Status = model.TransferStatusHold
+		} else {
+			// 需要对方确认的转账, 先把钱转入自己的冻结余额
+			expiredAt := time.Now().Add(config.Transfer.ConfirmTTL)
+			transferLog.Status = model.TransferStatusWaitForConfirm
+			transferLog.ExpiredAt = &expiredAt
 		}
+
+		fromUserWallet.Balance = fromUserWallet.Balance.Sub(amount)
+		fromUserWallet.Frozen = fromUserWallet.Frozen.Add(amount)
+	} else {
+		fromUserWallet.Balance = fromUserWallet.Balance.Sub(amount) // - 自己的钱包
+		toUserWallet.Balance = toUserWallet.Balance.Add(amount)     // + 对方的钱包
 	}
 
-	transferLog := model.TransferLog{
-		Currency: strings.ToUpper(input.Currency),
-		From:     context.Uid,
-		To:       input.To,
-		Status:   model.TransferStatusConfirmed,
-		Amount:   util.FloatToStr(amount), // 保留 8 未小数
-		Note:     input.Note,
+	// 余额不能为负数
+	if fromUserWallet.Balance.IsNegative() {
+		err = exception.NotEnoughBalance
+		return
 	}
 
-	if err = tx.Table(transferTableName).Create(&transferLog).Error; err != nil {
+	// 扣除我方的钱
+	if err = wallet.Update(tx, c.Code, fromUserWallet); err != nil {
 		return
 	}
 
-	if err = mapstructure.Decode(transferLog, &data.TransferLogPure); err != nil {
+	if err = tx.Create(&transferLog).Error; err != nil {
 		return
 	}
 
-	data.CreatedAt = transferLog.CreatedAt.Format(time.RFC3339Nano)
-	data.UpdatedAt = transferLog.UpdatedAt.Format(time.RFC3339Nano)
+	if frozen {
+		// 生成我的财务日志, 等待对方确认或者审核通过后才会给对方加钱
+		if err = finance.CreateLog(tx, c.Code, fromUserBefore, *fromUserWallet, transferLog.Id, model.FinanceTypeTransferFrozen, nil); err != nil {
+			return
+		}
 
-	// 如果财务日志表不存在的话, 那么就生成这个表
-	if tx.HasTable(financeLogTableName) == false {
-		if err = tx.CreateTable(model.FinanceLogMap[input.Currency]).Error; err != nil {
+		if _, err = ledger.Post(tx, c.Code, transferLog.Id, model.FinanceTypeTransferFrozen, nil,
+			ledger.Debit(uid, model.LedgerBucketBalance, amount),
+			ledger.Credit(uid, model.LedgerBucketFrozen, amount),
+		); err != nil {
 			return
 		}
+
+		return
+	}
+
+	// 给对方加钱
+	if err = wallet.Update(tx, c.Code, toUserWallet); err != nil {
+		return
 	}
 
 	// 生成我的财务日志
-	fromUserFinanceLog := model.FinanceLog{
-		OrderId:         transferLog.Id, // 可用余额的变动
-		Uid:             context.Uid,
-		BeforeBalance:   fromUserBeforeBalance,
-		BalanceMutation: -amount,
-		AfterBalance:    fromUserAfterBalance,
-		BeforeFrozen:    fromUserBeforeFrozen, // 冻结余额的变动
-		FrozenMutation:  0,
-		AfterFrozen:     fromUserAfterFrozen,
-		Type:            model.FinanceTypeTransferOut,
+	if err = finance.CreateLog(tx, c.Code, fromUserBefore, *fromUserWallet, transferLog.Id, model.FinanceTypeTransferOut, nil); err != nil {
+		return
 	}
 
 	// 生成对方的财务日志
-	toUserFinanceLog := model.FinanceLog{
-		OrderId:         transferLog.Id,
-		Uid:             input.To,
-		BeforeBalance:   toUserBeforeBalance, // 可用余额的变动
-		BalanceMutation: amount,
-		AfterBalance:    toUserAfterBalance,
-		BeforeFrozen:    toUserBeforeFrozen, // 冻结余额的变动
-		FrozenMutation:  0,
-		AfterFrozen:     toUserAfterFrozen,
-		Type:            model.FinanceTypeTransferIn,
+	if err = finance.CreateLog(tx, c.Code, toUserBefore, *toUserWallet, transferLog.Id, model.FinanceTypeTransferIn, nil); err != nil {
+		return
 	}
 
-	if err = tx.Table(financeLogTableName).Create(&fromUserFinanceLog).Error; err != nil {
+	// 记账, 从我的余额转到对方的余额
+	if _, err = ledger.Post(tx, c.Code, transferLog.Id, model.FinanceTypeTransferOut, nil,
+		ledger.Debit(uid, model.LedgerBucketBalance, amount),
+		ledger.Credit(toUserInfo.Id, model.LedgerBucketBalance, amount),
+	); err != nil {
 		return
 	}
 
-	if err = tx.Table(financeLogTableName).Create(&toUserFinanceLog).Error; err != nil {
+	// 被邀请人的第一笔转账, 结算首次支付的邀请奖励
+	if err = invite.Settle(tx, uid, model.StatusInvitePay); err != nil {
 		return
 	}
 
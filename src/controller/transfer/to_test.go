@@ -13,10 +13,15 @@ import (
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
 	"github.com/axetroy/go-server/src/service/token"
+	"github.com/axetroy/go-server/src/util"
 	"github.com/axetroy/go-server/tester"
 	"github.com/axetroy/mocker"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -32,15 +37,15 @@ func TestTo(t *testing.T) {
 	}, transfer.ToParams{
 		Currency: "CNY",
 		To:       userTo.Id,
-		Amount:   "0.0001", // 转账失败，钱包没有余额
+		Amount:   "0.01", // 转账失败，钱包没有余额
 	})
 
 	assert.Equal(t, exception.NotEnoughBalance.Error(), res1.Message)
 	assert.Equal(t, schema.StatusFail, res1.Status)
 
 	// 给账户充钱
-	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -81,6 +86,118 @@ func TestTo(t *testing.T) {
 	assert.Equal(t, "0.00000000", toUserWallet.Frozen)
 }
 
+func TestToWithInvalidAmount(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	// 转账金额不能为 0 或者负数
+	for _, amount := range []string{"0", "-1", "-0.01"} {
+		r := transfer.To(controller.Context{
+			Uid: userFrom.Id,
+		}, transfer.ToParams{
+			Currency: "CNY",
+			To:       userTo.Id,
+			Amount:   amount,
+		})
+
+		assert.Equal(t, exception.InvalidAmount.Error(), r.Message)
+		assert.Equal(t, schema.StatusFail, r.Status)
+	}
+
+	// 人民币最多只能有 2 位小数
+	r := transfer.To(controller.Context{
+		Uid: userFrom.Id,
+	}, transfer.ToParams{
+		Currency: "CNY",
+		To:       userTo.Id,
+		Amount:   "0.001",
+	})
+
+	assert.Equal(t, exception.InvalidAmountPrecision.Error(), r.Message)
+	assert.Equal(t, schema.StatusFail, r.Status)
+
+	// 余额不变
+	r2 := wallet.GetWallet(controller.Context{Uid: userFrom.Id}, "CNY")
+	fromUserWallet := schema.Wallet{}
+
+	assert.Nil(t, tester.Decode(r2.Data, &fromUserWallet))
+	assert.Equal(t, "100.00000000", fromUserWallet.Balance)
+}
+
+func TestToWithCurrencyLimit(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	minAmount := decimal.New(1, 0)
+	maxAmount := decimal.New(10, 0)
+
+	// 新增一个币种, 用户注册时还没有这个币种的钱包
+	c := model.Currency{
+		Code:         "T" + strings.ToUpper(util.RandomString(6)),
+		Name:         "测试币",
+		Scale:        2,
+		Enabled:      true,
+		Transferable: true,
+		MinAmount:    &minAmount,
+		MaxAmount:    &maxAmount,
+	}
+
+	assert.Nil(t, database.Db.Create(&c).Error)
+
+	defer database.DeleteRowByTable("currency", "code", c.Code)
+	defer database.DeleteRowByTable("wallet", "currency", c.Code)
+	defer database.DeleteRowByTable("transfer_log", "currency", c.Code)
+	defer database.DeleteRowByTable("finance_log", "currency", c.Code)
+
+	assert.Nil(t, database.Db.Create(&model.Wallet{
+		Id:       userFrom.Id,
+		Currency: c.Code,
+		Balance:  decimal.New(100, 0),
+		Frozen:   decimal.Zero,
+	}).Error)
+
+	to := func(amount string) schema.Response {
+		return transfer.To(controller.Context{
+			Uid: userFrom.Id,
+		}, transfer.ToParams{
+			Currency: c.Code,
+			To:       userTo.Id,
+			Amount:   amount,
+		})
+	}
+
+	assert.Equal(t, exception.AmountTooSmall.Error(), to("0.5").Message)
+	assert.Equal(t, exception.AmountTooLarge.Error(), to("11").Message)
+
+	// 收款方的钱包会在转账时自动创建
+	assert.Equal(t, "", to("10").Message)
+
+	toUserWallet := model.Wallet{}
+
+	assert.Nil(t, database.Db.Where("id = ? AND currency = ?", userTo.Id, c.Code).First(&toUserWallet).Error)
+	assert.Equal(t, "10", toUserWallet.Balance.String())
+
+	// 不允许转账的币种
+	assert.Nil(t, database.Db.Model(&c).Update("transferable", false).Error)
+	assert.Equal(t, exception.CurrencyNotTransferable.Error(), to("1").Message)
+
+	// 停用的币种
+	assert.Nil(t, database.Db.Model(&c).Update("enabled", false).Error)
+	assert.Equal(t, exception.CurrencyDisabled.Error(), to("1").Message)
+}
+
 func TestToRouter(t *testing.T) {
 	userFrom, _ := tester.CreateUser()
 	userTo, _ := tester.CreateUser()
@@ -98,8 +215,8 @@ func TestToRouter(t *testing.T) {
 	assert.Equal(t, schema.StatusSuccess, rr.Status)
 
 	// 给账户充钱
-	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -145,3 +262,155 @@ func TestToRouter(t *testing.T) {
 		assert.Equal(t, "0.00000000", toUserWallet.Frozen)
 	}
 }
+
+func TestToRouterWithIdempotencyKey(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userFrom.Username)
+	defer auth.DeleteUserByUserName(userTo.Username)
+
+	// 设置用户的交易密码
+	rr := user.SetPayPassword(controller.Context{Uid: userFrom.Id}, user.SetPayPasswordParams{
+		Password:        "123123",
+		PasswordConfirm: "123123",
+	})
+
+	assert.Equal(t, "", rr.Message)
+	assert.Equal(t, schema.StatusSuccess, rr.Status)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", userFrom.Id, model.WalletCNY).Update(model.Wallet{
+		Balance:  decimal.New(100, 0),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	header := mocker.Header{
+		"Authorization":   token.Prefix + " " + userFrom.Token,
+		"X-Pay-Password":  "123123",
+		"Idempotency-Key": "transfer-" + userFrom.Id,
+	}
+
+	body, _ := json.Marshal(&transfer.ToParams{
+		Currency: "CNY",
+		To:       userTo.Id,
+		Amount:   "20",
+	})
+
+	// 第一次转账
+	r1 := tester.HttpUser.Post("/v1/transfer", body, &header)
+	res1 := schema.Response{}
+	assert.Nil(t, json.Unmarshal([]byte(r1.Body.String()), &res1))
+	assert.Equal(t, "", res1.Message)
+	assert.Equal(t, schema.StatusSuccess, res1.Status)
+	assert.Equal(t, "", r1.Header().Get("Idempotent-Replayed"))
+
+	// 客户端超时后重试, 返回第一次的响应
+	r2 := tester.HttpUser.Post("/v1/transfer", body, &header)
+	assert.Equal(t, r1.Body.String(), r2.Body.String())
+	assert.Equal(t, "true", r2.Header().Get("Idempotent-Replayed"))
+
+	// 相同的幂等键, 不同的请求体
+	{
+		body, _ := json.Marshal(&transfer.ToParams{
+			Currency: "CNY",
+			To:       userTo.Id,
+			Amount:   "30",
+		})
+
+		r := tester.HttpUser.Post("/v1/transfer", body, &header)
+		res := schema.Response{}
+		assert.Nil(t, json.Unmarshal([]byte(r.Body.String()), &res))
+		assert.Equal(t, exception.IdempotencyKeyReused.Error(), res.Message)
+		assert.Equal(t, schema.StatusFail, res.Status)
+	}
+
+	// 只扣了一次钱
+	r3 := wallet.GetWallet(controller.Context{Uid: userFrom.Id}, "CNY")
+	fromUserWallet := schema.Wallet{}
+
+	assert.Nil(t, tester.Decode(r3.Data, &fromUserWallet))
+	assert.Equal(t, "80.00000000", fromUserWallet.Balance)
+}
+
+// 并发转账的压力测试, 无论如何转账, 所有账户的余额总和都应该保持不变
+func TestToConcurrent(t *testing.T) {
+	var (
+		userNum     = 5
+		transferNum = 100
+		users       = make([]schema.ProfileWithToken, 0)
+	)
+
+	for i := 0; i < userNum; i++ {
+		u, err := tester.CreateUser()
+
+		assert.Nil(t, err)
+
+		defer auth.DeleteUserByUserName(u.Username)
+
+		// 给每个账户充 100
+		assert.Nil(t, database.Db.Model(&model.Wallet{}).Where("id = ? AND currency = ?", u.Id, model.WalletCNY).Update(model.Wallet{
+			Balance:  decimal.New(100, 0),
+			Currency: model.WalletCNY,
+		}).Error)
+
+		users = append(users, u)
+	}
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < transferNum; i++ {
+		from := users[rand.Intn(userNum)]
+		to := users[rand.Intn(userNum)]
+
+		// 互相转账，制造交叉加锁的场景
+		if from.Id == to.Id {
+			to = users[(rand.Intn(userNum-1)+1+indexOf(users, from))%userNum]
+		}
+
+		wg.Add(1)
+
+		go func(from, to schema.ProfileWithToken) {
+			defer wg.Done()
+
+			r := transfer.To(controller.Context{
+				Uid: from.Id,
+			}, transfer.ToParams{
+				Currency: "CNY",
+				To:       to.Id,
+				Amount:   "7.33",
+			})
+
+			// 余额不足是允许的, 但是不能有其他错误 (例如死锁)
+			if r.Status != schema.StatusSuccess {
+				assert.Equal(t, exception.NotEnoughBalance.Error(), r.Message)
+			}
+		}(from, to)
+	}
+
+	wg.Wait()
+
+	total := decimal.Zero
+
+	for _, u := range users {
+		w := model.Wallet{}
+
+		assert.Nil(t, database.Db.Where("id = ? AND currency = ?", u.Id, model.WalletCNY).First(&w).Error)
+
+		// 余额不可能为负数
+		assert.False(t, w.Balance.IsNegative())
+
+		total = total.Add(w.Balance)
+	}
+
+	assert.True(t, total.Equal(decimal.New(int64(userNum*100), 0)), total.String())
+}
+
+func indexOf(users []schema.ProfileWithToken, u schema.ProfileWithToken) int {
+	for i, v := range users {
+		if v.Id == u.Id {
+			return i
+		}
+	}
+	return -1
+}
@@ -2,83 +2,49 @@
 package transfer
 
 import (
-	"fmt"
+	"github.com/axetroy/go-server/src/controller/message"
 	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/util"
-	"reflect"
-	"strings"
+	"github.com/jinzhu/gorm"
+	"time"
 )
 
-// 获取转账表名
-func GetTransferTableName(currency string) string {
-	return "transfer_log_" + strings.ToLower(currency)
-}
-
-type QueryParams struct {
-	Id       *string               `json:"id"`       // 转账ID
-	Currency *string               `json:"currency"` // 转账币种
-	From     *string               `json:"from"`     // 汇款人
-	To       *string               `json:"to"`       // 收款人
-	Amount   *string               `json:"amount"`   // 转账数量
-	Status   *model.TransferStatus `json:"status"`   // 转账状态
-}
-
-func GenerateTransferLogSQL(filter QueryParams, limit int, count bool) string {
-	suffix := `("deleted_at" IS NULL OR "deleted_at"='0001-01-01 00:00:00')`
-
-	filterArray := make([]string, 0)
-
-	{
-		t := reflect.TypeOf(filter)
-		v := reflect.ValueOf(filter)
-
-		for k := 0; k < t.NumField(); k++ {
-			key := t.Field(k).Tag.Get("json")
-			value := v.Field(k).Interface()
-
-			if key == "" {
-				continue
-			}
-
-			if !v.Field(k).IsValid() {
-				continue
-			}
-
-			if util.IsNil(value) {
-				continue
-			}
-
-			// 如果是指针的话
-			if util.IsPoint(value) {
-				// 获取指针对应的值
-				value = reflect.ValueOf(value).Elem()
-			} else {
-				continue
-			}
-
-			filterArray = append(filterArray, fmt.Sprintf(`"%s"='%v'`, key, value))
-		}
+func mapToSchema(model model.TransferLog, d *schema.TransferLog) {
+	d.Id = model.Id
+	d.Currency = model.Currency
+	d.From = model.From
+	d.To = model.To
+	d.Amount = util.AmountToStr(model.Amount)
+	d.Status = model.Status
+	d.Note = model.Note
+	d.HoldReason = model.HoldReason
+	d.Reviewer = model.Reviewer
+	d.ReversalOf = model.ReversalOf
+	if model.ExpiredAt != nil {
+		expiredAt := model.ExpiredAt.Format(time.RFC3339Nano)
+		d.ExpiredAt = &expiredAt
 	}
-
-	filterStr := "WHERE " + strings.Join(filterArray[:], " AND ")
-
-	if len(filterArray) != 0 {
-		filterStr = filterStr + " AND"
+	if model.ReviewedAt != nil {
+		reviewedAt := model.ReviewedAt.Format(time.RFC3339Nano)
+		d.ReviewedAt = &reviewedAt
 	}
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
 
-	SQLs := make([]string, 0)
-
-	selected := "*"
-
-	if count {
-		selected = "COUNT(*)"
-	}
+// 通知收款方收到了转账, 没有设置消息模版时不通知
+func notifyTransferIn(tx *gorm.DB, log model.TransferLog) (*message.Notice, error) {
+	fromUserInfo := model.User{Id: log.From}
 
-	for _, tableName := range model.TransferTableNames {
-		sql := fmt.Sprintf(`SELECT %s FROM "%s" %s %s`, selected, tableName, filterStr, suffix)
-		SQLs = append(SQLs, sql)
+	if err := tx.First(&fromUserInfo).Error; err != nil {
+		return nil, err
 	}
 
-	sql := fmt.Sprintf("%s LIMIT %d", strings.Join(SQLs[:], " UNION "), limit)
-	return sql
+	return message.Notify(tx, model.MessageEventTransferIn, log.To, map[string]interface{}{
+		"Amount":     util.AmountToStr(log.Amount),
+		"Currency":   log.Currency,
+		"From":       fromUserInfo.Username,
+		"TransferId": log.Id,
+	})
 }
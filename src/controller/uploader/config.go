@@ -30,10 +30,20 @@ type AvatarConfig struct {
 	Path string // 头像存储的路径
 }
 
+type StatementConfig struct {
+	Path string `json:"path"` // 生成的对账单存放目录, 不能通过普通文件的下载接口访问
+}
+
+type KycConfig struct {
+	Path string `json:"path"` // 实名认证的证件图片存放目录, 只能由管理员下载
+}
+
 type TConfig struct {
-	Path  string      `json:"path"`  //文件上传的根目录
-	File  FileConfig  `json:"file"`  // 普通文件上传的配置
-	Image ImageConfig `json:"image"` // 普通图片上传的配置
+	Path      string          `json:"path"`      //文件上传的根目录
+	File      FileConfig      `json:"file"`      // 普通文件上传的配置
+	Image     ImageConfig     `json:"image"`     // 普通图片上传的配置
+	Statement StatementConfig `json:"statement"` // 对账单文件的配置
+	Kyc       KycConfig       `json:"kyc"`       // 实名认证证件的配置
 }
 
 var Config = TConfig{
@@ -54,6 +64,12 @@ var Config = TConfig{
 			Path: "avatar",
 		},
 	},
+	Statement: StatementConfig{
+		Path: "statement",
+	},
+	Kyc: KycConfig{
+		Path: "kyc",
+	},
 }
 
 // 确保上传的文件目录存在
@@ -85,5 +101,13 @@ func init() {
 		return
 	}
 
+	if err = fs.EnsureDir(path.Join(Config.Path, Config.Statement.Path)); err != nil {
+		return
+	}
+
+	if err = fs.EnsureDir(path.Join(Config.Path, Config.Kyc.Path)); err != nil {
+		return
+	}
+
 	return
 }
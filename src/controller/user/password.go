@@ -10,6 +10,7 @@ import (
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/push"
 	"github.com/axetroy/go-server/src/util"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
@@ -58,6 +59,9 @@ func UpdatePassword(context controller.Context, input UpdatePasswordParams) (res
 		} else {
 			res.Data = true
 			res.Status = schema.StatusSuccess
+
+			// 修改了密码, 其他设备上的登陆需要重新登陆
+			_ = push.Publish(context.Uid, push.EventLogout, nil)
 		}
 	}()
 
@@ -133,6 +137,9 @@ func UpdatePasswordByAdmin(context controller.Context, userId string, input Upda
 		} else {
 			res.Data = true
 			res.Status = schema.StatusSuccess
+
+			// 管理员修改了密码, 强制用户重新登陆
+			_ = push.Publish(userId, push.EventLogout, nil)
 		}
 	}()
 
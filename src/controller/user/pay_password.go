@@ -6,6 +6,7 @@ import (
 	"github.com/asaskevich/govalidator"
 	"github.com/axetroy/go-server/src/controller"
 	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/message_queue"
 	"github.com/axetroy/go-server/src/middleware"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
@@ -250,8 +251,9 @@ func UpdatePayPasswordRouter(context *gin.Context) {
 
 func SendResetPayPassword(context controller.Context) (res schema.Response) {
 	var (
-		err error
-		tx  *gorm.DB
+		err        error
+		tx         *gorm.DB
+		resetEmail *model.EmailLog
 	)
 
 	defer func() {
@@ -281,6 +283,11 @@ func SendResetPayPassword(context controller.Context) (res schema.Response) {
 		} else {
 			res.Data = true
 			res.Status = schema.StatusSuccess
+
+			// 事务提交之后再加入消息队列, 由消息队列生成重置码并发送
+			if resetEmail != nil {
+				_ = message_queue.PublishEmail(*resetEmail)
+			}
 		}
 	}()
 
@@ -295,21 +302,24 @@ func SendResetPayPassword(context controller.Context) (res schema.Response) {
 		return
 	}
 
-	// 生成重置码
-	var resetCode = GenerateResetPayPasswordCode(userInfo.Id)
+	if userInfo.Email != nil {
+		log, er := message_queue.CreateEmail(tx, userInfo.Id, *userInfo.Email, "", email.TemplateResetPayPassword, nil)
 
-	// redis缓存重置码
-	if err = redis.ResetCodeClient.Set(resetCode, userInfo.Id, time.Minute*10).Err(); err != nil {
-		return
-	}
+		if er != nil {
+			err = er
+			return
+		}
 
-	if userInfo.Email != nil {
-		// 发送邮件
-		go func() {
-			e := email.NewMailer()
-			_ = e.SendForgotTradePasswordEmail(*userInfo.Email, resetCode)
-		}()
+		resetEmail = &log
 	} else if userInfo.Phone != nil {
+		// 生成重置码
+		var resetCode = GenerateResetPayPasswordCode(userInfo.Id)
+
+		// redis缓存重置码
+		if err = redis.ResetCodeClient.Set(resetCode, userInfo.Id, time.Minute*10).Err(); err != nil {
+			return
+		}
+
 		// TODO: 发送手机验证码
 		go func() {
 
@@ -21,6 +21,7 @@ type UpdateProfileParams struct {
 	Nickname *string       `json:"nickname" valid:"length(1|36)~昵称长度为1-36位"`
 	Gender   *model.Gender `json:"gender"`
 	Avatar   *string       `json:"avatar"`
+	Locale   *string       `json:"locale" valid:"length(2|16)~语言长度为2-16位"` // 用户使用的语言, 个人消息会使用这个语言的模版
 }
 
 func GetProfile(context controller.Context) (res schema.Response) {
@@ -215,6 +216,11 @@ func UpdateProfile(context controller.Context, input UpdateProfileParams) (res s
 		shouldUpdate = true
 	}
 
+	if input.Locale != nil {
+		updated.Locale = input.Locale
+		shouldUpdate = true
+	}
+
 	if shouldUpdate {
 		if err = tx.Table(updated.TableName()).Where(model.User{Id: context.Uid}).Updates(updated).Error; err != nil {
 			return
@@ -320,6 +326,11 @@ func UpdateProfileByAdmin(context controller.Context, userId string, input Updat
 		shouldUpdate = true
 	}
 
+	if input.Locale != nil {
+		updated.Locale = input.Locale
+		shouldUpdate = true
+	}
+
 	if shouldUpdate {
 		if err = tx.Table(updated.TableName()).Where(model.User{Id: userId}).Updates(updated).Error; err != nil {
 			return
@@ -0,0 +1,524 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package wallet
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/controller/currency"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/ledger"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/middleware"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"time"
+)
+
+type AdjustParams struct {
+	Uid      string            `json:"uid" valid:"required~请输入用户ID"`    // 被调整的用户
+	Currency string            `json:"currency" valid:"required~请选择币种"` // 币种
+	Type     model.FinanceType `json:"type" valid:"required~请选择调整类型"`   // 调整类型
+	Amount   string            `json:"amount" valid:"required~请输入调整数量"` // 调整数量
+	Reason   string            `json:"reason" valid:"required~请输入调整原因"` // 调整原因, 必填
+}
+
+type AdjustmentQuery struct {
+	schema.Query
+	Uid    *string                       `json:"uid" form:"uid"`       // 指定某个用户
+	Status *model.WalletAdjustmentStatus `json:"status" form:"status"` // 指定状态
+}
+
+func mapAdjustmentToSchema(model model.WalletAdjustment, d *schema.WalletAdjustment) {
+	d.Id = model.Id
+	d.Uid = model.Uid
+	d.Currency = model.Currency
+	d.Type = model.Type
+	d.Amount = util.AmountToStr(model.Amount)
+	d.Reason = model.Reason
+	d.Status = model.Status
+	d.Creator = model.Creator
+	d.Reviewer = model.Reviewer
+	if model.ReviewedAt != nil {
+		reviewedAt := model.ReviewedAt.Format(time.RFC3339Nano)
+		d.ReviewedAt = &reviewedAt
+	}
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 等待收款方确认或者等待风控审核的转账冻结的数量, 这部分冻结余额不能被管理员解冻
+func transferFrozen(tx *gorm.DB, uid string, currency string) (total decimal.Decimal, err error) {
+	err = tx.Model(&model.TransferLog{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("\"from\" = ? AND currency = ? AND status IN (?)", uid, currency, []model.TransferStatus{model.TransferStatusWaitForConfirm, model.TransferStatusHold}).
+		Row().
+		Scan(&total)
+	return
+}
+
+// 执行钱包调整, 与转账使用同样的锁
+func execAdjustment(tx *gorm.DB, adjustment *model.WalletAdjustment) (err error) {
+	wallets, err := Lock(tx, adjustment.Currency, adjustment.Uid)
+
+	if err != nil {
+		return
+	}
+
+	w := wallets[adjustment.Uid]
+	before := *w
+
+	var legs []ledger.Leg
+
+	switch adjustment.Type {
+	case model.FinanceTypeAdminCredit:
+		w.Balance = w.Balance.Add(adjustment.Amount)
+		legs = []ledger.Leg{
+			ledger.Debit(model.LedgerAccountAdjustment, model.LedgerBucketBalance, adjustment.Amount),
+			ledger.Credit(w.Id, model.LedgerBucketBalance, adjustment.Amount),
+		}
+	case model.FinanceTypeAdminDebit:
+		w.Balance = w.Balance.Sub(adjustment.Amount)
+		legs = []ledger.Leg{
+			ledger.Debit(w.Id, model.LedgerBucketBalance, adjustment.Amount),
+			ledger.Credit(model.LedgerAccountAdjustment, model.LedgerBucketBalance, adjustment.Amount),
+		}
+	case model.FinanceTypeFreeze:
+		w.Balance = w.Balance.Sub(adjustment.Amount)
+		w.Frozen = w.Frozen.Add(adjustment.Amount)
+		legs = []ledger.Leg{
+			ledger.Debit(w.Id, model.LedgerBucketBalance, adjustment.Amount),
+			ledger.Credit(w.Id, model.LedgerBucketFrozen, adjustment.Amount),
+		}
+	case model.FinanceTypeUnfreeze:
+		var locked decimal.Decimal
+
+		if locked, err = transferFrozen(tx, adjustment.Uid, adjustment.Currency); err != nil {
+			return
+		}
+
+		// 超过冻结余额的情况在下面统一返回冻结余额不足
+		if adjustment.Amount.LessThanOrEqual(w.Frozen) && adjustment.Amount.GreaterThan(w.Frozen.Sub(locked)) {
+			err = exception.FrozenByTransfer
+			return
+		}
+
+		w.Balance = w.Balance.Add(adjustment.Amount)
+		w.Frozen = w.Frozen.Sub(adjustment.Amount)
+		legs = []ledger.Leg{
+			ledger.Debit(w.Id, model.LedgerBucketFrozen, adjustment.Amount),
+			ledger.Credit(w.Id, model.LedgerBucketBalance, adjustment.Amount),
+		}
+	default:
+		err = exception.InvalidWalletAdjustmentType
+		return
+	}
+
+	if w.Balance.IsNegative() {
+		err = exception.NotEnoughBalance
+		return
+	}
+
+	if w.Frozen.IsNegative() {
+		err = exception.NotEnoughFrozen
+		return
+	}
+
+	if err = Update(tx, adjustment.Currency, w); err != nil {
+		return
+	}
+
+	if err = finance.CreateLog(tx, adjustment.Currency, before, *w, adjustment.Id, adjustment.Type, &adjustment.Reason); err != nil {
+		return
+	}
+
+	if _, err = ledger.Post(tx, adjustment.Currency, adjustment.Id, adjustment.Type, &adjustment.Reason, legs...); err != nil {
+		return
+	}
+
+	adjustment.Status = model.WalletAdjustmentStatusDone
+
+	return
+}
+
+// 管理员调整用户的钱包, 超过审核阈值的调整需要另一个管理员审核后才会执行
+func Adjust(context controller.Context, input AdjustParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.WalletAdjustment
+		tx           *gorm.DB
+		isValidInput bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	// 参数校验
+	if isValidInput, err = govalidator.ValidateStruct(input); err != nil {
+		return
+	} else if isValidInput == false {
+		err = exception.InvalidParams
+		return
+	}
+
+	if !model.IsValidWalletAdjustmentType(input.Type) {
+		err = exception.InvalidWalletAdjustmentType
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	c, err := currency.Get(tx, input.Currency)
+
+	if err != nil {
+		return
+	}
+
+	amount, err := util.ParseAmount(input.Amount, c.Scale)
+
+	if err != nil {
+		return
+	}
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminWalletAdjust); err != nil {
+		return
+	}
+
+	userInfo := model.User{
+		Id: input.Uid,
+	}
+
+	if err = tx.First(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	adjustment := model.WalletAdjustment{
+		Uid:      input.Uid,
+		Currency: c.Code,
+		Type:     input.Type,
+		Amount:   amount,
+		Reason:   input.Reason,
+		Status:   model.WalletAdjustmentStatusPending,
+		Creator:  context.Uid,
+	}
+
+	if err = tx.Create(&adjustment).Error; err != nil {
+		return
+	}
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+
+	// 没有超过阈值的调整直接执行
+	if threshold == nil || amount.LessThanOrEqual(*threshold) {
+		if err = execAdjustment(tx, &adjustment); err != nil {
+			return
+		}
+
+		if err = tx.Model(&adjustment).UpdateColumn("status", adjustment.Status).Error; err != nil {
+			return
+		}
+	}
+
+	mapAdjustmentToSchema(adjustment, &data)
+
+	return
+}
+
+// 审核一条钱包调整
+func review(context controller.Context, id string, approve bool) (res schema.Response) {
+	var (
+		err  error
+		data schema.WalletAdjustment
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+		}
+	}()
+
+	tx = database.Db.Begin()
+
+	if _, err = admin.Check(tx, context.Uid, *accession.AdminWalletApprove); err != nil {
+		return
+	}
+
+	adjustment := model.WalletAdjustment{}
+
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&adjustment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.WalletAdjustmentNotExist
+		}
+		return
+	}
+
+	if adjustment.Status != model.WalletAdjustmentStatusPending {
+		err = exception.WalletAdjustmentNotPending
+		return
+	}
+
+	// 必须由另一个管理员审核
+	if adjustment.Creator == context.Uid {
+		err = exception.WalletAdjustmentSelfApproved
+		return
+	}
+
+	if approve {
+		if err = execAdjustment(tx, &adjustment); err != nil {
+			return
+		}
+	} else {
+		adjustment.Status = model.WalletAdjustmentStatusRejected
+	}
+
+	now := time.Now()
+
+	adjustment.Reviewer = &context.Uid
+	adjustment.ReviewedAt = &now
+
+	if err = tx.Model(&adjustment).Updates(map[string]interface{}{
+		"status":      adjustment.Status,
+		"reviewer":    adjustment.Reviewer,
+		"reviewed_at": adjustment.ReviewedAt,
+	}).Error; err != nil {
+		return
+	}
+
+	mapAdjustmentToSchema(adjustment, &data)
+
+	return
+}
+
+// 审核通过并执行钱包调整
+func ApproveAdjustment(context controller.Context, id string) (res schema.Response) {
+	return review(context, id, true)
+}
+
+// 拒绝钱包调整
+func RejectAdjustment(context controller.Context, id string) (res schema.Response) {
+	return review(context, id, false)
+}
+
+// 获取钱包调整记录
+func GetAdjustmentList(context controller.Context, input AdjustmentQuery) (res schema.List) {
+	var (
+		err  error
+		data = make([]schema.WalletAdjustment, 0)
+		list = make([]model.WalletAdjustment, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			res.Message = err.Error()
+			res.Data = nil
+			res.Meta = nil
+		} else {
+			res.Data = data
+			res.Status = schema.StatusSuccess
+			res.Meta = meta
+		}
+	}()
+
+	if _, err = admin.Check(database.Db, context.Uid, *accession.AdminWalletAdjust); err != nil {
+		return
+	}
+
+	query := input.Query
+
+	query.Normalize()
+
+	filter := map[string]interface{}{}
+
+	if input.Uid != nil {
+		filter["uid"] = *input.Uid
+	}
+
+	if input.Status != nil {
+		filter["status"] = *input.Status
+	}
+
+	var total int64
+
+	if err = database.Db.Limit(query.Limit).Offset(query.Limit * query.Page).Order(query.Sort).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.WalletAdjustment{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.WalletAdjustment{}
+		mapAdjustmentToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(data)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+
+	return
+}
+
+func AdjustRouter(context *gin.Context) {
+	var (
+		input AdjustParams
+		err   error
+		res   = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindJSON(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = Adjust(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
+
+func ApproveAdjustmentRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = ApproveAdjustment(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("adjustment_id"))
+}
+
+func RejectAdjustmentRouter(context *gin.Context) {
+	var (
+		err error
+		res = schema.Response{}
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	res = RejectAdjustment(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, context.Param("adjustment_id"))
+}
+
+func GetAdjustmentListRouter(context *gin.Context) {
+	var (
+		err   error
+		res   = schema.List{}
+		input AdjustmentQuery
+	)
+
+	defer func() {
+		if err != nil {
+			res.Data = nil
+			res.Message = err.Error()
+		}
+		context.JSON(http.StatusOK, res)
+	}()
+
+	if err = context.ShouldBindQuery(&input); err != nil {
+		err = exception.InvalidParams
+		return
+	}
+
+	res = GetAdjustmentList(controller.Context{
+		Uid: context.GetString(middleware.ContextUidField),
+	}, input)
+}
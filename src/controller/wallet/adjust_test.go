@@ -0,0 +1,208 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package wallet_test
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/admin"
+	"github.com/axetroy/go-server/src/controller/auth"
+	"github.com/axetroy/go-server/src/controller/wallet"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/rbac/accession"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func assertWallet(t *testing.T, uid string, balance string, frozen string) {
+	r := wallet.GetWallet(controller.Context{Uid: uid}, "CNY")
+	w := schema.Wallet{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, tester.Decode(r.Data, &w))
+	assert.Equal(t, balance, w.Balance)
+	assert.Equal(t, frozen, w.Frozen)
+}
+
+func TestAdjust(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+	config.Admin.WalletAdjustApproveThreshold = nil
+	defer func() {
+		config.Admin.WalletAdjustApproveThreshold = threshold
+	}()
+
+	adjust := func(t model.FinanceType, amount string) schema.Response {
+		return wallet.Adjust(controller.Context{Uid: adminInfo.Id}, wallet.AdjustParams{
+			Uid:      userInfo.Id,
+			Currency: "CNY",
+			Type:     t,
+			Amount:   amount,
+			Reason:   "test",
+		})
+	}
+
+	// 充值
+	r := adjust(model.FinanceTypeAdminCredit, "100")
+	data := schema.WalletAdjustment{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, tester.Decode(r.Data, &data))
+	assert.Equal(t, model.WalletAdjustmentStatusDone, data.Status)
+	assertWallet(t, userInfo.Id, "100.00000000", "0.00000000")
+
+	// 冻结
+	assert.Equal(t, "", adjust(model.FinanceTypeFreeze, "30").Message)
+	assertWallet(t, userInfo.Id, "70.00000000", "30.00000000")
+
+	// 解冻
+	assert.Equal(t, "", adjust(model.FinanceTypeUnfreeze, "10").Message)
+	assertWallet(t, userInfo.Id, "80.00000000", "20.00000000")
+
+	// 扣除
+	assert.Equal(t, "", adjust(model.FinanceTypeAdminDebit, "50").Message)
+	assertWallet(t, userInfo.Id, "30.00000000", "20.00000000")
+
+	// 余额不足
+	assert.Equal(t, exception.NotEnoughBalance.Error(), adjust(model.FinanceTypeAdminDebit, "50").Message)
+	assert.Equal(t, exception.NotEnoughFrozen.Error(), adjust(model.FinanceTypeUnfreeze, "50").Message)
+	assertWallet(t, userInfo.Id, "30.00000000", "20.00000000")
+
+	// 调整原因是必填的
+	r2 := wallet.Adjust(controller.Context{Uid: adminInfo.Id}, wallet.AdjustParams{
+		Uid:      userInfo.Id,
+		Currency: "CNY",
+		Type:     model.FinanceTypeAdminCredit,
+		Amount:   "1",
+	})
+
+	assert.Equal(t, schema.StatusFail, r2.Status)
+
+	// 每次调整都会生成流水
+	var count int
+	assert.Nil(t, database.Db.Model(&model.FinanceLog{}).Where("uid = ? AND currency = ?", userInfo.Id, model.WalletCNY).Count(&count).Error)
+	assert.Equal(t, 4, count)
+}
+
+func TestUnfreezeTransferFrozen(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+	defer database.DeleteRowByTable("transfer_log", "from", userInfo.Id)
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+	config.Admin.WalletAdjustApproveThreshold = nil
+	defer func() {
+		config.Admin.WalletAdjustApproveThreshold = threshold
+	}()
+
+	adjust := func(t model.FinanceType, amount string) schema.Response {
+		return wallet.Adjust(controller.Context{Uid: adminInfo.Id}, wallet.AdjustParams{
+			Uid:      userInfo.Id,
+			Currency: "CNY",
+			Type:     t,
+			Amount:   amount,
+			Reason:   "test",
+		})
+	}
+
+	assert.Equal(t, "", adjust(model.FinanceTypeAdminCredit, "100").Message)
+	assert.Equal(t, "", adjust(model.FinanceTypeFreeze, "30").Message)
+
+	// 其中 20 属于等待收款方确认的转账
+	assert.Nil(t, database.Db.Create(&model.TransferLog{
+		Currency: "CNY",
+		From:     userInfo.Id,
+		To:       adminInfo.Id,
+		Amount:   decimal.New(20, 0),
+		Status:   model.TransferStatusWaitForConfirm,
+	}).Error)
+
+	assert.Equal(t, exception.FrozenByTransfer.Error(), adjust(model.FinanceTypeUnfreeze, "20").Message)
+	assertWallet(t, userInfo.Id, "70.00000000", "30.00000000")
+
+	assert.Equal(t, "", adjust(model.FinanceTypeUnfreeze, "10").Message)
+	assertWallet(t, userInfo.Id, "80.00000000", "20.00000000")
+}
+
+func TestAdjustWithApprove(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer auth.DeleteUserByUserName(userInfo.Username)
+
+	threshold := config.Admin.WalletAdjustApproveThreshold
+	d := decimal.New(1000, 0)
+	config.Admin.WalletAdjustApproveThreshold = &d
+	defer func() {
+		config.Admin.WalletAdjustApproveThreshold = threshold
+	}()
+
+	// 创建另一个有审核权限的管理员
+	r := admin.CreateAdmin(admin.CreateAdminParams{
+		Account:  "reviewer",
+		Password: "123123",
+		Name:     "reviewer",
+	}, false)
+
+	assert.Equal(t, "", r.Message)
+
+	defer admin.DeleteAdminByAccount("reviewer")
+
+	reviewer := schema.AdminProfile{}
+
+	assert.Nil(t, tester.Decode(r.Data, &reviewer))
+	assert.Nil(t, database.Db.Model(&model.Admin{Id: reviewer.Id}).Update("accession", pq.StringArray{accession.AdminWalletApprove.Name}).Error)
+
+	// 超过阈值，需要审核
+	r2 := wallet.Adjust(controller.Context{Uid: adminInfo.Id}, wallet.AdjustParams{
+		Uid:      userInfo.Id,
+		Currency: "CNY",
+		Type:     model.FinanceTypeAdminCredit,
+		Amount:   "5000",
+		Reason:   "test",
+	})
+
+	adjustment := schema.WalletAdjustment{}
+
+	assert.Equal(t, "", r2.Message)
+	assert.Nil(t, tester.Decode(r2.Data, &adjustment))
+	assert.Equal(t, model.WalletAdjustmentStatusPending, adjustment.Status)
+	assertWallet(t, userInfo.Id, "0.00000000", "0.00000000")
+
+	// 不能自己审核自己
+	r3 := wallet.ApproveAdjustment(controller.Context{Uid: adminInfo.Id}, adjustment.Id)
+	assert.Equal(t, exception.WalletAdjustmentSelfApproved.Error(), r3.Message)
+
+	r4 := wallet.ApproveAdjustment(controller.Context{Uid: reviewer.Id}, adjustment.Id)
+	assert.Equal(t, "", r4.Message)
+	assert.Nil(t, tester.Decode(r4.Data, &adjustment))
+	assert.Equal(t, model.WalletAdjustmentStatusDone, adjustment.Status)
+	assert.Equal(t, reviewer.Id, *adjustment.Reviewer)
+	assertWallet(t, userInfo.Id, "5000.00000000", "0.00000000")
+
+	// 不能重复审核
+	r5 := wallet.RejectAdjustment(controller.Context{Uid: reviewer.Id}, adjustment.Id)
+	assert.Equal(t, exception.WalletAdjustmentNotPending.Error(), r5.Message)
+
+	// 没有调整权限的管理员不能发起调整
+	r6 := wallet.Adjust(controller.Context{Uid: reviewer.Id}, wallet.AdjustParams{
+		Uid:      userInfo.Id,
+		Currency: "CNY",
+		Type:     model.FinanceTypeAdminCredit,
+		Amount:   "1",
+		Reason:   "test",
+	})
+	assert.Equal(t, exception.NoPermission.Error(), r6.Message)
+}
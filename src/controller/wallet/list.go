@@ -4,6 +4,7 @@ package wallet
 import (
 	"errors"
 	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/currency"
 	"github.com/axetroy/go-server/src/exception"
 	"github.com/axetroy/go-server/src/middleware"
 	"github.com/axetroy/go-server/src/model"
@@ -63,15 +64,30 @@ func GetWallets(context controller.Context) (res schema.Response) {
 		return
 	}
 
-	sql := GenerateWalletSQL(QueryParams{
-		Id: &userInfo.Id,
-	}, 100, false)
+	currencies, err := currency.GetEnabled(tx)
 
-	if err = tx.Raw(sql).Scan(&list).Error; err != nil {
+	if err != nil {
 		return
 	}
 
+	if err = tx.Where("id = ?", userInfo.Id).Find(&list).Error; err != nil {
+		return
+	}
+
+	wallets := map[string]model.Wallet{}
+
 	for _, v := range list {
+		wallets[v.Currency] = v
+	}
+
+	// 只返回已启用的币种, 还没有创建的钱包返回空钱包
+	for _, c := range currencies {
+		v, ok := wallets[c.Code]
+
+		if !ok {
+			v = emptyWallet(userInfo, c.Code)
+		}
+
 		wallet := schema.Wallet{}
 		mapToSchema(v, &wallet)
 		data = append(data, wallet)
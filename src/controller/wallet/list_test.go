@@ -8,6 +8,7 @@ import (
 	"github.com/axetroy/go-server/src/controller/wallet"
 	"github.com/axetroy/go-server/src/model"
 	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/database"
 	"github.com/axetroy/go-server/src/service/token"
 	"github.com/axetroy/go-server/tester"
 	"github.com/axetroy/mocker"
@@ -25,7 +26,12 @@ func TestGetWallets(t *testing.T) {
 	assert.Equal(t, schema.StatusSuccess, r.Status)
 	assert.Equal(t, "", r.Message)
 
-	assert.Len(t, r.Data, len(model.WalletTableNames))
+	var count int
+
+	assert.Nil(t, database.Db.Model(&model.Currency{}).Where("enabled = ?", true).Count(&count).Error)
+
+	// 每个已启用的币种都有一个钱包
+	assert.Len(t, r.Data, count)
 
 	list := make([]schema.Wallet, 0)
 	assert.Nil(t, tester.Decode(r.Data, &list))
@@ -0,0 +1,104 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package wallet
+
+import (
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 获取钱包加锁的顺序
+// 所有需要同时锁定多个钱包的地方都必须按照这个顺序加锁, 否则并发时可能会出现死锁
+func LockOrder(uid ...string) []string {
+	list := make([]string, 0, len(uid))
+	exist := map[string]bool{}
+
+	for _, id := range uid {
+		if exist[id] {
+			continue
+		}
+		exist[id] = true
+		list = append(list, id)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
+
+// 在事务中以 SELECT ... FOR UPDATE 锁定指定用户的钱包, 直到事务结束才会释放
+// 用户还没有该币种的钱包时会先创建一个空钱包
+// 返回以用户 ID 为 key 的钱包 Map
+func Lock(tx *gorm.DB, currency string, uid ...string) (wallets map[string]*model.Wallet, err error) {
+	wallets = map[string]*model.Wallet{}
+
+	currency = strings.ToUpper(currency)
+
+	for _, id := range LockOrder(uid...) {
+		w := model.Wallet{}
+
+		now := time.Now()
+
+		if err = tx.Exec(`INSERT INTO "wallet" ("id", "currency", "balance", "frozen", "created_at", "updated_at") VALUES (?, ?, 0, 0, ?, ?) ON CONFLICT DO NOTHING`, id, currency, now, now).Error; err != nil {
+			return
+		}
+
+		if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ? AND currency = ?", id, currency).First(&w).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				err = exception.InvalidWallet
+			}
+			return
+		}
+
+		wallets[id] = &w
+	}
+
+	return
+}
+
+// 保存已锁定的钱包的余额和冻结余额
+func Update(tx *gorm.DB, currency string, w *model.Wallet) (err error) {
+	return tx.Model(&model.Wallet{}).Where("id = ? AND currency = ?", w.Id, strings.ToUpper(currency)).Updates(map[string]interface{}{
+		"balance": w.Balance,
+		"frozen":  w.Frozen,
+	}).Error
+}
+
+// 需要锁定的钱包
+type LockKey struct {
+	Currency string
+	Uid      string
+}
+
+// 同时锁定多个币种的钱包, 先按币种再按用户ID的顺序加锁
+// 一个事务需要锁定多个币种的钱包时, 必须在一开始通过这里一起加锁
+// 返回以币种和用户 ID 为 key 的钱包 Map
+func LockAll(tx *gorm.DB, keys ...LockKey) (wallets map[string]map[string]*model.Wallet, err error) {
+	wallets = map[string]map[string]*model.Wallet{}
+
+	uids := map[string][]string{}
+	currencies := make([]string, 0)
+
+	for _, key := range keys {
+		currency := strings.ToUpper(key.Currency)
+
+		if _, ok := uids[currency]; !ok {
+			currencies = append(currencies, currency)
+		}
+
+		uids[currency] = append(uids[currency], key.Uid)
+	}
+
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		if wallets[currency], err = Lock(tx, currency, uids[currency]...); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -4,6 +4,7 @@ package wallet
 import (
 	"errors"
 	"github.com/axetroy/go-server/src/controller"
+	"github.com/axetroy/go-server/src/controller/currency"
 	"github.com/axetroy/go-server/src/exception"
 	"github.com/axetroy/go-server/src/middleware"
 	"github.com/axetroy/go-server/src/model"
@@ -12,19 +13,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"net/http"
-	"strings"
 )
 
-func IsValidWallet(walletName string) bool {
-	for _, validWallet := range model.Wallets {
-		// 有效币种验证忽略大小写
-		if validWallet == strings.ToUpper(walletName) {
-			return true
-		}
-	}
-	return false
-}
-
 func GetWallet(context controller.Context, currencyName string) (res schema.Response) {
 	var (
 		err  error
@@ -73,18 +63,22 @@ func GetWallet(context controller.Context, currencyName string) (res schema.Resp
 		return
 	}
 
-	walletInfo := model.Wallet{
-		Id: userInfo.Id,
-	}
+	// 检查是否是有效的币种
+	c, err := currency.Get(tx, currencyName)
 
-	// 检查是否是有效的钱包
-	if IsValidWallet(strings.ToUpper(currencyName)) == false {
-		err = exception.InvalidWallet
+	if err != nil {
 		return
 	}
 
-	if err = tx.Table("wallet_"+strings.ToLower(currencyName)).Where("id = ?", context.Uid).Scan(&walletInfo).Error; err != nil {
-		return
+	walletInfo := model.Wallet{}
+
+	if err = tx.Where("id = ? AND currency = ?", userInfo.Id, c.Code).First(&walletInfo).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+		// 之后新增的币种还没有创建钱包, 返回一个空钱包
+		err = nil
+		walletInfo = emptyWallet(userInfo, c.Code)
 	}
 
 	mapToSchema(walletInfo, &data)
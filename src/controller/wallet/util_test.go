@@ -3,13 +3,13 @@ package wallet_test
 
 import (
 	"github.com/axetroy/go-server/src/controller/wallet"
-	"github.com/axetroy/go-server/src/model"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
-func TestGetTableName(t *testing.T) {
-	assert.Equal(t, "wallet_cny", wallet.GetTableName(model.WalletCNY))
-	assert.Equal(t, "wallet_usd", wallet.GetTableName(model.WalletUSD))
-	assert.Equal(t, "wallet_coin", wallet.GetTableName(model.WalletCOIN))
+func TestLockOrder(t *testing.T) {
+	assert.Equal(t, []string{"1", "2"}, wallet.LockOrder("2", "1"))
+	assert.Equal(t, []string{"1", "2"}, wallet.LockOrder("1", "2"))
+	assert.Equal(t, []string{"1"}, wallet.LockOrder("1", "1"))
+	assert.Equal(t, []string{}, wallet.LockOrder())
 }
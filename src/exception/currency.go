@@ -0,0 +1,14 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	CurrencyNotExist        = New("币种不存在")
+	CurrencyExist           = New("币种已存在")
+	CurrencyDisabled        = New("该币种已停用")
+	CurrencyNotTransferable = New("该币种不支持转账")
+	InvalidCurrencyCode     = New("币种代码只能是大写字母或数字")
+	InvalidCurrencyScale    = New("币种精度必须在 0 到 18 之间")
+	InvalidCurrencyLimit    = New("最小转账数量不能大于最大转账数量")
+	AmountTooSmall          = New("数量低于该币种的最小限额")
+	AmountTooLarge          = New("数量超过了该币种的最大限额")
+)
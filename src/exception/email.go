@@ -0,0 +1,9 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	EmailTemplateNotExist = New("邮件模版不存在")
+	EmailCatcherDisabled  = New("只有开发环境中使用 file/memory 发送方式时才能查看已发送的邮件")
+	EmailLogNotExist      = New("邮件发送记录不存在")
+	EmailLogNotFailed     = New("只能重新发送失败的邮件")
+)
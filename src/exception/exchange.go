@@ -0,0 +1,13 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	ExchangeSameCurrency    = New("不能兑换成相同的币种")
+	ExchangeRateNotExist    = New("没有该币种的汇率")
+	ExchangeRateUnavailable = New("获取汇率失败, 请稍后再试")
+	InvalidExchangeRate     = New("汇率必须是大于 0 的数字")
+	ExchangeAmountTooSmall  = New("兑换数量太少")
+	ExchangeQuoteNotExist   = New("报价不存在")
+	ExchangeQuoteExpired    = New("报价已过期, 请重新获取报价")
+	ExchangeQuoteExecuted   = New("该报价已兑换")
+)
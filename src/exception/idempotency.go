@@ -0,0 +1,8 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	IdempotencyKeyInvalid    = New("无效的幂等键")
+	IdempotencyKeyReused     = New("该幂等键已用于另一个不同的请求")
+	IdempotencyKeyProcessing = New("相同幂等键的请求正在处理中, 请稍后重试")
+)
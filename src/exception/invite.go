@@ -2,5 +2,8 @@
 package exception
 
 var (
-	InviteNotExist = New("邀请记录不存在")
+	InviteNotExist           = New("邀请记录不存在")
+	InviteRewardRuleNotExist = New("邀请奖励规则不存在")
+	InvalidInviteRewardRule  = New("无效的邀请奖励规则")
+	InvalidInviteMilestone   = New("无效的邀请里程碑")
 )
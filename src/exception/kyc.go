@@ -0,0 +1,14 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	KycNotExist         = New("实名认证申请不存在")
+	KycPending          = New("已有实名认证申请正在审核")
+	KycNotPending       = New("实名认证申请已经审核过了")
+	KycLevelReached     = New("已经完成了该等级的实名认证")
+	InvalidKycLevel     = New("无效的实名认证等级")
+	KycImageMissing     = New("请上传完整的证件图片")
+	KycDocumentNotExist = New("证件图片不存在")
+	IdNumberUsed        = New("该证件已被其他账号认证")
+	KycRequired         = New("转账数量超过限制, 请先完成实名认证")
+)
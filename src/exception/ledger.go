@@ -0,0 +1,8 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	LedgerUnbalanced             = New("记账凭证借贷不平衡")
+	LedgerInvalidAmount          = New("记账分录的数量必须大于 0")
+	LedgerReconciliationNotExist = New("还没有对账报告")
+)
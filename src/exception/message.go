@@ -2,5 +2,9 @@
 package exception
 
 var (
-	MessageNotExist = New("用户消息不存在")
+	MessageNotExist         = New("用户消息不存在")
+	MessageTemplateNotExist = New("消息模版不存在")
+	MessageTemplateExist    = New("这个事件已经有相同语言的模版")
+	InvalidMessageTemplate  = New("无效的消息模版")
+	InvalidMessageVariable  = New("消息模版使用了这个事件不提供的变量")
 )
@@ -2,5 +2,7 @@
 package exception
 
 var (
-	NotificationNotExist = New("系统通知不存在")
+	NotificationNotExist      = New("系统通知不存在")
+	InvalidNotificationTarget = New("无效的通知对象")
+	InvalidNotificationWindow = New("通知的过期时间必须在发布时间之后")
 )
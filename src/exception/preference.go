@@ -0,0 +1,10 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	InvalidQuietHours          = New("无效的免打扰时间")
+	InvalidTimezone            = New("无效的时区")
+	InvalidNotificationEvent   = New("无效的通知事件")
+	InvalidNotificationChannel = New("无效的通知渠道")
+	InvalidUnsubscribeToken    = New("无效的退订链接")
+)
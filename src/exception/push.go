@@ -0,0 +1,6 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	InvalidOrigin = New("不允许从该来源建立推送连接")
+)
@@ -0,0 +1,6 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	TooManyRequests = New("请求太频繁, 请稍后再试")
+)
@@ -0,0 +1,9 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	StatementNotExist      = New("对账单不存在")
+	StatementNotReady      = New("对账单还没有生成")
+	InvalidStatementRange  = New("对账单的日期范围不正确")
+	StatementRangeTooLarge = New("对账单的日期范围太大")
+)
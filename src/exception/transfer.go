@@ -0,0 +1,20 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package exception
+
+var (
+	TransferNotExist          = New("转账记录不存在")
+	TransferNotWaitForConfirm = New("该转账不是待确认的状态")
+	TransferExpired           = New("该转账已过期")
+	TransferNotHold           = New("该转账不是等待风控审核的状态")
+	TransferRuleNotExist      = New("转账规则不存在")
+	InvalidTransferRule       = New("无效的转账规则")
+	InvalidRecurrence         = New("无效的重复规则")
+	InvalidScheduleTime       = New("执行时间必须晚于当前时间")
+	TransferScheduleNotExist  = New("定时转账不存在")
+	TransferScheduleInvalid   = New("定时转账当前的状态不能进行该操作")
+	TransferToSelf            = New("不能转账给自己")
+	RecipientAmbiguous        = New("匹配到多个收款方, 请使用用户ID转账")
+	TransferNotReversible     = New("只有已完成的转账才能撤回")
+	TransferNothingToReverse  = New("收款方余额不足, 没有可以撤回的数量")
+	InvalidReversalPolicy     = New("无效的撤回策略")
+)
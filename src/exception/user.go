@@ -6,4 +6,5 @@ var (
 	InvalidConfirmPassword = New("两次密码不一致")
 	InvalidResetCode       = New("重置码错误或已失效")
 	RequirePayPasswordSet  = New("需要先设置交易密码")
+	InvalidUsername        = New("用户名不能是邮箱, 手机号或者用户ID的格式")
 )
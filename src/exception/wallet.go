@@ -3,6 +3,15 @@ package exception
 
 var (
 	// wallet
-	NotEnoughBalance = New("钱包余额不足")
-	InvalidWallet    = New("无效的钱包")
+	NotEnoughBalance       = New("钱包余额不足")
+	NotEnoughFrozen        = New("钱包冻结余额不足")
+	InvalidWallet          = New("无效的钱包")
+	InvalidAmount          = New("金额必须是大于 0 的数字")
+	InvalidAmountPrecision = New("金额的小数位数超出了该币种的精度")
+	// 钱包调整
+	InvalidWalletAdjustmentType  = New("无效的调整类型")
+	WalletAdjustmentNotExist     = New("钱包调整记录不存在")
+	WalletAdjustmentNotPending   = New("该调整不是待审核的状态")
+	WalletAdjustmentSelfApproved = New("不能审核自己发起的调整")
+	FrozenByTransfer             = New("解冻的数量超过了可以解冻的冻结余额, 部分冻结余额属于等待确认或者审核的转账")
 )
@@ -0,0 +1,240 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message_queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/axetroy/go-server/src/service/redis"
+	"github.com/jinzhu/gorm"
+	"github.com/nsqio/go-nsq"
+	"time"
+)
+
+// 重试的最长延迟, 受限于 nsqd 的 --max-req-timeout
+const maxRetryDelay = time.Hour
+
+// 验证码的有效期, 这些模版的邮件在发送时生成验证码
+var codeTTL = map[string]time.Duration{
+	email.TemplateActivation:       time.Minute * 30,
+	email.TemplateResetPassword:    time.Minute * 30,
+	email.TemplateResetPayPassword: time.Minute * 10,
+}
+
+// 在事务中记录要发送的邮件, 调用方需要在事务提交之后调用 PublishEmail
+// locale 为空时使用用户设置的语言, 需要验证码的模版不需要传入 data
+func CreateEmail(tx *gorm.DB, uid string, to string, locale string, template string, data map[string]interface{}) (log model.EmailLog, err error) {
+	var payload []byte
+
+	log = model.EmailLog{
+		Uid:      uid,
+		To:       to,
+		Template: template,
+		Locale:   locale,
+		Status:   model.EmailLogStatusPending,
+	}
+
+	if data != nil {
+		if payload, err = json.Marshal(data); err != nil {
+			return
+		}
+
+		d := string(payload)
+		log.Data = &d
+	}
+
+	if err = tx.Create(&log).Error; err != nil {
+		return
+	}
+
+	if payload, err = json.Marshal(SendEmailBody{LogId: log.Id, Uid: uid}); err != nil {
+		return
+	}
+
+	log.Payload = string(payload)
+
+	err = tx.Model(&log).UpdateColumn("payload", log.Payload).Error
+
+	return
+}
+
+// 在事务中记录要发送的激活邮件
+func CreateActivationEmail(tx *gorm.DB, uid string, to string) (model.EmailLog, error) {
+	return CreateEmail(tx, uid, to, "", email.TemplateActivation, nil)
+}
+
+// 把已经记录的邮件加入消息队列, 失败时标记为发送失败, 由管理员重新发送
+func PublishEmail(log model.EmailLog) (err error) {
+	if err = Publish(TopicSendEmail, []byte(log.Payload)); err != nil {
+		_ = database.Db.Model(&log).Updates(map[string]interface{}{
+			"status": model.EmailLogStatusFailed,
+			"error":  err.Error(),
+		}).Error
+	}
+
+	return
+}
+
+// 按照记录发送邮件, 需要验证码的模版生成新的验证码, 发送失败时删除这个验证码
+func sendEmail(log model.EmailLog) (err error) {
+	var code string
+
+	userInfo := model.User{}
+
+	if err = database.Db.Where("id = ?", log.Uid).First(&userInfo).Error; err != nil {
+		return
+	}
+
+	locale := log.Locale
+
+	if locale == "" && userInfo.Locale != nil {
+		locale = *userInfo.Locale
+	}
+
+	mailer := email.NewMailer()
+
+	ttl, ok := codeTTL[log.Template]
+
+	// 其他模版的数据在记录时已经确定
+	if !ok {
+		data := map[string]interface{}{}
+
+		if log.Data != nil {
+			if err = json.Unmarshal([]byte(*log.Data), &data); err != nil {
+				return
+			}
+		}
+
+		return mailer.SendTemplate(log.To, locale, log.Template, data)
+	}
+
+	client := redis.ResetCodeClient
+
+	if log.Template == email.TemplateActivation {
+		client = redis.ActivationCodeClient
+		code, err = email.NewActivationCode()
+	} else {
+		code, err = email.NewResetCode()
+	}
+
+	if err != nil {
+		return
+	}
+
+	if err = client.Set(code, userInfo.Id, ttl).Err(); err != nil {
+		return
+	}
+
+	switch log.Template {
+	case email.TemplateActivation:
+		err = mailer.SendActivationEmail(log.To, code, locale)
+	case email.TemplateResetPassword:
+		err = mailer.SendForgotPasswordEmail(log.To, code, locale)
+	default:
+		err = mailer.SendForgotTradePasswordEmail(log.To, code, locale)
+	}
+
+	if err != nil {
+		_ = client.Del(code).Err()
+	}
+
+	return
+}
+
+// 第几次重试的延迟, 每次翻倍
+func retryDelay(attempts int) time.Duration {
+	delay := config.Email.RetryDelay
+
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay = delay * 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay
+}
+
+// 最多尝试发送的次数, 不能超过消息队列的最大重试次数, 否则消息会在放入死信队列之前被消息队列丢弃
+func maxAttempts() int {
+	n := config.Email.MaxAttempts
+
+	if Config.MaxAttempts > 0 && n > int(Config.MaxAttempts) {
+		n = int(Config.MaxAttempts)
+	}
+
+	return n
+}
+
+// 消费发送邮件的消息, 发送失败时延迟重新入队, 超过最大次数后放入死信队列
+func handleSendEmail(message *nsq.Message) error {
+	body := SendEmailBody{}
+
+	if err := json.Unmarshal(message.Body, &body); err != nil {
+		// 无法解析的消息重试也没有用
+		fmt.Printf("无法解析的邮件: %s\n", string(message.Body))
+		return nil
+	}
+
+	log := model.EmailLog{}
+
+	if err := database.Db.Where("id = ?", body.LogId).First(&log).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// 没有记录就不知道发送给谁
+			fmt.Printf("邮件记录 %s 不存在\n", body.LogId)
+			return nil
+		}
+		return err
+	}
+
+	if log.Status == model.EmailLogStatusSent {
+		// 重复投递的消息
+		return nil
+	}
+
+	attempts := int(message.Attempts)
+
+	err := sendEmail(log)
+
+	updates := map[string]interface{}{
+		"attempts": attempts,
+	}
+
+	if err == nil {
+		now := time.Now()
+		updates["status"] = model.EmailLogStatusSent
+		updates["error"] = nil
+		updates["sent_at"] = &now
+	} else {
+		updates["error"] = err.Error()
+
+		if attempts >= maxAttempts() {
+			updates["status"] = model.EmailLogStatusFailed
+		}
+	}
+
+	if err := database.Db.Model(&log).Updates(updates).Error; err != nil {
+		fmt.Printf("更新邮件记录 %s 失败: %s\n", log.Id, err.Error())
+	}
+
+	if err == nil {
+		fmt.Printf("发送邮件 %s 到 %s\n", log.Template, log.To)
+		return nil
+	}
+
+	fmt.Printf("发送邮件到 %s 失败, 第 %d 次: %s\n", log.To, attempts, err.Error())
+
+	if attempts >= maxAttempts() {
+		// 放入死信队列, 由管理员检查之后重新发送
+		return Publish(TopicSendEmailDead, message.Body)
+	}
+
+	message.DisableAutoResponse()
+	message.Requeue(retryDelay(attempts))
+
+	return nil
+}
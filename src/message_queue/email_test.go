@@ -0,0 +1,255 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package message_queue
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/service/database"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/axetroy/go-server/src/service/redis"
+	"github.com/axetroy/go-server/src/util"
+	mail "github.com/jordan-wright/email"
+	"github.com/lib/pq"
+	"github.com/nsqio/go-nsq"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// 总是发送失败的发送方式
+type failingTransport struct{}
+
+func (t failingTransport) Send(msg *mail.Email) error {
+	return errors.New("dial tcp: i/o timeout")
+}
+
+// 记录消息是否被重新入队
+type delegate struct {
+	requeued bool
+	delay    time.Duration
+}
+
+func (d *delegate) OnFinish(m *nsq.Message) {}
+
+func (d *delegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	d.requeued = true
+	d.delay = delay
+}
+
+func (d *delegate) OnTouch(m *nsq.Message) {}
+
+func createUser(t *testing.T) model.User {
+	userInfo := model.User{
+		Username:   "test-" + util.RandomString(6),
+		Password:   util.GeneratePassword("123123"),
+		Status:     model.UserStatusInactivated,
+		Role:       pq.StringArray{model.DefaultUser.Name},
+		InviteCode: util.GenerateInviteCode(),
+	}
+
+	assert.Nil(t, database.Db.Create(&userInfo).Error)
+
+	return userInfo
+}
+
+func createLog(t *testing.T, uid string) model.EmailLog {
+	log, err := CreateActivationEmail(database.Db, uid, "test@example.com")
+
+	assert.Nil(t, err)
+
+	return log
+}
+
+// 模拟消息队列第 attempts 次投递这条消息
+func consume(t *testing.T, log model.EmailLog, attempts uint16) (*delegate, model.EmailLog) {
+	d := &delegate{}
+
+	message := nsq.NewMessage(nsq.MessageID{}, []byte(log.Payload))
+	message.Attempts = attempts
+	message.Delegate = d
+
+	assert.Nil(t, handleSendEmail(message))
+
+	result := model.EmailLog{}
+
+	assert.Nil(t, database.Db.Where("id = ?", log.Id).First(&result).Error)
+
+	return d, result
+}
+
+func TestCreateActivationEmail(t *testing.T) {
+	userInfo := createUser(t)
+
+	defer database.DeleteRowByTable("user", "id", userInfo.Id)
+	defer database.DeleteRowByTable("email_log", "uid", userInfo.Id)
+
+	log := createLog(t, userInfo.Id)
+
+	// 消息中只有记录ID和用户ID, 没有激活码
+	assert.JSONEq(t, `{"log_id":"`+log.Id+`","uid":"`+userInfo.Id+`"}`, log.Payload)
+	assert.Equal(t, model.EmailLogStatusPending, log.Status)
+}
+
+func TestHandleSendEmail(t *testing.T) {
+	transport := email.CurrentTransport()
+
+	defer email.Use(transport)
+
+	userInfo := createUser(t)
+
+	defer database.DeleteRowByTable("user", "id", userInfo.Id)
+	defer database.DeleteRowByTable("email_log", "uid", userInfo.Id)
+
+	memory := email.NewMemoryTransport()
+
+	email.Use(memory)
+
+	log := createLog(t, userInfo.Id)
+
+	d, result := consume(t, log, 1)
+
+	assert.False(t, d.requeued)
+	assert.Equal(t, model.EmailLogStatusSent, result.Status)
+	assert.Equal(t, 1, result.Attempts)
+	assert.NotNil(t, result.SentAt)
+
+	list, _ := memory.Captured(0)
+
+	if !assert.Len(t, list, 1) {
+		return
+	}
+
+	// 邮件中的激活码可以激活这个用户
+	code := regexp.MustCompile(`activation-[0-9a-f]{32}`).FindString(list[0].Text)
+
+	defer redis.ActivationCodeClient.Del(code)
+
+	uid, err := redis.ActivationCodeClient.Get(code).Result()
+
+	assert.Nil(t, err)
+	assert.Equal(t, userInfo.Id, uid)
+
+	// 重复投递的消息不会再次发送
+	consume(t, log, 2)
+
+	list, _ = memory.Captured(0)
+
+	assert.Len(t, list, 1)
+
+	// 每次发送都生成新的激活码
+	another := createLog(t, userInfo.Id)
+
+	consume(t, another, 1)
+
+	list, _ = memory.Captured(0)
+
+	if assert.Len(t, list, 2) {
+		next := regexp.MustCompile(`activation-[0-9a-f]{32}`).FindString(list[0].Text)
+
+		defer redis.ActivationCodeClient.Del(next)
+
+		assert.NotEqual(t, code, next)
+	}
+}
+
+// 重置密码的邮件在发送时生成重置码, 其他模版使用记录中的数据
+func TestHandleSendEmailTemplates(t *testing.T) {
+	transport := email.CurrentTransport()
+
+	defer email.Use(transport)
+
+	userInfo := createUser(t)
+
+	defer database.DeleteRowByTable("user", "id", userInfo.Id)
+	defer database.DeleteRowByTable("email_log", "uid", userInfo.Id)
+
+	memory := email.NewMemoryTransport()
+
+	email.Use(memory)
+
+	reset, err := CreateEmail(database.Db, userInfo.Id, "test@example.com", "", email.TemplateResetPassword, nil)
+
+	assert.Nil(t, err)
+	assert.Nil(t, reset.Data)
+
+	_, result := consume(t, reset, 1)
+
+	assert.Equal(t, model.EmailLogStatusSent, result.Status)
+
+	notice, err := CreateEmail(database.Db, userInfo.Id, "test@example.com", "zh-CN", email.TemplateNotice, map[string]interface{}{
+		"Title":          "通知标题",
+		"Content":        "通知内容",
+		"UnsubscribeURL": "https://example.com/unsubscribe",
+	})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, notice.Data)
+
+	_, result = consume(t, notice, 1)
+
+	assert.Equal(t, model.EmailLogStatusSent, result.Status)
+
+	list, _ := memory.Captured(0)
+
+	if !assert.Len(t, list, 2) {
+		return
+	}
+
+	// 最新的邮件在前面
+	assert.Contains(t, list[0].Text, "通知内容")
+
+	code := regexp.MustCompile(`reset-[0-9a-f]{32}`).FindString(list[1].Text)
+
+	defer redis.ResetCodeClient.Del(code)
+
+	uid, err := redis.ResetCodeClient.Get(code).Result()
+
+	assert.Nil(t, err)
+	assert.Equal(t, userInfo.Id, uid)
+}
+
+func TestHandleSendEmailRetry(t *testing.T) {
+	transport := email.CurrentTransport()
+
+	defer email.Use(transport)
+
+	userInfo := createUser(t)
+
+	defer database.DeleteRowByTable("user", "id", userInfo.Id)
+	defer database.DeleteRowByTable("email_log", "uid", userInfo.Id)
+
+	email.Use(failingTransport{})
+
+	log := createLog(t, userInfo.Id)
+
+	// 发送失败时延迟重新入队, 每次延迟翻倍
+	d, result := consume(t, log, 1)
+
+	assert.True(t, d.requeued)
+	assert.Equal(t, config.Email.RetryDelay, d.delay)
+	assert.Equal(t, model.EmailLogStatusPending, result.Status)
+	assert.Equal(t, 1, result.Attempts)
+	assert.Equal(t, "dial tcp: i/o timeout", *result.Error)
+
+	d, result = consume(t, log, 2)
+
+	assert.True(t, d.requeued)
+	assert.Equal(t, config.Email.RetryDelay*2, d.delay)
+	assert.Equal(t, 2, result.Attempts)
+
+	// 超过最大次数之后不再重试, 放入死信队列
+	d, result = consume(t, log, uint16(maxAttempts()))
+
+	assert.False(t, d.requeued)
+	assert.Equal(t, model.EmailLogStatusFailed, result.Status)
+	assert.Equal(t, maxAttempts(), result.Attempts)
+	assert.Nil(t, result.SentAt)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, config.Email.RetryDelay, retryDelay(1))
+	assert.Equal(t, config.Email.RetryDelay*4, retryDelay(3))
+	assert.Equal(t, maxRetryDelay, retryDelay(100))
+}
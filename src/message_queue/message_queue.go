@@ -2,11 +2,7 @@
 package message_queue
 
 import (
-	"encoding/json"
-	"fmt"
 	"github.com/axetroy/go-server/src/config"
-	"github.com/axetroy/go-server/src/service/email"
-	"github.com/axetroy/go-server/src/service/redis"
 	"github.com/nsqio/go-nsq"
 	"sync"
 	"time"
@@ -16,15 +12,22 @@ type Topic string
 type Chanel string
 
 var (
-	TopicSendEmail  Topic       = "send_email"
-	ChanelSendEmail Chanel      = "send_email"
-	Address         string      // 消息队列地址
-	Config          *nsq.Config // 消息队列的配置
+	TopicSendEmail          Topic       = "send_email"
+	ChanelSendEmail         Chanel      = "send_email"
+	TopicSendEmailDead      Topic       = "send_email_dead"    // 超过最大重试次数仍然发送失败的邮件
+	TopicGenerateStatement  Topic       = "generate_statement" // 生成对账单, 消息内容为对账单ID
+	ChanelGenerateStatement Chanel      = "generate_statement"
+	TopicDispatchNotice     Topic       = "dispatch_notice" // 按照用户的通知设置把事件发送到各个渠道
+	ChanelDispatchNotice    Chanel      = "dispatch_notice"
+	Address                 string      // 消息队列地址
+	Config                  *nsq.Config // 消息队列的配置
 )
 
-type SendActivationEmailBody struct {
-	Email string `json:"email"` // 要发送的邮箱
-	Code  string `json:"code"`  // 发送的激活码
+// 消息中只有记录ID和用户ID, 收件人, 模版和数据从记录中读取
+// 激活码和重置码在每次发送时重新生成, 不保存在消息队列和数据库中
+type SendEmailBody struct {
+	LogId string `json:"log_id"` // 邮件发送记录的ID
+	Uid   string `json:"uid"`    // 发送给哪个用户
 }
 
 func init() {
@@ -50,26 +53,7 @@ func RunMessageQueueConsumer() {
 
 	wg.Add(1)
 
-	_, err = CreateConsumer(TopicSendEmail, ChanelSendEmail, nsq.HandlerFunc(func(message *nsq.Message) error {
-
-		body := SendActivationEmailBody{}
-
-		if err := json.Unmarshal(message.Body, &body); err != nil {
-			return err
-		}
-
-		mailer := email.NewMailer()
-
-		// 发送邮件
-		if err := mailer.SendActivationEmail(body.Email, body.Code); err != nil {
-			// 邮件没发出去的话，删除 redis 的 key
-			_ = redis.ActivationCodeClient.Del(body.Code).Err()
-		}
-
-		fmt.Printf("发送验证码 %s 到 %s\n", body.Code, body.Email)
-
-		return nil
-	}))
+	_, err = CreateConsumer(TopicSendEmail, ChanelSendEmail, nsq.HandlerFunc(handleSendEmail))
 
 	if err != nil {
 		panic(err)
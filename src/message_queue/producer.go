@@ -4,6 +4,7 @@ package message_queue
 import (
 	"errors"
 	"github.com/nsqio/go-nsq"
+	"time"
 )
 
 var (
@@ -26,22 +27,48 @@ func createProducer() (err error) {
 
 // 发布消息
 func Publish(topic Topic, message []byte) (err error) {
+	return DeferredPublish(topic, 0, message)
+}
+
+// 确保链接可用
+func ping() error {
 	var (
 		maxConnectTimes = 5
 		connectTimes    = 0
 	)
 
-	// 确保链接可用
 	for {
 		if producer.Ping() == nil {
-			break
+			return nil
 		}
 		if connectTimes >= maxConnectTimes {
-			err = errors.New("publish timeout")
-			return
+			return errors.New("publish timeout")
 		}
 		connectTimes = connectTimes + 1
 	}
+}
+
+// 一次发布多条消息, 要么全部发布成功, 要么全部失败
+func MultiPublish(topic Topic, messages [][]byte) (err error) {
+	if err = ping(); err != nil {
+		return
+	}
+
+	for _, message := range messages {
+		if len(message) == 0 {
+			err = errors.New("message can not be empty")
+			return
+		}
+	}
+
+	return producer.MultiPublish(string(topic), messages)
+}
+
+// 发布延迟投递的消息, 延迟不能超过 nsqd 的 --max-req-timeout, 默认为 1 小时
+func DeferredPublish(topic Topic, delay time.Duration, message []byte) (err error) {
+	if err = ping(); err != nil {
+		return
+	}
 
 	//不能发布空串，否则会导致 error
 	if len(message) == 0 {
@@ -49,7 +76,13 @@ func Publish(topic Topic, message []byte) (err error) {
 		return
 	}
 
-	if err = producer.Publish(string(topic), message); err != nil {
+	if delay > 0 {
+		err = producer.DeferredPublish(string(topic), delay, message)
+	} else {
+		err = producer.Publish(string(topic), message)
+	}
+
+	if err != nil {
 		return
 	}
 
@@ -30,9 +30,9 @@ func Authenticate(isAdmin bool) gin.HandlerFunc {
 			}
 		}()
 
+		// 查询参数中的 Token 同样需要校验, WebSocket 和 SSE 无法设置请求头时使用
 		if s, isExist := context.GetQuery(token.AuthField); isExist == true {
 			tokenString = s
-			return
 		} else {
 			tokenString = context.GetHeader(token.AuthField)
 
@@ -0,0 +1,193 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/redis"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+)
+
+var (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength   = 255
+)
+
+// 幂等键对应的记录
+type idempotencyRecord struct {
+	Hash     string `json:"hash"`     // 请求的指纹, 由请求方法, 路径和 body 生成
+	Finished bool   `json:"finished"` // 请求是否已经处理完成
+	Status   int    `json:"status"`   // 响应的 HTTP 状态码
+	Body     string `json:"body"`     // 响应的 body
+}
+
+// 记录响应内容的 ResponseWriter
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// 幂等键的中间件, 必须安排在JWT的中间件后面
+// 客户端在请求头中携带 Idempotency-Key, 则同一个用户使用相同的键重复请求时, 直接返回第一次请求的响应
+// 相同的键但是请求体不同, 则拒绝请求
+func Idempotency(context *gin.Context) {
+	var (
+		err      error
+		redisKey string
+		finished bool
+	)
+
+	defer func() {
+		// 占用了幂等键但是没有正常处理完成 (例如 panic), 则释放这个键，允许客户端重试
+		if redisKey != "" && !finished {
+			_ = redis.IdempotencyClient.Del(redisKey).Err()
+		}
+
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if err != nil {
+			context.JSON(http.StatusOK, schema.Response{
+				Status:  schema.StatusFail,
+				Message: err.Error(),
+				Data:    nil,
+			})
+
+			context.Abort()
+		}
+	}()
+
+	// 只有写操作才需要幂等
+	switch context.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+
+	key := context.GetHeader(IdempotencyKeyHeader)
+
+	// 没有携带幂等键的请求不做处理
+	if len(key) == 0 {
+		return
+	}
+
+	if len(key) > idempotencyKeyMaxLength {
+		err = exception.IdempotencyKeyInvalid
+		return
+	}
+
+	uid := context.GetString(ContextUidField)
+
+	if uid == "" {
+		err = exception.UserNotLogin
+		return
+	}
+
+	var body []byte
+
+	if context.Request.Body != nil {
+		if body, err = ioutil.ReadAll(context.Request.Body); err != nil {
+			return
+		}
+	}
+
+	// 重新把 body 放回去，供后面的路由读取
+	context.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	key = "idempotency-" + uid + "-" + key
+	hash := util.MD5(context.Request.Method + " " + context.Request.URL.Path + " " + string(body))
+
+	var raw []byte
+
+	if raw, err = json.Marshal(idempotencyRecord{Hash: hash}); err != nil {
+		return
+	}
+
+	var isFirst bool
+
+	// 原子地占用这个键，只有第一个请求能占用成功
+	if isFirst, err = redis.IdempotencyClient.SetNX(key, raw, config.User.IdempotencyKeyTTL).Result(); err != nil {
+		return
+	}
+
+	if !isFirst {
+		var value string
+
+		if value, err = redis.IdempotencyClient.Get(key).Result(); err != nil {
+			return
+		}
+
+		record := idempotencyRecord{}
+
+		if err = json.Unmarshal([]byte(value), &record); err != nil {
+			return
+		}
+
+		if record.Hash != hash {
+			err = exception.IdempotencyKeyReused
+			return
+		}
+
+		if !record.Finished {
+			err = exception.IdempotencyKeyProcessing
+			return
+		}
+
+		// 重放第一次请求的响应
+		context.Header(IdempotencyReplayedHeader, "true")
+		context.Data(record.Status, "application/json; charset=utf-8", []byte(record.Body))
+		context.Abort()
+		return
+	}
+
+	redisKey = key
+
+	writer := &idempotencyWriter{
+		ResponseWriter: context.Writer,
+		body:           &bytes.Buffer{},
+	}
+
+	context.Writer = writer
+
+	context.Next()
+
+	// 服务器错误的响应不保存, 允许客户端重试
+	if writer.Status() >= http.StatusInternalServerError {
+		return
+	}
+
+	// 响应已经发出, 这里的错误不能再返回给客户端, 保存失败则释放这个键
+	if b, er := json.Marshal(idempotencyRecord{
+		Hash:     hash,
+		Finished: true,
+		Status:   writer.Status(),
+		Body:     writer.body.String(),
+	}); er == nil {
+		finished = redis.IdempotencyClient.Set(redisKey, b, config.User.IdempotencyKeyTTL).Err() == nil
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package middleware
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/redis"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 限制访问频率的中间件, 同一个用户在 window 时间内最多请求 limit 次, 没有登陆的按 IP 计算
+// 使用固定的时间窗口计数, 超过之后在响应头 Retry-After 中返回需要等待的秒数
+func RateLimit(name string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var (
+			err error
+		)
+
+		defer func() {
+			if r := recover(); r != nil {
+				switch t := r.(type) {
+				case string:
+					err = errors.New(t)
+				case error:
+					err = t
+				default:
+					err = exception.Unknown
+				}
+			}
+
+			if err != nil {
+				context.JSON(http.StatusOK, schema.Response{
+					Status:  schema.StatusFail,
+					Message: err.Error(),
+					Data:    nil,
+				})
+
+				context.Abort()
+			}
+		}()
+
+		if limit <= 0 {
+			return
+		}
+
+		who := context.GetString(ContextUidField)
+
+		if who == "" {
+			who = context.ClientIP()
+		}
+
+		key := "rate-limit-" + name + "-" + who
+
+		var count int64
+
+		if count, err = redis.RateLimitClient.Incr(key).Result(); err != nil {
+			return
+		}
+
+		// 第一次请求时开始计时
+		if count == 1 {
+			if err = redis.RateLimitClient.Expire(key, window).Err(); err != nil {
+				return
+			}
+		}
+
+		if count > int64(limit) {
+			ttl, er := redis.RateLimitClient.TTL(key).Result()
+
+			if er == nil && ttl > 0 {
+				context.Header("Retry-After", strconv.Itoa(int(ttl.Seconds()+0.5)))
+			} else if er == nil {
+				// 设置过期时间失败的计数, 重新开始计时, 避免一直被限制
+				_ = redis.RateLimitClient.Expire(key, window).Err()
+			}
+
+			err = exception.TooManyRequests
+			return
+		}
+	}
+}
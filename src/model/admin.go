@@ -2,6 +2,7 @@
 package model
 
 import (
+	"github.com/axetroy/go-server/src/rbac/accession"
 	"github.com/axetroy/go-server/src/util"
 	"github.com/jinzhu/gorm"
 	"github.com/lib/pq"
@@ -36,3 +37,16 @@ func (news *Admin) TableName() string {
 func (news *Admin) BeforeCreate(scope *gorm.Scope) error {
 	return scope.SetColumn("id", util.GenerateId())
 }
+
+// 管理员是否拥有某个权限, 超级管理员拥有所有权限
+func (news *Admin) HasAccession(a accession.Accession) bool {
+	if news.IsSuper {
+		return true
+	}
+	for _, v := range news.Accession {
+		if v == a.Name {
+			return true
+		}
+	}
+	return false
+}
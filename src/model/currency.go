@@ -0,0 +1,33 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// 币种
+// 钱包, 转账记录, 财务日志都是同一张表, 通过币种字段区分. 新增币种只需要在币种表中添加一条记录
+type Currency struct {
+	Code         string           `gorm:"primary_key;unique;not null;index;type:varchar(16)" json:"code"` // 币种代码, 大写, 例如 CNY
+	Name         string           `gorm:"not null;type:varchar(32)" json:"name"`                          // 币种名称
+	Scale        int32            `gorm:"not null" json:"scale"`                                          // 币种的精度, 即允许的小数位数
+	Enabled      bool             `gorm:"not null" json:"enabled"`                                        // 是否启用, 停用的币种不能进行任何操作
+	Transferable bool             `gorm:"not null" json:"transferable"`                                   // 是否允许用户之间转账
+	MinAmount    *decimal.Decimal `gorm:"null;type:numeric" json:"min_amount"`                            // 单笔转账的最小数量, 为空则不限制
+	MaxAmount    *decimal.Decimal `gorm:"null;type:numeric" json:"max_amount"`                            // 单笔转账的最大数量, 为空则不限制
+	KycAmount    *decimal.Decimal `gorm:"null;type:numeric" json:"kyc_amount"`                            // 单笔转账超过这个数量需要实名认证, 为空则不需要
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// 内置的币种, 数据库初始化时如果不存在则会自动创建
+var DefaultCurrencies = []Currency{
+	{Code: WalletCNY, Name: "人民币", Scale: 2, Enabled: true, Transferable: true},
+	{Code: WalletUSD, Name: "美元", Scale: 2, Enabled: true, Transferable: true},
+	{Code: WalletCOIN, Name: "积分", Scale: 8, Enabled: true, Transferable: true},
+}
+
+func (news *Currency) TableName() string {
+	return "currency"
+}
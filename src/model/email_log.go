@@ -0,0 +1,41 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type EmailLogStatus int
+
+const (
+	EmailLogStatusFailed  EmailLogStatus = -1 // 超过最大重试次数, 已经放入死信队列
+	EmailLogStatusPending EmailLogStatus = 0  // 等待发送或者等待重试
+	EmailLogStatusSent    EmailLogStatus = 1  // 已发送
+)
+
+// 通过消息队列发送的邮件记录
+type EmailLog struct {
+	Id        string         `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 记录ID
+	Uid       string         `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 发送给哪个用户
+	To        string         `gorm:"not null;index;type:varchar(255)" json:"to"`                   // 收件人邮箱
+	Template  string         `gorm:"not null;type:varchar(32)" json:"template"`                    // 使用的邮件模版
+	Locale    string         `gorm:"not null;default:'';type:varchar(16)" json:"locale"`           // 邮件使用的语言, 为空时使用用户设置的语言
+	Data      *string        `gorm:"null;type:text" json:"data"`                                   // 模版数据, 激活和重置密码的邮件为空, 验证码在发送时生成
+	Payload   string         `gorm:"not null;type:text" json:"payload"`                            // 队列中的消息内容, 重新发送时使用
+	Status    EmailLogStatus `gorm:"not null;index" json:"status"`                                 // 状态
+	Attempts  int            `gorm:"not null;default:0" json:"attempts"`                           // 已经尝试发送的次数
+	Error     *string        `gorm:"null;type:text" json:"error"`                                  // 最后一次发送失败的原因
+	SentAt    *time.Time     `gorm:"null" json:"sent_at"`                                          // 发送成功的时间
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (news *EmailLog) TableName() string {
+	return "email_log"
+}
+
+func (news *EmailLog) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type ExchangeQuoteStatus int
+
+const (
+	ExchangeQuoteStatusPending  ExchangeQuoteStatus = 0 // 等待用户确认兑换
+	ExchangeQuoteStatusExecuted ExchangeQuoteStatus = 1 // 已兑换
+)
+
+// 管理员维护的汇率表, 1 个 From 可以兑换 Rate 个 To
+type ExchangeRate struct {
+	From      string          `gorm:"primary_key;not null;type:varchar(16)" json:"from"` // 源币种
+	To        string          `gorm:"primary_key;not null;type:varchar(16)" json:"to"`   // 目标币种
+	Rate      decimal.Decimal `gorm:"not null;type:numeric" json:"rate"`                 // 中间价
+	Updater   string          `gorm:"not null;type:varchar(32)" json:"updater"`          // 最后修改的管理员
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// 兑换报价, 在有效期内可以按照报价锁定的汇率兑换
+type ExchangeQuote struct {
+	Id        string              `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 报价ID
+	Uid       string              `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 用户ID
+	From      string              `gorm:"not null;type:varchar(16)" json:"from"`                        // 源币种
+	To        string              `gorm:"not null;type:varchar(16)" json:"to"`                          // 目标币种
+	Amount    decimal.Decimal     `gorm:"not null;type:numeric" json:"amount"`                          // 兑换的源币种数量
+	Rate      decimal.Decimal     `gorm:"not null;type:numeric" json:"rate"`                            // 扣除点差之后的汇率
+	Gross     decimal.Decimal     `gorm:"not null;type:numeric" json:"gross"`                           // 按汇率兑换得到的目标币种数量
+	Fee       decimal.Decimal     `gorm:"not null;type:numeric" json:"fee"`                             // 手续费, 目标币种
+	Receive   decimal.Decimal     `gorm:"not null;type:numeric" json:"receive"`                         // 实际到账的目标币种数量
+	Status    ExchangeQuoteStatus `gorm:"not null;index" json:"status"`                                 // 状态
+	ExpiredAt time.Time           `gorm:"not null" json:"expired_at"`                                   // 报价的过期时间
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (news *ExchangeRate) TableName() string {
+	return "exchange_rate"
+}
+
+func (news *ExchangeQuote) TableName() string {
+	return "exchange_quote"
+}
+
+func (news *ExchangeQuote) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// 每个钱包哈希链的检查点, 记录最后一条流水的序号和哈希
+// 与流水在同一个事务中更新, 截断哈希链末尾的流水时可以通过检查点发现
+type FinanceCheckpoint struct {
+	Uid       string `gorm:"primary_key;not null;type:varchar(32)" json:"uid"`      // 对应的用户
+	Currency  string `gorm:"primary_key;not null;type:varchar(16)" json:"currency"` // 对应的币种
+	Sequence  int64  `gorm:"not null" json:"sequence"`                              // 最后一条流水的序号, 即流水的数量
+	Hash      string `gorm:"not null;type:varchar(64)" json:"hash"`                 // 最后一条流水的哈希
+	Signature string `gorm:"not null;default:'';type:varchar(64)" json:"signature"` // 检查点的签名, 防止截断流水之后把检查点改成之前的流水
+	UpdatedAt time.Time
+}
+
+func (news *FinanceCheckpoint) TableName() string {
+	return "finance_checkpoint"
+}
+
+// 使用流水哈希链的密钥计算检查点的签名
+func (news *FinanceCheckpoint) Sign(secret string) string {
+	content, _ := json.Marshal([]interface{}{news.Uid, news.Currency, news.Sequence, news.Hash})
+
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	_, _ = mac.Write(content)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
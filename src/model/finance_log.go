@@ -2,8 +2,13 @@
 package model
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"github.com/axetroy/go-server/src/util"
 	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
 	"time"
 )
 
@@ -13,67 +18,109 @@ var (
 	FinanceTypeTransferIn  FinanceType = "transfer_in"  // 转入
 	FinanceTypeTransferOut FinanceType = "transfer_out" // 转出
 
-	FinanceLogMap = map[string]interface{}{
-		"cny":  FinanceLogCny{},
-		"usd":  FinanceLogUsd{},
-		"coin": FinanceLogCoin{},
-	}
+	FinanceTypeTransferFrozen FinanceType = "transfer_frozen" // 转出等待对方确认, 余额转入冻结
+	FinanceTypeTransferRefund FinanceType = "transfer_refund" // 对方拒绝或超时未确认, 冻结退回余额
+
+	FinanceTypeReversalOut FinanceType = "reversal_out" // 管理员撤回转账, 从收款方扣回
+	FinanceTypeReversalIn  FinanceType = "reversal_in"  // 管理员撤回转账, 退回给转账方
+
+	FinanceTypeAdminCredit FinanceType = "admin_credit" // 管理员增加余额, 例如人工充值
+	FinanceTypeAdminDebit  FinanceType = "admin_debit"  // 管理员扣除余额
+	FinanceTypeFreeze      FinanceType = "freeze"       // 管理员冻结余额
+	FinanceTypeUnfreeze    FinanceType = "unfreeze"     // 管理员解冻余额
+
+	FinanceTypeOpening FinanceType = "opening" // 启用复式记账之前已有的余额
+
+	FinanceTypeExchangeOut FinanceType = "exchange_out" // 兑换成其他币种, 扣除源币种
+	FinanceTypeExchangeIn  FinanceType = "exchange_in"  // 兑换成其他币种, 得到目标币种
+
+	FinanceTypeInviteReward FinanceType = "invite_reward" // 邀请奖励, 邀请人和被邀请人都有
 )
 
 type FinanceLog struct {
-	Id              string      `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 流水ID
-	Currency        string      `gorm:"not null;index;type:varchar(16)" json:"currency"`              // 对应的币种流水
-	OrderId         string      `gorm:"null;index;type:varchar(32)" json:"order_id"`                  // 对应的订单id, 系统产生的流水可能不会存在orderId
-	Uid             string      `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 对应的用户
-	BeforeBalance   float64     `gorm:"not null;" json:"before_balance"`                              // 这条流水前的余额
-	BalanceMutation float64     `gorm:"not null;" json:"balance_mutation"`                            // 可用余额的变动，正数则为加，负数为减
-	AfterBalance    float64     `gorm:"not null" json:"after_balance"`                                // 这条流水后的余额
-	BeforeFrozen    float64     `gorm:"not null" json:"before_frozen"`                                // 这条流水前的冻结余额
-	FrozenMutation  float64     `gorm:"not null" json:"frozen_mutation"`                              // 冻结余额的变动,正数则为加，负数为减
-	AfterFrozen     float64     `gorm:"not null" json:"after_frozen"`                                 // 这条流水后的冻结余额
-	Type            FinanceType `gorm:"not null" json:"status"`                                       // 流水类型
-	Note            *string     `gorm:"null;type:varchar(128)" json:"note"`                           // 流水备注
+	Id              string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 流水ID
+	Currency        string          `gorm:"not null;index;type:varchar(16)" json:"currency"`              // 对应的币种流水
+	OrderId         string          `gorm:"null;index;type:varchar(32)" json:"order_id"`                  // 对应的订单id, 系统产生的流水可能不会存在orderId
+	Uid             string          `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 对应的用户
+	BeforeBalance   decimal.Decimal `gorm:"not null;type:numeric" json:"before_balance"`                  // 这条流水前的余额
+	BalanceMutation decimal.Decimal `gorm:"not null;type:numeric" json:"balance_mutation"`                // 可用余额的变动，正数则为加，负数为减
+	AfterBalance    decimal.Decimal `gorm:"not null;type:numeric" json:"after_balance"`                   // 这条流水后的余额
+	BeforeFrozen    decimal.Decimal `gorm:"not null;type:numeric" json:"before_frozen"`                   // 这条流水前的冻结余额
+	FrozenMutation  decimal.Decimal `gorm:"not null;type:numeric" json:"frozen_mutation"`                 // 冻结余额的变动,正数则为加，负数为减
+	AfterFrozen     decimal.Decimal `gorm:"not null;type:numeric" json:"after_frozen"`                    // 这条流水后的冻结余额
+	Type            FinanceType     `gorm:"not null" json:"status"`                                       // 流水类型
+	Note            *string         `gorm:"null;type:varchar(128)" json:"note"`                           // 流水备注
+	Sequence        int64           `gorm:"not null;default:0;index" json:"sequence"`                     // 该钱包的第几条流水, 从 1 开始
+	PrevHash        string          `gorm:"not null;default:'';type:varchar(64)" json:"prev_hash"`        // 该钱包上一条流水的哈希
+	Hash            string          `gorm:"not null;default:'';type:varchar(64)" json:"hash"`             // 这条流水的哈希, 包含上一条流水的哈希
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 	DeletedAt       *time.Time `sql:"index" json:"-"`
 }
 
-type FinanceLogCny struct {
-	FinanceLog
+func (news *FinanceLog) BeforeCreate(scope *gorm.Scope) error {
+	// 哈希链需要在写入之前确定 ID
+	if news.Id != "" {
+		return nil
+	}
+	return scope.SetColumn("id", util.GenerateId())
 }
 
-type FinanceLogUsd struct {
-	FinanceLog
-}
+// 参与哈希计算的流水内容, 覆盖流水的内容以及上一条流水的哈希
+// 创建时间和删除时间精确到微秒, 与数据库保存的精度一致
+func (news *FinanceLog) hashContent() []byte {
+	var note interface{}
 
-type FinanceLogCoin struct {
-	FinanceLog
-}
+	if news.Note != nil {
+		note = *news.Note
+	}
 
-func (news *FinanceLog) BeforeCreate(scope *gorm.Scope) error {
-	return scope.SetColumn("id", util.GenerateId())
-}
+	fields := []interface{}{
+		news.Id,
+		news.Currency,
+		news.OrderId,
+		news.Uid,
+		news.Sequence,
+		news.BeforeBalance.String(),
+		news.BalanceMutation.String(),
+		news.AfterBalance.String(),
+		news.BeforeFrozen.String(),
+		news.FrozenMutation.String(),
+		news.AfterFrozen.String(),
+		news.Type,
+		note,
+		news.CreatedAt.UnixNano() / int64(time.Microsecond),
+		news.PrevHash,
+	}
 
-func (news *FinanceLogCny) TableName() string {
-	return "finance_log_cny"
-}
+	// 流水不应该被软删除, 软删除之后哈希不匹配
+	// 未删除的流水不加入这个字段, 与之前计算的哈希保持一致
+	if news.DeletedAt != nil {
+		fields = append(fields, news.DeletedAt.UnixNano()/int64(time.Microsecond))
+	}
 
-func (news *FinanceLogUsd) TableName() string {
-	return "finance_log_usd"
-}
+	content, _ := json.Marshal(fields)
 
-func (news *FinanceLogCoin) TableName() string {
-	return "finance_log_coin"
+	return content
 }
 
-func (news *FinanceLogCny) BeforeCreate(scope *gorm.Scope) error {
-	return scope.SetColumn("id", util.GenerateId())
+// 计算流水的哈希, 任何一条流水被修改或者删除, 之后的哈希链都会断开
+// 使用保存在数据库之外的密钥计算 HMAC, 只能修改数据库时无法重新计算出正确的哈希
+func (news *FinanceLog) ComputeHash(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	_, _ = mac.Write(news.hashContent())
+
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-func (news *FinanceLogUsd) BeforeCreate(scope *gorm.Scope) error {
-	return scope.SetColumn("id", util.GenerateId())
+// 旧版本不带密钥的哈希, 只用于把已有的哈希链迁移到 HMAC
+func (news *FinanceLog) LegacyHash() string {
+	sum := sha256.Sum256(news.hashContent())
+
+	return hex.EncodeToString(sum[:])
 }
 
-func (news *FinanceLogCoin) BeforeCreate(scope *gorm.Scope) error {
-	return scope.SetColumn("id", util.GenerateId())
+func (news *FinanceLog) TableName() string {
+	return "finance_log"
 }
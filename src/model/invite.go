@@ -21,6 +21,9 @@ type InviteHistory struct {
 	Invitee       string       `gorm:"not null;unique;index;type:varchar(32)" json:"invitee"` // 受邀请人, 只有唯一的一个
 	Status        InviteStatus `gorm:"not null;" json:"status"`                               // 受邀请人的激活状态
 	RewardSettled bool         `gorm:"not null;" json:"reward_settled"`                       // 是否已发放奖励, 包括邀请人和收邀请人的奖励
+	RegisteredAt  *time.Time   `gorm:"null" json:"registered_at"`                             // 结算注册奖励的时间, 被邀请人激活账号时结算
+	AuthAt        *time.Time   `gorm:"null" json:"auth_at"`                                   // 结算认证奖励的时间
+	PaidAt        *time.Time   `gorm:"null" json:"paid_at"`                                   // 结算首次支付奖励的时间
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	DeletedAt     *time.Time `sql:"index"`
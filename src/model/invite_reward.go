@@ -0,0 +1,53 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// 邀请奖励规则, 被邀请人达到某个里程碑时, 给邀请人和被邀请人发放奖励
+// 每个里程碑和币种只有一条规则, 同一个里程碑可以同时奖励多个币种
+type InviteRewardRule struct {
+	Id            string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`                        // 规则ID
+	Milestone     InviteStatus    `gorm:"not null;unique_index:invite_reward_rule_milestone" json:"milestone"`                 // 里程碑, 即被邀请人的状态
+	Currency      string          `gorm:"not null;unique_index:invite_reward_rule_milestone;type:varchar(16)" json:"currency"` // 奖励的币种
+	InviterAmount decimal.Decimal `gorm:"not null;type:numeric" json:"inviter_amount"`                                         // 邀请人的奖励
+	InviteeAmount decimal.Decimal `gorm:"not null;type:numeric" json:"invitee_amount"`                                         // 被邀请人的奖励
+	Enabled       bool            `gorm:"not null;default:true" json:"enabled"`                                                // 是否启用
+	Updater       string          `gorm:"not null;type:varchar(32)" json:"updater"`                                            // 最后修改的管理员
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (news *InviteRewardRule) TableName() string {
+	return "invite_reward_rule"
+}
+
+func (news *InviteRewardRule) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
+
+// 已发放的邀请奖励, 每个邀请的每个里程碑和币种只会发放一次
+type InviteReward struct {
+	Id            string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`                    // 奖励ID, 也是财务日志的订单号
+	InviteId      string          `gorm:"not null;unique_index:invite_reward_milestone;type:varchar(32)" json:"invite_id"` // 对应的邀请记录
+	Milestone     InviteStatus    `gorm:"not null;unique_index:invite_reward_milestone" json:"milestone"`                  // 达到的里程碑
+	Currency      string          `gorm:"not null;unique_index:invite_reward_milestone;type:varchar(16)" json:"currency"`  // 奖励的币种
+	Inviter       string          `gorm:"not null;index;type:varchar(32)" json:"inviter"`                                  // 邀请人
+	Invitee       string          `gorm:"not null;index;type:varchar(32)" json:"invitee"`                                  // 被邀请人
+	InviterAmount decimal.Decimal `gorm:"not null;type:numeric" json:"inviter_amount"`                                     // 邀请人得到的奖励
+	InviteeAmount decimal.Decimal `gorm:"not null;type:numeric" json:"invitee_amount"`                                     // 被邀请人得到的奖励
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (news *InviteReward) TableName() string {
+	return "invite_reward"
+}
+
+func (news *InviteReward) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"time"
+)
+
+type KycStatus int
+type KycIdType string
+
+const (
+	KycStatusRejected KycStatus = -1 // 审核被拒绝
+	KycStatusPending  KycStatus = 0  // 等待管理员审核
+	KycStatusApproved KycStatus = 1  // 审核通过
+
+	KycLevelNone     int32 = 0 // 未认证
+	KycLevelBasic    int32 = 1 // 初级认证, 需要证件的正反面
+	KycLevelAdvanced int32 = 2 // 高级认证, 另外需要手持证件的照片
+
+	KycIdTypeIdCard   KycIdType = "id_card"  // 身份证
+	KycIdTypePassport KycIdType = "passport" // 护照
+)
+
+// 实名认证的申请记录, 证件的图片通过证件上传接口上传之后再提交
+type Kyc struct {
+	Id         string         `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 申请ID
+	Uid        string         `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 申请的用户
+	Level      int32          `gorm:"not null" json:"level"`                                        // 申请的认证等级
+	Name       string         `gorm:"not null;type:varchar(64)" json:"name"`                        // 真实姓名
+	IdType     KycIdType      `gorm:"not null;type:varchar(16)" json:"id_type"`                     // 证件类型
+	IdNumber   string         `gorm:"not null;index;type:varchar(32)" json:"id_number"`             // 证件号码
+	Images     pq.StringArray `gorm:"not null;type:varchar(64)[]" json:"images"`                    // 证件图片的文件名, 依次为正面, 反面, 手持证件
+	Status     KycStatus      `gorm:"not null;index" json:"status"`                                 // 状态
+	Reason     *string        `gorm:"null;type:varchar(255)" json:"reason"`                         // 拒绝的原因
+	Reviewer   *string        `gorm:"null;type:varchar(32)" json:"reviewer"`                        // 审核的管理员
+	ReviewedAt *time.Time     `gorm:"null" json:"reviewed_at"`                                      // 审核时间
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  *time.Time `sql:"index"`
+}
+
+func (news *Kyc) TableName() string {
+	return "kyc"
+}
+
+func (news *Kyc) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
@@ -0,0 +1,30 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 用户上传的实名认证证件图片, 存放在私有目录, 只能由上传的用户用于提交申请
+type KycDocument struct {
+	Id        string `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 文件ID
+	Uid       string `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 上传的用户
+	Filename  string `gorm:"not null;unique;type:varchar(64)" json:"filename"`             // 存储在服务端的文件名
+	Origin    string `gorm:"not null;type:varchar(255)" json:"origin"`                     // 上传文件的原始名
+	Size      int64  `gorm:"not null" json:"size"`                                         // 文件大小
+	CreatedAt time.Time
+}
+
+func (news *KycDocument) TableName() string {
+	return "kyc_document"
+}
+
+func (news *KycDocument) BeforeCreate(scope *gorm.Scope) error {
+	// 文件名由 ID 生成, 需要在写入之前确定 ID
+	if news.Id != "" {
+		return nil
+	}
+	return scope.SetColumn("id", util.GenerateId())
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type LedgerDirection string
+
+type LedgerBucket string
+
+const (
+	LedgerDirectionDebit  LedgerDirection = "debit"  // 借方, 用户钱包减少
+	LedgerDirectionCredit LedgerDirection = "credit" // 贷方, 用户钱包增加
+
+	LedgerBucketBalance LedgerBucket = "balance" // 可用余额
+	LedgerBucketFrozen  LedgerBucket = "frozen"  // 冻结余额
+
+	// 系统账户, 与用户钱包之间的资金往来都记录在系统账户上, 系统账户的余额可以为负数
+	LedgerAccountAdjustment = "system:adjustment" // 管理员调整
+	LedgerAccountFee        = "system:fee"        // 手续费收入
+	LedgerAccountReward     = "system:reward"     // 发放的奖励
+	LedgerAccountOpening    = "system:opening"    // 启用复式记账之前的期初余额
+	LedgerAccountExchange   = "system:exchange"   // 币种兑换, 每个币种各自平衡
+)
+
+// 记账凭证, 一次资金变动对应一条凭证, 凭证下所有分录的借贷必须平衡
+type LedgerPosting struct {
+	Id        string      `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 凭证ID
+	Currency  string      `gorm:"not null;index;type:varchar(16)" json:"currency"`              // 币种
+	OrderId   string      `gorm:"null;index;type:varchar(32)" json:"order_id"`                  // 对应的订单ID, 例如转账ID
+	Type      FinanceType `gorm:"not null;index" json:"type"`                                   // 业务类型
+	Note      *string     `gorm:"null;type:varchar(128)" json:"note"`                           // 备注
+	CreatedAt time.Time
+}
+
+// 记账分录
+type LedgerEntry struct {
+	Id        string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 分录ID
+	PostingId string          `gorm:"not null;index;type:varchar(32)" json:"posting_id"`            // 所属凭证
+	Currency  string          `gorm:"not null;index;type:varchar(16)" json:"currency"`              // 币种
+	Account   string          `gorm:"not null;index;type:varchar(32)" json:"account"`               // 账户, 用户ID或者系统账户
+	Bucket    LedgerBucket    `gorm:"not null;type:varchar(16)" json:"bucket"`                      // 可用余额还是冻结余额
+	Direction LedgerDirection `gorm:"not null;type:varchar(8)" json:"direction"`                    // 借贷方向
+	Amount    decimal.Decimal `gorm:"not null;type:numeric" json:"amount"`                          // 数量, 总是正数
+	CreatedAt time.Time
+}
+
+// 对账报告
+type LedgerReconciliation struct {
+	Id                 string    `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 报告ID
+	Wallets            int       `gorm:"not null" json:"wallets"`                                      // 检查的钱包数量
+	Drifts             int       `gorm:"not null" json:"drifts"`                                       // 与分录不一致的钱包数量
+	UnbalancedPostings int       `gorm:"not null" json:"unbalanced_postings"`                          // 借贷不平衡的凭证数量
+	Detail             string    `gorm:"not null;type:text" json:"detail"`                             // 不一致的明细, JSON 格式
+	StartedAt          time.Time `gorm:"not null" json:"started_at"`                                   // 开始对账的时间
+	CreatedAt          time.Time
+}
+
+func (news *LedgerPosting) TableName() string {
+	return "ledger_posting"
+}
+
+func (news *LedgerEntry) TableName() string {
+	return "ledger_entry"
+}
+
+func (news *LedgerReconciliation) TableName() string {
+	return "ledger_reconciliation"
+}
+
+func (news *LedgerPosting) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
+
+func (news *LedgerEntry) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
+
+func (news *LedgerReconciliation) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
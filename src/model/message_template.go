@@ -0,0 +1,52 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 系统自动发送的消息事件, 每个事件可以为不同的语言设置模版
+const (
+	MessageEventTransferIn       = "transfer.in"            // 收到转账, 变量: Amount, Currency, From, TransferId
+	MessageEventTransferReversed = "transfer.reversed"      // 转账被管理员撤回, 转账双方都会收到, 变量: Amount, Currency, Reversed, Reason, TransferId
+	MessageEventScheduleRun      = "transfer.schedule_run"  // 定时转账已执行, 变量: Amount, Currency, To, ScheduleId, TransferId
+	MessageEventScheduleHold     = "transfer.schedule_hold" // 定时转账触发风控规则, 等待审核, 变量: Amount, Currency, To, ScheduleId, TransferId
+	MessageEventScheduleFail     = "transfer.schedule_fail" // 定时转账执行失败, 变量: Amount, Currency, To, ScheduleId, Reason
+	MessageEventRoleChanged      = "user.role_change"       // 角色变更, 变量: Roles
+)
+
+// 系统事件提供的模版变量, 这些事件的模版只能使用这里列出的变量
+var MessageEventVars = map[string][]string{
+	MessageEventTransferIn:       {"Amount", "Currency", "From", "TransferId"},
+	MessageEventTransferReversed: {"Amount", "Currency", "Reversed", "Reason", "TransferId"},
+	MessageEventScheduleRun:      {"Amount", "Currency", "To", "ScheduleId", "TransferId"},
+	MessageEventScheduleHold:     {"Amount", "Currency", "To", "ScheduleId", "TransferId"},
+	MessageEventScheduleFail:     {"Amount", "Currency", "To", "ScheduleId", "Reason"},
+	MessageEventRoleChanged:      {"Roles"},
+}
+
+// 消息模版, 标题和内容使用 text/template 语法, 例如 {{.Amount}}
+type MessageTemplate struct {
+	Id        string  `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"`                       // 模版ID
+	Event     string  `gorm:"not null;unique_index:message_template_event_locale;type:varchar(64)" json:"event"`  // 事件名称
+	Locale    string  `gorm:"not null;unique_index:message_template_event_locale;type:varchar(16)" json:"locale"` // 语言, 例如 zh-CN, en-US
+	Title     string  `gorm:"not null;type:varchar(255)" json:"title"`                                            // 标题模版
+	Content   string  `gorm:"not null;type:text" json:"content"`                                                  // 内容模版
+	Note      *string `gorm:"null;type:varchar(255)" json:"note"`                                                 // 备注
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `sql:"index"`
+}
+
+func (news *MessageTemplate) TableName() string {
+	return "message_template"
+}
+
+func (news *MessageTemplate) BeforeCreate(scope *gorm.Scope) error {
+	if err := scope.SetColumn("id", util.GenerateId()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ package model
 import (
 	"github.com/axetroy/go-server/src/util"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 	"time"
 )
 
@@ -14,23 +15,34 @@ const (
 	NotificationStatusActive   NotificationStatus = 0  // 启用的状态
 )
 
+// 目标条件都为空时通知所有用户, 设置了多个条件时用户需要同时满足
 type Notification struct {
-	Id        string             `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"` // 通知ID
-	Author    string             `gorm:"not null;index;type:varchar(32)" json:"Author"`                // 发布这则公告的作者
-	Title     string             `gorm:"not null;index;type:varchar(32)" json:"title"`                 // 公告标题
-	Content   string             `gorm:"not null;type:text" json:"content"`                            // 公告内容
-	Status    NotificationStatus `gorm:"not null" json:"status"`                                       // 公告状态
-	Note      *string            `gorm:"null;type:varchar(255)" json:"note"`                           // 这条通知的备注
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt *time.Time `sql:"index"`
+	Id               string             `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"` // 通知ID
+	Author           string             `gorm:"not null;index;type:varchar(32)" json:"Author"`                // 发布这则公告的作者
+	Title            string             `gorm:"not null;index;type:varchar(32)" json:"title"`                 // 公告标题
+	Content          string             `gorm:"not null;type:text" json:"content"`                            // 公告内容
+	Status           NotificationStatus `gorm:"not null" json:"status"`                                       // 公告状态
+	Note             *string            `gorm:"null;type:varchar(255)" json:"note"`                           // 这条通知的备注
+	TargetRoles      pq.StringArray     `gorm:"null;type:varchar(36)[]" json:"target_roles"`                  // 指定角色, 用户拥有其中任意一个角色即可
+	TargetUsers      pq.StringArray     `gorm:"null;type:varchar(32)[]" json:"target_users"`                  // 指定用户
+	MinLevel         *int32             `gorm:"null" json:"min_level"`                                        // 用户等级的下限, 包括这个等级
+	MaxLevel         *int32             `gorm:"null" json:"max_level"`                                        // 用户等级的上限, 包括这个等级
+	RegisteredAfter  *time.Time         `gorm:"null" json:"registered_after"`                                 // 在这个时间之后注册的用户, 包括这个时间
+	RegisteredBefore *time.Time         `gorm:"null" json:"registered_before"`                                // 在这个时间之前注册的用户, 不包括这个时间
+	PublishAt        *time.Time         `gorm:"null;index" json:"publish_at"`                                 // 发布时间, 为空则立即发布
+	ExpireAt         *time.Time         `gorm:"null;index" json:"expire_at"`                                  // 过期时间, 过期之后用户不再看到, 为空则不过期
+	PushedAt         *time.Time         `gorm:"null" json:"pushed_at"`                                        // 推送给在线用户的时间, 为空表示还没有推送
+	PushedUid        *string            `gorm:"null;type:varchar(32)" json:"pushed_uid"`                      // 已经推送到的最后一个用户ID, 推送中断之后从这个用户之后继续
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        *time.Time `sql:"index"`
 }
 
 type NotificationMark struct {
-	Id           string       `gorm:"primary_key;not null;unique(uid);index;type:varchar(32)" json:"id"` // 通知ID, 通知 ID 和 UID 为联合唯一
-	Uid          string       `gorm:"not null;index;unique(id);type:varchar(32)" json:"uid"`             // 对应的用户ID, 联合通知ID唯一
-	Read         bool         `gorm:"not null" json:"read"`                                              // 是否已读
-	Notification Notification `gorm:"foreign_key:Id;association_foreign_key:Id" json:"notification"`     // 关联外键
+	Id           string       `gorm:"primary_key;not null;index;type:varchar(32)" json:"id"`         // 通知ID, 通知 ID 和 UID 为联合主键
+	Uid          string       `gorm:"primary_key;not null;index;type:varchar(32)" json:"uid"`        // 对应的用户ID, 通知 ID 和 UID 为联合主键
+	Read         bool         `gorm:"not null" json:"read"`                                          // 是否已读
+	Notification Notification `gorm:"foreign_key:Id;association_foreign_key:Id" json:"notification"` // 关联外键
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	DeletedAt    *time.Time `sql:"index"`
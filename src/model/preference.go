@@ -0,0 +1,78 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 通知用户的渠道
+const (
+	NotificationChannelInApp = "in_app" // 站内的个人消息
+	NotificationChannelEmail = "email"  // 邮件
+	NotificationChannelSMS   = "sms"    // 短信
+	NotificationChannelPush  = "push"   // 实时推送给在线的客户端
+)
+
+// 所有的通知渠道, 以及用户没有设置时是否默认开启
+var NotificationChannels = map[string]bool{
+	NotificationChannelInApp: true,
+	NotificationChannelEmail: true,
+	NotificationChannelSMS:   false,
+	NotificationChannelPush:  true,
+}
+
+// 用户可以设置通知方式的事件
+var MessageEvents = []string{
+	MessageEventTransferIn,
+	MessageEventTransferReversed,
+	MessageEventScheduleRun,
+	MessageEventScheduleHold,
+	MessageEventScheduleFail,
+	MessageEventRoleChanged,
+}
+
+// 用户的通知设置
+type NotificationPreference struct {
+	Uid              string  `gorm:"primary_key;not null;type:varchar(32)" json:"uid"`                // 用户ID
+	QuietStart       *string `gorm:"null;type:varchar(5)" json:"quiet_start"`                         // 免打扰的开始时间, 格式为 HH:MM
+	QuietEnd         *string `gorm:"null;type:varchar(5)" json:"quiet_end"`                           // 免打扰的结束时间, 格式为 HH:MM, 小于开始时间表示跨天
+	Timezone         string  `gorm:"not null;type:varchar(64)" json:"timezone"`                       // 免打扰时间使用的时区, 例如 Asia/Shanghai
+	UnsubscribeToken string  `gorm:"not null;unique;index;type:varchar(32)" json:"unsubscribe_token"` // 邮件中退订链接使用的令牌
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// 用户对某个事件的某个渠道的设置, 没有记录时使用渠道的默认设置
+type NotificationSubscription struct {
+	Uid       string `gorm:"primary_key;not null;type:varchar(32)" json:"uid"`     // 用户ID
+	Event     string `gorm:"primary_key;not null;type:varchar(64)" json:"event"`   // 事件名称
+	Channel   string `gorm:"primary_key;not null;type:varchar(16)" json:"channel"` // 通知渠道
+	Enabled   bool   `gorm:"not null" json:"enabled"`                              // 是否开启
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (news *NotificationPreference) TableName() string {
+	return "notification_preference"
+}
+
+func (news *NotificationPreference) BeforeCreate(scope *gorm.Scope) error {
+	// 退订链接不需要登陆, 令牌必须无法猜测
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	if err := scope.SetColumn("unsubscribe_token", hex.EncodeToString(b)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (news *NotificationSubscription) TableName() string {
+	return "notification_subscription"
+}
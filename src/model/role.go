@@ -16,6 +16,7 @@ var (
 		*accession.Password2Update,
 		*accession.PasswordUpdate,
 		*accession.DoTransfer,
+		*accession.DoExchange,
 	})
 )
 
@@ -0,0 +1,48 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type StatementStatus int
+
+const (
+	StatementStatusFailed  StatementStatus = -1 // 生成失败
+	StatementStatusPending StatementStatus = 0  // 等待生成
+	StatementStatusDone    StatementStatus = 1  // 已生成, 可以下载
+)
+
+type StatementFormat string
+
+const (
+	StatementFormatCSV StatementFormat = "csv"
+	StatementFormatPDF StatementFormat = "pdf"
+)
+
+// 钱包的对账单, 根据流水生成的文件
+type Statement struct {
+	Id        string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 对账单ID
+	Uid       string          `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 哪个用户的钱包
+	Currency  string          `gorm:"not null;type:varchar(16)" json:"currency"`                    // 哪个币种的钱包
+	Format    StatementFormat `gorm:"not null;type:varchar(8)" json:"format"`                       // 文件格式, csv/pdf
+	StartAt   time.Time       `gorm:"not null" json:"start_at"`                                     // 开始时间, 包括这个时间
+	EndAt     time.Time       `gorm:"not null" json:"end_at"`                                       // 结束时间, 不包括这个时间
+	Status    StatementStatus `gorm:"not null;index" json:"status"`                                 // 状态
+	Filename  *string         `gorm:"null;type:varchar(64)" json:"filename"`                        // 生成的文件名
+	Error     *string         `gorm:"null;type:varchar(255)" json:"error"`                          // 生成失败的原因
+	Creator   string          `gorm:"not null;type:varchar(32)" json:"creator"`                     // 由谁发起, 用户自己或者管理员的ID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `sql:"index"`
+}
+
+func (news *Statement) TableName() string {
+	return "statement"
+}
+
+func (news *Statement) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
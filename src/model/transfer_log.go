@@ -4,74 +4,48 @@ package model
 import (
 	"github.com/axetroy/go-server/src/util"
 	"github.com/jinzhu/gorm"
-	"strings"
+	"github.com/shopspring/decimal"
 	"time"
 )
 
 type TransferStatus int
 
 var (
-	transferLogTablePrefix                      = "transfer_log_"
+	TransferStatusDeclined       TransferStatus = -3 // 风控审核被拒绝, 已退回
+	TransferStatusExpired        TransferStatus = -2 // 收款方超时未确认, 已退回
 	TransferStatusReject         TransferStatus = -1 // 收款方拒接接受
 	TransferStatusWaitForConfirm TransferStatus = 0  // 等待收款方确认
 	TransferStatusConfirmed      TransferStatus = 1  // 收款方已确认
-
-	TransferLogCnyTableName  = transferLogTablePrefix + strings.ToLower(WalletCNY)  // 人民币表名
-	TransferLogUsdTableName  = transferLogTablePrefix + strings.ToLower(WalletUSD)  // 美元表名
-	TransferLogCoinTableName = transferLogTablePrefix + strings.ToLower(WalletCOIN) // 积分表名
-
-	TransferTableNames = []string{ // 所有的表名
-		TransferLogCnyTableName,
-		TransferLogUsdTableName,
-		TransferLogCoinTableName,
-	}
-
-	TransferLogMap = map[string]interface{}{
-		WalletCNY:  TransferLogCny{},
-		WalletUSD:  TransferLogUsd{},
-		WalletCOIN: TransferLogCoin{},
-	}
+	TransferStatusHold           TransferStatus = 2  // 触发风控规则, 等待管理员审核
+	TransferStatusReversed       TransferStatus = 3  // 已被管理员撤回, 撤回的数量见对应的反向转账
 )
 
 type TransferLog struct {
-	Id           string         `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 转账ID
-	Currency     string         `gorm:"not null;index;" json:"currency"`                              // 转账币种
-	From         string         `gorm:"not null;index;type:varchar(32)" json:"from"`                  // 汇款人
-	To           string         `gorm:"not null;index;type:varchar(32)" json:"to"`                    // 收款人
-	Amount       string         `gorm:"not null;type:numeric" json:"amount"`                          // 转账数量
-	Status       TransferStatus `gorm:"not null" json:"status"`                                       // 转账状态
-	Note         *string        `gorm:"null;type:varchar(128)" json:"note"`                           // 转账备注
-	SnapshotFrom *string        `gorm:"null" json:"-"`                                                // 转账者的钱包快照
-	SnapshotTo   *string        `gorm:"null" json:"-"`                                                // 收款人的钱包快照
+	Id           string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 转账ID
+	Currency     string          `gorm:"not null;index;type:varchar(16)" json:"currency"`              // 转账币种
+	From         string          `gorm:"not null;index;type:varchar(32)" json:"from"`                  // 汇款人
+	To           string          `gorm:"not null;index;type:varchar(32)" json:"to"`                    // 收款人
+	Amount       decimal.Decimal `gorm:"not null;type:numeric" json:"amount"`                          // 转账数量
+	Status       TransferStatus  `gorm:"not null" json:"status"`                                       // 转账状态
+	Note         *string         `gorm:"null;type:varchar(128)" json:"note"`                           // 转账备注
+	SnapshotFrom *string         `gorm:"null" json:"-"`                                                // 转账者的钱包快照
+	SnapshotTo   *string         `gorm:"null" json:"-"`                                                // 收款人的钱包快照
+	ExpiredAt    *time.Time      `gorm:"null;index" json:"expired_at"`                                 // 等待收款方确认的过期时间, 不需要确认的转账为空
+	NeedConfirm  bool            `gorm:"not null;default:false" json:"need_confirm"`                   // 是否需要收款方确认, 风控审核通过之后使用
+	HoldReason   *string         `gorm:"null;type:varchar(255)" json:"hold_reason"`                    // 触发的风控规则
+	Reviewer     *string         `gorm:"null;type:varchar(32)" json:"reviewer"`                        // 风控审核的管理员
+	ReviewedAt   *time.Time      `gorm:"null" json:"reviewed_at"`                                      // 风控审核时间
+	ScheduleId   *string         `gorm:"null;index;type:varchar(32)" json:"schedule_id"`               // 由哪个定时转账产生
+	ReversalOf   *string         `gorm:"null;index;type:varchar(32)" json:"reversal_of"`               // 管理员撤回时产生的反向转账, 对应原来的转账
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	DeletedAt    *time.Time `sql:"index" json:"-"`
 }
 
-type TransferLogCny struct {
-	TransferLog
-}
-
-type TransferLogUsd struct {
-	TransferLog
-}
-
-type TransferLogCoin struct {
-	TransferLog
-}
-
 func (news *TransferLog) BeforeCreate(scope *gorm.Scope) error {
 	return scope.SetColumn("id", util.GenerateId())
 }
 
-func (news *TransferLogCny) TableName() string {
-	return TransferLogCnyTableName
-}
-
-func (news *TransferLogUsd) TableName() string {
-	return TransferLogUsdTableName
-}
-
-func (news *TransferLogCoin) TableName() string {
-	return TransferLogCoinTableName
+func (news *TransferLog) TableName() string {
+	return "transfer_log"
 }
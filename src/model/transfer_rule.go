@@ -0,0 +1,34 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// 转账的风控规则, 触发规则的转账会被冻结等待管理员审核, 而不是直接拒绝
+// 每个币种可以按用户等级设置不同的规则, Level 为 0 的规则对没有单独设置的等级生效
+// 为空的字段表示不限制
+type TransferRule struct {
+	Id           string           `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`               // 规则ID
+	Currency     string           `gorm:"not null;unique_index:transfer_rule_level;type:varchar(16)" json:"currency"` // 币种
+	Level        int32            `gorm:"not null;default:0;unique_index:transfer_rule_level" json:"level"`           // 用户等级, 0 表示所有等级
+	MinAmount    *decimal.Decimal `gorm:"null;type:numeric" json:"min_amount"`                                        // 单笔最小数量
+	MaxAmount    *decimal.Decimal `gorm:"null;type:numeric" json:"max_amount"`                                        // 单笔最大数量
+	DailyLimit   *decimal.Decimal `gorm:"null;type:numeric" json:"daily_limit"`                                       // 每个用户每天的累计转出数量
+	MonthlyLimit *decimal.Decimal `gorm:"null;type:numeric" json:"monthly_limit"`                                     // 每个用户每月的累计转出数量
+	HourlyCount  *int             `gorm:"null" json:"hourly_count"`                                                   // 每个用户一小时内的转账次数
+	Updater      string           `gorm:"not null;type:varchar(32)" json:"updater"`                                   // 最后修改的管理员
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (news *TransferRule) TableName() string {
+	return "transfer_rule"
+}
+
+func (news *TransferRule) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
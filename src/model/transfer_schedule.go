@@ -0,0 +1,50 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type TransferScheduleStatus int
+
+const (
+	TransferScheduleStatusCancelled TransferScheduleStatus = -2 // 用户已取消
+	TransferScheduleStatusFailed    TransferScheduleStatus = -1 // 一次性的定时转账重试之后仍然失败
+	TransferScheduleStatusActive    TransferScheduleStatus = 0  // 等待执行
+	TransferScheduleStatusPaused    TransferScheduleStatus = 1  // 用户已暂停
+	TransferScheduleStatusFinished  TransferScheduleStatus = 2  // 已执行完, 不会再执行
+)
+
+// 定时转账, 没有重复规则的只执行一次
+type TransferSchedule struct {
+	Id             string                 `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 定时转账ID
+	Uid            string                 `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 转账方
+	Currency       string                 `gorm:"not null;type:varchar(16)" json:"currency"`                    // 币种
+	To             string                 `gorm:"not null;type:varchar(32)" json:"to"`                          // 收款方
+	Amount         decimal.Decimal        `gorm:"not null;type:numeric" json:"amount"`                          // 每次转账的数量
+	Note           *string                `gorm:"null;type:varchar(128)" json:"note"`                           // 转账备注
+	Confirm        bool                   `gorm:"not null;default:false" json:"confirm"`                        // 是否需要收款方确认
+	Recurrence     *string                `gorm:"null;type:varchar(255)" json:"recurrence"`                     // 重复规则, cron 或者 RRULE, 为空表示只执行一次
+	StartAt        time.Time              `gorm:"not null" json:"start_at"`                                     // 开始时间, 一次性的定时转账在这个时间执行
+	NextRunAt      *time.Time             `gorm:"null;index" json:"next_run_at"`                                // 下一次执行的时间, 包括余额不足之后的重试
+	Status         TransferScheduleStatus `gorm:"not null;index" json:"status"`                                 // 状态
+	RunCount       int                    `gorm:"not null;default:0" json:"run_count"`                          // 已成功执行的次数
+	Attempts       int                    `gorm:"not null;default:0" json:"attempts"`                           // 本次已经失败的次数
+	LastRunAt      *time.Time             `gorm:"null" json:"last_run_at"`                                      // 最后一次成功执行的时间
+	LastTransferId *string                `gorm:"null;type:varchar(32)" json:"last_transfer_id"`                // 最后一次产生的转账记录
+	LastError      *string                `gorm:"null;type:varchar(255)" json:"last_error"`                     // 最后一次失败的原因
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time `sql:"index"`
+}
+
+func (news *TransferSchedule) TableName() string {
+	return "transfer_schedule"
+}
+
+func (news *TransferSchedule) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
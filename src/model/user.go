@@ -40,6 +40,8 @@ type User struct {
 	EnableTOTP    bool           `gorm:"not null;" json:"enable_totp"`                                 // 是否启用双重身份认证
 	Secret        string         `gorm:"not null;type:varchar(32)" json:"secret"`                      // 用户自己的密钥
 	InviteCode    string         `gorm:"not null;unique;type:varchar(8)" json:"invite_code"`           // 用户的邀请码，邀请码唯一
+	KycLevel      int32          `gorm:"not null;default:0" json:"kyc_level"`                          // 实名认证的等级, 0 表示未认证
+	Locale        *string        `gorm:"null;type:varchar(16)" json:"locale"`                          // 用户使用的语言, 例如 zh-CN, en-US
 	OauthGoogleId *string        `gorm:"null;unique;type:varchar(255)" json:"oauth_google_id"`         // 用户的GoogleAuth唯一标识符
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
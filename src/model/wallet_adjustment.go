@@ -0,0 +1,61 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type WalletAdjustmentStatus int
+
+const (
+	WalletAdjustmentStatusRejected WalletAdjustmentStatus = -1 // 审核被拒绝, 没有执行
+	WalletAdjustmentStatusPending  WalletAdjustmentStatus = 0  // 等待另一个管理员审核
+	WalletAdjustmentStatusDone     WalletAdjustmentStatus = 1  // 已执行
+)
+
+var (
+	// 调整类型对应的流水类型
+	WalletAdjustmentTypes = []FinanceType{
+		FinanceTypeAdminCredit,
+		FinanceTypeAdminDebit,
+		FinanceTypeFreeze,
+		FinanceTypeUnfreeze,
+	}
+)
+
+// 管理员对用户钱包的调整记录
+type WalletAdjustment struct {
+	Id         string                 `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 调整记录ID
+	Uid        string                 `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 被调整的用户
+	Currency   string                 `gorm:"not null;index;type:varchar(16)" json:"currency"`              // 币种
+	Type       FinanceType            `gorm:"not null;index" json:"type"`                                   // 调整类型
+	Amount     decimal.Decimal        `gorm:"not null;type:numeric" json:"amount"`                          // 调整数量
+	Reason     string                 `gorm:"not null;type:varchar(255)" json:"reason"`                     // 调整原因
+	Status     WalletAdjustmentStatus `gorm:"not null;index" json:"status"`                                 // 状态
+	Creator    string                 `gorm:"not null;index;type:varchar(32)" json:"creator"`               // 发起调整的管理员
+	Reviewer   *string                `gorm:"null;type:varchar(32)" json:"reviewer"`                        // 审核的管理员
+	ReviewedAt *time.Time             `gorm:"null" json:"reviewed_at"`                                      // 审核时间
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  *time.Time `sql:"index"`
+}
+
+func (news *WalletAdjustment) TableName() string {
+	return "wallet_adjustment"
+}
+
+func (news *WalletAdjustment) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
+
+func IsValidWalletAdjustmentType(t FinanceType) bool {
+	for _, v := range WalletAdjustmentTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
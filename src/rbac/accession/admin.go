@@ -12,10 +12,10 @@ var (
 	AdminNewsUpdate = New("news::update", "有权限修改新闻")
 	AdminNewsDelete = New("news::delete", "有权限删除新闻")
 
-	adminNotificationGet    = New("notification::get", "有权限获取公告")
-	adminNotificationCreate = New("notification::create", "有权限创建公告")
-	adminNotificationUpdate = New("notification::update", "有权限修改公告")
-	adminNotificationDelete = New("notification::delete", "有权限删除公告")
+	AdminNotificationGet    = New("notification::get", "有权限获取公告")
+	AdminNotificationCreate = New("notification::create", "有权限创建公告")
+	AdminNotificationUpdate = New("notification::update", "有权限修改公告")
+	AdminNotificationDelete = New("notification::delete", "有权限删除公告")
 
 	AdminUserGet    = New("user::get", "有权限获取用户信息")
 	AdminUserCreate = New("user::create", "有权限创建新用户")
@@ -37,6 +37,30 @@ var (
 	AdminReportUpdate = New("report::update", "有权限修改反馈信息")
 	AdminReportDelete = New("report::delete", "有权限删除反馈信息")
 
+	AdminWalletAdjust  = New("wallet::adjust", "有权限调整用户钱包的余额/冻结")
+	AdminWalletApprove = New("wallet::approve", "有权限审核大额的钱包调整")
+
+	AdminCurrencyUpdate = New("currency::update", "有权限添加/修改币种")
+
+	AdminTransferRule    = New("transfer::rule", "有权限设置转账的风控规则")
+	AdminTransferReview  = New("transfer::review", "有权限审核触发风控规则的转账")
+	AdminTransferReverse = New("transfer::reverse", "有权限撤回已完成的转账")
+
+	AdminExchangeRateUpdate = New("exchange::rate", "有权限维护兑换汇率")
+
+	AdminLedgerGet = New("ledger::get", "有权限查看对账报告和校验流水的哈希链")
+
+	AdminFinanceStatement = New("finance::statement", "有权限生成和下载用户的对账单")
+
+	AdminInviteReward    = New("invite::reward", "有权限设置邀请奖励规则和查看奖励发放记录")
+	AdminInviteAnalytics = New("invite::analytics", "有权限查看邀请关系和邀请数据分析")
+
+	AdminKycReview = New("kyc::review", "有权限审核用户的实名认证")
+
+	AdminMessageTemplate = New("message::template", "有权限维护消息模版和用模版给用户发送消息")
+
+	AdminEmail = New("email::manage", "有权限预览邮件模版, 查看和重新发送邮件")
+
 	// 管理员的所有权限
 	AdminList = []*Accession{
 		AdminAdminGet,
@@ -49,10 +73,10 @@ var (
 		AdminNewsUpdate,
 		AdminNewsDelete,
 
-		adminNotificationGet,
-		adminNotificationUpdate,
-		adminNotificationDelete,
-		adminNotificationCreate,
+		AdminNotificationGet,
+		AdminNotificationUpdate,
+		AdminNotificationDelete,
+		AdminNotificationCreate,
 
 		AdminUserGet,
 		AdminUserCreate,
@@ -73,6 +97,30 @@ var (
 		AdminReportGet,
 		AdminReportUpdate,
 		AdminReportDelete,
+
+		AdminWalletAdjust,
+		AdminWalletApprove,
+
+		AdminCurrencyUpdate,
+
+		AdminTransferRule,
+		AdminTransferReview,
+		AdminTransferReverse,
+
+		AdminExchangeRateUpdate,
+
+		AdminLedgerGet,
+
+		AdminFinanceStatement,
+
+		AdminInviteReward,
+		AdminInviteAnalytics,
+
+		AdminKycReview,
+
+		AdminMessageTemplate,
+
+		AdminEmail,
 	}
 
 	AdminMap = map[string]*Accession{}
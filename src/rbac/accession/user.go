@@ -9,6 +9,7 @@ var (
 	Password2Reset  = New("password2.reset", "有权限重置二级密码")
 	Password2Update = New("password2::update", "有权限修改二级密码")
 	DoTransfer      = New("transfer::create", "有权限发起转账交易")
+	DoExchange      = New("exchange::create", "有权限兑换币种")
 
 	// 用户的所有的权限
 	List = []*Accession{
@@ -17,6 +18,7 @@ var (
 		Password2Set,
 		Password2Update,
 		DoTransfer,
+		DoExchange,
 	}
 
 	Map = map[string]*Accession{}
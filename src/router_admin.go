@@ -6,14 +6,25 @@ import (
 	"github.com/axetroy/go-server/src/config"
 	"github.com/axetroy/go-server/src/controller/admin"
 	"github.com/axetroy/go-server/src/controller/banner"
+	"github.com/axetroy/go-server/src/controller/currency"
+	"github.com/axetroy/go-server/src/controller/downloader"
+	"github.com/axetroy/go-server/src/controller/email"
+	"github.com/axetroy/go-server/src/controller/exchange"
+	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/controller/kyc"
+	"github.com/axetroy/go-server/src/controller/ledger"
 	"github.com/axetroy/go-server/src/controller/menu"
 	"github.com/axetroy/go-server/src/controller/message"
 	"github.com/axetroy/go-server/src/controller/news"
 	"github.com/axetroy/go-server/src/controller/notification"
 	"github.com/axetroy/go-server/src/controller/report"
 	"github.com/axetroy/go-server/src/controller/role"
+	"github.com/axetroy/go-server/src/controller/statement"
 	"github.com/axetroy/go-server/src/controller/system"
+	"github.com/axetroy/go-server/src/controller/transfer"
 	"github.com/axetroy/go-server/src/controller/user"
+	"github.com/axetroy/go-server/src/controller/wallet"
 	"github.com/axetroy/go-server/src/middleware"
 	"github.com/axetroy/go-server/src/schema"
 	"github.com/axetroy/go-server/src/service/dotenv"
@@ -56,6 +67,7 @@ func init() {
 		})
 
 		adminAuthMiddleware := middleware.Authenticate(true) // 管理员Token的中间件
+		idempotencyMiddleware := middleware.Idempotency      // 幂等键的中间件, 必须在管理员Token的中间件之后
 
 		// 登陆
 		v1.POST("/login", admin.LoginRouter)         // 管理员登陆
@@ -114,17 +126,24 @@ func init() {
 			notificationRouter.GET("", notification.GetNotificationListByAdminRouter) // 获取系统通知列表
 			notificationRouter.PUT("/n/:id", notification.UpdateRouter)               // 更新系统通知
 			notificationRouter.DELETE("/n/:id", notification.DeleteRouter)            // 删除系统通知
-			notificationRouter.GET("/n/:id", notification.GetRouter)                  // 获取单条系统通知
+			notificationRouter.GET("/n/:id", notification.GetByAdminRouter)           // 获取单条系统通知
+			notificationRouter.POST("/target", notification.PreviewTargetRouter)      // 预览通知对象匹配的用户数量
 		}
 
 		// 个人消息
 		{
 			messageRouter := v1.Group("/message")
-			messageRouter.POST("", message.CreateRouter)                        // 创建个人消息
-			messageRouter.GET("", message.GetMessageListByAdminRouter)          // 获取消息列表
-			messageRouter.GET("/m/:message_id", message.GetAdminRouter)         // 获取个人消息
-			messageRouter.PUT("/m/:message_id", message.UpdateRouter)           // 更新个人消息
-			messageRouter.DELETE("/m/:message_id", message.DeleteByAdminRouter) // 删除个人消息
+			messageRouter.POST("", message.CreateRouter)                                   // 创建个人消息
+			messageRouter.GET("", message.GetMessageListByAdminRouter)                     // 获取消息列表
+			messageRouter.GET("/m/:message_id", message.GetAdminRouter)                    // 获取个人消息
+			messageRouter.PUT("/m/:message_id", message.UpdateRouter)                      // 更新个人消息
+			messageRouter.DELETE("/m/:message_id", message.DeleteByAdminRouter)            // 删除个人消息
+			messageRouter.POST("/send", message.SendRouter)                                // 用模版给用户发送消息
+			messageRouter.POST("/template", message.CreateTemplateRouter)                  // 创建消息模版
+			messageRouter.GET("/template", message.GetTemplateListRouter)                  // 获取消息模版列表
+			messageRouter.GET("/template/t/:template_id", message.GetTemplateRouter)       // 获取消息模版
+			messageRouter.PUT("/template/t/:template_id", message.UpdateTemplateRouter)    // 更新消息模版
+			messageRouter.DELETE("/template/t/:template_id", message.DeleteTemplateRouter) // 删除消息模版
 		}
 
 		// 用户反馈
@@ -156,6 +175,90 @@ func init() {
 			menuRouter.DELETE("/m/:menu_id", menu.DeleteRouter) // 删除菜单
 		}
 
+		// 用户钱包
+		{
+			walletRouter := v1.Group("wallet")
+			walletRouter.GET("/adjustment", wallet.GetAdjustmentListRouter)                                                 // 获取钱包调整记录
+			walletRouter.POST("/adjustment", idempotencyMiddleware, wallet.AdjustRouter)                                    // 调整用户的钱包, 充值/扣除/冻结/解冻
+			walletRouter.PUT("/adjustment/a/:adjustment_id/approve", idempotencyMiddleware, wallet.ApproveAdjustmentRouter) // 审核通过大额的钱包调整
+			walletRouter.PUT("/adjustment/a/:adjustment_id/reject", idempotencyMiddleware, wallet.RejectAdjustmentRouter)   // 拒绝大额的钱包调整
+		}
+
+		// 币种
+		{
+			currencyRouter := v1.Group("currency")
+			currencyRouter.GET("", currency.GetListByAdminRouter) // 获取所有币种
+			currencyRouter.POST("", currency.CreateRouter)        // 添加币种
+			currencyRouter.PUT("/c/:code", currency.UpdateRouter) // 修改币种, 启用/停用, 转账限额
+		}
+
+		// 转账风控
+		{
+			transferRouter := v1.Group("transfer")
+			transferRouter.GET("/rule", transfer.GetRulesRouter)                                                  // 获取转账规则
+			transferRouter.PUT("/rule", transfer.SetRuleRouter)                                                   // 设置转账规则
+			transferRouter.DELETE("/rule/r/:rule_id", transfer.DeleteRuleRouter)                                  // 删除转账规则
+			transferRouter.GET("/hold", transfer.GetHoldListRouter)                                               // 获取等待风控审核的转账
+			transferRouter.PUT("/hold/t/:transfer_id/approve", idempotencyMiddleware, transfer.ApproveHoldRouter) // 审核通过
+			transferRouter.PUT("/hold/t/:transfer_id/decline", idempotencyMiddleware, transfer.DeclineHoldRouter) // 审核拒绝, 退回转账方
+			transferRouter.PUT("/t/:transfer_id/reverse", idempotencyMiddleware, transfer.ReverseRouter)          // 撤回已完成的转账
+		}
+
+		// 汇率
+		{
+			exchangeRouter := v1.Group("exchange")
+			exchangeRouter.GET("/rate", exchange.GetRatesRouter) // 获取汇率表
+			exchangeRouter.PUT("/rate", exchange.SetRateRouter)  // 设置汇率
+		}
+
+		// 账务
+		{
+			ledgerRouter := v1.Group("ledger")
+			ledgerRouter.GET("/reconciliation", ledger.GetLatestReconciliationRouter) // 获取最近一次的对账报告
+			ledgerRouter.GET("/chain", finance.VerifyChainByAdminRouter)              // 校验流水的哈希链
+		}
+
+		// 邀请奖励和邀请数据分析
+		{
+			inviteRouter := v1.Group("invite")
+			inviteRouter.GET("/reward", invite.GetRewardsByAdminRouter)                   // 获取邀请奖励的发放记录
+			inviteRouter.GET("/reward/rule", invite.GetRewardRulesRouter)                 // 获取邀请奖励规则
+			inviteRouter.PUT("/reward/rule", invite.SetRewardRuleRouter)                  // 设置邀请奖励规则
+			inviteRouter.DELETE("/reward/rule/r/:rule_id", invite.DeleteRewardRuleRouter) // 删除邀请奖励规则
+			inviteRouter.GET("/tree", invite.GetTreeRouter)                               // 获取用户的邀请关系树
+			inviteRouter.GET("/funnel", invite.GetFunnelRouter)                           // 获取每个邀请人的转化漏斗
+			inviteRouter.GET("/leaderboard", invite.GetLeaderboardRouter)                 // 获取邀请排行榜
+			inviteRouter.GET("/cluster", invite.GetClustersRouter)                        // 获取可疑的邀请
+		}
+
+		// 实名认证审核
+		{
+			kycRouter := v1.Group("kyc")
+			kycRouter.GET("", kyc.GetKycListByAdminRouter)                      // 获取实名认证申请
+			kycRouter.GET("/k/:kyc_id", kyc.GetKycByAdminRouter)                // 获取实名认证申请详情
+			kycRouter.PUT("/k/:kyc_id/approve", kyc.ApproveRouter)              // 审核通过
+			kycRouter.PUT("/k/:kyc_id/reject", kyc.RejectRouter)                // 审核拒绝
+			kycRouter.GET("/document/:filename", downloader.KycDocumentByAdmin) // 下载证件图片
+		}
+
+		// 对账单
+		{
+			statementRouter := v1.Group("statement")
+			statementRouter.GET("", statement.GetStatementsByAdminRouter)                 // 获取对账单列表
+			statementRouter.POST("", statement.CreateByAdminRouter)                       // 生成用户的对账单
+			statementRouter.GET("/s/:statement_id/download", downloader.StatementByAdmin) // 下载对账单
+		}
+
+		// 邮件模版
+		{
+			emailRouter := v1.Group("email")
+			emailRouter.GET("/preview/:name", email.PreviewRouter)       // 用示例数据预览邮件模版
+			emailRouter.GET("/captured", email.GetCapturedRouter)        // 查看开发环境中捕获的邮件
+			emailRouter.GET("/log", email.GetLogsRouter)                 // 获取邮件发送记录列表
+			emailRouter.GET("/log/l/:log_id", email.GetLogRouter)        // 获取邮件发送记录
+			emailRouter.PUT("/log/l/:log_id/resend", email.ResendRouter) // 重新发送失败的邮件
+		}
+
 		v1.GET("/system", system.GetSystemInfoRouter) // 获取系统相关信息
 	}
 
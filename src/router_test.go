@@ -0,0 +1,72 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package src_test
+
+import (
+	"github.com/axetroy/go-server/src"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+var linkReg = regexp.MustCompile(`href="([^"]+\?code=[^"]+)"`)
+
+// 邮件中的链接指向 API 时必须有对应的 GET 路由, 否则必须指向前端页面
+func TestEmailLinks(t *testing.T) {
+	api, err := url.Parse(config.User.Domain)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	web, err := url.Parse(config.User.WebDomain)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	routes := map[string]bool{}
+
+	for _, r := range src.UserRouter.Routes() {
+		if r.Method == "GET" {
+			routes[r.Path] = true
+		}
+	}
+
+	transport := email.NewMemoryTransport()
+	mailer := &email.Mailer{Transport: transport}
+
+	assert.Nil(t, mailer.SendActivationEmail("a@example.com", "activation-123", "zh-CN"))
+	assert.Nil(t, mailer.SendForgotPasswordEmail("a@example.com", "reset-123", "zh-CN"))
+	assert.Nil(t, mailer.SendForgotTradePasswordEmail("a@example.com", "reset-pay-123", "zh-CN"))
+
+	list, err := transport.Captured(0)
+
+	assert.Nil(t, err)
+	assert.Len(t, list, 3)
+
+	for _, m := range list {
+		match := linkReg.FindStringSubmatch(m.HTML)
+
+		if !assert.Len(t, match, 2, m.Subject) {
+			continue
+		}
+
+		link, err := url.Parse(match[1])
+
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		if link.Host == api.Host {
+			assert.True(t, routes[link.Path], "%s 的链接 %s 没有对应的路由", m.Subject, link.String())
+		} else {
+			assert.Equal(t, web.Host, link.Host, m.Subject)
+		}
+	}
+
+	// 重置密码需要用户填写新密码, 链接指向前端页面
+	assert.NotEqual(t, api.Host, web.Host)
+}
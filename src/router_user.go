@@ -7,16 +7,23 @@ import (
 	"github.com/axetroy/go-server/src/controller/address"
 	"github.com/axetroy/go-server/src/controller/auth"
 	"github.com/axetroy/go-server/src/controller/banner"
+	"github.com/axetroy/go-server/src/controller/currency"
 	"github.com/axetroy/go-server/src/controller/downloader"
 	"github.com/axetroy/go-server/src/controller/email"
+	"github.com/axetroy/go-server/src/controller/exchange"
 	"github.com/axetroy/go-server/src/controller/finance"
+	"github.com/axetroy/go-server/src/controller/inbox"
 	"github.com/axetroy/go-server/src/controller/invite"
+	"github.com/axetroy/go-server/src/controller/kyc"
 	"github.com/axetroy/go-server/src/controller/message"
 	"github.com/axetroy/go-server/src/controller/news"
 	"github.com/axetroy/go-server/src/controller/notification"
 	"github.com/axetroy/go-server/src/controller/oauth2"
+	"github.com/axetroy/go-server/src/controller/preference"
+	"github.com/axetroy/go-server/src/controller/push"
 	"github.com/axetroy/go-server/src/controller/report"
 	"github.com/axetroy/go-server/src/controller/resource"
+	"github.com/axetroy/go-server/src/controller/statement"
 	"github.com/axetroy/go-server/src/controller/transfer"
 	"github.com/axetroy/go-server/src/controller/uploader"
 	"github.com/axetroy/go-server/src/controller/user"
@@ -63,6 +70,7 @@ func init() {
 		})
 
 		userAuthMiddleware := middleware.Authenticate(false) // 用户Token的中间件
+		idempotencyMiddleware := middleware.Idempotency      // 幂等键的中间件, 必须在用户Token的中间件之后
 
 		// 认证类
 		{
@@ -70,9 +78,13 @@ func init() {
 			authRouter.POST("/signup", auth.SignUpRouter)               // 注册账号
 			authRouter.POST("/signin", auth.SignInRouter)               // 登陆账号
 			authRouter.POST("/activation", auth.ActivationRouter)       // 激活账号
+			authRouter.GET("/activation", auth.ActivationByLinkRouter)  // 通过邮件中的链接激活账号
 			authRouter.PUT("/password/reset", auth.ResetPasswordRouter) // 密码重置
 		}
 
+		// 邮件中的退订链接, 不需要登陆
+		v1.GET("/unsubscribe/:token", preference.UnsubscribeRouter)
+
 		// oAuth2 认证
 		{
 			oAuthRouter := v1.Group("/oauth2")
@@ -83,7 +95,7 @@ func init() {
 		// 用户类
 		{
 			userRouter := v1.Group("/user")
-			userRouter.Use(userAuthMiddleware)
+			userRouter.Use(userAuthMiddleware, idempotencyMiddleware)
 			userRouter.GET("/signout", user.SignOut)                                                                      // 用户登出
 			userRouter.GET("/profile", user.GetProfileRouter)                                                             // 获取用户详细信息
 			userRouter.PUT("/profile", rbac.Require(*accession.ProfileUpdate), user.UpdateProfileRouter)                  // 更新用户资料
@@ -93,11 +105,22 @@ func init() {
 			userRouter.PUT("/password2/reset", rbac.Require(*accession.Password2Reset), user.ResetPayPasswordRouter)      // 重置交易密码
 			userRouter.POST("/password2/reset", rbac.Require(*accession.Password2Reset), user.SendResetPayPasswordRouter) // 发送重置交易密码的邮件/短信
 			userRouter.POST("/avatar", user.UploadAvatarRouter)                                                           // 上传用户头像
+			userRouter.GET("/preferences", preference.GetRouter)                                                          // 获取通知设置
+			userRouter.PUT("/preferences", preference.UpdateRouter)                                                       // 修改通知设置
 			// 邀请人列表
 			{
 				inviteRouter := userRouter.Group("/invite")
-				inviteRouter.GET("", invite.GetInviteListByUserRouter) // 获取我已邀请的列表
-				inviteRouter.GET("/i/:invite_id", invite.GetRouter)    // 获取单条邀请记录详情
+				inviteRouter.GET("", invite.GetInviteListByUserRouter)  // 获取我已邀请的列表
+				inviteRouter.GET("/i/:invite_id", invite.GetRouter)     // 获取单条邀请记录详情
+				inviteRouter.GET("/reward", invite.GetRewardsRouter)    // 获取我得到的邀请奖励
+				inviteRouter.GET("/downline", invite.GetDownlineRouter) // 获取我每一层下线的人数
+			}
+			// 实名认证
+			{
+				kycRouter := userRouter.Group("/kyc")
+				kycRouter.GET("", kyc.GetKycListRouter)               // 获取我的实名认证申请
+				kycRouter.POST("", kyc.SubmitRouter)                  // 提交实名认证申请
+				kycRouter.POST("/document", kyc.UploadDocumentRouter) // 上传证件图片
 			}
 			// 收货地址
 			{
@@ -119,19 +142,40 @@ func init() {
 			walletRouter.GET("/w/:currency", wallet.GetWalletRouter) // 获取单个钱包的详细信息
 		}
 
+		v1.GET("/currency", currency.GetListRouter) // 获取已启用的币种
+
 		{
 			transferRouter := v1.Group("/transfer")
 			transferRouter.Use(userAuthMiddleware)
-			transferRouter.GET("", transfer.GetHistoryRouter)                                                           // 获取我的转账记录
-			transferRouter.POST("", rbac.Require(*accession.DoTransfer), middleware.AuthPayPassword, transfer.ToRouter) // 转账给某人
-			transferRouter.GET("/t/:transfer_id", transfer.GetDetailRouter)                                             // 获取单条转账详情
+			transferRouter.GET("", transfer.GetHistoryRouter)                                                                                                                      // 获取我的转账记录
+			transferRouter.POST("", rbac.Require(*accession.DoTransfer), middleware.AuthPayPassword, idempotencyMiddleware, transfer.ToRouter)                                     // 转账给某人
+			transferRouter.GET("/recipient", middleware.RateLimit("transfer-recipient", config.Transfer.PreviewLimit, config.Transfer.PreviewWindow), transfer.GetRecipientRouter) // 转账之前预览收款方
+			transferRouter.GET("/t/:transfer_id", transfer.GetDetailRouter)                                                                                                        // 获取单条转账详情
+			transferRouter.PUT("/t/:transfer_id/accept", idempotencyMiddleware, transfer.AcceptRouter)                                                                             // 收款方接受转账
+			transferRouter.PUT("/t/:transfer_id/reject", idempotencyMiddleware, transfer.RejectRouter)                                                                             // 收款方拒绝转账
+			transferRouter.GET("/schedule", transfer.GetSchedulesRouter)                                                                                                           // 获取我的定时转账
+			transferRouter.POST("/schedule", rbac.Require(*accession.DoTransfer), middleware.AuthPayPassword, idempotencyMiddleware, transfer.CreateScheduleRouter)                // 创建定时转账
+			transferRouter.PUT("/schedule/s/:schedule_id/pause", transfer.PauseScheduleRouter)                                                                                     // 暂停定时转账
+			transferRouter.PUT("/schedule/s/:schedule_id/resume", transfer.ResumeScheduleRouter)                                                                                   // 恢复定时转账
+			transferRouter.PUT("/schedule/s/:schedule_id/cancel", transfer.CancelScheduleRouter)                                                                                   // 取消定时转账
+		}
+
+		// 币种兑换
+		{
+			exchangeRouter := v1.Group("/exchange")
+			exchangeRouter.Use(userAuthMiddleware)
+			exchangeRouter.POST("/quote", rbac.Require(*accession.DoExchange), exchange.QuoteRouter)                                                                   // 获取兑换报价
+			exchangeRouter.PUT("/q/:quote_id/execute", rbac.Require(*accession.DoExchange), middleware.AuthPayPassword, idempotencyMiddleware, exchange.ExecuteRouter) // 按照报价兑换
 		}
 
 		// 财务日志
 		{
 			financeRouter := v1.Group("/finance")
 			financeRouter.Use(userAuthMiddleware)
-			financeRouter.GET("/history", finance.GetHistory) // TODO: 获取我的财务日志
+			financeRouter.GET("/history", finance.GetHistory)                             // TODO: 获取我的财务日志
+			financeRouter.GET("/statement", statement.GetStatementsRouter)                // 获取我的对账单列表
+			financeRouter.POST("/statement", statement.CreateRouter)                      // 生成对账单
+			financeRouter.GET("/statement/s/:statement_id", statement.GetStatementRouter) // 获取对账单详情
 		}
 
 		// 新闻咨询类
@@ -148,6 +192,7 @@ func init() {
 			notificationRouter.GET("", notification.GetNotificationListByUserRouter) // 获取系统通知列表
 			notificationRouter.GET("/n/:id", notification.GetRouter)                 // 获取某一条系统通知详情
 			notificationRouter.PUT("/n/:id/read", notification.ReadRouter)           // 标记通知为已读
+			notificationRouter.PUT("/read", notification.ReadAllRouter)              // 标记所有通知为已读
 		}
 
 		// 用户的个人消息, 个人消息是可以删除的
@@ -158,12 +203,29 @@ func init() {
 			messageRouter.GET("/m/:message_id", message.GetRouter)             // 获取单个消息详情
 			messageRouter.PUT("/m/:message_id/read", message.ReadRouter)       // 标记消息为已读
 			messageRouter.DELETE("/m/:message_id", message.DeleteByUserRouter) // 删除消息
+			messageRouter.PUT("/read", message.ReadAllRouter)                  // 标记所有消息为已读
+			messageRouter.DELETE("", message.DeleteBatchByUserRouter)          // 批量删除消息
+		}
+
+		// 收件箱
+		{
+			inboxRouter := v1.Group("/inbox")
+			inboxRouter.Use(userAuthMiddleware)
+			inboxRouter.GET("/unread", inbox.GetUnreadRouter) // 获取每个分类未读的数量
+		}
+
+		// 实时推送新的消息, 通知, 转账和强制登出
+		{
+			pushRouter := v1.Group("/push")
+			pushRouter.Use(userAuthMiddleware)
+			pushRouter.GET("/ws", push.WebSocketRouter) // WebSocket 推送
+			pushRouter.GET("/sse", push.SSERouter)      // Server-Sent Events 推送, 不支持 WebSocket 时使用
 		}
 
 		// 用户反馈
 		{
 			reportRouter := v1.Group("/report")
-			reportRouter.Use(userAuthMiddleware)
+			reportRouter.Use(userAuthMiddleware, idempotencyMiddleware)
 			reportRouter.GET("", report.GetListRouter)                // 获取我的反馈列表
 			reportRouter.POST("", report.CreateRouter)                // 添加一条反馈
 			reportRouter.GET("/r/:report_id", report.GetReportRouter) // 获取反馈详情
@@ -192,9 +254,10 @@ func init() {
 			v1.GET("/resource/image/:filename", resource.Image)         // 获取图片纯文本
 			v1.GET("/resource/thumbnail/:filename", resource.Thumbnail) // 获取缩略图纯文本
 			// 下载资源
-			v1.GET("/download/file/:filename", downloader.File)           // 下载文件
-			v1.GET("/download/image/:filename", downloader.Image)         // 下载图片
-			v1.GET("/download/thumbnail/:filename", downloader.Thumbnail) // 下载缩略图
+			v1.GET("/download/file/:filename", downloader.File)                                   // 下载文件
+			v1.GET("/download/image/:filename", downloader.Image)                                 // 下载图片
+			v1.GET("/download/thumbnail/:filename", downloader.Thumbnail)                         // 下载缩略图
+			v1.GET("/download/statement/:statement_id", userAuthMiddleware, downloader.Statement) // 下载自己的对账单
 			// 公共资源目录
 			v1.GET("/avatar/:filename", user.GetAvatarRouter) // 获取用户头像
 
@@ -0,0 +1,19 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+type CurrencyPure struct {
+	Code         string  `json:"code"`         // 币种代码
+	Name         string  `json:"name"`         // 币种名称
+	Scale        int32   `json:"scale"`        // 币种精度
+	Enabled      bool    `json:"enabled"`      // 是否启用
+	Transferable bool    `json:"transferable"` // 是否允许转账
+	MinAmount    *string `json:"min_amount"`   // 单笔转账的最小数量
+	MaxAmount    *string `json:"max_amount"`   // 单笔转账的最大数量
+	KycAmount    *string `json:"kyc_amount"`   // 时间窗口内累计转账超过这个数量需要实名认证
+}
+
+type Currency struct {
+	CurrencyPure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+type EmailPreview struct {
+	Name    string `json:"name"`    // 模版名称
+	Locale  string `json:"locale"`  // 实际使用的语言, 没有对应语言的模版时为默认语言
+	Subject string `json:"subject"` // 邮件标题
+	HTML    string `json:"html"`    // HTML 正文
+	Text    string `json:"text"`    // 纯文本正文
+}
+
+// 开发环境中捕获的邮件
+type CapturedEmail struct {
+	Id        string   `json:"id"`
+	From      string   `json:"from"`
+	To        []string `json:"to"`
+	Subject   string   `json:"subject"`
+	Text      string   `json:"text"`
+	HTML      string   `json:"html"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type EmailLogPure struct {
+	Id       string               `json:"id"`       // 记录ID
+	Uid      string               `json:"uid"`      // 发送给哪个用户
+	To       string               `json:"to"`       // 收件人邮箱
+	Template string               `json:"template"` // 使用的邮件模版
+	Status   model.EmailLogStatus `json:"status"`   // 状态
+	Attempts int                  `json:"attempts"` // 已经尝试发送的次数
+	Error    *string              `json:"error"`    // 最后一次发送失败的原因
+}
+
+type EmailLog struct {
+	EmailLogPure
+	SentAt    *string `json:"sent_at"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+type ExchangeQuotePure struct {
+	Id      string                    `json:"id"`      // 报价ID
+	Uid     string                    `json:"uid"`     // 用户ID
+	From    string                    `json:"from"`    // 源币种
+	To      string                    `json:"to"`      // 目标币种
+	Amount  string                    `json:"amount"`  // 兑换的源币种数量
+	Rate    string                    `json:"rate"`    // 扣除点差之后的汇率
+	Gross   string                    `json:"gross"`   // 按汇率兑换得到的目标币种数量
+	Fee     string                    `json:"fee"`     // 手续费, 目标币种
+	Receive string                    `json:"receive"` // 实际到账的目标币种数量
+	Status  model.ExchangeQuoteStatus `json:"status"`  // 状态
+}
+
+type ExchangeQuote struct {
+	ExchangeQuotePure
+	ExpiredAt string `json:"expired_at"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type ExchangeRate struct {
+	From      string `json:"from"`    // 源币种
+	To        string `json:"to"`      // 目标币种
+	Rate      string `json:"rate"`    // 中间价
+	Updater   string `json:"updater"` // 最后修改的管理员
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
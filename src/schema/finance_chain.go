@@ -0,0 +1,17 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+// 哈希链断开的位置
+type FinanceChainBreak struct {
+	Uid      string `json:"uid"`      // 用户ID
+	Currency string `json:"currency"` // 币种
+	LogId    string `json:"log_id"`   // 第一条校验失败的流水ID
+	Sequence int64  `json:"sequence"` // 该流水的序号
+	Reason   string `json:"reason"`   // 校验失败的原因
+}
+
+type FinanceChainReport struct {
+	Wallets int                 `json:"wallets"` // 校验的钱包数量
+	Logs    int                 `json:"logs"`    // 校验的流水数量
+	Broken  []FinanceChainBreak `json:"broken"`  // 哈希链断开的钱包, 每个钱包只报告第一个断开的位置
+}
@@ -0,0 +1,14 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+// 未读的数量, 用于显示收件箱角标
+type InboxUnread struct {
+	Message      int64 `json:"message"`      // 未读的个人消息数量
+	Notification int64 `json:"notification"` // 未读的系统通知数量
+	Total        int64 `json:"total"`        // 未读的总数
+}
+
+// 批量操作影响的数量
+type InboxAffected struct {
+	Count int64 `json:"count"` // 标记已读或者删除的数量
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+// 某一层下线的人数
+type InviteDownline struct {
+	Level int   `json:"level"` // 层级, 1 为直接邀请的用户
+	Count int64 `json:"count"` // 人数
+}
+
+// 邀请关系树中的一个节点
+type InviteTreeNode struct {
+	Uid       string             `json:"uid"`        // 用户ID
+	Username  string             `json:"username"`   // 用户名
+	Inviter   string             `json:"inviter"`    // 邀请人
+	Level     int                `json:"level"`      // 在树中的层级, 1 为直接邀请的用户
+	Status    model.InviteStatus `json:"status"`     // 邀请状态
+	CreatedAt string             `json:"created_at"` // 被邀请的时间
+}
+
+// 邀请人的转化漏斗
+type InviteFunnel struct {
+	Inviter    string  `json:"inviter"`    // 邀请人
+	Username   string  `json:"username"`   // 邀请人的用户名
+	Registered int64   `json:"registered"` // 注册的人数
+	Auth       int64   `json:"auth"`       // 完成认证的人数
+	Pay        int64   `json:"pay"`        // 完成首次支付的人数
+	AuthRate   float64 `json:"auth_rate"`  // 注册到认证的转化率
+	PayRate    float64 `json:"pay_rate"`   // 认证到首次支付的转化率
+	Rank       int     `json:"rank"`       // 排名, 只在排行榜中有值
+}
+
+// 可疑的邀请, 同一个邀请人的多个被邀请人使用同一个 IP 登陆
+type InviteCluster struct {
+	Inviter     string   `json:"inviter"`      // 邀请人
+	Username    string   `json:"username"`     // 邀请人的用户名
+	Ip          string   `json:"ip"`           // 共同使用的 IP
+	Invitees    []string `json:"invitees"`     // 使用这个 IP 的被邀请人
+	WithInviter bool     `json:"with_inviter"` // 邀请人自己是否也使用过这个 IP
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+type InviteRewardRulePure struct {
+	Id            string             `json:"id"`             // 规则ID
+	Milestone     model.InviteStatus `json:"milestone"`      // 里程碑
+	Currency      string             `json:"currency"`       // 奖励的币种
+	InviterAmount string             `json:"inviter_amount"` // 邀请人的奖励
+	InviteeAmount string             `json:"invitee_amount"` // 被邀请人的奖励
+	Enabled       bool               `json:"enabled"`        // 是否启用
+	Updater       string             `json:"updater"`        // 最后修改的管理员
+}
+
+type InviteRewardRule struct {
+	InviteRewardRulePure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type InviteRewardPure struct {
+	Id            string             `json:"id"`             // 奖励ID
+	InviteId      string             `json:"invite_id"`      // 对应的邀请记录
+	Milestone     model.InviteStatus `json:"milestone"`      // 达到的里程碑
+	Currency      string             `json:"currency"`       // 奖励的币种
+	Inviter       string             `json:"inviter"`        // 邀请人
+	Invitee       string             `json:"invitee"`        // 被邀请人
+	InviterAmount string             `json:"inviter_amount"` // 邀请人得到的奖励
+	InviteeAmount string             `json:"invitee_amount"` // 被邀请人得到的奖励
+}
+
+type InviteReward struct {
+	InviteRewardPure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+type KycPure struct {
+	Id       string          `json:"id"`        // 申请ID
+	Uid      string          `json:"uid"`       // 申请的用户
+	Level    int32           `json:"level"`     // 申请的认证等级
+	Name     string          `json:"name"`      // 真实姓名
+	IdType   model.KycIdType `json:"id_type"`   // 证件类型
+	IdNumber string          `json:"id_number"` // 证件号码
+	Images   []string        `json:"images"`    // 证件图片的地址
+	Status   model.KycStatus `json:"status"`    // 状态
+	Reason   *string         `json:"reason"`    // 拒绝的原因
+	Reviewer *string         `json:"reviewer"`  // 审核的管理员
+}
+
+type Kyc struct {
+	KycPure
+	ReviewedAt *string `json:"reviewed_at"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+type KycDocument struct {
+	Filename  string `json:"filename"`   // 存储在服务端的文件名, 提交申请时使用
+	Origin    string `json:"origin"`     // 上传文件的原始名
+	Size      int64  `json:"size"`       // 文件大小
+	CreatedAt string `json:"created_at"` // 上传时间
+}
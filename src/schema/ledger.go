@@ -0,0 +1,27 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+// 钱包与分录不一致的明细
+type LedgerDrift struct {
+	Uid            string `json:"uid"`             // 用户ID
+	Currency       string `json:"currency"`        // 币种
+	Balance        string `json:"balance"`         // 钱包的可用余额
+	Frozen         string `json:"frozen"`          // 钱包的冻结余额
+	JournalBalance string `json:"journal_balance"` // 根据分录计算出的可用余额
+	JournalFrozen  string `json:"journal_frozen"`  // 根据分录计算出的冻结余额
+}
+
+type LedgerReconciliationDetail struct {
+	Drifts             []LedgerDrift `json:"drifts"`              // 不一致的钱包
+	UnbalancedPostings []string      `json:"unbalanced_postings"` // 借贷不平衡的凭证ID
+}
+
+type LedgerReconciliation struct {
+	Id                 string                     `json:"id"`                  // 报告ID
+	Wallets            int                        `json:"wallets"`             // 检查的钱包数量
+	Drifts             int                        `json:"drifts"`              // 不一致的钱包数量
+	UnbalancedPostings int                        `json:"unbalanced_postings"` // 借贷不平衡的凭证数量
+	Detail             LedgerReconciliationDetail `json:"detail"`              // 明细
+	StartedAt          string                     `json:"started_at"`          // 开始对账的时间
+	CreatedAt          string                     `json:"created_at"`          // 对账完成的时间
+}
@@ -0,0 +1,17 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+type MessageTemplatePure struct {
+	Id      string  `json:"id"`      // 模版ID
+	Event   string  `json:"event"`   // 事件名称
+	Locale  string  `json:"locale"`  // 语言
+	Title   string  `json:"title"`   // 标题模版
+	Content string  `json:"content"` // 内容模版
+	Note    *string `json:"note"`    // 备注
+}
+
+type MessageTemplate struct {
+	MessageTemplatePure
+	CreatedAt string `json:"created_at"` // 创建时间
+	UpdatedAt string `json:"updated_at"` // 更新时间
+}
@@ -13,21 +13,37 @@ type NotificationPure struct {
 
 type Notification struct {
 	NotificationPure
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	PublishAt *string `json:"publish_at"` // 发布时间
+	ExpireAt  *string `json:"expire_at"`  // 过期时间
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
 }
 
 // 这是管理员获取的接口
 type NotificationPureAdmin struct {
-	Id      string  `json:"id"`
-	Author  string  `json:"author"`
-	Title   string  `json:"title"`
-	Content string  `json:"content"`
-	Note    *string `json:"note"`
+	Id          string   `json:"id"`
+	Author      string   `json:"author"`
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	Note        *string  `json:"note"`
+	TargetRoles []string `json:"target_roles"` // 指定角色
+	TargetUsers []string `json:"target_users"` // 指定用户
+	MinLevel    *int32   `json:"min_level"`    // 用户等级的下限
+	MaxLevel    *int32   `json:"max_level"`    // 用户等级的上限
 }
 
 type NotificationAdmin struct {
 	NotificationPureAdmin
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	RegisteredAfter  *string `json:"registered_after"`  // 注册时间的开始
+	RegisteredBefore *string `json:"registered_before"` // 注册时间的结束
+	PublishAt        *string `json:"publish_at"`        // 发布时间
+	ExpireAt         *string `json:"expire_at"`         // 过期时间
+	PushedAt         *string `json:"pushed_at"`         // 推送给在线用户的时间
+	CreatedAt        string  `json:"created_at"`
+	UpdatedAt        string  `json:"updated_at"`
+}
+
+// 通知对象的预览
+type NotificationTargetPreview struct {
+	Count int64 `json:"count"` // 匹配的用户数量
 }
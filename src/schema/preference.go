@@ -0,0 +1,17 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+// 用户对某个事件的某个渠道的设置
+type NotificationSubscription struct {
+	Event   string `json:"event"`   // 事件名称
+	Channel string `json:"channel"` // 通知渠道
+	Enabled bool   `json:"enabled"` // 是否开启
+}
+
+// 用户的通知设置
+type NotificationPreference struct {
+	QuietStart    *string                    `json:"quiet_start"`   // 免打扰的开始时间
+	QuietEnd      *string                    `json:"quiet_end"`     // 免打扰的结束时间
+	Timezone      string                     `json:"timezone"`      // 免打扰时间使用的时区
+	Subscriptions []NotificationSubscription `json:"subscriptions"` // 每个事件每个渠道的设置
+}
@@ -13,6 +13,8 @@ type ProfilePure struct {
 	Role       []string `json:"role"`
 	Level      int32    `json:"level"`
 	InviteCode string   `json:"invite_code"`
+	KycLevel   int32    `json:"kyc_level"` // 实名认证的等级, 0 表示未认证
+	Locale     *string  `json:"locale"`    // 用户使用的语言
 }
 
 type ProfileWithToken struct {
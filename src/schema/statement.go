@@ -0,0 +1,35 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+type StatementPure struct {
+	Id       string                `json:"id"`       // 对账单ID
+	Uid      string                `json:"uid"`      // 哪个用户的钱包
+	Currency string                `json:"currency"` // 币种
+	Format   model.StatementFormat `json:"format"`   // 文件格式, csv/pdf
+	Status   model.StatementStatus `json:"status"`   // 状态
+	Filename *string               `json:"filename"` // 生成的文件名
+	Error    *string               `json:"error"`    // 生成失败的原因
+	Creator  string                `json:"creator"`  // 由谁发起
+}
+
+type Statement struct {
+	StatementPure
+	StartAt   string `json:"start_at"`
+	EndAt     string `json:"end_at"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// 对账单中的一条流水
+type StatementRow struct {
+	Time         string  `json:"time"`         // 发生时间
+	Type         string  `json:"type"`         // 流水类型
+	OrderId      string  `json:"order_id"`     // 对应的订单
+	Counterparty string  `json:"counterparty"` // 交易对方
+	Amount       string  `json:"amount"`       // 可用余额的变动
+	Frozen       string  `json:"frozen"`       // 冻结余额的变动
+	Balance      string  `json:"balance"`      // 这条流水后的可用余额
+	Note         *string `json:"note"`         // 备注
+}
@@ -11,10 +11,16 @@ type TransferLogPure struct {
 	Amount   string               `json:"amount"`   // 转账数量
 	Status   model.TransferStatus `json:"status"`   // 转账状态
 	Note     *string              `json:"string"`   // 转账备注
+
+	HoldReason *string `json:"hold_reason"` // 触发的风控规则
+	Reviewer   *string `json:"reviewer"`    // 风控审核或者撤回的管理员
+	ReversalOf *string `json:"reversal_of"` // 撤回产生的反向转账, 对应原来的转账ID
 }
 
 type TransferLog struct {
 	TransferLogPure
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ExpiredAt  *string `json:"expired_at"`  // 等待收款方确认的过期时间
+	ReviewedAt *string `json:"reviewed_at"` // 风控审核时间
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
 }
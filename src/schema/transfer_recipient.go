@@ -0,0 +1,8 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+// 转账之前预览的收款方信息, 不包含用户ID等可以定位到账号的信息
+type TransferRecipient struct {
+	DisplayName string `json:"display_name"` // 隐藏了部分字符的昵称或者用户名
+	Avatar      string `json:"avatar"`       // 头像
+}
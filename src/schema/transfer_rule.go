@@ -0,0 +1,20 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+type TransferRulePure struct {
+	Id           string  `json:"id"`            // 规则ID
+	Currency     string  `json:"currency"`      // 币种
+	Level        int32   `json:"level"`         // 用户等级, 0 表示所有等级
+	MinAmount    *string `json:"min_amount"`    // 单笔最小数量
+	MaxAmount    *string `json:"max_amount"`    // 单笔最大数量
+	DailyLimit   *string `json:"daily_limit"`   // 每天的累计转出数量
+	MonthlyLimit *string `json:"monthly_limit"` // 每月的累计转出数量
+	HourlyCount  *int    `json:"hourly_count"`  // 一小时内的转账次数
+	Updater      string  `json:"updater"`       // 最后修改的管理员
+}
+
+type TransferRule struct {
+	TransferRulePure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
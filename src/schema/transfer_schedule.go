@@ -0,0 +1,29 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+type TransferSchedulePure struct {
+	Id             string                       `json:"id"`               // 定时转账ID
+	Uid            string                       `json:"uid"`              // 转账方
+	Currency       string                       `json:"currency"`         // 币种
+	To             string                       `json:"to"`               // 收款方
+	Amount         string                       `json:"amount"`           // 每次转账的数量
+	Note           *string                      `json:"note"`             // 转账备注
+	Confirm        bool                         `json:"confirm"`          // 是否需要收款方确认
+	Recurrence     *string                      `json:"recurrence"`       // 重复规则, 为空表示只执行一次
+	Status         model.TransferScheduleStatus `json:"status"`           // 状态
+	RunCount       int                          `json:"run_count"`        // 已成功执行的次数
+	Attempts       int                          `json:"attempts"`         // 本次已经失败的次数
+	LastTransferId *string                      `json:"last_transfer_id"` // 最后一次产生的转账记录
+	LastError      *string                      `json:"last_error"`       // 最后一次失败的原因
+}
+
+type TransferSchedule struct {
+	TransferSchedulePure
+	StartAt   string  `json:"start_at"`
+	NextRunAt *string `json:"next_run_at"`
+	LastRunAt *string `json:"last_run_at"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
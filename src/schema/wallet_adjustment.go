@@ -0,0 +1,23 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/src/model"
+
+type WalletAdjustmentPure struct {
+	Id       string                       `json:"id"`       // 调整记录ID
+	Uid      string                       `json:"uid"`      // 被调整的用户
+	Currency string                       `json:"currency"` // 币种
+	Type     model.FinanceType            `json:"type"`     // 调整类型
+	Amount   string                       `json:"amount"`   // 调整数量
+	Reason   string                       `json:"reason"`   // 调整原因
+	Status   model.WalletAdjustmentStatus `json:"status"`   // 状态
+	Creator  string                       `json:"creator"`  // 发起调整的管理员
+	Reviewer *string                      `json:"reviewer"` // 审核的管理员
+}
+
+type WalletAdjustment struct {
+	WalletAdjustmentPure
+	ReviewedAt *string `json:"reviewed_at"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
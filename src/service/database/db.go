@@ -33,30 +33,73 @@ func init() {
 
 		// Migrate the schema
 		db.AutoMigrate(
-			new(model.Admin),            // 管理员表
-			new(model.News),             // 新闻公告
-			new(model.User),             // 用户表
-			new(model.Role),             // 角色表 - RBAC
-			new(model.WalletCny),        // 钱包 - CNY
-			new(model.WalletUsd),        // 钱包 - USD
-			new(model.WalletCoin),       // 钱包 - COIN
-			new(model.InviteHistory),    // 邀请表
-			new(model.LoginLog),         // 登陆成功表
-			new(model.TransferLogCny),   // 转账记录 - CNY
-			new(model.TransferLogUsd),   // 转账记录 - USD
-			new(model.TransferLogCoin),  // 转账记录 - COIN
-			new(model.FinanceLogCny),    // 流水列表 - CNY
-			new(model.FinanceLogUsd),    // 流水列表 - USD
-			new(model.FinanceLogCoin),   // 流水列表 - COIN
-			new(model.Notification),     // 系统消息
-			new(model.NotificationMark), // 系统消息的已读记录
-			new(model.Message),          // 个人消息
-			new(model.Address),          // 收货地址
-			new(model.Banner),           // Banner 表
-			new(model.Report),           // 反馈表
-			new(model.Menu),             // 后台管理员菜单
+			new(model.Admin),                    // 管理员表
+			new(model.News),                     // 新闻公告
+			new(model.User),                     // 用户表
+			new(model.Role),                     // 角色表 - RBAC
+			new(model.Currency),                 // 币种表
+			new(model.Wallet),                   // 钱包
+			new(model.InviteHistory),            // 邀请表
+			new(model.InviteRewardRule),         // 邀请奖励规则
+			new(model.InviteReward),             // 已发放的邀请奖励
+			new(model.LoginLog),                 // 登陆成功表
+			new(model.Kyc),                      // 实名认证申请
+			new(model.KycDocument),              // 实名认证的证件图片
+			new(model.TransferLog),              // 转账记录
+			new(model.TransferRule),             // 转账风控规则
+			new(model.TransferSchedule),         // 定时转账
+			new(model.FinanceLog),               // 流水列表
+			new(model.FinanceCheckpoint),        // 流水哈希链的检查点
+			new(model.Notification),             // 系统消息
+			new(model.NotificationMark),         // 系统消息的已读记录
+			new(model.Message),                  // 个人消息
+			new(model.MessageTemplate),          // 个人消息的模版
+			new(model.NotificationPreference),   // 用户的通知设置
+			new(model.NotificationSubscription), // 用户对每个事件的通知渠道设置
+			new(model.Address),                  // 收货地址
+			new(model.Banner),                   // Banner 表
+			new(model.Report),                   // 反馈表
+			new(model.Menu),                     // 后台管理员菜单
+			new(model.WalletAdjustment),         // 管理员的钱包调整记录
+			new(model.LedgerPosting),            // 记账凭证
+			new(model.LedgerEntry),              // 记账分录
+			new(model.LedgerReconciliation),     // 对账报告
+			new(model.ExchangeRate),             // 汇率表
+			new(model.ExchangeQuote),            // 兑换报价
+			new(model.Statement),                // 对账单
+			new(model.EmailLog),                 // 邮件发送记录
 		)
 
+		// 已读记录的主键从通知 ID 改为通知 ID 和 UID
+		if err := migratePrimaryKey(db, "notification_mark", "id", "uid"); err != nil {
+			panic(err)
+		}
+
+		// 把旧的按币种分表的数据迁移到统一的表
+		if err := migrateCurrencyTables(db); err != nil {
+			panic(err)
+		}
+
+		// 为启用复式记账之前已有余额的钱包生成期初分录
+		if err := migrateLedgerOpening(db); err != nil {
+			panic(err)
+		}
+
+		// 为加入哈希链之前的流水补上哈希
+		if err := migrateFinanceChain(db); err != nil {
+			panic(err)
+		}
+
+		// 把旧版本的哈希链迁移到 HMAC
+		if err := migrateFinanceHmac(db); err != nil {
+			panic(err)
+		}
+
+		// 为已有的哈希链生成检查点
+		if err := migrateFinanceCheckpoint(db); err != nil {
+			panic(err)
+		}
+
 		fmt.Println("数据库同步完成.")
 	}
 
@@ -82,6 +125,20 @@ func init() {
 		}
 	}
 
+	// 确保内置的币种存在
+	for _, c := range model.DefaultCurrencies {
+		currency := c
+		if err := db.Where("code = ?", currency.Code).First(&model.Currency{}).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				if err = db.Create(&currency).Error; err != nil {
+					panic(err)
+				}
+			} else {
+				panic(err)
+			}
+		}
+	}
+
 	defaultRole := model.Role{Name: model.DefaultUser.Name}
 
 	// 确保有默认的角色
@@ -0,0 +1,435 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+// 旧版本每个币种都有独立的钱包/转账记录/流水表, 例如 wallet_cny, transfer_log_cny, finance_log_cny
+// 迁移到以币种字段区分的统一表之后删除旧表. 已迁移过的表不存在, 所以可以重复执行
+// optional 是旧表中可能还没有的字段, 旧表没有这个字段时使用对应的默认值
+var legacyCurrencyTables = []struct {
+	prefix   string
+	table    string
+	columns  []string
+	optional map[string]string
+}{
+	{
+		prefix:  "wallet_",
+		table:   "wallet",
+		columns: []string{"id", "balance", "frozen", "created_at", "updated_at", "deleted_at"},
+	},
+	{
+		prefix:  "transfer_log_",
+		table:   "transfer_log",
+		columns: []string{"id", "from", "to", "amount", "status", "note", "snapshot_from", "snapshot_to", "created_at", "updated_at", "deleted_at"},
+		optional: map[string]string{
+			"expired_at":   "NULL",
+			"need_confirm": "false",
+		},
+	},
+	{
+		prefix:  "finance_log_",
+		table:   "finance_log",
+		columns: []string{"id", "order_id", "uid", "before_balance", "balance_mutation", "after_balance", "before_frozen", "frozen_mutation", "after_frozen", "type", "note", "created_at", "updated_at", "deleted_at"},
+	},
+}
+
+func migrateCurrencyTables(db *gorm.DB) (err error) {
+	for _, currency := range model.Wallets {
+		for _, t := range legacyCurrencyTables {
+			legacyTable := t.prefix + strings.ToLower(currency)
+
+			if db.HasTable(legacyTable) == false {
+				continue
+			}
+
+			fmt.Printf("正在迁移 %s 到 %s...\n", legacyTable, t.table)
+
+			if err = migrateTable(db, legacyTable, t.table, currency, t.columns, t.optional); err != nil {
+				return fmt.Errorf("迁移 %s 到 %s 失败: %v", legacyTable, t.table, err)
+			}
+		}
+	}
+
+	return
+}
+
+// 获取表中实际存在的字段
+func tableColumns(db *gorm.DB, table string) (columns map[string]bool, err error) {
+	var rows *sql.Rows
+
+	if rows, err = db.Raw(`SELECT column_name FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = ?`, table).Rows(); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	columns = map[string]bool{}
+
+	for rows.Next() {
+		var name string
+
+		if err = rows.Scan(&name); err != nil {
+			return
+		}
+
+		columns[name] = true
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func migrateTable(db *gorm.DB, from string, to string, currency string, columns []string, optional map[string]string) (err error) {
+	tx := db.Begin()
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	var exists map[string]bool
+
+	if exists, err = tableColumns(tx, from); err != nil {
+		return
+	}
+
+	targets := make([]string, 0, len(columns)+len(optional))
+	sources := make([]string, 0, len(columns)+len(optional))
+
+	for _, column := range columns {
+		targets = append(targets, fmt.Sprintf(`"%s"`, column))
+		sources = append(sources, fmt.Sprintf(`"%s"`, column))
+	}
+
+	for column, fallback := range optional {
+		targets = append(targets, fmt.Sprintf(`"%s"`, column))
+
+		if exists[column] {
+			sources = append(sources, fmt.Sprintf(`"%s"`, column))
+		} else {
+			sources = append(sources, fallback)
+		}
+	}
+
+	// 旧表的币种字段可能是小写的, 统一使用大写的币种代码
+	// 不忽略主键冲突, 统一表中已存在相同 ID 的记录说明数据有问题, 需要人工处理
+	raw := fmt.Sprintf(`INSERT INTO "%s" ("currency", %s) SELECT ?, %s FROM "%s"`, to, strings.Join(targets, ", "), strings.Join(sources, ", "), from)
+
+	if err = tx.Exec(raw, currency).Error; err != nil {
+		return
+	}
+
+	if err = tx.DropTable(from).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+// 启用复式记账之前已有余额的钱包没有任何分录, 对账时会被当成不一致
+// 为这些钱包生成一条期初凭证, 从期初系统账户转入钱包当前的余额. 已有分录的钱包会被跳过, 所以可以重复执行
+func migrateLedgerOpening(db *gorm.DB) (err error) {
+	list := make([]model.Wallet, 0)
+
+	if err = db.Where(`(balance <> 0 OR frozen <> 0) AND NOT EXISTS (SELECT 1 FROM "ledger_entry" e WHERE e.account = "wallet".id AND e.currency = "wallet".currency)`).Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, w := range list {
+		if err = openLedger(db, w); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func openLedger(db *gorm.DB, w model.Wallet) (err error) {
+	tx := db.Begin()
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	posting := model.LedgerPosting{
+		Currency: w.Currency,
+		OrderId:  w.Id,
+		Type:     model.FinanceTypeOpening,
+	}
+
+	if err = tx.Create(&posting).Error; err != nil {
+		return
+	}
+
+	entries := []model.LedgerEntry{
+		{Account: model.LedgerAccountOpening, Bucket: model.LedgerBucketBalance, Direction: model.LedgerDirectionDebit, Amount: w.Balance.Add(w.Frozen)},
+		{Account: w.Id, Bucket: model.LedgerBucketBalance, Direction: model.LedgerDirectionCredit, Amount: w.Balance},
+		{Account: w.Id, Bucket: model.LedgerBucketFrozen, Direction: model.LedgerDirectionCredit, Amount: w.Frozen},
+	}
+
+	for _, entry := range entries {
+		if entry.Amount.IsZero() {
+			continue
+		}
+
+		entry.PostingId = posting.Id
+		entry.Currency = w.Currency
+
+		if err = tx.Create(&entry).Error; err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// 为加入哈希链之前的流水补上序号和哈希
+// 只处理还没有任何流水加入哈希链的钱包, 已经开始哈希链的钱包不会被修改
+func migrateFinanceChain(db *gorm.DB) (err error) {
+	type wallet struct {
+		Uid      string
+		Currency string
+	}
+
+	wallets := make([]wallet, 0)
+
+	if err = db.Unscoped().Model(&model.FinanceLog{}).Select("uid, currency").Group("uid, currency").Having("MAX(sequence) = 0").Scan(&wallets).Error; err != nil {
+		return
+	}
+
+	for _, w := range wallets {
+		if err = chainFinanceLog(db, w.Uid, w.Currency); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func chainFinanceLog(db *gorm.DB, uid string, currency string) (err error) {
+	tx := db.Begin()
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	list := make([]model.FinanceLog, 0)
+
+	if err = tx.Unscoped().Where("uid = ? AND currency = ?", uid, currency).Order("created_at ASC, id ASC").Find(&list).Error; err != nil {
+		return
+	}
+
+	prevHash := ""
+
+	for i, log := range list {
+		log.Sequence = int64(i + 1)
+		log.PrevHash = prevHash
+		log.Hash = log.ComputeHash(config.Finance.HashSecret)
+
+		if err = tx.Unscoped().Model(&model.FinanceLog{}).Where("id = ?", log.Id).UpdateColumns(map[string]interface{}{
+			"sequence":  log.Sequence,
+			"prev_hash": log.PrevHash,
+			"hash":      log.Hash,
+		}).Error; err != nil {
+			return
+		}
+
+		prevHash = log.Hash
+	}
+
+	return
+}
+
+// 旧版本的哈希链使用不带密钥的 SHA256, 重新计算为 HMAC
+// 第一条流水的哈希与旧版本的哈希一致时才迁移, 已经迁移过的钱包会被跳过, 所以可以重复执行
+func migrateFinanceHmac(db *gorm.DB) (err error) {
+	list := make([]model.FinanceLog, 0)
+
+	if err = db.Unscoped().Where("sequence = 1").Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, log := range list {
+		if log.Hash != log.LegacyHash() {
+			continue
+		}
+
+		if err = rehashFinanceLog(db, log.Uid, log.Currency); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func rehashFinanceLog(db *gorm.DB, uid string, currency string) (err error) {
+	tx := db.Begin()
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	list := make([]model.FinanceLog, 0)
+
+	if err = tx.Unscoped().Where("uid = ? AND currency = ?", uid, currency).Order("sequence ASC, id ASC").Find(&list).Error; err != nil {
+		return
+	}
+
+	legacyHash := ""
+
+	// 已经断开的哈希链不能迁移, 否则迁移之后就无法再发现之前的修改
+	for i, log := range list {
+		if log.Sequence != int64(i+1) || log.PrevHash != legacyHash || log.Hash != log.LegacyHash() {
+			fmt.Printf("%s 的 %s 流水哈希链在第 %d 条断开, 跳过迁移\n", uid, currency, log.Sequence)
+			return
+		}
+
+		legacyHash = log.Hash
+	}
+
+	prevHash := ""
+
+	for _, log := range list {
+		log.PrevHash = prevHash
+		log.Hash = log.ComputeHash(config.Finance.HashSecret)
+
+		if err = tx.Unscoped().Model(&model.FinanceLog{}).Where("id = ?", log.Id).UpdateColumns(map[string]interface{}{
+			"prev_hash": log.PrevHash,
+			"hash":      log.Hash,
+		}).Error; err != nil {
+			return
+		}
+
+		prevHash = log.Hash
+	}
+
+	// 检查点与迁移之前的哈希链一致时才更新, 不一致的检查点保留下来由校验发现
+	checkpoint := model.FinanceCheckpoint{
+		Uid:      uid,
+		Currency: currency,
+		Sequence: int64(len(list)),
+		Hash:     prevHash,
+	}
+
+	err = tx.Model(&model.FinanceCheckpoint{}).Where("uid = ? AND currency = ? AND sequence = ? AND hash = ?", uid, currency, checkpoint.Sequence, legacyHash).UpdateColumns(map[string]interface{}{
+		"hash":      checkpoint.Hash,
+		"signature": checkpoint.Sign(config.Finance.HashSecret),
+	}).Error
+
+	return
+}
+
+// 为没有检查点的钱包生成检查点, 取该钱包最后一条流水的序号和哈希. 已有检查点的钱包会被跳过, 所以可以重复执行
+// 之前没有签名的检查点补上签名
+func migrateFinanceCheckpoint(db *gorm.DB) (err error) {
+	if err = db.Exec(`INSERT INTO "finance_checkpoint" ("uid", "currency", "sequence", "hash", "updated_at") SELECT DISTINCT ON ("uid", "currency") "uid", "currency", "sequence", "hash", NOW() FROM "finance_log" WHERE "sequence" > 0 ORDER BY "uid", "currency", "sequence" DESC ON CONFLICT ("uid", "currency") DO NOTHING`).Error; err != nil {
+		return
+	}
+
+	list := make([]model.FinanceCheckpoint, 0)
+
+	if err = db.Where("signature = ''").Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, checkpoint := range list {
+		if err = db.Model(&model.FinanceCheckpoint{}).Where("uid = ? AND currency = ?", checkpoint.Uid, checkpoint.Currency).UpdateColumn("signature", checkpoint.Sign(config.Finance.HashSecret)).Error; err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// 获取表的主键约束名和主键字段, 没有主键时约束名为空
+func primaryKey(db *gorm.DB, table string) (name string, columns []string, err error) {
+	var rows *sql.Rows
+
+	if rows, err = db.Raw(`SELECT c.conname, a.attname FROM pg_constraint c JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY(c.conkey) WHERE c.conrelid = to_regclass(?) AND c.contype = 'p' ORDER BY array_position(c.conkey, a.attnum)`, table).Rows(); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var column string
+
+		if err = rows.Scan(&name, &column); err != nil {
+			return
+		}
+
+		columns = append(columns, column)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// AutoMigrate 不会修改已存在的表的主键, 主键字段变化时需要删除旧的主键约束再重新创建
+// 主键已经是这些字段时跳过, 所以可以重复执行
+func migratePrimaryKey(db *gorm.DB, table string, columns ...string) (err error) {
+	var (
+		name    string
+		current []string
+	)
+
+	if name, current, err = primaryKey(db, table); err != nil {
+		return
+	}
+
+	if strings.Join(current, ",") == strings.Join(columns, ",") {
+		return
+	}
+
+	fmt.Printf("正在修改 %s 的主键为 (%s)...\n", table, strings.Join(columns, ", "))
+
+	tx := db.Begin()
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	if name != "" {
+		if err = tx.Exec(fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT "%s"`, table, name)).Error; err != nil {
+			return
+		}
+	}
+
+	quoted := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		quoted = append(quoted, fmt.Sprintf(`"%s"`, column))
+	}
+
+	err = tx.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD PRIMARY KEY (%s)`, table, strings.Join(quoted, ", "))).Error
+
+	return
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package database
+
+import (
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// 旧版本 transfer_log_cny 的表结构, 没有 expired_at 和 need_confirm 字段
+const legacyTransferLogTable = `CREATE TABLE "transfer_log_cny" (
+	"id" varchar(32) NOT NULL PRIMARY KEY,
+	"currency" text NOT NULL,
+	"from" varchar(32) NOT NULL,
+	"to" varchar(32) NOT NULL,
+	"amount" numeric NOT NULL,
+	"status" integer NOT NULL,
+	"note" varchar(128),
+	"snapshot_from" text,
+	"snapshot_to" text,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone
+)`
+
+func createLegacyTransferLog(t *testing.T, id string) {
+	assert.Nil(t, Db.Exec(legacyTransferLogTable).Error)
+	assert.Nil(t, Db.Exec(`INSERT INTO "transfer_log_cny" ("id", "currency", "from", "to", "amount", "status", "note", "created_at", "updated_at") VALUES (?, 'cny', 'from', 'to', 10, ?, 'legacy', NOW(), NOW())`, id, model.TransferStatusConfirmed).Error)
+}
+
+func TestMigrateCurrencyTables(t *testing.T) {
+	id := util.GenerateId()
+
+	defer DeleteRowByTable("transfer_log", "id", id)
+	defer Db.DropTableIfExists("transfer_log_cny")
+
+	createLegacyTransferLog(t, id)
+
+	assert.Nil(t, migrateCurrencyTables(Db))
+
+	// 旧表已删除
+	assert.False(t, Db.HasTable("transfer_log_cny"))
+
+	log := model.TransferLog{}
+
+	assert.Nil(t, Db.Where("id = ?", id).First(&log).Error)
+	assert.Equal(t, model.WalletCNY, log.Currency)
+	assert.Equal(t, "from", log.From)
+	assert.Equal(t, "to", log.To)
+	assert.Equal(t, model.TransferStatusConfirmed, log.Status)
+	assert.Equal(t, "legacy", *log.Note)
+	assert.Nil(t, log.ExpiredAt)
+	assert.False(t, log.NeedConfirm)
+}
+
+func TestMigrateCurrencyTablesConflict(t *testing.T) {
+	id := util.GenerateId()
+
+	defer DeleteRowByTable("transfer_log", "id", id)
+	defer Db.DropTableIfExists("transfer_log_cny")
+
+	// 统一表中已存在相同 ID 的记录
+	assert.Nil(t, Db.Exec(`INSERT INTO "transfer_log" ("id", "currency", "from", "to", "amount", "status", "created_at", "updated_at") VALUES (?, ?, 'a', 'b', 1, ?, NOW(), NOW())`, id, model.WalletCNY, model.TransferStatusConfirmed).Error)
+
+	createLegacyTransferLog(t, id)
+
+	// 主键冲突时迁移失败, 旧表保留
+	assert.NotNil(t, migrateCurrencyTables(Db))
+	assert.True(t, Db.HasTable("transfer_log_cny"))
+
+	log := model.TransferLog{}
+
+	assert.Nil(t, Db.Where("id = ?", id).First(&log).Error)
+	assert.Equal(t, "a", log.From)
+}
+
+func TestMigratePrimaryKey(t *testing.T) {
+	defer Db.DropTableIfExists("notification_mark_legacy")
+
+	// 旧版本的已读记录只以通知 ID 为主键
+	assert.Nil(t, Db.Exec(`CREATE TABLE "notification_mark_legacy" ("id" varchar(32) NOT NULL PRIMARY KEY, "uid" varchar(32) NOT NULL)`).Error)
+	assert.Nil(t, Db.Exec(`INSERT INTO "notification_mark_legacy" ("id", "uid") VALUES ('n1', 'u1')`).Error)
+
+	// 不同的用户不能标记同一条通知
+	assert.NotNil(t, Db.Exec(`INSERT INTO "notification_mark_legacy" ("id", "uid") VALUES ('n1', 'u2')`).Error)
+
+	assert.Nil(t, migratePrimaryKey(Db, "notification_mark_legacy", "id", "uid"))
+
+	_, columns, err := primaryKey(Db, "notification_mark_legacy")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "uid"}, columns)
+
+	assert.Nil(t, Db.Exec(`INSERT INTO "notification_mark_legacy" ("id", "uid") VALUES ('n1', 'u2')`).Error)
+	assert.NotNil(t, Db.Exec(`INSERT INTO "notification_mark_legacy" ("id", "uid") VALUES ('n1', 'u1')`).Error)
+
+	// 重复执行不会有变化
+	assert.Nil(t, migratePrimaryKey(Db, "notification_mark_legacy", "id", "uid"))
+}
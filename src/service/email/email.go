@@ -2,26 +2,19 @@
 package email
 
 import (
-	"crypto/tls"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/axetroy/go-server/src/config"
 	"github.com/jordan-wright/email"
-	"net/smtp"
 	"net/textproto"
 )
 
-const (
-	prefix                 = "[GOTEST]: "
-	tmpActivation          = `<a href="javascript: void 0">点击这里激活</a>或使用激活码: %v`
-	tmpForgotPassword      = `<a href="javascript: void 0">点击连接重置密码</a>或使用重置码: %v`
-	tmpForgotTradePassword = `<a href="javascript: void 0">点击连接重置交易密码</a>或使用重置码: %v`
-)
-
 var Config = config.SMTP
 
 type Mailer struct {
-	Auth *smtp.Auth
+	Transport Transport // 发送邮件的方式
 }
 
 type Message struct {
@@ -39,15 +32,8 @@ type Message struct {
 }
 
 func NewMailer() *Mailer {
-	// Set up authentication information.
-	auth := smtp.PlainAuth(
-		"",
-		Config.Username,
-		Config.Password,
-		Config.Host,
-	)
 	return &Mailer{
-		Auth: &auth,
+		Transport: transport,
 	}
 }
 
@@ -66,27 +52,26 @@ func (e *Mailer) Send(message *Message) (err error) {
 		Headers: textproto.MIMEHeader{},
 	}
 
-	var addr = Config.Host + ":" + Config.Port
-
-	if err = msg.SendWithTLS(
-		addr,
-		*e.Auth, &tls.Config{
-			ServerName:         Config.Host,
-			InsecureSkipVerify: true,
-		}); err != nil {
+	if err = e.Transport.Send(msg); err != nil {
 		return
 	}
 
 	return nil
 }
 
-// 发送激活邮件
-func (e *Mailer) SendActivationEmail(toEmail string, code string) (err error) {
+// 用模版渲染并发送邮件
+func (e *Mailer) SendTemplate(toEmail string, locale string, name string, data map[string]interface{}) (err error) {
+	var c *Content
+
+	if c, err = Render(locale, name, data); err != nil {
+		return
+	}
+
 	if err = e.Send(&Message{
 		To:      []string{toEmail},
-		Subject: prefix + "账号激活",
-		Text:    []byte("请点击连接激活您的账号"),
-		HTML:    []byte(fmt.Sprintf(tmpActivation, code)),
+		Subject: c.Subject,
+		Text:    []byte(c.Text),
+		HTML:    []byte(c.HTML),
 	}); err != nil {
 		return
 	}
@@ -94,30 +79,48 @@ func (e *Mailer) SendActivationEmail(toEmail string, code string) (err error) {
 	return nil
 }
 
-// 发送忘记密码邮件
-func (e *Mailer) SendForgotPasswordEmail(toEmail string, code string) (err error) {
-	if err = e.Send(&Message{
-		To:      []string{toEmail},
-		Subject: prefix + "忘记登陆密码",
-		Text:    []byte("请点击重置您的登陆密码"),
-		HTML:    []byte(fmt.Sprintf(tmpForgotPassword, code)),
-	}); err != nil {
-		return
+// 生成新的激活码, 不能根据用户 ID 推算出来
+func NewActivationCode() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
 
-	return nil
+	return "activation-" + hex.EncodeToString(b), nil
 }
 
-// 发送忘记交易密码邮件
-func (e *Mailer) SendForgotTradePasswordEmail(toEmail string, code string) (err error) {
-	if err = e.Send(&Message{
-		To:      []string{toEmail},
-		Subject: prefix + "忘记交易密码",
-		Text:    []byte("请点击连接激活您的账号"),
-		HTML:    []byte(fmt.Sprintf(tmpForgotTradePassword, code)),
-	}); err != nil {
-		return
+// 生成新的重置码, 用于重置登陆密码和交易密码
+func NewResetCode() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
 
-	return nil
+	return "reset-" + hex.EncodeToString(b), nil
+}
+
+// 发送激活邮件
+func (e *Mailer) SendActivationEmail(toEmail string, code string, locale string) (err error) {
+	return e.SendTemplate(toEmail, locale, TemplateActivation, map[string]interface{}{
+		"Code": code,
+		"Link": Link(config.Email.ActivationPath, code),
+	})
+}
+
+// 发送忘记密码邮件
+func (e *Mailer) SendForgotPasswordEmail(toEmail string, code string, locale string) (err error) {
+	return e.SendTemplate(toEmail, locale, TemplateResetPassword, map[string]interface{}{
+		"Code": code,
+		"Link": PageLink(config.Email.ResetPasswordPath, code),
+	})
+}
+
+// 发送忘记交易密码邮件
+func (e *Mailer) SendForgotTradePasswordEmail(toEmail string, code string, locale string) (err error) {
+	return e.SendTemplate(toEmail, locale, TemplateResetPayPassword, map[string]interface{}{
+		"Code": code,
+		"Link": PageLink(config.Email.ResetPayPasswordPath, code),
+	})
 }
@@ -0,0 +1,220 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email
+
+import (
+	"bytes"
+	"github.com/axetroy/go-fs"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"golang.org/x/net/html"
+	"html/template"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	TemplateActivation       = "activation"         // 账号激活
+	TemplateResetPassword    = "reset_password"     // 重置登陆密码
+	TemplateResetPayPassword = "reset_pay_password" // 重置交易密码
+	TemplateNotice           = "notice"             // 消息通知
+)
+
+var (
+	// 所有的邮件模版, 每个语言的目录下都应该有这些模版
+	Templates = []string{
+		TemplateActivation,
+		TemplateResetPassword,
+		TemplateResetPayPassword,
+		TemplateNotice,
+	}
+	localeReg = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+	spaceReg  = regexp.MustCompile(`\s+`)
+	cache     = map[string]*template.Template{}
+	mutex     = sync.RWMutex{}
+)
+
+// 渲染之后的邮件
+type Content struct {
+	Locale  string // 实际使用的语言
+	Subject string
+	HTML    string
+	Text    string // 从 HTML 生成的纯文本, 给不支持 HTML 的邮件客户端
+}
+
+func isTemplate(name string) bool {
+	for _, t := range Templates {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 加载模版, 同一个语言的模版只解析一次
+func load(locale string, name string) (*template.Template, error) {
+	key := locale + "/" + name
+
+	mutex.RLock()
+	t, ok := cache[key]
+	mutex.RUnlock()
+
+	if ok {
+		return t, nil
+	}
+
+	dir := config.Email.TemplateDir
+
+	t, err := template.New("layout.html").Option("missingkey=error").ParseFiles(
+		path.Join(dir, "layout.html"),
+		path.Join(dir, locale, "common.html"),
+		path.Join(dir, locale, name+".html"),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mutex.Lock()
+	cache[key] = t
+	mutex.Unlock()
+
+	return t, nil
+}
+
+// 查找模版, 没有对应语言的模版时使用默认语言
+func lookup(locale string, name string) (string, *template.Template, error) {
+	if !isTemplate(name) {
+		return "", nil, exception.EmailTemplateNotExist
+	}
+
+	dir := config.Email.TemplateDir
+
+	if !localeReg.MatchString(locale) || !fs.PathExists(path.Join(dir, locale, name+".html")) {
+		locale = config.Message.DefaultLocale
+	}
+
+	t, err := load(locale, name)
+
+	return locale, t, err
+}
+
+// 用数据渲染邮件模版
+func Render(locale string, name string, data map[string]interface{}) (*Content, error) {
+	locale, t, err := lookup(locale, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]interface{}{
+		"Sender": config.SMTP.Sender.Name,
+	}
+
+	for k, v := range data {
+		vars[k] = v
+	}
+
+	subject := bytes.NewBuffer(nil)
+
+	if err = t.ExecuteTemplate(subject, "subject", vars); err != nil {
+		return nil, err
+	}
+
+	body := bytes.NewBuffer(nil)
+
+	if err = t.Execute(body, vars); err != nil {
+		return nil, err
+	}
+
+	return &Content{
+		Locale:  locale,
+		Subject: strings.TrimSpace(html.UnescapeString(subject.String())),
+		HTML:    body.String(),
+		Text:    toText(body.String()),
+	}, nil
+}
+
+// 生成邮件里直接请求 API 的链接, 例如 https://example.com/v1/auth/activation?code=xxx
+func Link(p string, code string) string {
+	return config.User.Domain + p + "?code=" + url.QueryEscape(code)
+}
+
+// 生成邮件里前端页面的链接, 用于重置密码等需要用户填写表单的操作, 例如 https://www.example.com/password/reset?code=xxx
+func PageLink(p string, code string) string {
+	return config.User.WebDomain + p + "?code=" + url.QueryEscape(code)
+}
+
+// 把 HTML 转成纯文本, 链接会以 "文字 (地址)" 的形式保留
+func toText(s string) string {
+	var (
+		buf  = bytes.NewBuffer(nil)
+		href string
+		skip int
+	)
+
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return tidy(buf.String())
+		case html.TextToken:
+			if skip == 0 {
+				buf.WriteString(spaceReg.ReplaceAllString(string(z.Text()), " "))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+
+			switch string(name) {
+			case "head", "style", "script":
+				skip++
+			case "a":
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					if string(key) == "href" {
+						href = string(val)
+					}
+				}
+			case "br", "div", "p", "li", "tr", "hr", "h1", "h2", "h3", "h4", "h5", "h6":
+				buf.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+
+			switch string(name) {
+			case "head", "style", "script":
+				skip--
+			case "a":
+				if href != "" {
+					buf.WriteString(" (" + href + ")")
+					href = ""
+				}
+			case "div", "li", "tr":
+				buf.WriteString("\n")
+			case "p", "h1", "h2", "h3", "h4", "h5", "h6":
+				buf.WriteString("\n\n")
+			}
+		}
+	}
+}
+
+// 去掉每行首尾的空白, 连续的空行只保留一行
+func tidy(s string) string {
+	lines := make([]string, 0)
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email_test
+
+import (
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/service/email"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// 测试时的工作目录是当前包的目录
+	config.Email.TemplateDir = "../../../templates/email"
+}
+
+func TestRender(t *testing.T) {
+	link := email.Link(config.Email.ActivationPath, "activation-123")
+
+	assert.Equal(t, config.User.Domain+config.Email.ActivationPath+"?code=activation-123", link)
+
+	c, err := email.Render("en-US", email.TemplateActivation, map[string]interface{}{
+		"Code": "activation-123",
+		"Link": link,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "en-US", c.Locale)
+	assert.Equal(t, "Activate your account", c.Subject)
+	assert.Contains(t, c.HTML, `href="`+link+`"`)
+	assert.Contains(t, c.Text, "Activate account ("+link+")")
+	assert.Contains(t, c.Text, "activation-123")
+	assert.NotContains(t, c.Text, "<")
+
+	// 交易密码的邮件不再提示激活账号
+	c, err = email.Render("zh-CN", email.TemplateResetPayPassword, map[string]interface{}{
+		"Code": "reset-123",
+		"Link": email.Link(config.Email.ResetPayPasswordPath, "reset-123"),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "重置交易密码", c.Subject)
+	assert.NotContains(t, c.Text, "激活")
+
+	// 没有对应语言的模版时使用默认语言
+	c, err = email.Render("fr-FR", email.TemplateResetPassword, map[string]interface{}{
+		"Code": "reset-123",
+		"Link": "",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, config.Message.DefaultLocale, c.Locale)
+
+	// 不合法的语言不会读取到模版目录之外
+	c, err = email.Render("../../etc", email.TemplateResetPassword, map[string]interface{}{
+		"Code": "reset-123",
+		"Link": "",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, config.Message.DefaultLocale, c.Locale)
+
+	// 缺少变量
+	_, err = email.Render("en-US", email.TemplateActivation, map[string]interface{}{})
+
+	assert.NotNil(t, err)
+
+	// 不存在的模版
+	_, err = email.Render("en-US", "not_exist", nil)
+
+	assert.Equal(t, exception.EmailTemplateNotExist, err)
+}
+
+func TestRenderNotice(t *testing.T) {
+	c, err := email.Render("zh-CN", email.TemplateNotice, map[string]interface{}{
+		"Title":          "转账 <到账>",
+		"Content":        "您收到了一笔转账",
+		"UnsubscribeURL": "http://127.0.0.1/v1/unsubscribe/token?event=transfer.in",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "转账 <到账>", c.Subject)
+	assert.Contains(t, c.HTML, "转账 &lt;到账&gt;")
+	assert.True(t, strings.HasPrefix(c.Text, "转账 <到账>\n\n您收到了一笔转账"))
+	assert.Contains(t, c.Text, "退订 (http://127.0.0.1/v1/unsubscribe/token?event=transfer.in)")
+}
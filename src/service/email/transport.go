@@ -0,0 +1,400 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/jordan-wright/email"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 最多保存在内存中的邮件数量, 超过时丢弃最早的邮件
+const maxMemoryEmails = 100
+
+// 邮件的发送方式, 接入新的发送方式时实现这个接口并调用 Use
+type Transport interface {
+	Send(msg *email.Email) error
+}
+
+// 能够查看已发送邮件的发送方式, 用于在开发环境中测试注册/重置密码等流程
+type Catcher interface {
+	Transport
+	Captured(limit int) ([]Captured, error)
+}
+
+// 被捕获的邮件
+type Captured struct {
+	Id        string
+	From      string
+	To        []string
+	Subject   string
+	Text      string
+	HTML      string
+	CreatedAt time.Time
+}
+
+var transport Transport
+
+func init() {
+	switch config.Email.Transport {
+	case config.EmailTransportFile:
+		transport = NewFileTransport(config.Email.Maildir)
+	case config.EmailTransportMemory:
+		transport = NewMemoryTransport()
+	default:
+		transport = NewSMTPTransport()
+	}
+}
+
+// 设置使用的发送方式
+func Use(t Transport) {
+	transport = t
+}
+
+// 当前使用的发送方式
+func CurrentTransport() Transport {
+	return transport
+}
+
+// 收件人的地址, 包括抄送和密送
+func recipients(msg *email.Email) ([]string, error) {
+	to := make([]string, 0, len(msg.To)+len(msg.Cc)+len(msg.Bcc))
+
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, v := range list {
+			addr, err := mail.ParseAddress(v)
+
+			if err != nil {
+				return nil, err
+			}
+
+			to = append(to, addr.Address)
+		}
+	}
+
+	if len(to) == 0 {
+		return nil, errors.New("must specify at least one recipient")
+	}
+
+	return to, nil
+}
+
+func newId() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%d.%s", time.Now().UnixNano(), hex.EncodeToString(b))
+}
+
+const (
+	smtpDialTimeout = time.Second * 10 // 连接 SMTP 服务器的超时时间
+	smtpTimeout     = time.Minute      // 默认的读写超时时间
+)
+
+// 通过 SMTP 服务器发送
+type SMTPTransport struct {
+	Host     string
+	Port     string
+	Auth     smtp.Auth
+	Security string        // tls 或者 starttls
+	TLS      *tls.Config   // 默认会校验服务器证书
+	Timeout  time.Duration // 每个阶段读写的超时时间, 防止服务器不响应时一直阻塞, 为 0 时使用默认值
+}
+
+func NewSMTPTransport() *SMTPTransport {
+	return &SMTPTransport{
+		Host:     config.SMTP.Host,
+		Port:     config.SMTP.Port,
+		Auth:     smtp.PlainAuth("", config.SMTP.Username, config.SMTP.Password, config.SMTP.Host),
+		Security: config.SMTP.Security,
+		TLS: &tls.Config{
+			ServerName:         config.SMTP.Host,
+			InsecureSkipVerify: config.SMTP.InsecureSkipVerify == "on",
+		},
+	}
+}
+
+func (t *SMTPTransport) Send(msg *email.Email) (err error) {
+	var (
+		to   []string
+		from *mail.Address
+		raw  []byte
+		conn net.Conn
+		c    *smtp.Client
+	)
+
+	if to, err = recipients(msg); err != nil {
+		return
+	}
+
+	sender := msg.Sender
+
+	if sender == "" {
+		sender = msg.From
+	}
+
+	if from, err = mail.ParseAddress(sender); err != nil {
+		return
+	}
+
+	if raw, err = msg.Bytes(); err != nil {
+		return
+	}
+
+	timeout := t.Timeout
+
+	if timeout <= 0 {
+		timeout = smtpTimeout
+	}
+
+	addr := net.JoinHostPort(t.Host, t.Port)
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	switch t.Security {
+	case config.SMTPSecurityTLS:
+		if conn, err = tls.DialWithDialer(dialer, "tcp", addr, t.TLS); err != nil {
+			return
+		}
+	case config.SMTPSecurityStartTLS:
+		if conn, err = dialer.Dial("tcp", addr); err != nil {
+			return
+		}
+	default:
+		return fmt.Errorf("unknown smtp security `%s`", t.Security)
+	}
+
+	// 握手, 认证和投递之前的命令共用一个期限, STARTTLS 升级之后的连接也会使用这个期限
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	if c, err = smtp.NewClient(conn, t.Host); err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	defer c.Close()
+
+	if t.Security == config.SMTPSecurityStartTLS {
+		// 服务器不支持 STARTTLS 时不能明文发送账号密码
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+
+		if err = c.StartTLS(t.TLS); err != nil {
+			return
+		}
+	}
+
+	if t.Auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err = c.Auth(t.Auth); err != nil {
+				return
+			}
+		}
+	}
+
+	if err = c.Mail(from.Address); err != nil {
+		return
+	}
+
+	for _, v := range to {
+		if err = c.Rcpt(v); err != nil {
+			return
+		}
+	}
+
+	// 邮件正文可能比较大, 发送之前重新计算期限
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return
+	}
+
+	w, err := c.Data()
+
+	if err != nil {
+		return
+	}
+
+	if _, err = w.Write(raw); err != nil {
+		return
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	return c.Quit()
+}
+
+// 写入到本地的 maildir 目录, 可以用邮件客户端直接打开
+type FileTransport struct {
+	Dir string
+}
+
+func NewFileTransport(dir string) *FileTransport {
+	return &FileTransport{
+		Dir: dir,
+	}
+}
+
+func (t *FileTransport) Send(msg *email.Email) (err error) {
+	var raw []byte
+
+	if _, err = recipients(msg); err != nil {
+		return
+	}
+
+	if raw, err = msg.Bytes(); err != nil {
+		return
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err = os.MkdirAll(path.Join(t.Dir, sub), os.ModePerm); err != nil {
+			return
+		}
+	}
+
+	// 先写入 tmp 目录再移动到 new 目录, 这样读取的时候不会读到写了一半的邮件
+	name := newId()
+	tmp := path.Join(t.Dir, "tmp", name)
+
+	if err = ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return
+	}
+
+	return os.Rename(tmp, path.Join(t.Dir, "new", name))
+}
+
+func (t *FileTransport) Captured(limit int) (list []Captured, err error) {
+	type file struct {
+		name string
+		info os.FileInfo
+	}
+
+	files := make([]file, 0)
+
+	for _, sub := range []string{"new", "cur"} {
+		infos, err := ioutil.ReadDir(path.Join(t.Dir, sub))
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, info := range infos {
+			if !info.IsDir() {
+				files = append(files, file{name: path.Join(t.Dir, sub, info.Name()), info: info})
+			}
+		}
+	}
+
+	// 最新的邮件在前面
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().After(files[j].info.ModTime())
+	})
+
+	list = make([]Captured, 0)
+
+	for _, f := range files {
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+
+		r, err := os.Open(f.name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := email.NewEmailFromReader(r)
+
+		_ = r.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, capture(f.info.Name(), msg, f.info.ModTime()))
+	}
+
+	return
+}
+
+// 保存在内存中, 只在当前进程中可以查看
+type MemoryTransport struct {
+	mutex  sync.RWMutex
+	emails []Captured
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{
+		emails: make([]Captured, 0),
+	}
+}
+
+func (t *MemoryTransport) Send(msg *email.Email) (err error) {
+	if _, err = recipients(msg); err != nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.emails = append(t.emails, capture(newId(), msg, time.Now()))
+
+	if len(t.emails) > maxMemoryEmails {
+		t.emails = t.emails[len(t.emails)-maxMemoryEmails:]
+	}
+
+	return
+}
+
+func (t *MemoryTransport) Captured(limit int) ([]Captured, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	list := make([]Captured, 0)
+
+	for i := len(t.emails) - 1; i >= 0; i-- {
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+		list = append(list, t.emails[i])
+	}
+
+	return list, nil
+}
+
+func capture(id string, msg *email.Email, createdAt time.Time) Captured {
+	// 从文件中读取的标题是经过编码的, 例如 =?UTF-8?q?...?=
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Subject)
+
+	if err != nil {
+		subject = msg.Subject
+	}
+
+	return Captured{
+		Id:        id,
+		From:      msg.From,
+		To:        append(append(append([]string{}, msg.To...), msg.Cc...), msg.Bcc...),
+		Subject:   subject,
+		Text:      string(msg.Text),
+		HTML:      string(msg.HTML),
+		CreatedAt: createdAt,
+	}
+}
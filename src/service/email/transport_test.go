@@ -0,0 +1,201 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package email_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/service/email"
+	jemail "github.com/jordan-wright/email"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newEmail(to string, subject string) *jemail.Email {
+	return &jemail.Email{
+		From:    "Tester <tester@example.com>",
+		To:      []string{to},
+		Subject: subject,
+		Text:    []byte("hello"),
+		HTML:    []byte("<p>hello</p>"),
+	}
+}
+
+func TestMemoryTransport(t *testing.T) {
+	transport := email.NewMemoryTransport()
+
+	assert.Nil(t, transport.Send(newEmail("a@example.com", "first")))
+	assert.Nil(t, transport.Send(newEmail("b@example.com", "second")))
+
+	// 没有收件人
+	assert.NotNil(t, transport.Send(&jemail.Email{From: "tester@example.com"}))
+
+	list, err := transport.Captured(0)
+
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "second", list[0].Subject)
+	assert.Equal(t, []string{"b@example.com"}, list[0].To)
+
+	list, err = transport.Captured(1)
+
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestFileTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maildir")
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	transport := email.NewFileTransport(dir)
+
+	assert.Nil(t, transport.Send(newEmail("a@example.com", "账号激活")))
+
+	files, err := ioutil.ReadDir(dir + "/new")
+
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	list, err := transport.Captured(10)
+
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "账号激活", list[0].Subject)
+	assert.Equal(t, "hello", strings.TrimSpace(list[0].Text))
+	assert.Equal(t, "<p>hello</p>", strings.TrimSpace(list[0].HTML))
+}
+
+func TestMailerUseTransport(t *testing.T) {
+	transport := email.NewMemoryTransport()
+
+	mailer := &email.Mailer{Transport: transport}
+
+	assert.Nil(t, mailer.SendActivationEmail("a@example.com", "activation-123", "en-US"))
+
+	list, _ := transport.Captured(1)
+
+	assert.Len(t, list, 1)
+	assert.Equal(t, "Activate your account", list[0].Subject)
+	assert.Contains(t, list[0].Text, "activation-123")
+}
+
+// 服务器不支持 STARTTLS 时不会明文发送
+func TestSMTPTransportRequireStartTLS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+
+		_, _ = conn.Write([]byte("220 localhost ESMTP\r\n"))
+
+		for {
+			line, err := r.ReadString('\n')
+
+			if err != nil {
+				return
+			}
+
+			switch strings.ToUpper(strings.Fields(line)[0]) {
+			case "EHLO":
+				_, _ = conn.Write([]byte("250-localhost\r\n250 AUTH PLAIN\r\n"))
+			case "QUIT":
+				_, _ = conn.Write([]byte("221 bye\r\n"))
+				return
+			default:
+				_, _ = conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	transport := &email.SMTPTransport{
+		Host:     host,
+		Port:     port,
+		Security: config.SMTPSecurityStartTLS,
+		TLS:      &tls.Config{ServerName: host},
+	}
+
+	err = transport.Send(newEmail("a@example.com", "hello"))
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "STARTTLS")
+
+	transport.Security = "unknown"
+
+	assert.NotNil(t, transport.Send(newEmail("a@example.com", "hello")))
+}
+
+// 服务器接受连接之后不响应时, 超时返回错误而不是一直阻塞
+func TestSMTPTransportTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		// 不发送欢迎信息, 只读取客户端的数据
+		_, _ = ioutil.ReadAll(conn)
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	transport := &email.SMTPTransport{
+		Host:     host,
+		Port:     port,
+		Security: config.SMTPSecurityStartTLS,
+		TLS:      &tls.Config{ServerName: host},
+		Timeout:  time.Millisecond * 200,
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- transport.Send(newEmail("a@example.com", "hello"))
+	}()
+
+	select {
+	case err = <-done:
+		assert.NotNil(t, err)
+		if e, ok := err.(net.Error); assert.True(t, ok) {
+			assert.True(t, e.Timeout())
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("send did not time out")
+	}
+}
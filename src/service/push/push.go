@@ -0,0 +1,167 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package push
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/src/config"
+	"github.com/axetroy/go-server/src/model"
+	"github.com/axetroy/go-server/src/schema"
+	"github.com/axetroy/go-server/src/service/redis"
+	"github.com/mitchellh/mapstructure"
+	"log"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventMessage      EventType = "message"      // 新的个人消息
+	EventNotification EventType = "notification" // 新的系统通知
+	EventTransfer     EventType = "transfer"     // 收到转账
+	EventLogout       EventType = "logout"       // 强制登出, 客户端应该丢弃当前的 Token
+	EventPing         EventType = "ping"         // 心跳
+)
+
+// 推送给客户端的事件
+type Event struct {
+	Type EventType   `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// 在 Redis 频道中传递的事件, 每个实例收到之后推送给自己的连接
+type envelope struct {
+	Uid   string          `json:"uid"` // 推送给哪个用户, 为空表示所有在线的用户
+	Event json.RawMessage `json:"event"`
+}
+
+// 一个推送连接
+type Subscriber struct {
+	uid string
+	C   chan []byte // 编码之后的事件
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[string]map[*Subscriber]bool{}
+	once        sync.Once
+)
+
+// 订阅 Redis 频道, 每个实例只订阅一次, 断线之后 go-redis 会自动重连
+func listen() {
+	ps := redis.Client.Subscribe(config.Push.Channel)
+
+	// 等待订阅成功, 否则刚建立连接时推送的事件可能会丢失
+	if _, err := ps.Receive(); err != nil {
+		log.Println("订阅推送频道失败", err)
+	}
+
+	go func() {
+		for msg := range ps.Channel() {
+			e := envelope{}
+
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				log.Println("无效的推送事件", err)
+				continue
+			}
+
+			dispatch(e.Uid, e.Event)
+		}
+	}()
+}
+
+// 把事件推送给本实例上的连接, 客户端接收太慢时丢弃, 不阻塞其他连接
+func dispatch(uid string, payload []byte) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	send := func(set map[*Subscriber]bool) {
+		for s := range set {
+			select {
+			case s.C <- payload:
+			default:
+			}
+		}
+	}
+
+	if uid != "" {
+		send(subscribers[uid])
+		return
+	}
+
+	for _, set := range subscribers {
+		send(set)
+	}
+}
+
+// 用户建立一个推送连接, 同一个用户可以有多个连接
+func Subscribe(uid string) *Subscriber {
+	once.Do(listen)
+
+	s := &Subscriber{
+		uid: uid,
+		C:   make(chan []byte, config.Push.Buffer),
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if subscribers[uid] == nil {
+		subscribers[uid] = map[*Subscriber]bool{}
+	}
+
+	subscribers[uid][s] = true
+
+	return s
+}
+
+// 断开推送连接
+func Unsubscribe(s *Subscriber) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if set, ok := subscribers[s.uid]; ok {
+		delete(set, s)
+
+		if len(set) == 0 {
+			delete(subscribers, s.uid)
+		}
+	}
+}
+
+// 推送一个事件给某个用户的所有连接, 包括其他实例上的连接
+// 推送是尽力而为的, 客户端重连之后应该重新拉取列表
+func Publish(uid string, t EventType, data interface{}) error {
+	event, err := json.Marshal(Event{Type: t, Data: data})
+
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(envelope{Uid: uid, Event: event})
+
+	if err != nil {
+		return err
+	}
+
+	return redis.Client.Publish(config.Push.Channel, string(payload)).Err()
+}
+
+// 推送一个事件给所有在线的用户
+func Broadcast(t EventType, data interface{}) error {
+	return Publish("", t, data)
+}
+
+// 推送一条新的个人消息
+func PublishMessage(m model.Message) error {
+	data := schema.Message{}
+
+	if err := mapstructure.Decode(m, &data.MessagePure); err != nil {
+		return err
+	}
+
+	data.CreatedAt = m.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = m.UpdatedAt.Format(time.RFC3339Nano)
+
+	return Publish(m.Uid, EventMessage, data)
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package push_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/src/service/push"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, s *push.Subscriber) *push.Event {
+	select {
+	case payload := <-s.C:
+		e := push.Event{}
+		assert.Nil(t, json.Unmarshal(payload, &e))
+		return &e
+	case <-time.After(time.Second * 3):
+		return nil
+	}
+}
+
+func TestPublish(t *testing.T) {
+	a := push.Subscribe("test-TestPublish-a")
+	b := push.Subscribe("test-TestPublish-b")
+
+	defer push.Unsubscribe(a)
+	defer push.Unsubscribe(b)
+
+	// 只推送给指定的用户
+	assert.Nil(t, push.Publish("test-TestPublish-a", push.EventLogout, nil))
+
+	e := receive(t, a)
+
+	if assert.NotNil(t, e) {
+		assert.Equal(t, push.EventLogout, e.Type)
+	}
+
+	select {
+	case <-b.C:
+		assert.Fail(t, "不应该收到其他用户的事件")
+	case <-time.After(time.Millisecond * 200):
+	}
+
+	// 广播给所有用户
+	assert.Nil(t, push.Broadcast(push.EventNotification, map[string]string{"title": "test"}))
+
+	for _, s := range []*push.Subscriber{a, b} {
+		e := receive(t, s)
+
+		if assert.NotNil(t, e) {
+			assert.Equal(t, push.EventNotification, e.Type)
+		}
+	}
+
+	// 断开之后不再收到
+	push.Unsubscribe(a)
+
+	assert.Nil(t, push.Publish("test-TestPublish-a", push.EventLogout, nil))
+
+	select {
+	case <-a.C:
+		assert.Fail(t, "断开之后不应该收到事件")
+	case <-time.After(time.Millisecond * 200):
+	}
+}
@@ -10,6 +10,8 @@ var (
 	Client               *redis.Client // 默认的redis存储
 	ActivationCodeClient *redis.Client // 存储激活码的
 	ResetCodeClient      *redis.Client // 存储重置密码的
+	IdempotencyClient    *redis.Client // 存储幂等键对应的响应
+	RateLimitClient      *redis.Client // 存储接口的访问频率
 	Config               = config.Redis
 )
 
@@ -19,7 +21,7 @@ func init() {
 		password = Config.Password
 	)
 
-	// 初始化3个DB连接
+	// 初始化5个DB连接
 	Client = redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
@@ -38,4 +40,16 @@ func init() {
 		DB:       2,
 	})
 
+	IdempotencyClient = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       3,
+	})
+
+	RateLimitClient = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       4,
+	})
+
 }
@@ -0,0 +1,36 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package sms
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 短信服务商, 接入新的服务商时实现这个接口并调用 Use
+type Provider interface {
+	Send(phone string, content string) error
+}
+
+// 只打印短信内容, 没有接入短信服务商时使用
+type logProvider struct{}
+
+func (p logProvider) Send(phone string, content string) error {
+	fmt.Printf("发送短信到 %s: %s\n", phone, content)
+	return nil
+}
+
+var provider Provider = logProvider{}
+
+// 设置使用的短信服务商
+func Use(p Provider) {
+	provider = p
+}
+
+// 发送短信
+func Send(phone string, content string) error {
+	if phone == "" {
+		return errors.New("phone can not be empty")
+	}
+
+	return provider.Send(phone, content)
+}
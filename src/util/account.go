@@ -0,0 +1,33 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util
+
+import (
+	"github.com/asaskevich/govalidator"
+	"regexp"
+)
+
+var (
+	phoneRegexp      = regexp.MustCompile(`^\+?\d{6,15}$`) // 手机号, 可以带国际区号
+	idRegexp         = regexp.MustCompile(`^\d{16,20}$`)   // 用户ID, 雪花算法生成的数字
+	inviteCodeRegexp = regexp.MustCompile(`^[0-9a-f]{8}$`) // 邀请码, 8 位十六进制
+)
+
+// 是否是手机号的格式
+func IsPhone(s string) bool {
+	return phoneRegexp.MatchString(s)
+}
+
+// 是否是用户ID的格式
+func IsId(s string) bool {
+	return idRegexp.MatchString(s)
+}
+
+// 是否是邀请码的格式
+func IsInviteCode(s string) bool {
+	return inviteCodeRegexp.MatchString(s)
+}
+
+// 用户名不能是邮箱, 手机号或者用户ID的格式, 否则可以抢注别人的邮箱或者手机号, 让按账号查找用户时匹配错人
+func IsValidUsername(s string) bool {
+	return !govalidator.IsEmail(s) && !IsPhone(s) && !IsId(s)
+}
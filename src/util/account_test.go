@@ -0,0 +1,35 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util_test
+
+import (
+	"github.com/axetroy/go-server/src/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsPhone(t *testing.T) {
+	assert.True(t, util.IsPhone("13800138000"))
+	assert.True(t, util.IsPhone("+8613800138000"))
+	assert.False(t, util.IsPhone("1380013800a"))
+	assert.False(t, util.IsPhone(util.GenerateId()))
+}
+
+func TestIsId(t *testing.T) {
+	assert.True(t, util.IsId(util.GenerateId()))
+	assert.False(t, util.IsId("13800138000"))
+	assert.False(t, util.IsId("axetroy"))
+}
+
+func TestIsInviteCode(t *testing.T) {
+	assert.True(t, util.IsInviteCode(util.GenerateInviteCode()))
+	assert.False(t, util.IsInviteCode("axetroy"))
+	assert.False(t, util.IsInviteCode("ABCDEF12"))
+}
+
+func TestIsValidUsername(t *testing.T) {
+	assert.True(t, util.IsValidUsername("axetroy"))
+	assert.True(t, util.IsValidUsername("用户"+util.GenerateId()))
+	assert.False(t, util.IsValidUsername("test@example.com"))
+	assert.False(t, util.IsValidUsername("13800138000"))
+	assert.False(t, util.IsValidUsername(util.GenerateId()))
+}
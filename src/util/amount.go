@@ -0,0 +1,36 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util
+
+import (
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/shopspring/decimal"
+)
+
+// 金额对外展示时统一保留的小数位数
+const AmountDisplayScale int32 = 8
+
+// 统一的货币金额格式化，保留 8 位小数
+func AmountToStr(d decimal.Decimal) string {
+	return d.StringFixed(AmountDisplayScale)
+}
+
+// 解析用户输入的金额, 金额必须大于 0, 且小数位数不能超过该币种的精度
+func ParseAmount(s string, scale int32) (amount decimal.Decimal, err error) {
+	if amount, err = decimal.NewFromString(s); err != nil {
+		err = exception.InvalidAmount
+		return
+	}
+
+	if amount.Sign() <= 0 {
+		err = exception.InvalidAmount
+		return
+	}
+
+	// 截断到指定精度后不相等，说明小数位数过多
+	if !amount.Truncate(scale).Equal(amount) {
+		err = exception.InvalidAmountPrecision
+		return
+	}
+
+	return
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util_test
+
+import (
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAmountToStr(t *testing.T) {
+	assert.Equal(t, "0.10000000", util.AmountToStr(decimal.RequireFromString("0.1")))
+	assert.Equal(t, "12.20000000", util.AmountToStr(decimal.RequireFromString("12.2")))
+	assert.Equal(t, "5.00000000", util.AmountToStr(decimal.New(5, 0)))
+	// 浮点数无法精确表示的值
+	assert.Equal(t, "0.30000000", util.AmountToStr(decimal.RequireFromString("0.1").Add(decimal.RequireFromString("0.2"))))
+}
+
+func TestParseAmount(t *testing.T) {
+	amount, err := util.ParseAmount("20", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "20", amount.String())
+
+	amount, err = util.ParseAmount("0.01", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.01", amount.String())
+
+	amount, err = util.ParseAmount("1.10", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1", amount.String())
+
+	// 不是数字
+	_, err = util.ParseAmount("abc", 2)
+	assert.Equal(t, exception.InvalidAmount, err)
+
+	// 0
+	_, err = util.ParseAmount("0", 2)
+	assert.Equal(t, exception.InvalidAmount, err)
+
+	// 负数
+	_, err = util.ParseAmount("-1", 2)
+	assert.Equal(t, exception.InvalidAmount, err)
+
+	// 小数位数过多
+	_, err = util.ParseAmount("0.001", 2)
+	assert.Equal(t, exception.InvalidAmountPrecision, err)
+
+	_, err = util.ParseAmount("0.00000001", 8)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,21 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util
+
+import "strings"
+
+// 隐藏名称中间的字符, 只保留首尾各一个字符, 例如 "axetroy" => "a*****y"
+// 两个字符的名称只保留第一个字符
+func MaskName(name string) string {
+	runes := []rune(name)
+
+	switch len(runes) {
+	case 0:
+		return ""
+	case 1:
+		return "*"
+	case 2:
+		return string(runes[0]) + "*"
+	default:
+		return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+	}
+}
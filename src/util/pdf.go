@@ -0,0 +1,140 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// A4 纸的尺寸, 单位 pt
+const (
+	PDFPageWidth  = 595.0
+	PDFPageHeight = 842.0
+)
+
+// 一个只支持文字和直线的 PDF 生成器
+// 使用阅读器内置的 STSong-Light 字体, 不需要嵌入字体文件就能显示中文
+// 字体中 ASCII 字符是半角宽度, 其余字符是全角宽度, 排版时可以用 PDFTextWidth 计算文字的宽度
+type PDF struct {
+	pages []*bytes.Buffer
+}
+
+func NewPDF() *PDF {
+	return &PDF{}
+}
+
+// 新增一页, 之后的内容都写在这一页上
+func (p *PDF) AddPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+}
+
+func (p *PDF) current() *bytes.Buffer {
+	if len(p.pages) == 0 {
+		p.AddPage()
+	}
+	return p.pages[len(p.pages)-1]
+}
+
+// 在 (x, y) 处写一行文字, 原点在页面左下角
+func (p *PDF) Text(x float64, y float64, size float64, text string) {
+	_, _ = fmt.Fprintf(p.current(), "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, pdfEncode(text))
+}
+
+// 画一条直线
+func (p *PDF) Line(x1 float64, y1 float64, x2 float64, y2 float64) {
+	_, _ = fmt.Fprintf(p.current(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// 文字在指定字号下的宽度
+func PDFTextWidth(text string, size float64) float64 {
+	width := 0.0
+
+	for _, r := range text {
+		if r < 0x80 {
+			width = width + 0.5
+		} else {
+			width = width + 1
+		}
+	}
+
+	return width * size
+}
+
+// 截断文字, 使它在指定字号下不超过 maxWidth
+func PDFTruncate(text string, size float64, maxWidth float64) string {
+	if PDFTextWidth(text, size) <= maxWidth {
+		return text
+	}
+
+	runes := []rune(text)
+
+	for len(runes) > 0 && PDFTextWidth(string(runes)+"...", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "..."
+}
+
+// 按照 UniGB-UCS2-H 编码, 也就是 UCS-2 大端序, 超出范围的字符用 ? 代替
+func pdfEncode(text string) string {
+	buf := bytes.Buffer{}
+
+	for _, r := range text {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		_, _ = fmt.Fprintf(&buf, "%04X", r)
+	}
+
+	return buf.String()
+}
+
+// 生成 PDF 文件的内容
+func (p *PDF) Bytes() []byte {
+	if len(p.pages) == 0 {
+		p.AddPage()
+	}
+
+	var (
+		buf     = bytes.Buffer{}
+		offsets = make([]int, 0)
+	)
+
+	// 对象编号: 1 目录, 2 页面树, 3-5 字体, 之后每页占用页面和内容两个对象
+	writeObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		_, _ = fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	kids := bytes.Buffer{}
+
+	for i := range p.pages {
+		_, _ = fmt.Fprintf(&kids, "%d 0 R ", 6+i*2)
+	}
+
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(p.pages)))
+	writeObject("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	writeObject("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	writeObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+
+	for i, page := range p.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", PDFPageWidth, PDFPageHeight, 7+i*2))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := buf.Len()
+
+	_, _ = fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+
+	for _, offset := range offsets {
+		_, _ = fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+
+	_, _ = fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPDF(t *testing.T) {
+	pdf := util.NewPDF()
+
+	pdf.Text(40, 800, 12, "对账单 2019-01")
+	pdf.Line(40, 790, 555, 790)
+	pdf.AddPage()
+	pdf.Text(40, 800, 12, "hello")
+
+	b := pdf.Bytes()
+
+	assert.True(t, bytes.HasPrefix(b, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(b, []byte("%%EOF\n")))
+	assert.Contains(t, string(b), "/Count 2")
+	// 中文按照 UCS-2 编码
+	assert.Contains(t, string(b), "<5BF98D26535500200032003000310039002D00300031>")
+
+	// xref 中的偏移量要指向对应的对象
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(b)
+	assert.NotNil(t, startxref)
+
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	assert.True(t, bytes.HasPrefix(b[xref:], []byte("xref\n")))
+
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(b, -1)
+	assert.Len(t, offsets, 9)
+
+	for i, offset := range offsets {
+		n, _ := strconv.Atoi(string(offset[1]))
+		assert.True(t, bytes.HasPrefix(b[n:], []byte(fmt.Sprintf("%d 0 obj", i+1))))
+	}
+}
+
+func TestPDFTruncate(t *testing.T) {
+	assert.Equal(t, 20.0, util.PDFTextWidth("abcd", 10))
+	assert.Equal(t, 20.0, util.PDFTextWidth("中文", 10))
+	assert.Equal(t, "abcd", util.PDFTruncate("abcd", 10, 20))
+	assert.Equal(t, "ab...", util.PDFTruncate("abcdefgh", 10, 25))
+	assert.Equal(t, "中...", util.PDFTruncate("中文中文", 10, 25))
+}
@@ -0,0 +1,356 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util
+
+import (
+	"github.com/axetroy/go-server/src/exception"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RecurrenceDaily   = "DAILY"
+	RecurrenceWeekly  = "WEEKLY"
+	RecurrenceMonthly = "MONTHLY"
+
+	// 查找下一次执行时间时最多向后查找的天数
+	recurrenceMaxDays = 366 * 5
+)
+
+var (
+	cronMacros = map[string]string{
+		"@hourly":  "0 * * * *",
+		"@daily":   "0 0 * * *",
+		"@weekly":  "0 0 * * 0",
+		"@monthly": "0 0 1 * *",
+	}
+
+	rruleWeekdays = map[string]int{
+		"SU": 0,
+		"MO": 1,
+		"TU": 2,
+		"WE": 3,
+		"TH": 4,
+		"FR": 5,
+		"SA": 6,
+	}
+)
+
+// 重复规则, 支持 cron 和类似 RRULE 的两种写法
+// cron 为 5 个字段 "分 时 日 月 周", 例如 "0 9 * * 1" 表示每周一 9 点, 也支持 @hourly, @daily, @weekly, @monthly
+// RRULE 例如 "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;BYHOUR=9;BYMINUTE=30", FREQ 支持 DAILY, WEEKLY, MONTHLY,
+// 没有指定的 BY* 字段取开始时间对应的值, INTERVAL 从开始时间算起
+type Recurrence struct {
+	minutes  uint64 // 每一位表示一个允许的值
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	dayAny   bool // cron 的日和周同时指定时, 满足其中一个即可
+	weekAny  bool
+	freq     string
+	interval int
+	start    time.Time
+}
+
+// 解析重复规则, start 为规则的开始时间, 用于 RRULE 的默认值和间隔
+func ParseRecurrence(expr string, start time.Time) (r *Recurrence, err error) {
+	expr = strings.TrimSpace(expr)
+
+	if m, ok := cronMacros[expr]; ok {
+		expr = m
+	}
+
+	r = &Recurrence{interval: 1, start: start}
+
+	if strings.Contains(strings.ToUpper(expr), "FREQ=") {
+		err = r.parseRRule(expr)
+	} else {
+		err = r.parseCron(expr)
+	}
+
+	if err != nil {
+		return nil, exception.InvalidRecurrence
+	}
+
+	return
+}
+
+func (r *Recurrence) parseCron(expr string) error {
+	fields := strings.Fields(expr)
+
+	if len(fields) != 5 {
+		return exception.InvalidRecurrence
+	}
+
+	var err error
+
+	if r.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return err
+	}
+
+	if r.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return err
+	}
+
+	if r.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return err
+	}
+
+	if r.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return err
+	}
+
+	// 周日可以写成 0 或者 7
+	if r.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return err
+	}
+
+	if r.weekdays&(1<<7) != 0 {
+		r.weekdays = r.weekdays&^(1<<7) | 1
+	}
+
+	r.dayAny = fields[2] == "*"
+	r.weekAny = fields[4] == "*"
+
+	return nil
+}
+
+// 解析 cron 的单个字段, 支持 *, a, a-b, */n, a-b/n 以及用逗号分隔的列表
+func parseCronField(field string, min int, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		lo, hi := min, max
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, exception.InvalidRecurrence
+			}
+			part = part[:i]
+		}
+
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, exception.InvalidRecurrence
+			}
+
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, exception.InvalidRecurrence
+			}
+		default:
+			if lo, err = strconv.Atoi(part); err != nil {
+				return 0, exception.InvalidRecurrence
+			}
+
+			hi = lo
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, exception.InvalidRecurrence
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (r *Recurrence) parseRRule(expr string) error {
+	var (
+		byDay      []string
+		byMonthDay []string
+		byHour     []string
+		byMinute   []string
+	)
+
+	for _, part := range strings.Split(strings.ToUpper(expr), ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+
+		if len(kv) != 2 {
+			return exception.InvalidRecurrence
+		}
+
+		values := strings.Split(kv[1], ",")
+
+		switch kv[0] {
+		case "FREQ":
+			r.freq = kv[1]
+		case "INTERVAL":
+			n, err := strconv.Atoi(kv[1])
+
+			if err != nil || n <= 0 {
+				return exception.InvalidRecurrence
+			}
+
+			r.interval = n
+		case "BYDAY":
+			byDay = values
+		case "BYMONTHDAY":
+			byMonthDay = values
+		case "BYHOUR":
+			byHour = values
+		case "BYMINUTE":
+			byMinute = values
+		default:
+			return exception.InvalidRecurrence
+		}
+	}
+
+	if r.freq != RecurrenceDaily && r.freq != RecurrenceWeekly && r.freq != RecurrenceMonthly {
+		return exception.InvalidRecurrence
+	}
+
+	var err error
+
+	if r.minutes, err = parseValues(byMinute, r.start.Minute(), 0, 59); err != nil {
+		return err
+	}
+
+	if r.hours, err = parseValues(byHour, r.start.Hour(), 0, 23); err != nil {
+		return err
+	}
+
+	r.months = 1<<13 - 2 // 1 到 12 月
+	r.days = 1<<32 - 2   // 1 到 31 日
+	r.weekdays = 1<<7 - 1
+	r.dayAny = true
+	r.weekAny = true
+
+	if byMonthDay != nil || r.freq == RecurrenceMonthly && byDay == nil {
+		if r.days, err = parseValues(byMonthDay, r.start.Day(), 1, 31); err != nil {
+			return err
+		}
+		r.dayAny = false
+	}
+
+	if byDay != nil || r.freq == RecurrenceWeekly && byMonthDay == nil {
+		r.weekdays = 0
+
+		if byDay == nil {
+			r.weekdays = 1 << uint(r.start.Weekday())
+		}
+
+		for _, d := range byDay {
+			w, ok := rruleWeekdays[d]
+
+			if !ok {
+				return exception.InvalidRecurrence
+			}
+
+			r.weekdays |= 1 << uint(w)
+		}
+		r.weekAny = false
+	}
+
+	return nil
+}
+
+func parseValues(values []string, def int, min int, max int) (bits uint64, err error) {
+	if values == nil {
+		return 1 << uint(def), nil
+	}
+
+	for _, s := range values {
+		v, er := strconv.Atoi(s)
+
+		if er != nil || v < min || v > max {
+			return 0, exception.InvalidRecurrence
+		}
+
+		bits |= 1 << uint(v)
+	}
+
+	return bits, nil
+}
+
+// 某一天是否满足规则的日期部分
+func (r *Recurrence) matchDay(t time.Time) bool {
+	if r.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dayOk := r.days&(1<<uint(t.Day())) != 0
+	weekOk := r.weekdays&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case r.freq == "" && !r.dayAny && !r.weekAny:
+		// cron 的日和周都指定时满足其一即可, RRULE 需要同时满足
+		if !dayOk && !weekOk {
+			return false
+		}
+	case !dayOk || !weekOk:
+		return false
+	}
+
+	if r.interval <= 1 {
+		return true
+	}
+
+	start := time.Date(r.start.Year(), r.start.Month(), r.start.Day(), 0, 0, 0, 0, t.Location())
+	days := int(t.Sub(start).Hours()/24 + 0.5)
+
+	switch r.freq {
+	case RecurrenceDaily:
+		return days%r.interval == 0
+	case RecurrenceWeekly:
+		// 以周一作为一周的开始
+		offset := (int(r.start.Weekday()) + 6) % 7
+		return ((days+offset)/7)%r.interval == 0
+	case RecurrenceMonthly:
+		months := (t.Year()-r.start.Year())*12 + int(t.Month()) - int(r.start.Month())
+		return months%r.interval == 0
+	}
+
+	return true
+}
+
+// 获取 after 之后的下一次执行时间, 精确到分钟, 找不到时返回 false
+func (r *Recurrence) Next(after time.Time) (time.Time, bool) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	// 不早于开始时间
+	if t.Before(r.start) {
+		t = r.start.Truncate(time.Minute)
+		if t.Before(r.start) {
+			t = t.Add(time.Minute)
+		}
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	for i := 0; i < recurrenceMaxDays; i++ {
+		if r.matchDay(day) {
+			for h := 0; h < 24; h++ {
+				if r.hours&(1<<uint(h)) == 0 {
+					continue
+				}
+
+				for m := 0; m < 60; m++ {
+					if r.minutes&(1<<uint(m)) == 0 {
+						continue
+					}
+
+					candidate := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location())
+
+					if !candidate.Before(t) {
+						return candidate, true
+					}
+				}
+			}
+		}
+
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return time.Time{}, false
+}
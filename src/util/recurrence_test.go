@@ -0,0 +1,79 @@
+// Copyright 2019 Axetroy. All rights reserved. MIT license.
+package util_test
+
+import (
+	"github.com/axetroy/go-server/src/exception"
+	"github.com/axetroy/go-server/src/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	// 2019-06-05 是周三
+	start := time.Date(2019, 6, 5, 10, 30, 0, 0, time.Local)
+
+	next := func(expr string, after time.Time) string {
+		r, err := util.ParseRecurrence(expr, start)
+
+		assert.Nil(t, err, expr)
+
+		if err != nil {
+			return ""
+		}
+
+		n, ok := r.Next(after)
+
+		assert.True(t, ok, expr)
+
+		return n.Format("2006-01-02 15:04 Mon")
+	}
+
+	// cron
+	assert.Equal(t, "2019-06-10 09:00 Mon", next("0 9 * * 1", start))
+	assert.Equal(t, "2019-06-05 10:45 Wed", next("*/15 * * * *", start))
+	assert.Equal(t, "2019-06-09 00:00 Sun", next("0 0 * * 7", start))
+	assert.Equal(t, "2019-07-01 00:00 Mon", next("@monthly", start))
+	assert.Equal(t, "2019-06-06 00:00 Thu", next("@daily", start))
+	// 日和周同时指定时满足其一即可
+	assert.Equal(t, "2019-06-07 08:00 Fri", next("0 8 15 * 5", start))
+	assert.Equal(t, "2020-02-29 00:00 Sat", next("0 0 29 2 *", start))
+
+	// RRULE, 没有指定的字段取开始时间
+	assert.Equal(t, "2019-06-12 10:30 Wed", next("FREQ=WEEKLY", start))
+	assert.Equal(t, "2019-06-06 10:30 Thu", next("FREQ=DAILY", start))
+	assert.Equal(t, "2019-06-07 10:30 Fri", next("FREQ=DAILY;INTERVAL=2", start))
+	assert.Equal(t, "2019-07-05 10:30 Fri", next("FREQ=MONTHLY", start))
+	assert.Equal(t, "2019-06-07 09:00 Fri", next("FREQ=WEEKLY;BYDAY=MO,FR;BYHOUR=9;BYMINUTE=0", start))
+	// 每两周, 下一周跳过
+	assert.Equal(t, "2019-06-17 09:00 Mon", next("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO;BYHOUR=9;BYMINUTE=0", start))
+	assert.Equal(t, "2019-08-01 09:30 Thu", next("FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=1;BYHOUR=9", start))
+
+	// 不早于开始时间
+	assert.Equal(t, "2019-06-05 11:00 Wed", next("0 * * * *", start.AddDate(0, 0, -1)))
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"FREQ=YEARLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3",
+	} {
+		_, err := util.ParseRecurrence(expr, start)
+		assert.Equal(t, exception.InvalidRecurrence, err, expr)
+	}
+
+	// 永远不会执行的规则
+	r, err := util.ParseRecurrence("0 0 31 2 *", start)
+
+	assert.Nil(t, err)
+
+	_, ok := r.Next(start)
+
+	assert.False(t, ok)
+}